@@ -15,6 +15,7 @@ import (
 	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/keyvalue"
 	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/namespace"
 	"github.com/influxdata/kapacitor/pipeline"
 	alertservice "github.com/influxdata/kapacitor/services/alert"
 	"github.com/influxdata/kapacitor/services/hipchat"
@@ -86,9 +87,16 @@ func newAlertNode(et *ExecutingTask, n *pipeline.AlertNode, d NodeDiagnostic) (a
 	}
 	an.node.runF = an.runAlert
 
-	an.topic = n.Topic
+	// Topics of namespaced tasks are within the namespace of the task.
+	ns, name := namespace.Split(et.Task.ID)
+	if n.Topic != "" {
+		an.topic, err = namespace.Qualify(ns, n.Topic)
+		if err != nil {
+			return nil, err
+		}
+	}
 	// Create anonymous topic name
-	an.anonTopic = fmt.Sprintf("%s:%s:%s", et.tm.ID(), et.Task.ID, an.Name())
+	an.anonTopic = namespace.Join(ns, fmt.Sprintf("%s:%s:%s", et.tm.ID(), name, an.Name()))
 
 	// Create buffer pool for the templates
 	an.bufPool = sync.Pool{
@@ -628,6 +636,10 @@ func (n *AlertNode) handleEvent(event alert.Event) {
 	}
 	n.diag.AlertTriggered(event.State.Level, event.State.ID, event.State.Message, event.Data.Result.Series[0])
 
+	if r := n.et.tm.AlertEventRecorder; r != nil {
+		r.RecordAlertEvent(n.et.Task.ID, event)
+	}
+
 	// If we have anon handlers, emit event to the anonTopic
 	if n.hasAnonTopic() {
 		event.Topic = n.anonTopic
@@ -649,6 +661,17 @@ func (n *AlertNode) handleEvent(event alert.Event) {
 	}
 }
 
+// freezeFlightRecorder saves the task's flight recorder as a recording,
+// if the task is configured to do so.
+func (n *AlertNode) freezeFlightRecorder() {
+	if !n.et.Task.FlightRecorder.FreezeOnCritical || n.et.flightRecorder == nil {
+		return
+	}
+	if f := n.et.tm.FlightRecorderService; f != nil {
+		f.FreezeFlightRecorder(n.et.Task.ID, n.et.flightRecorder.snapshot())
+	}
+}
+
 func (n *AlertNode) determineLevel(p edge.FieldsTagsTimeGetter, currentLevel alert.Level) alert.Level {
 	if higherLevel, found := n.findFirstMatchLevel(alert.Critical, currentLevel-1, p); found {
 		return higherLevel
@@ -818,6 +841,9 @@ func (a *alertState) BufferedBatch(b edge.BufferedBatchMessage) (edge.Message, e
 	}
 
 	a.n.handleEvent(event)
+	if l == alert.Critical && a.changed {
+		a.n.freezeFlightRecorder()
+	}
 
 	// Update tags or fields with event state
 	if a.n.a.LevelTag != "" ||
@@ -881,6 +907,9 @@ func (a *alertState) Point(p edge.PointMessage) (edge.Message, error) {
 		}
 
 		a.n.handleEvent(event)
+		if l == alert.Critical && a.changed {
+			a.n.freezeFlightRecorder()
+		}
 
 		// Prepare an augmented point to return
 		p = p.ShallowCopy()
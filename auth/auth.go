@@ -11,6 +11,7 @@ type Interface interface {
 	Authenticate(username, password string) (User, error)
 	User(username string) (User, error)
 	SubscriptionUser(token string) (User, error)
+	TokenUser(token string) (User, error)
 	GrantSubscriptionAccess(token, db, rp string) error
 	ListSubscriptionTokens() ([]string, error)
 	RevokeSubscriptionAccess(token string) error
@@ -68,6 +69,8 @@ type User struct {
 	hash  []byte
 	// Map of resource -> Bitmask of Privileges
 	privileges map[string]Privilege
+	// Resource patterns the user is restricted to, empty means unrestricted.
+	scopes []string
 }
 
 // Create a user with the given privileges.
@@ -126,10 +129,48 @@ func (u User) Privileges() map[string][]Privilege {
 	return privileges
 }
 
+// Return a copy of the user restricted to the resources matching the scopes.
+// A scope is a resource pattern, see https://golang.org/pkg/path/#Match,
+// a resource is within a scope if the resource or any of its parents matches the scope.
+// The privileges of the user are unchanged, so the user must also have the privilege for the resource.
+func (u User) WithScopes(scopes []string) User {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = path.Clean(scope)
+	}
+	u.scopes = s
+	return u
+}
+
+// Return a copy of the scopes the user is restricted to.
+func (u User) Scopes() []string {
+	scopes := make([]string, len(u.scopes))
+	copy(scopes, u.scopes)
+	return scopes
+}
+
+// inScope reports whether the clean resource is within the scopes of the user.
+func (u User) inScope(resource string) bool {
+	if len(u.scopes) == 0 {
+		return true
+	}
+	for {
+		for _, scope := range u.scopes {
+			if matched, _ := path.Match(scope, resource); matched {
+				return true
+			}
+		}
+		if resource == "/" {
+			return false
+		}
+		resource = path.Dir(resource)
+	}
+}
+
 // Determine wether the user is authorized to take the action.
 // Returns nil if the action is authorized, otherwise returns an error describing the needed permissions.
 func (u User) AuthorizeAction(action Action) error {
-	if action.Privilege == NoPrivileges || u.admin {
+	if action.Privilege == NoPrivileges || (u.admin && len(u.scopes) == 0) {
 		return nil
 	}
 	// Find a matching resource of the form /path/to/resource
@@ -138,6 +179,15 @@ func (u User) AuthorizeAction(action Action) error {
 	if !path.IsAbs(action.Resource) {
 		return fmt.Errorf("invalid action resource: %q, must be an absolute path", action.Resource)
 	}
+	if !u.inScope(path.Clean(action.Resource)) {
+		return authError{
+			username: u.name,
+			action:   action,
+		}
+	}
+	if u.admin {
+		return nil
+	}
 	if len(u.privileges) > 0 {
 		// Clean path to prevent path traversal like /a/b/../d when user has access to /a/b
 		resource := path.Clean(action.Resource)
@@ -477,3 +477,65 @@ func Test_User_AuthorizeAction(t *testing.T) {
 		}
 	}
 }
+
+func Test_User_WithScopes(t *testing.T) {
+	privs := map[string][]auth.Privilege{
+		"/api/tasks": []auth.Privilege{auth.ReadPrivilege, auth.WritePrivilege},
+	}
+	user := auth.NewUser("username", nil, false, privs).WithScopes([]string{"/api/tasks/team-a-*"})
+	admin := auth.NewUser("admin", nil, true, nil).WithScopes([]string{"/api/tasks/team-a-*"})
+
+	testCases := []struct {
+		user       auth.User
+		action     auth.Action
+		authorized bool
+	}{
+		{
+			user:       user,
+			action:     auth.Action{Resource: "/api/tasks/team-a-cpu", Privilege: auth.WritePrivilege},
+			authorized: true,
+		},
+		{
+			user:       user,
+			action:     auth.Action{Resource: "/api/tasks/team-a-cpu/vars", Privilege: auth.ReadPrivilege},
+			authorized: true,
+		},
+		{
+			// In scope but without privilege
+			user:       user,
+			action:     auth.Action{Resource: "/api/tasks/team-a-cpu", Privilege: auth.DeletePrivilege},
+			authorized: false,
+		},
+		{
+			user:       user,
+			action:     auth.Action{Resource: "/api/tasks/team-b-cpu", Privilege: auth.ReadPrivilege},
+			authorized: false,
+		},
+		{
+			user:       user,
+			action:     auth.Action{Resource: "/api/tasks", Privilege: auth.ReadPrivilege},
+			authorized: false,
+		},
+		{
+			user:       user,
+			action:     auth.Action{Resource: "/api/tasks/team-a-cpu/../team-b-cpu", Privilege: auth.ReadPrivilege},
+			authorized: false,
+		},
+		{
+			user:       admin,
+			action:     auth.Action{Resource: "/api/tasks/team-a-cpu", Privilege: auth.DeletePrivilege},
+			authorized: true,
+		},
+		{
+			user:       admin,
+			action:     auth.Action{Resource: "/api/users", Privilege: auth.ReadPrivilege},
+			authorized: false,
+		},
+	}
+	for _, tc := range testCases {
+		err := tc.user.AuthorizeAction(tc.action)
+		if got, exp := err == nil, tc.authorized; got != exp {
+			t.Errorf("unexpected authorization of %s for %v got %t exp %t: %v", tc.user.Name(), tc.action, got, exp, err)
+		}
+	}
+}
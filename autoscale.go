@@ -1,13 +1,24 @@
 package kapacitor
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	text "text/template"
 	"time"
 
 	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/models"
 	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/services/httpd"
 	k8s "github.com/influxdata/kapacitor/services/k8s/client"
 	swarm "github.com/influxdata/kapacitor/services/swarm/client"
 	"github.com/influxdata/kapacitor/tick/ast"
@@ -38,12 +49,32 @@ type resourceState struct {
 	current      int
 }
 
+// recommendation is a desired replica count of a resource at a point in time.
+type recommendation struct {
+	time     time.Time
+	replicas int
+}
+
 type event struct {
 	ID  resourceID
 	Old int
 	New int
 }
 
+// autoscaleDecision is a change of the replica count of a resource decided by the node.
+type autoscaleDecision struct {
+	Time     time.Time `json:"time"`
+	Resource string    `json:"resource"`
+	Old      int       `json:"old"`
+	New      int       `json:"new"`
+	// Reason describes how the new replica count was determined.
+	Reason string `json:"reason"`
+	// Cooldown indicates the change was dropped because of a cooldown timer.
+	Cooldown bool `json:"cooldown"`
+	// DryRun indicates the change was not applied because of dry run mode.
+	DryRun bool `json:"dry-run"`
+}
+
 type AutoscaleNode struct {
 	node
 
@@ -53,6 +84,8 @@ type AutoscaleNode struct {
 	replicasScopePool stateful.ScopePool
 
 	resourceStates map[string]resourceState
+	// recommendations holds the recent desired replica counts by resource, oldest first.
+	recommendations map[string][]recommendation
 
 	increaseCount      *expvar.Int
 	decreaseCount      *expvar.Int
@@ -65,6 +98,20 @@ type AutoscaleNode struct {
 	decreaseCooldown time.Duration
 
 	currentField string
+
+	// The stabilization windows and max steps are zero if the autoscaler does not support them.
+	increaseStabilizationWindow time.Duration
+	decreaseStabilizationWindow time.Duration
+	maxIncreaseStep             int
+	maxDecreaseStep             int
+
+	dryRun bool
+
+	// history holds the most recent decisions, oldest first.
+	historyMu   sync.RWMutex
+	history     []autoscaleDecision
+	historySize int
+	routes      []httpd.Route
 }
 
 // Create a new AutoscaleNode which can trigger autoscale events.
@@ -79,6 +126,8 @@ func newAutoscaleNode(
 	decreaseCooldown time.Duration,
 	currentField string,
 	replicas *ast.LambdaNode,
+	dryRun bool,
+	historySize int,
 ) (*AutoscaleNode, error) {
 	if min < 1 {
 		return nil, fmt.Errorf("minimum count must be >= 1, got %d", min)
@@ -92,6 +141,7 @@ func newAutoscaleNode(
 	kn := &AutoscaleNode{
 		node:              node{Node: n, et: et, diag: d},
 		resourceStates:    make(map[string]resourceState),
+		recommendations:   make(map[string][]recommendation),
 		min:               min,
 		max:               max,
 		increaseCooldown:  increaseCooldown,
@@ -100,8 +150,11 @@ func newAutoscaleNode(
 		a:                 a,
 		replicasExpr:      replicasExpr,
 		replicasScopePool: replicasScopePool,
+		dryRun:            dryRun,
+		historySize:       historySize,
 	}
 	kn.node.runF = kn.runAutoscale
+	kn.node.stopF = kn.stopAutoscale
 	return kn, nil
 }
 
@@ -114,6 +167,17 @@ func (n *AutoscaleNode) runAutoscale([]byte) error {
 	n.statMap.Set(statsAutoscaleDecreaseEventsCount, n.decreaseCount)
 	n.statMap.Set(statsAutoscaleCooldownDropsCount, n.cooldownDropsCount)
 
+	if n.et.tm.HTTPDService != nil {
+		n.routes = []httpd.Route{{
+			Method:      "GET",
+			Pattern:     path.Join("/tasks/", n.et.Task.ID, n.Name(), "history"),
+			HandlerFunc: n.handleHistory,
+		}}
+		if err := n.et.tm.HTTPDService.AddRoutes(n.routes); err != nil {
+			return err
+		}
+	}
+
 	consumer := edge.NewGroupedConsumer(
 		n.ins[0],
 		n,
@@ -122,6 +186,44 @@ func (n *AutoscaleNode) runAutoscale([]byte) error {
 	return consumer.Consume()
 }
 
+func (n *AutoscaleNode) stopAutoscale() {
+	if n.routes != nil {
+		n.et.tm.HTTPDService.DelRoutes(n.routes)
+	}
+}
+
+// handleHistory responds with the most recent decisions of the node.
+func (n *AutoscaleNode) handleHistory(w http.ResponseWriter, r *http.Request) {
+	n.historyMu.RLock()
+	defer n.historyMu.RUnlock()
+	decisions := n.history
+	if decisions == nil {
+		decisions = []autoscaleDecision{}
+	}
+	b, err := json.Marshal(struct {
+		Decisions []autoscaleDecision `json:"decisions"`
+	}{Decisions: decisions})
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(b)
+}
+
+// recordDecision adds the decision to the history, dropping the oldest decision if the history is full.
+func (n *AutoscaleNode) recordDecision(d autoscaleDecision) {
+	if n.historySize <= 0 {
+		return
+	}
+	n.historyMu.Lock()
+	defer n.historyMu.Unlock()
+	if len(n.history) >= n.historySize {
+		copy(n.history, n.history[1:])
+		n.history = n.history[:len(n.history)-1]
+	}
+	n.history = append(n.history, d)
+}
+
 func (n *AutoscaleNode) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.Receiver, error) {
 	return edge.NewReceiverFromForwardReceiverWithStats(
 		n.outs,
@@ -206,14 +308,20 @@ func (n *AutoscaleNode) handlePoint(streamName string, dims models.Dimensions, p
 		Old: state.current,
 		New: newReplicas,
 	}
+	reason := fmt.Sprintf("replicas expression evaluated to %d", newReplicas)
 	// Check bounds
 	if n.max > 0 && e.New > n.max {
 		e.New = n.max
+		reason += fmt.Sprintf(", limited to max %d", n.max)
 	}
 	if e.New < n.min {
 		e.New = n.min
+		reason += fmt.Sprintf(", limited to min %d", n.min)
 	}
 
+	t := p.Time()
+	e.New, reason = n.stabilize(id.ID(), t, e.Old, e.New, reason)
+
 	// Validate something changed
 	if e.New == e.Old {
 		// Nothing to do
@@ -224,38 +332,51 @@ func (n *AutoscaleNode) handlePoint(streamName string, dims models.Dimensions, p
 	change := e.New - e.Old
 	state.current = e.New
 
+	d := autoscaleDecision{
+		Time:     t,
+		Resource: id.ID(),
+		Old:      e.Old,
+		New:      e.New,
+		Reason:   reason,
+		DryRun:   n.dryRun,
+	}
+
 	// Check last change cooldown times
-	t := p.Time()
 	var counter *expvar.Int
 	switch {
 	case change > 0:
 		if t.Before(state.lastIncrease.Add(n.increaseCooldown)) {
-			// Still hot, nothing to do
-			n.cooldownDropsCount.Add(1)
-			return nil, nil
+			// Still hot, the change is dropped
+			d.Cooldown = true
 		}
 		state.lastIncrease = t
 		counter = n.increaseCount
 	case change < 0:
 		if t.Before(state.lastDecrease.Add(n.decreaseCooldown)) {
-			// Still hot, nothing to do
-			n.cooldownDropsCount.Add(1)
-			return nil, nil
+			// Still hot, the change is dropped
+			d.Cooldown = true
 		}
 		state.lastDecrease = t
 		counter = n.decreaseCount
 	}
 
-	// We have a valid event to apply
-	if err := n.applyEvent(e); err != nil {
-		return nil, errors.Wrap(err, "failed to apply scaling event")
-	}
+	if d.Cooldown {
+		n.cooldownDropsCount.Add(1)
+	} else {
+		// We have a valid event to apply, in dry run mode it is only recorded
+		if !n.dryRun {
+			if err := n.applyEvent(e); err != nil {
+				return nil, errors.Wrap(err, "failed to apply scaling event")
+			}
+		}
 
-	// Only save the updated state if we were successful
-	n.resourceStates[id.ID()] = state
+		// Only save the updated state if we were successful
+		n.resourceStates[id.ID()] = state
 
-	// Count event
-	counter.Add(1)
+		// Count event
+		counter.Add(1)
+	}
+	n.recordDecision(d)
 
 	// Create new tags for the point.
 	// Leave room for the namespace,kind, and resource tags.
@@ -272,14 +393,67 @@ func (n *AutoscaleNode) handlePoint(streamName string, dims models.Dimensions, p
 		streamName, "", "",
 		dims,
 		models.Fields{
-			"old": int64(e.Old),
-			"new": int64(e.New),
+			"old":      int64(d.Old),
+			"new":      int64(d.New),
+			"reason":   d.Reason,
+			"cooldown": d.Cooldown,
+			"dry_run":  d.DryRun,
 		},
 		newTags,
 		t,
 	), nil
 }
 
+// stabilize returns the replica count to set, given the desired replica count.
+// The change is limited by the desired replica counts within the stabilization windows and by the max steps.
+func (n *AutoscaleNode) stabilize(id string, t time.Time, current, desired int, reason string) (int, string) {
+	if n.increaseStabilizationWindow > 0 || n.decreaseStabilizationWindow > 0 {
+		window := n.increaseStabilizationWindow
+		if n.decreaseStabilizationWindow > window {
+			window = n.decreaseStabilizationWindow
+		}
+		// Drop the recommendations outside of both windows
+		recs := n.recommendations[id]
+		i := 0
+		for i < len(recs) && !recs[i].time.After(t.Add(-window)) {
+			i++
+		}
+		recs = append(recs[i:], recommendation{time: t, replicas: desired})
+		n.recommendations[id] = recs
+
+		// Increase to the lowest and decrease to the highest recommendation within the windows
+		up, down := desired, desired
+		for _, r := range recs {
+			if n.increaseStabilizationWindow > 0 && r.time.After(t.Add(-n.increaseStabilizationWindow)) && r.replicas < up {
+				up = r.replicas
+			}
+			if n.decreaseStabilizationWindow > 0 && r.time.After(t.Add(-n.decreaseStabilizationWindow)) && r.replicas > down {
+				down = r.replicas
+			}
+		}
+		stabilized := current
+		if stabilized < up {
+			stabilized = up
+		}
+		if stabilized > down {
+			stabilized = down
+		}
+		if stabilized != desired {
+			desired = stabilized
+			reason += fmt.Sprintf(", stabilized to %d", stabilized)
+		}
+	}
+	if n.maxIncreaseStep > 0 && desired-current > n.maxIncreaseStep {
+		desired = current + n.maxIncreaseStep
+		reason += fmt.Sprintf(", limited to increase step %d", n.maxIncreaseStep)
+	}
+	if n.maxDecreaseStep > 0 && current-desired > n.maxDecreaseStep {
+		desired = current - n.maxDecreaseStep
+		reason += fmt.Sprintf(", limited to decrease step %d", n.maxDecreaseStep)
+	}
+	return desired, reason
+}
+
 func (n *AutoscaleNode) applyEvent(e event) error {
 	n.diag.SettingReplicas(e.New, e.Old, e.ID.ID())
 	err := n.a.SetReplicas(e.ID, e.New)
@@ -345,7 +519,7 @@ func newK8sAutoscaleNode(et *ExecutingTask, n *pipeline.K8sAutoscaleNode, d Node
 		kind:            n.Kind,
 		namespace:       n.Namespace,
 	}
-	return newAutoscaleNode(
+	an, err := newAutoscaleNode(
 		et,
 		d,
 		n,
@@ -356,7 +530,17 @@ func newK8sAutoscaleNode(et *ExecutingTask, n *pipeline.K8sAutoscaleNode, d Node
 		n.DecreaseCooldown,
 		n.CurrentField,
 		n.Replicas,
+		n.DryRunFlag,
+		int(n.HistorySize),
 	)
+	if err != nil {
+		return nil, err
+	}
+	an.increaseStabilizationWindow = n.IncreaseStabilizationWindow
+	an.decreaseStabilizationWindow = n.DecreaseStabilizationWindow
+	an.maxIncreaseStep = int(n.MaxIncreaseStep)
+	an.maxDecreaseStep = int(n.MaxDecreaseStep)
+	return an, nil
 }
 
 type k8sResourceID struct {
@@ -481,6 +665,8 @@ func newSwarmAutoscaleNode(et *ExecutingTask, n *pipeline.SwarmAutoscaleNode, d
 		n.DecreaseCooldown,
 		n.CurrentField,
 		n.Replicas,
+		n.DryRunFlag,
+		int(n.HistorySize),
 	)
 }
 
@@ -536,3 +722,221 @@ func (a *swarmAutoscaler) SetResourceIDOnTags(id resourceID, tags models.Tags) {
 		tags[a.outputServiceNameTag] = id.ID()
 	}
 }
+
+/////////////////////////////////////
+// HTTP implementation of Autoscaler
+
+type httpAutoscaler struct {
+	client *http.Client
+
+	resourceName          string
+	resourceNameTag       string
+	outputResourceNameTag string
+
+	getURL       *text.Template
+	getMethod    string
+	replicasPath []string
+
+	setURL    *text.Template
+	setMethod string
+	setBody   *text.Template
+
+	headers map[string]string
+}
+
+func newHTTPAutoscaleNode(et *ExecutingTask, n *pipeline.HTTPAutoscaleNode, d NodeDiagnostic) (*AutoscaleNode, error) {
+	outputResourceNameTag := n.OutputResourceNameTag
+	if outputResourceNameTag == "" {
+		outputResourceNameTag = n.ResourceNameTag
+	}
+	setURL := n.SetURL
+	if setURL == "" {
+		setURL = n.GetURL
+	}
+	a := &httpAutoscaler{
+		client:                &http.Client{Timeout: n.Timeout},
+		resourceName:          n.ResourceName,
+		resourceNameTag:       n.ResourceNameTag,
+		outputResourceNameTag: outputResourceNameTag,
+		getMethod:             n.GetMethod,
+		replicasPath:          strings.Split(strings.Trim(n.ReplicasPath, "."), "."),
+		setMethod:             n.SetMethod,
+		headers:               n.Headers,
+	}
+	var err error
+	if a.getURL, err = text.New("getURL").Parse(n.GetURL); err != nil {
+		return nil, errors.Wrap(err, "invalid getURL template")
+	}
+	if a.setURL, err = text.New("setURL").Parse(setURL); err != nil {
+		return nil, errors.Wrap(err, "invalid setURL template")
+	}
+	if a.setBody, err = text.New("setBody").Parse(n.SetBody); err != nil {
+		return nil, errors.Wrap(err, "invalid setBody template")
+	}
+	return newAutoscaleNode(
+		et,
+		d,
+		n,
+		a,
+		int(n.Min),
+		int(n.Max),
+		n.IncreaseCooldown,
+		n.DecreaseCooldown,
+		n.CurrentField,
+		n.Replicas,
+		n.DryRunFlag,
+		int(n.HistorySize),
+	)
+}
+
+// httpResourceID identifies a resource by its name,
+// the tags are kept to render the templates of the requests.
+type httpResourceID struct {
+	Name string
+	Tags models.Tags
+}
+
+func (id httpResourceID) ID() string {
+	return id.Name
+}
+
+func (id httpResourceID) String() string {
+	return id.Name
+}
+
+// httpAutoscaleInfo is the data available to the templates of the requests.
+type httpAutoscaleInfo struct {
+	// Name of the resource
+	Name string
+	// Tags of the point
+	Tags map[string]string
+	// Replicas is the new replica count, it is zero when getting the replica count.
+	Replicas int
+}
+
+func (a *httpAutoscaler) ResourceIDFromTags(tags models.Tags) (resourceID, error) {
+	// Get the name of the resource
+	var name string
+	switch {
+	case a.resourceName != "":
+		name = a.resourceName
+	case a.resourceNameTag != "":
+		t, ok := tags[a.resourceNameTag]
+		if ok {
+			name = t
+		}
+	default:
+		return nil, errors.New("expected one of ResourceName or ResourceNameTag to be set")
+	}
+	if name == "" {
+		return nil, errors.New("could not determine the name of the resource")
+	}
+	return httpResourceID{
+		Name: name,
+		Tags: tags.Copy(),
+	}, nil
+}
+
+func (a *httpAutoscaler) Replicas(id resourceID) (int, error) {
+	hid := id.(httpResourceID)
+	info := httpAutoscaleInfo{Name: hid.Name, Tags: hid.Tags}
+	u, err := a.render(a.getURL, info)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to render getURL")
+	}
+	body, err := a.do(a.getMethod, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return 0, errors.Wrap(err, "failed to decode response")
+	}
+	for _, k := range a.replicasPath {
+		switch o := v.(type) {
+		case map[string]interface{}:
+			v = o[k]
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(o) {
+				return 0, fmt.Errorf("invalid index %q of array in replicas path", k)
+			}
+			v = o[i]
+		default:
+			v = nil
+		}
+		if v == nil {
+			return 0, fmt.Errorf("replicas path %q not found in response", strings.Join(a.replicasPath, "."))
+		}
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("replicas must be a number, got %T", v)
+	}
+	if i, err := n.Int64(); err == nil {
+		return int(i), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, err
+	}
+	return int(f), nil
+}
+
+func (a *httpAutoscaler) SetReplicas(id resourceID, replicas int) error {
+	hid := id.(httpResourceID)
+	info := httpAutoscaleInfo{Name: hid.Name, Tags: hid.Tags, Replicas: replicas}
+	u, err := a.render(a.setURL, info)
+	if err != nil {
+		return errors.Wrap(err, "failed to render setURL")
+	}
+	body, err := a.render(a.setBody, info)
+	if err != nil {
+		return errors.Wrap(err, "failed to render setBody")
+	}
+	_, err = a.do(a.setMethod, u, strings.NewReader(body))
+	return err
+}
+
+// do sends the request and returns the body of a successful response.
+func (a *httpAutoscaler) do(method, u string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range a.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s failed with status %d: %s", method, u, resp.StatusCode, bytes.TrimSpace(b))
+	}
+	return b, nil
+}
+
+func (a *httpAutoscaler) SetResourceIDOnTags(id resourceID, tags models.Tags) {
+	if a.outputResourceNameTag != "" {
+		tags[a.outputResourceNameTag] = id.ID()
+	}
+}
+
+func (a *httpAutoscaler) render(tmpl *text.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
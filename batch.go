@@ -61,12 +61,20 @@ func (n *BatchNode) Wait() error {
 	return nil
 }
 
+// batchSource is a child of the BatchNode querying batches on a schedule.
+type batchSource interface {
+	Node
+	DBRPs() ([]DBRP, error)
+	Start()
+	Abort()
+}
+
 // Return list of databases and retention policies
 // the batcher will query.
 func (n *BatchNode) DBRPs() ([]DBRP, error) {
 	var dbrps []DBRP
 	for _, b := range n.children {
-		d, err := b.(*QueryNode).DBRPs()
+		d, err := b.(batchSource).DBRPs()
 		if err != nil {
 			return nil, err
 		}
@@ -81,13 +89,13 @@ func (n *BatchNode) Count() int {
 
 func (n *BatchNode) Start() {
 	for _, b := range n.children {
-		b.(*QueryNode).Start()
+		b.(batchSource).Start()
 	}
 }
 
 func (n *BatchNode) Abort() {
 	for _, b := range n.children {
-		b.(*QueryNode).Abort()
+		b.(batchSource).Abort()
 	}
 }
 
@@ -100,7 +108,10 @@ type BatchQueries struct {
 func (n *BatchNode) Queries(start, stop time.Time) ([]BatchQueries, error) {
 	queries := make([]BatchQueries, len(n.children))
 	for i, b := range n.children {
-		qn := b.(*QueryNode)
+		qn, ok := b.(*QueryNode)
+		if !ok {
+			return nil, fmt.Errorf("cannot query node %s, only InfluxDB queries can be recorded", b.Name())
+		}
 		qs, err := qn.Queries(start, stop)
 		if err != nil {
 			return nil, err
@@ -14,6 +14,7 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/influxdata/influxdb/influxql"
@@ -36,11 +37,13 @@ const (
 	logsPath          = basePath + "/logs"
 	debugVarsPath     = basePath + "/debug/vars"
 	tasksPath         = basePath + "/tasks"
+	tasksTestPath     = basePath + "/tasks/test"
 	templatesPath     = basePath + "/templates"
 	recordingsPath    = basePath + "/recordings"
 	recordStreamPath  = basePath + "/recordings/stream"
 	recordBatchPath   = basePath + "/recordings/batch"
 	recordQueryPath   = basePath + "/recordings/query"
+	recordFlightPath  = basePath + "/recordings/flight-recorder"
 	replaysPath       = basePath + "/replays"
 	replayBatchPath   = basePath + "/replays/batch"
 	replayQueryPath   = basePath + "/replays/query"
@@ -53,6 +56,13 @@ const (
 	storagePath       = basePath + "/storage"
 	storesPath        = storagePath + "/stores"
 	backupPath        = storagePath + "/backup"
+	usersPath         = basePath + "/users"
+	rolesPath         = basePath + "/roles"
+	apiTokensPath     = basePath + "/tokens"
+	auditPath         = basePath + "/audit"
+	namespacesPath    = basePath + "/namespaces"
+	scrapersPath      = basePath + "/scrapers"
+	targetsPath       = "targets"
 )
 
 // HTTP configuration for connecting to Kapacitor
@@ -90,6 +100,7 @@ const (
 	_ AuthenticationMethod = iota
 	UserAuthentication
 	BearerAuthentication
+	TokenAuthentication
 )
 
 // Set of credentials depending on the authentication method
@@ -101,7 +112,7 @@ type Credentials struct {
 	Username string
 	Password string
 
-	// BearerAuthentication fields
+	// BearerAuthentication and TokenAuthentication fields
 
 	Token string
 }
@@ -115,7 +126,7 @@ func (c Credentials) Validate() error {
 		if c.Password == "" {
 			return errors.New("missing password")
 		}
-	case BearerAuthentication:
+	case BearerAuthentication, TokenAuthentication:
 		if c.Token == "" {
 			return errors.New("missing token")
 		}
@@ -317,6 +328,7 @@ const (
 	Failed Status = iota
 	Running
 	Finished
+	Truncated
 )
 
 func (s Status) MarshalText() ([]byte, error) {
@@ -327,6 +339,8 @@ func (s Status) MarshalText() ([]byte, error) {
 		return []byte("running"), nil
 	case Finished:
 		return []byte("finished"), nil
+	case Truncated:
+		return []byte("truncated"), nil
 	default:
 		return nil, fmt.Errorf("unknown Status %d", s)
 	}
@@ -340,6 +354,8 @@ func (s *Status) UnmarshalText(text []byte) error {
 		*s = Running
 	case "finished":
 		*s = Finished
+	case "truncated":
+		*s = Truncated
 	default:
 		return fmt.Errorf("unknown Status %s", t)
 	}
@@ -359,6 +375,8 @@ type Clock int
 const (
 	Fast Clock = iota
 	Real
+	// Scaled replays data at a multiple of real time and can be paused, stepped and moved forward.
+	Scaled
 )
 
 func (c Clock) MarshalText() ([]byte, error) {
@@ -367,6 +385,8 @@ func (c Clock) MarshalText() ([]byte, error) {
 		return []byte("fast"), nil
 	case Real:
 		return []byte("real"), nil
+	case Scaled:
+		return []byte("scaled"), nil
 	default:
 		return nil, fmt.Errorf("unknown Clock %d", c)
 	}
@@ -378,6 +398,8 @@ func (c *Clock) UnmarshalText(text []byte) error {
 		*c = Fast
 	case "real":
 		*c = Real
+	case "scaled":
+		*c = Scaled
 	default:
 		return fmt.Errorf("unknown Clock %s", s)
 	}
@@ -571,6 +593,145 @@ type Task struct {
 	Created        time.Time      `json:"created"`
 	Modified       time.Time      `json:"modified"`
 	LastEnabled    time.Time      `json:"last-enabled,omitempty"`
+	FlightRecorder FlightRecorder `json:"flight-recorder"`
+	Limits         TaskLimits     `json:"limits"`
+	EdgePolicies   EdgePolicies   `json:"edge-policies"`
+}
+
+// FlightRecorder configures a rolling recording of the most recent input data of a stream task.
+type FlightRecorder struct {
+	// How much of the most recent data to keep, zero disables the flight recorder.
+	Window Duration `json:"window" yaml:"window"`
+	// Whether to freeze the flight recorder into a recording when an alert of the task goes critical.
+	FreezeOnCritical bool `json:"freeze-on-critical" yaml:"freeze-on-critical"`
+}
+
+// TaskLimits bounds the resources of a task, so that it cannot starve the other tasks.
+// A zero maximum disables a limit.
+// The current usage of an executing task is part of its task stats.
+type TaskLimits struct {
+	// Maximum number of points buffered across the window and join nodes of the task.
+	BufferedPoints Limit `json:"buffered-points" yaml:"buffered-points"`
+	// Maximum number of groups of any node of the task.
+	Groups Limit `json:"groups" yaml:"groups"`
+	// Maximum number of messages queued on any edge of the task.
+	EdgeQueue Limit `json:"edge-queue" yaml:"edge-queue"`
+	// Maximum share of a single CPU, in percent, the task spends processing data.
+	// While the task exceeds its share its input is throttled according to the policy.
+	CPU Limit `json:"cpu" yaml:"cpu"`
+}
+
+// Limit is a maximum and the policy applied once it is reached.
+type Limit struct {
+	Max    int64       `json:"max" yaml:"max"`
+	Policy LimitPolicy `json:"policy" yaml:"policy"`
+}
+
+// LimitPolicy determines what happens when a task reaches one of its limits.
+type LimitPolicy int
+
+const (
+	// LimitDrop drops the data that would exceed the limit.
+	LimitDrop LimitPolicy = iota
+	// LimitPause drops all input of the task until it is restarted.
+	LimitPause
+	// LimitError stops the task with an error.
+	LimitError
+)
+
+func (p LimitPolicy) MarshalText() ([]byte, error) {
+	switch p {
+	case LimitDrop:
+		return []byte("drop"), nil
+	case LimitPause:
+		return []byte("pause"), nil
+	case LimitError:
+		return []byte("error"), nil
+	default:
+		return nil, fmt.Errorf("unknown LimitPolicy %d", p)
+	}
+}
+
+func (p *LimitPolicy) UnmarshalText(text []byte) error {
+	switch s := string(text); s {
+	case "drop":
+		*p = LimitDrop
+	case "pause":
+		*p = LimitPause
+	case "error":
+		*p = LimitError
+	default:
+		return fmt.Errorf("unknown LimitPolicy %s", s)
+	}
+	return nil
+}
+
+func (p LimitPolicy) String() string {
+	s, err := p.MarshalText()
+	if err != nil {
+		return err.Error()
+	}
+	return string(s)
+}
+
+// EdgePolicies determines what the edges of a task do with new data while their buffer is full.
+type EdgePolicies struct {
+	// Default is the policy of all edges of the task, including its input.
+	Default EdgePolicy `json:"default" yaml:"default"`
+	// Nodes overrides the policy of the edges into specific nodes, keyed by node name.
+	Nodes map[string]EdgePolicy `json:"nodes,omitempty" yaml:"nodes"`
+}
+
+type EdgePolicy int
+
+const (
+	// EdgeBlock waits until the buffer has room, slowing down the parent node.
+	EdgeBlock EdgePolicy = iota
+	// EdgeDropOldest drops the oldest buffered data to make room for new data.
+	EdgeDropOldest
+	// EdgeDropNewest drops the new data.
+	EdgeDropNewest
+	// EdgeSpillToDisk writes new data to disk until the buffer has room again.
+	EdgeSpillToDisk
+)
+
+func (p EdgePolicy) MarshalText() ([]byte, error) {
+	switch p {
+	case EdgeBlock:
+		return []byte("block"), nil
+	case EdgeDropOldest:
+		return []byte("drop-oldest"), nil
+	case EdgeDropNewest:
+		return []byte("drop-newest"), nil
+	case EdgeSpillToDisk:
+		return []byte("spill-to-disk"), nil
+	default:
+		return nil, fmt.Errorf("unknown EdgePolicy %d", p)
+	}
+}
+
+func (p *EdgePolicy) UnmarshalText(text []byte) error {
+	switch s := string(text); s {
+	case "block":
+		*p = EdgeBlock
+	case "drop-oldest":
+		*p = EdgeDropOldest
+	case "drop-newest":
+		*p = EdgeDropNewest
+	case "spill-to-disk":
+		*p = EdgeSpillToDisk
+	default:
+		return fmt.Errorf("unknown EdgePolicy %s", s)
+	}
+	return nil
+}
+
+func (p EdgePolicy) String() string {
+	s, err := p.MarshalText()
+	if err != nil {
+		return err.Error()
+	}
+	return string(s)
 }
 
 // A Template plus its read-only attributes.
@@ -606,10 +767,15 @@ type Replay struct {
 	Recording     string    `json:"recording"`
 	RecordingTime bool      `json:"recording-time"`
 	Clock         Clock     `json:"clock"`
+	Scale         float64   `json:"scale,omitempty"`
 	Date          time.Time `json:"date"`
 	Error         string    `json:"error"`
 	Status        Status    `json:"status"`
 	Progress      float64   `json:"progress"`
+	// Paused and Position are only reported for running replays using the Scaled clock.
+	Paused bool `json:"paused,omitempty"`
+	// Position is the amount of data time replayed since the start of the replay.
+	Position Duration `json:"position,omitempty"`
 }
 
 type JSONOperation struct {
@@ -637,6 +803,8 @@ func (c *Client) prepRequest(req *http.Request) error {
 			req.SetBasicAuth(c.credentials.Username, c.credentials.Password)
 		case BearerAuthentication:
 			req.Header.Set("Authorization", "Bearer "+c.credentials.Token)
+		case TokenAuthentication:
+			req.Header.Set("Authorization", "Token "+c.credentials.Token)
 		default:
 			return errors.New("unknown authentication method set")
 		}
@@ -768,6 +936,10 @@ func (c *Client) TemplateLink(id string) Link {
 	return Link{Relation: Self, Href: path.Join(templatesPath, id)}
 }
 
+func (c *Client) NamespaceLink(name string) Link {
+	return Link{Relation: Self, Href: path.Join(namespacesPath, name)}
+}
+
 func (c *Client) ConfigSectionLink(section string) Link {
 	return Link{Relation: Self, Href: path.Join(configPath, section)}
 }
@@ -805,14 +977,21 @@ func (c *Client) StorageLink(name string) Link {
 	return Link{Relation: Self, Href: path.Join(storesPath, name)}
 }
 
+func (c *Client) ScraperTargetsLink(name string) Link {
+	return Link{Relation: Self, Href: path.Join(scrapersPath, name, targetsPath)}
+}
+
 type CreateTaskOptions struct {
-	ID         string     `json:"id,omitempty" yaml:"id"`
-	TemplateID string     `json:"template-id,omitempty" yaml:"template-id"`
-	Type       TaskType   `json:"type,omitempty"`
-	DBRPs      []DBRP     `json:"dbrps,omitempty" yaml:"dbrps"`
-	TICKscript string     `json:"script,omitempty"`
-	Status     TaskStatus `json:"status,omitempty"`
-	Vars       Vars       `json:"vars,omitempty" yaml:"vars"`
+	ID             string          `json:"id,omitempty" yaml:"id"`
+	TemplateID     string          `json:"template-id,omitempty" yaml:"template-id"`
+	Type           TaskType        `json:"type,omitempty"`
+	DBRPs          []DBRP          `json:"dbrps,omitempty" yaml:"dbrps"`
+	TICKscript     string          `json:"script,omitempty"`
+	Status         TaskStatus      `json:"status,omitempty"`
+	Vars           Vars            `json:"vars,omitempty" yaml:"vars"`
+	FlightRecorder *FlightRecorder `json:"flight-recorder,omitempty" yaml:"flight-recorder"`
+	Limits         *TaskLimits     `json:"limits,omitempty" yaml:"limits"`
+	EdgePolicies   *EdgePolicies   `json:"edge-policies,omitempty" yaml:"edge-policies"`
 }
 
 // Create a new task.
@@ -827,6 +1006,7 @@ func (c *Client) CreateTask(opt CreateTaskOptions) (Task, error) {
 
 	u := *c.url
 	u.Path = tasksPath
+	u.RawQuery = namespaceQuery(opt.ID)
 
 	req, err := http.NewRequest("POST", u.String(), &buf)
 	if err != nil {
@@ -840,13 +1020,16 @@ func (c *Client) CreateTask(opt CreateTaskOptions) (Task, error) {
 }
 
 type UpdateTaskOptions struct {
-	ID         string     `json:"id,omitempty" yaml:"id"`
-	TemplateID string     `json:"template-id,omitempty" yaml:"template-id"`
-	Type       TaskType   `json:"type,omitempty"`
-	DBRPs      []DBRP     `json:"dbrps,omitempty" yaml:"dbrps"`
-	TICKscript string     `json:"script,omitempty"`
-	Status     TaskStatus `json:"status,omitempty"`
-	Vars       Vars       `json:"vars,omitempty" yaml:"vars"`
+	ID             string          `json:"id,omitempty" yaml:"id"`
+	TemplateID     string          `json:"template-id,omitempty" yaml:"template-id"`
+	Type           TaskType        `json:"type,omitempty"`
+	DBRPs          []DBRP          `json:"dbrps,omitempty" yaml:"dbrps"`
+	TICKscript     string          `json:"script,omitempty"`
+	Status         TaskStatus      `json:"status,omitempty"`
+	Vars           Vars            `json:"vars,omitempty" yaml:"vars"`
+	FlightRecorder *FlightRecorder `json:"flight-recorder,omitempty" yaml:"flight-recorder"`
+	Limits         *TaskLimits     `json:"limits,omitempty" yaml:"limits"`
+	EdgePolicies   *EdgePolicies   `json:"edge-policies,omitempty" yaml:"edge-policies"`
 }
 
 // Update an existing task.
@@ -880,6 +1063,97 @@ func (c *Client) UpdateTask(link Link, opt UpdateTaskOptions) (Task, error) {
 	return t, nil
 }
 
+// TestTaskOptions describes a task to run in isolation against a fixture of
+// input data and the outputs the task is expected to produce.
+type TestTaskOptions struct {
+	DBRPs      []DBRP `json:"dbrps,omitempty" yaml:"dbrps"`
+	TICKscript string `json:"script,omitempty" yaml:"script"`
+	Vars       Vars   `json:"vars,omitempty" yaml:"vars"`
+	// Data is the input fixture in line protocol, every point must have a timestamp.
+	Data string `json:"data" yaml:"data"`
+	// Precision of the timestamps in Data, defaults to "n".
+	Precision string               `json:"precision,omitempty" yaml:"precision"`
+	Expected  TestTaskExpectations `json:"expected" yaml:"expected"`
+}
+
+// TestTaskExpectations are the outputs a tested task is expected to produce.
+// A nil field is not checked, an empty non-nil field expects no output.
+type TestTaskExpectations struct {
+	// Alerts emitted by any alert node, in the order they were emitted.
+	Alerts []TestAlert `json:"alerts" yaml:"alerts"`
+	// Points written by any influxDBOut node, ordered by time, database,
+	// retention policy, measurement and tags.
+	Writes []TestPoint `json:"writes" yaml:"writes"`
+	// Final contents of httpOut nodes keyed by endpoint name.
+	HTTPOut map[string]*influxql.Result `json:"httpOut,omitempty" yaml:"httpOut"`
+}
+
+// TestAlert is an alert emitted by a tested task.
+// When used as an expectation an empty Message or zero Time is not compared.
+type TestAlert struct {
+	ID      string    `json:"id"`
+	Message string    `json:"message,omitempty"`
+	Level   string    `json:"level"`
+	Time    time.Time `json:"time"`
+}
+
+// TestPoint is a point written by a tested task.
+type TestPoint struct {
+	Database        string                 `json:"database"`
+	RetentionPolicy string                 `json:"retentionPolicy" yaml:"retentionPolicy"`
+	Name            string                 `json:"measurement" yaml:"measurement"`
+	Tags            map[string]string      `json:"tags,omitempty"`
+	Fields          map[string]interface{} `json:"fields"`
+	Time            time.Time              `json:"time"`
+}
+
+// TestFailure describes a difference between an expected and an actual output.
+type TestFailure struct {
+	// Output is one of "alerts", "writes" or "httpOut/<endpoint>".
+	Output string `json:"output"`
+	// Index of the mismatched entry, -1 if the mismatch is not about a single entry.
+	Index    int         `json:"index"`
+	Message  string      `json:"message"`
+	Expected interface{} `json:"expected,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+}
+
+// TestTaskResult is the result of a task test run.
+type TestTaskResult struct {
+	Passed   bool                        `json:"passed"`
+	Failures []TestFailure               `json:"failures"`
+	Alerts   []TestAlert                 `json:"alerts"`
+	Writes   []TestPoint                 `json:"writes"`
+	HTTPOut  map[string]*influxql.Result `json:"httpOut"`
+	// Error is set if the task failed to run.
+	Error string `json:"error"`
+}
+
+// Run a task against a fixture of data and compare its outputs with the expectations.
+// The task is not saved, its alerts and writes are captured instead of being sent.
+// Tasks with httpPost, kafkaOut, mqttOut or autoscale nodes cannot be tested.
+func (c *Client) TestTask(opt TestTaskOptions) (TestTaskResult, error) {
+	r := TestTaskResult{}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return r, err
+	}
+
+	u := *c.url
+	u.Path = tasksTestPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &r, http.StatusOK)
+	return r, err
+}
+
 type TaskOptions struct {
 	DotView      string
 	ScriptFormat string
@@ -954,10 +1228,12 @@ func (c *Client) DeleteTask(link Link) error {
 
 type ListTasksOptions struct {
 	TaskOptions
-	Pattern string
-	Fields  []string
-	Offset  int
-	Limit   int
+	// Namespace restricts the list to the tasks within the namespace.
+	Namespace string
+	Pattern   string
+	Fields    []string
+	Offset    int
+	Limit     int
 }
 
 func (o *ListTasksOptions) Default() {
@@ -969,6 +1245,9 @@ func (o *ListTasksOptions) Default() {
 
 func (o *ListTasksOptions) Values() *url.Values {
 	v := o.TaskOptions.Values()
+	if o.Namespace != "" {
+		v.Set("namespace", o.Namespace)
+	}
 	v.Set("pattern", o.Pattern)
 	for _, field := range o.Fields {
 		v.Add("fields", field)
@@ -1042,6 +1321,7 @@ func (c *Client) CreateTemplate(opt CreateTemplateOptions) (Template, error) {
 
 	u := *c.url
 	u.Path = templatesPath
+	u.RawQuery = namespaceQuery(opt.ID)
 
 	req, err := http.NewRequest("POST", u.String(), &buf)
 	if err != nil {
@@ -1157,10 +1437,12 @@ func (c *Client) DeleteTemplate(link Link) error {
 
 type ListTemplatesOptions struct {
 	TemplateOptions
-	Pattern string
-	Fields  []string
-	Offset  int
-	Limit   int
+	// Namespace restricts the list to the templates within the namespace.
+	Namespace string
+	Pattern   string
+	Fields    []string
+	Offset    int
+	Limit     int
 }
 
 func (o *ListTemplatesOptions) Default() {
@@ -1172,6 +1454,9 @@ func (o *ListTemplatesOptions) Default() {
 
 func (o *ListTemplatesOptions) Values() *url.Values {
 	v := o.TemplateOptions.Values()
+	if o.Namespace != "" {
+		v.Set("namespace", o.Namespace)
+	}
 	v.Set("pattern", o.Pattern)
 	for _, field := range o.Fields {
 		v.Add("fields", field)
@@ -1211,6 +1496,178 @@ func (c *Client) ListTemplates(opt *ListTemplatesOptions) ([]Template, error) {
 	return r.Templates, nil
 }
 
+// namespaceQuery returns the query that restricts a create request to the namespace of the first namespaced ID,
+// so that privileges for the namespace are sufficient to create the object.
+func namespaceQuery(ids ...string) string {
+	for _, id := range ids {
+		if i := strings.Index(id, "/"); i >= 0 {
+			v := url.Values{}
+			v.Set("namespace", id[:i])
+			return v.Encode()
+		}
+	}
+	return ""
+}
+
+// Namespace partitions tasks, templates, recordings and alert topics,
+// whose IDs are prefixed by the namespace name and a '/'.
+type Namespace struct {
+	Link Link   `json:"link"`
+	Name string `json:"name"`
+	// DBRPs the tasks within the namespace are allowed to access, empty allows all DBRPs.
+	// A DBRP with an empty retention policy allows all retention policies of the database.
+	DBRPs []DBRP `json:"dbrps"`
+}
+
+type CreateNamespaceOptions struct {
+	Name  string `json:"name"`
+	DBRPs []DBRP `json:"dbrps,omitempty"`
+}
+
+// Create a new namespace.
+// Errors if the namespace already exists.
+func (c *Client) CreateNamespace(opt CreateNamespaceOptions) (Namespace, error) {
+	n := Namespace{}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return n, err
+	}
+
+	u := *c.url
+	u.Path = namespacesPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return n, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &n, http.StatusOK)
+	return n, err
+}
+
+type UpdateNamespaceOptions struct {
+	DBRPs *[]DBRP `json:"dbrps,omitempty"`
+}
+
+// Update an existing namespace.
+// Only fields that are set will be updated.
+func (c *Client) UpdateNamespace(link Link, opt UpdateNamespaceOptions) (Namespace, error) {
+	n := Namespace{}
+	if link.Href == "" {
+		return n, fmt.Errorf("invalid link %v", link)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return n, err
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("PATCH", u.String(), &buf)
+	if err != nil {
+		return n, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &n, http.StatusOK)
+	return n, err
+}
+
+// Get information about a namespace.
+func (c *Client) Namespace(link Link) (Namespace, error) {
+	n := Namespace{}
+	if link.Href == "" {
+		return n, fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return n, err
+	}
+
+	_, err = c.Do(req, &n, http.StatusOK)
+	return n, err
+}
+
+// Delete a namespace.
+// Errors if any task or template still exists within the namespace.
+func (c *Client) DeleteNamespace(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	return err
+}
+
+type ListNamespacesOptions struct {
+	Pattern string
+	Offset  int
+	Limit   int
+}
+
+func (o *ListNamespacesOptions) Default() {
+	if o.Limit == 0 {
+		o.Limit = 100
+	}
+}
+
+func (o *ListNamespacesOptions) Values() *url.Values {
+	v := &url.Values{}
+	v.Set("pattern", o.Pattern)
+	v.Set("offset", strconv.FormatInt(int64(o.Offset), 10))
+	v.Set("limit", strconv.FormatInt(int64(o.Limit), 10))
+	return v
+}
+
+// Get namespaces.
+func (c *Client) ListNamespaces(opt *ListNamespacesOptions) ([]Namespace, error) {
+	if opt == nil {
+		opt = new(ListNamespacesOptions)
+	}
+	opt.Default()
+
+	u := *c.url
+	u.Path = namespacesPath
+	u.RawQuery = opt.Values().Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response type
+	type response struct {
+		Namespaces []Namespace `json:"namespaces"`
+	}
+
+	r := &response{}
+
+	_, err = c.Do(req, r, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return r.Namespaces, nil
+}
+
 // Get information about a recording.
 func (c *Client) Recording(link Link) (Recording, error) {
 	r := Recording{}
@@ -1257,6 +1714,7 @@ func (c *Client) RecordStream(opt RecordStreamOptions) (Recording, error) {
 
 	u := *c.url
 	u.Path = recordStreamPath
+	u.RawQuery = namespaceQuery(opt.ID, opt.Task)
 
 	req, err := http.NewRequest("POST", u.String(), &buf)
 	if err != nil {
@@ -1292,6 +1750,7 @@ func (c *Client) RecordBatch(opt RecordBatchOptions) (Recording, error) {
 
 	u := *c.url
 	u.Path = recordBatchPath
+	u.RawQuery = namespaceQuery(opt.ID, opt.Task)
 
 	req, err := http.NewRequest("POST", u.String(), &buf)
 	if err != nil {
@@ -1328,6 +1787,41 @@ func (c *Client) RecordQuery(opt RecordQueryOptions) (Recording, error) {
 
 	u := *c.url
 	u.Path = recordQueryPath
+	u.RawQuery = namespaceQuery(opt.ID)
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &r, http.StatusCreated)
+	if err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+type RecordFlightRecorderOptions struct {
+	ID   string `json:"id,omitempty"`
+	Task string `json:"task"`
+}
+
+// Freeze the flight recorder of a task into a stream recording.
+// Returns once the recording is saved.
+func (c *Client) RecordFlightRecorder(opt RecordFlightRecorderOptions) (Recording, error) {
+	r := Recording{}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return r, err
+	}
+
+	u := *c.url
+	u.Path = recordFlightPath
+	u.RawQuery = namespaceQuery(opt.ID, opt.Task)
 
 	req, err := http.NewRequest("POST", u.String(), &buf)
 	if err != nil {
@@ -1360,10 +1854,12 @@ func (c *Client) DeleteRecording(link Link) error {
 }
 
 type ListRecordingsOptions struct {
-	Pattern string
-	Fields  []string
-	Offset  int
-	Limit   int
+	// Namespace restricts the list to the recordings within the namespace.
+	Namespace string
+	Pattern   string
+	Fields    []string
+	Offset    int
+	Limit     int
 }
 
 func (o *ListRecordingsOptions) Default() {
@@ -1374,6 +1870,9 @@ func (o *ListRecordingsOptions) Default() {
 
 func (o *ListRecordingsOptions) Values() *url.Values {
 	v := &url.Values{}
+	if o.Namespace != "" {
+		v.Set("namespace", o.Namespace)
+	}
 	v.Set("pattern", o.Pattern)
 	for _, field := range o.Fields {
 		v.Add("fields", field)
@@ -1422,6 +1921,8 @@ type CreateReplayOptions struct {
 	Task          string `json:"task"`
 	RecordingTime bool   `json:"recording-time"`
 	Clock         Clock  `json:"clock"`
+	// Scale is the multiple of real time used by the Scaled clock.
+	Scale float64 `json:"scale,omitempty"`
 }
 
 func (o *CreateReplayOptions) Default() {
@@ -1463,6 +1964,7 @@ type ReplayBatchOptions struct {
 	Stop          time.Time `json:"stop"`
 	RecordingTime bool      `json:"recording-time"`
 	Clock         Clock     `json:"clock"`
+	Scale         float64   `json:"scale,omitempty"`
 }
 
 // Replay a query against a task.
@@ -1493,12 +1995,13 @@ func (c *Client) ReplayBatch(opt ReplayBatchOptions) (Replay, error) {
 }
 
 type ReplayQueryOptions struct {
-	ID            string `json:"id,omitempty"`
-	Task          string `json:"task"`
-	Query         string `json:"query"`
-	Cluster       string `json:"cluster,omitempty"`
-	RecordingTime bool   `json:"recording-time"`
-	Clock         Clock  `json:"clock"`
+	ID            string  `json:"id,omitempty"`
+	Task          string  `json:"task"`
+	Query         string  `json:"query"`
+	Cluster       string  `json:"cluster,omitempty"`
+	RecordingTime bool    `json:"recording-time"`
+	Clock         Clock   `json:"clock"`
+	Scale         float64 `json:"scale,omitempty"`
 }
 
 // Replay a query against a task.
@@ -1550,27 +2053,118 @@ func (c *Client) Replay(link Link) (Replay, error) {
 	return r, nil
 }
 
-// Delete a replay. This will cancel a running replay.
-func (c *Client) DeleteReplay(link Link) error {
-	if link.Href == "" {
-		return fmt.Errorf("invalid link %v", link)
+type ReplayAction int
+
+const (
+	// Stop the replay clock from advancing.
+	PauseReplay ReplayAction = iota
+	// Resume a paused replay clock.
+	ResumeReplay
+	// Move the replay clock forward by a duration.
+	StepReplay
+	// Move the replay clock forward to a position.
+	SeekReplay
+)
+
+func (a ReplayAction) MarshalText() ([]byte, error) {
+	switch a {
+	case PauseReplay:
+		return []byte("pause"), nil
+	case ResumeReplay:
+		return []byte("resume"), nil
+	case StepReplay:
+		return []byte("step"), nil
+	case SeekReplay:
+		return []byte("seek"), nil
+	default:
+		return nil, fmt.Errorf("unknown ReplayAction %d", a)
 	}
-	u := *c.url
-	u.Path = link.Href
+}
 
-	req, err := http.NewRequest("DELETE", u.String(), nil)
-	if err != nil {
-		return err
+func (a *ReplayAction) UnmarshalText(text []byte) error {
+	switch s := string(text); s {
+	case "pause":
+		*a = PauseReplay
+	case "resume":
+		*a = ResumeReplay
+	case "step":
+		*a = StepReplay
+	case "seek":
+		*a = SeekReplay
+	default:
+		return fmt.Errorf("unknown ReplayAction %s", s)
 	}
+	return nil
+}
 
-	_, err = c.Do(req, nil, http.StatusNoContent)
+func (a ReplayAction) String() string {
+	s, err := a.MarshalText()
 	if err != nil {
-		return err
+		return err.Error()
 	}
-	return nil
+	return string(s)
 }
 
-type ListReplaysOptions struct {
+type UpdateReplayOptions struct {
+	Action ReplayAction `json:"action"`
+	// Step is the duration the clock is moved forward by the step action.
+	Step Duration `json:"step,omitempty"`
+	// Position is the duration since the start of the replay that the seek action moves the clock to.
+	Position Duration `json:"position,omitempty"`
+}
+
+// Control a running replay.
+// Only replays using the Scaled clock can be controlled.
+func (c *Client) UpdateReplay(link Link, opt UpdateReplayOptions) (Replay, error) {
+	r := Replay{}
+	if link.Href == "" {
+		return r, fmt.Errorf("invalid link %v", link)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return r, err
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("PATCH", u.String(), &buf)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &r, http.StatusOK)
+	if err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// Delete a replay. This will cancel a running replay.
+func (c *Client) DeleteReplay(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type ListReplaysOptions struct {
 	Pattern string
 	Fields  []string
 	Offset  int
@@ -1841,8 +2435,10 @@ func (c *Client) DoServiceTest(link Link, sto ServiceTestOptions) (ServiceTestRe
 }
 
 type ListTopicsOptions struct {
-	Pattern  string
-	MinLevel string
+	// Namespace restricts the list to the topics within the namespace.
+	Namespace string
+	Pattern   string
+	MinLevel  string
 }
 
 func (o *ListTopicsOptions) Default() {
@@ -1853,6 +2449,9 @@ func (o *ListTopicsOptions) Default() {
 
 func (o *ListTopicsOptions) Values() *url.Values {
 	v := &url.Values{}
+	if o.Namespace != "" {
+		v.Set("namespace", o.Namespace)
+	}
 	v.Set("pattern", o.Pattern)
 	v.Set("min-level", o.MinLevel)
 	return v
@@ -2289,6 +2888,630 @@ func (c *Client) Backup() (int64, io.ReadCloser, error) {
 	return resp.ContentLength, resp.Body, nil
 }
 
+type Privilege int
+
+const (
+	NoPrivileges Privilege = iota
+	ReadPrivilege
+	WritePrivilege
+	DeletePrivilege
+	AllPrivileges
+)
+
+func (p Privilege) MarshalText() ([]byte, error) {
+	switch p {
+	case NoPrivileges:
+		return []byte("none"), nil
+	case ReadPrivilege:
+		return []byte("read"), nil
+	case WritePrivilege:
+		return []byte("write"), nil
+	case DeletePrivilege:
+		return []byte("delete"), nil
+	case AllPrivileges:
+		return []byte("all"), nil
+	default:
+		return nil, fmt.Errorf("unknown Privilege %d", p)
+	}
+}
+
+func (p *Privilege) UnmarshalText(text []byte) error {
+	switch s := string(text); s {
+	case "none":
+		*p = NoPrivileges
+	case "read":
+		*p = ReadPrivilege
+	case "write":
+		*p = WritePrivilege
+	case "delete":
+		*p = DeletePrivilege
+	case "all":
+		*p = AllPrivileges
+	default:
+		return fmt.Errorf("unknown Privilege %s", s)
+	}
+	return nil
+}
+
+func (p Privilege) String() string {
+	s, err := p.MarshalText()
+	if err != nil {
+		return err.Error()
+	}
+	return string(s)
+}
+
+type User struct {
+	Link  Link     `json:"link"`
+	Name  string   `json:"name"`
+	Admin bool     `json:"admin"`
+	Roles []string `json:"roles"`
+}
+
+type Role struct {
+	Link Link   `json:"link"`
+	Name string `json:"name"`
+	// Map of resource path to the privileges granted on the resource.
+	Permissions map[string][]Privilege `json:"permissions"`
+}
+
+func (c *Client) UserLink(name string) Link {
+	return Link{Relation: Self, Href: path.Join(usersPath, name)}
+}
+
+func (c *Client) RoleLink(name string) Link {
+	return Link{Relation: Self, Href: path.Join(rolesPath, name)}
+}
+
+type CreateUserOptions struct {
+	Name     string   `json:"name"`
+	Password string   `json:"password"`
+	Admin    bool     `json:"admin"`
+	Roles    []string `json:"roles,omitempty"`
+}
+
+// Create a new user.
+// Errors if the user already exists.
+func (c *Client) CreateUser(opt CreateUserOptions) (User, error) {
+	user := User{}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return user, err
+	}
+
+	u := *c.url
+	u.Path = usersPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &user, http.StatusOK)
+	return user, err
+}
+
+type UpdateUserOptions struct {
+	Password string    `json:"password,omitempty"`
+	Admin    *bool     `json:"admin,omitempty"`
+	Roles    *[]string `json:"roles,omitempty"`
+}
+
+// Update an existing user.
+// Only fields that are set will be updated.
+func (c *Client) UpdateUser(link Link, opt UpdateUserOptions) (User, error) {
+	user := User{}
+	if link.Href == "" {
+		return user, fmt.Errorf("invalid link %v", link)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return user, err
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("PATCH", u.String(), &buf)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &user, http.StatusOK)
+	return user, err
+}
+
+// Get information about a user.
+func (c *Client) User(link Link) (User, error) {
+	user := User{}
+	if link.Href == "" {
+		return user, fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return user, err
+	}
+
+	_, err = c.Do(req, &user, http.StatusOK)
+	return user, err
+}
+
+// Delete a user.
+func (c *Client) DeleteUser(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	return err
+}
+
+type ListUsersOptions struct {
+	Pattern string
+	Offset  int
+	Limit   int
+}
+
+func (o *ListUsersOptions) Default() {
+	if o.Limit == 0 {
+		o.Limit = 100
+	}
+}
+
+func (o *ListUsersOptions) Values() *url.Values {
+	v := &url.Values{}
+	v.Set("pattern", o.Pattern)
+	v.Set("offset", strconv.FormatInt(int64(o.Offset), 10))
+	v.Set("limit", strconv.FormatInt(int64(o.Limit), 10))
+	return v
+}
+
+// Get users.
+func (c *Client) ListUsers(opt *ListUsersOptions) ([]User, error) {
+	if opt == nil {
+		opt = new(ListUsersOptions)
+	}
+	opt.Default()
+
+	u := *c.url
+	u.Path = usersPath
+	u.RawQuery = opt.Values().Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response type
+	type response struct {
+		Users []User `json:"users"`
+	}
+
+	r := &response{}
+
+	_, err = c.Do(req, r, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return r.Users, nil
+}
+
+type CreateRoleOptions struct {
+	Name        string                 `json:"name"`
+	Permissions map[string][]Privilege `json:"permissions"`
+}
+
+// Create a new role.
+// Errors if the role already exists.
+func (c *Client) CreateRole(opt CreateRoleOptions) (Role, error) {
+	r := Role{}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return r, err
+	}
+
+	u := *c.url
+	u.Path = rolesPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &r, http.StatusOK)
+	return r, err
+}
+
+type UpdateRoleOptions struct {
+	// Permissions replaces all permissions of the role, if set.
+	Permissions map[string][]Privilege `json:"permissions,omitempty"`
+}
+
+// Update an existing role.
+// Only fields that are set will be updated.
+func (c *Client) UpdateRole(link Link, opt UpdateRoleOptions) (Role, error) {
+	r := Role{}
+	if link.Href == "" {
+		return r, fmt.Errorf("invalid link %v", link)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return r, err
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("PATCH", u.String(), &buf)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &r, http.StatusOK)
+	return r, err
+}
+
+// Get information about a role.
+func (c *Client) Role(link Link) (Role, error) {
+	r := Role{}
+	if link.Href == "" {
+		return r, fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return r, err
+	}
+
+	_, err = c.Do(req, &r, http.StatusOK)
+	return r, err
+}
+
+// Delete a role.
+// The role is also removed from all users it was granted to.
+func (c *Client) DeleteRole(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	return err
+}
+
+type ListRolesOptions struct {
+	Pattern string
+	Offset  int
+	Limit   int
+}
+
+func (o *ListRolesOptions) Default() {
+	if o.Limit == 0 {
+		o.Limit = 100
+	}
+}
+
+func (o *ListRolesOptions) Values() *url.Values {
+	v := &url.Values{}
+	v.Set("pattern", o.Pattern)
+	v.Set("offset", strconv.FormatInt(int64(o.Offset), 10))
+	v.Set("limit", strconv.FormatInt(int64(o.Limit), 10))
+	return v
+}
+
+// Get roles.
+func (c *Client) ListRoles(opt *ListRolesOptions) ([]Role, error) {
+	if opt == nil {
+		opt = new(ListRolesOptions)
+	}
+	opt.Default()
+
+	u := *c.url
+	u.Path = rolesPath
+	u.RawQuery = opt.Values().Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response type
+	type response struct {
+		Roles []Role `json:"roles"`
+	}
+
+	r := &response{}
+
+	_, err = c.Do(req, r, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return r.Roles, nil
+}
+
+type APIToken struct {
+	Link        Link      `json:"link"`
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	Description string    `json:"description"`
+	Scopes      []string  `json:"scopes"`
+	Created     time.Time `json:"created"`
+	// Expires is nil if the token never expires.
+	Expires *time.Time `json:"expires,omitempty"`
+	// Token is the secret credential, it is only returned when the token is created.
+	Token string `json:"token,omitempty"`
+}
+
+func (c *Client) APITokenLink(id string) Link {
+	return Link{Relation: Self, Href: path.Join(apiTokensPath, id)}
+}
+
+type CreateAPITokenOptions struct {
+	// Username of the owner of the token, defaults to the authenticated user.
+	// Only admins may create tokens for other users.
+	Username    string `json:"username,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Resource patterns the token is restricted to, empty means all the resources of the user.
+	Scopes []string `json:"scopes,omitempty"`
+	// Lifetime of the token, zero means the token never expires.
+	TTL Duration `json:"ttl,omitempty"`
+}
+
+// Create a new API token.
+// The returned token contains the secret credential, which cannot be retrieved again.
+func (c *Client) CreateAPIToken(opt CreateAPITokenOptions) (APIToken, error) {
+	t := APIToken{}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return t, err
+	}
+
+	u := *c.url
+	u.Path = apiTokensPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return t, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &t, http.StatusOK)
+	return t, err
+}
+
+// Get information about an API token.
+func (c *Client) APIToken(link Link) (APIToken, error) {
+	t := APIToken{}
+	if link.Href == "" {
+		return t, fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return t, err
+	}
+
+	_, err = c.Do(req, &t, http.StatusOK)
+	return t, err
+}
+
+// Delete an API token, revoking it.
+func (c *Client) DeleteAPIToken(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	return err
+}
+
+// Get API tokens.
+// Admins get the tokens of all users, other users only get their own tokens.
+func (c *Client) ListAPITokens() ([]APIToken, error) {
+	u := *c.url
+	u.Path = apiTokensPath
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response type
+	type response struct {
+		Tokens []APIToken `json:"tokens"`
+	}
+
+	r := &response{}
+
+	_, err = c.Do(req, r, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return r.Tokens, nil
+}
+
+// AuditEntry describes a single mutating API request.
+type AuditEntry struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request-id"`
+	Method    string    `json:"method"`
+	// Route is the pattern of the API route that served the request.
+	Route string `json:"route"`
+	Path  string `json:"path"`
+	// User is the name of the user that made the request.
+	User string `json:"user"`
+	// ResourceID is the ID of the object the request acted on, if known.
+	ResourceID string `json:"resource-id"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+	// Before and After are JSON summaries of the object before and after the request.
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type ListAuditEntriesOptions struct {
+	User     string
+	Method   string
+	Resource string
+	// Only list entries in the time range [Start, Stop], zero values are unbounded.
+	Start  time.Time
+	Stop   time.Time
+	Offset int
+	Limit  int
+}
+
+func (o *ListAuditEntriesOptions) Default() {
+	if o.Limit == 0 {
+		o.Limit = 100
+	}
+}
+
+func (o *ListAuditEntriesOptions) Values() *url.Values {
+	v := &url.Values{}
+	if o.User != "" {
+		v.Set("user", o.User)
+	}
+	if o.Method != "" {
+		v.Set("method", o.Method)
+	}
+	if o.Resource != "" {
+		v.Set("resource", o.Resource)
+	}
+	if !o.Start.IsZero() {
+		v.Set("start", o.Start.Format(time.RFC3339Nano))
+	}
+	if !o.Stop.IsZero() {
+		v.Set("stop", o.Stop.Format(time.RFC3339Nano))
+	}
+	v.Set("offset", strconv.FormatInt(int64(o.Offset), 10))
+	v.Set("limit", strconv.FormatInt(int64(o.Limit), 10))
+	return v
+}
+
+// Get audit entries, newest first.
+func (c *Client) ListAuditEntries(opt *ListAuditEntriesOptions) ([]AuditEntry, error) {
+	if opt == nil {
+		opt = new(ListAuditEntriesOptions)
+	}
+	opt.Default()
+
+	u := *c.url
+	u.Path = auditPath
+	u.RawQuery = opt.Values().Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response type
+	type response struct {
+		Entries []AuditEntry `json:"entries"`
+	}
+
+	r := &response{}
+
+	_, err = c.Do(req, r, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return r.Entries, nil
+}
+
+// ScraperTarget describes a target discovered for a scraper and the result of its last scrape.
+type ScraperTarget struct {
+	URL string `json:"url"`
+	// Labels are the labels added to the points scraped from the target.
+	Labels map[string]string `json:"labels"`
+	// DiscoveredLabels are the labels of the target before relabeling.
+	DiscoveredLabels map[string]string `json:"discovered-labels"`
+	// Health is up or down depending on the last scrape, or unknown if the target was not scraped yet.
+	Health     string    `json:"health"`
+	LastScrape time.Time `json:"last-scrape"`
+	// Duration of the last scrape.
+	Duration Duration `json:"duration"`
+	// Samples is the number of samples returned by the last scrape.
+	Samples   int    `json:"samples"`
+	LastError string `json:"last-error"`
+}
+
+// Get the targets discovered for a scraper.
+func (c *Client) ListScraperTargets(link Link) ([]ScraperTarget, error) {
+	if link.Href == "" {
+		return nil, fmt.Errorf("invalid link %v", link)
+	}
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response type
+	type response struct {
+		Targets []ScraperTarget `json:"targets"`
+	}
+
+	r := &response{}
+
+	_, err = c.Do(req, r, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return r.Targets, nil
+}
+
 type LogLevelOptions struct {
 	Level string `json:"level"`
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -613,6 +614,74 @@ func Test_CreateTask(t *testing.T) {
 	}
 }
 
+func Test_TestTask(t *testing.T) {
+	tickScript := "stream|from().measurement('cpu')|alert().id('cpu').crit(lambda: TRUE)"
+	data := "cpu value=1 0000000001\n"
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opt client.TestTaskOptions
+		body, _ := ioutil.ReadAll(r.Body)
+		err := json.Unmarshal(body, &opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.URL.Path == "/kapacitor/v1/tasks/test" && r.Method == "POST" {
+			exp := client.TestTaskOptions{
+				DBRPs:      []client.DBRP{{Database: "dbname", RetentionPolicy: "rpname"}},
+				TICKscript: tickScript,
+				Data:       data,
+				Precision:  "s",
+				Expected: client.TestTaskExpectations{
+					Alerts: []client.TestAlert{{ID: "cpu", Level: "CRITICAL"}},
+				},
+			}
+			if !reflect.DeepEqual(exp, opt) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "unexpected TestTask body: got:\n%v\nexp:\n%v\n", opt, exp)
+			} else {
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, `{"passed":false,"failures":[{"output":"alerts","index":0,"message":"unexpected level"}],"alerts":[{"id":"cpu","level":"WARNING","time":"1970-01-01T00:00:01Z"}]}`)
+			}
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result, err := c.TestTask(client.TestTaskOptions{
+		DBRPs:      []client.DBRP{{Database: "dbname", RetentionPolicy: "rpname"}},
+		TICKscript: tickScript,
+		Data:       data,
+		Precision:  "s",
+		Expected: client.TestTaskExpectations{
+			Alerts: []client.TestAlert{{ID: "cpu", Level: "CRITICAL"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := client.TestTaskResult{
+		Passed: false,
+		Failures: []client.TestFailure{{
+			Output:  "alerts",
+			Index:   0,
+			Message: "unexpected level",
+		}},
+		Alerts: []client.TestAlert{{
+			ID:    "cpu",
+			Level: "WARNING",
+			Time:  time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC),
+		}},
+	}
+	if !reflect.DeepEqual(exp, result) {
+		t.Errorf("unexpected test result:\ngot:\n%v\nexp:\n%v", result, exp)
+	}
+}
+
 func Test_UpdateTask(t *testing.T) {
 	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var task client.UpdateTaskOptions
@@ -1428,6 +1497,39 @@ func Test_RecordQuery(t *testing.T) {
 	}
 }
 
+func Test_RecordFlightRecorder(t *testing.T) {
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts client.RecordFlightRecorderOptions
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &opts)
+		if r.URL.Path == "/kapacitor/v1/recordings/flight-recorder" && r.Method == "POST" &&
+			opts.Task == "taskname" {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"link": {"rel":"self", "href":"/kapacitor/v1/recordings/rid1"},"id":"rid1","status":"finished"}`)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v body: %s", r, string(body))
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	r, err := c.RecordFlightRecorder(client.RecordFlightRecorderOptions{
+		Task: "taskname",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, got := "rid1", r.ID; got != exp {
+		t.Errorf("unexpected recording ID for test: got: %s exp: %s", got, exp)
+	}
+	if exp, got := client.Finished, r.Status; got != exp {
+		t.Errorf("unexpected recording status for test: got: %v exp: %v", got, exp)
+	}
+}
+
 func Test_Recording(t *testing.T) {
 	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/kapacitor/v1/recordings/rid1" && r.Method == "GET" {
@@ -1780,6 +1882,59 @@ func Test_CreateReplay(t *testing.T) {
 	}
 }
 
+func Test_UpdateReplay(t *testing.T) {
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts client.UpdateReplayOptions
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &opts)
+		if r.URL.Path == "/kapacitor/v1/replays/replayid" && r.Method == "PATCH" &&
+			opts.Action == client.StepReplay &&
+			opts.Step == client.Duration(10*time.Second) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{
+		"link": {"rel":"self", "href":"/kapacitor/v1/replays/replayid"},
+		"id": "replayid",
+		"task": "taskid",
+		"recording": "recordingid",
+		"clock": "scaled",
+		"scale": 10,
+		"status": "running",
+		"paused": true,
+		"position": "1m10s"
+}`)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	replay, err := c.UpdateReplay(c.ReplayLink("replayid"), client.UpdateReplayOptions{
+		Action: client.StepReplay,
+		Step:   client.Duration(10 * time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := client.Replay{
+		Link:      client.Link{Relation: client.Self, Href: "/kapacitor/v1/replays/replayid"},
+		ID:        "replayid",
+		Task:      "taskid",
+		Recording: "recordingid",
+		Clock:     client.Scaled,
+		Scale:     10,
+		Status:    client.Running,
+		Paused:    true,
+		Position:  client.Duration(70 * time.Second),
+	}
+	if !reflect.DeepEqual(exp, replay) {
+		t.Errorf("unexpected replay got: %v exp %v", replay, exp)
+	}
+}
+
 func Test_ReplayBatch(t *testing.T) {
 	stop := time.Now().UTC()
 	start := stop.Add(-24 * time.Hour)
@@ -3134,3 +3289,127 @@ func Test_BearerAuthentication(t *testing.T) {
 		t.Errorf("unexpected version: got: %s exp: %s", got, exp)
 	}
 }
+
+func Test_CreateUser(t *testing.T) {
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opt client.CreateUserOptions
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &opt)
+
+		if r.URL.Path == "/kapacitor/v1/users" && r.Method == "POST" {
+			exp := client.CreateUserOptions{
+				Name:     "bob",
+				Password: "secret",
+				Roles:    []string{"dashboard"},
+			}
+			if !reflect.DeepEqual(exp, opt) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "unexpected CreateUser body: got:\n%v\nexp:\n%v\n", opt, exp)
+			} else {
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, `{"link": {"rel":"self", "href":"/kapacitor/v1/users/bob"}, "name":"bob", "admin":false, "roles":["dashboard"]}`)
+			}
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	user, err := c.CreateUser(client.CreateUserOptions{
+		Name:     "bob",
+		Password: "secret",
+		Roles:    []string{"dashboard"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := client.User{
+		Link:  client.Link{Relation: client.Self, Href: "/kapacitor/v1/users/bob"},
+		Name:  "bob",
+		Roles: []string{"dashboard"},
+	}
+	if !reflect.DeepEqual(exp, user) {
+		t.Errorf("unexpected user got %v exp %v", user, exp)
+	}
+}
+
+func Test_UpdateUser(t *testing.T) {
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/kapacitor/v1/users/bob" && r.Method == "PATCH" {
+			// Only the set fields are sent
+			if got, exp := strings.TrimSpace(string(body)), `{"admin":true,"roles":[]}`; got != exp {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "unexpected UpdateUser body: got:\n%s\nexp:\n%s\n", got, exp)
+			} else {
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, `{"link": {"rel":"self", "href":"/kapacitor/v1/users/bob"}, "name":"bob", "admin":true, "roles":[]}`)
+			}
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	admin := true
+	roles := []string{}
+	user, err := c.UpdateUser(c.UserLink("bob"), client.UpdateUserOptions{
+		Admin: &admin,
+		Roles: &roles,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Admin {
+		t.Error("expected user to be an admin")
+	}
+}
+
+func Test_CreateRole(t *testing.T) {
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/kapacitor/v1/roles" && r.Method == "POST" {
+			if got, exp := strings.TrimSpace(string(body)), `{"name":"author","permissions":{"/api/tasks":["read","write"]}}`; got != exp {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "unexpected CreateRole body: got:\n%s\nexp:\n%s\n", got, exp)
+			} else {
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, `{"link": {"rel":"self", "href":"/kapacitor/v1/roles/author"}, "name":"author", "permissions":{"/api/tasks":["read","write"]}}`)
+			}
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	permissions := map[string][]client.Privilege{
+		"/api/tasks": {client.ReadPrivilege, client.WritePrivilege},
+	}
+	role, err := c.CreateRole(client.CreateRoleOptions{
+		Name:        "author",
+		Permissions: permissions,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := client.Role{
+		Link:        client.Link{Relation: client.Self, Href: "/kapacitor/v1/roles/author"},
+		Name:        "author",
+		Permissions: permissions,
+	}
+	if !reflect.DeepEqual(exp, role) {
+		t.Errorf("unexpected role got %v exp %v", role, exp)
+	}
+}
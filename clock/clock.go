@@ -4,12 +4,13 @@ A clock that provides blocking calls that wait until absolute times have occurre
 package clock
 
 import (
+	"fmt"
 	"sync"
 	"time"
 )
 
 // A clock interface to read time and wait until an absolute time arrives.
-// Three implementations are available: A 'wall' clock that is based on realtime, a 'fast' clock that is always ahead and a 'set' clock that can be controlled via a setting time explicitly.
+// Four implementations are available: A 'wall' clock that is based on realtime, a 'fast' clock that is always ahead, a 'set' clock that can be controlled via a setting time explicitly and a 'scaled' clock that runs at a multiple of realtime and can be paused.
 type Clock interface {
 	Setter
 	// Wait until time t has arrived. If t is in the past it immediately returns.
@@ -100,3 +101,150 @@ func (c *setclock) Set(t time.Time) {
 	c.cond.Broadcast()
 	c.cond.L.Unlock()
 }
+
+// A Clock whose progress can be controlled while it is in use.
+type Controller interface {
+	Clock
+	// Returns the current time of the clock
+	Now() time.Time
+	// Stop the clock from advancing until Resume is called.
+	Pause()
+	// Resume advancing the clock after a Pause.
+	Resume()
+	// Returns whether the clock is paused.
+	Paused() bool
+	// Move the clock forward by d, whether or not it is paused.
+	Step(d time.Duration)
+	// Move the clock forward to t. It is an error to seek backwards.
+	Seek(t time.Time) error
+}
+
+// implementation of clock that advances at a multiple of realtime
+type scaledclock struct {
+	zero  time.Time
+	scale float64
+
+	mu sync.Mutex
+	// base is the clock time at the realtime anchor
+	base   time.Time
+	anchor time.Time
+	paused bool
+	// changed is closed and replaced each time the clock is modified
+	changed chan struct{}
+}
+
+// Get a clock that advances scale times faster than realtime and can be paused, stepped and moved forward.
+func Scaled(scale float64) Controller {
+	now := time.Now()
+	return &scaledclock{
+		zero:    now,
+		scale:   scale,
+		base:    now,
+		anchor:  now,
+		changed: make(chan struct{}),
+	}
+}
+
+func (c *scaledclock) Zero() time.Time {
+	return c.zero
+}
+
+// now returns the current clock time, c.mu must be held.
+func (c *scaledclock) now() time.Time {
+	if c.paused {
+		return c.base
+	}
+	return c.base.Add(time.Duration(float64(time.Since(c.anchor)) * c.scale))
+}
+
+// notify wakes any waiters, c.mu must be held.
+func (c *scaledclock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *scaledclock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now()
+}
+
+func (c *scaledclock) Until(t time.Time) {
+	for {
+		c.mu.Lock()
+		now := c.now()
+		if !t.After(now) {
+			c.mu.Unlock()
+			return
+		}
+		changed := c.changed
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if !c.paused {
+			timer = time.NewTimer(time.Duration(float64(t.Sub(now)) / c.scale))
+			timerC = timer.C
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Set moves the clock forward to t, times before the current time are ignored.
+func (c *scaledclock) Set(t time.Time) {
+	_ = c.Seek(t)
+}
+
+func (c *scaledclock) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return
+	}
+	c.base = c.now()
+	c.paused = true
+	c.notify()
+}
+
+func (c *scaledclock) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return
+	}
+	c.anchor = time.Now()
+	c.paused = false
+	c.notify()
+}
+
+func (c *scaledclock) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+func (c *scaledclock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.base = c.now().Add(d)
+	c.anchor = time.Now()
+	c.notify()
+}
+
+func (c *scaledclock) Seek(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := c.now(); t.Before(now) {
+		return fmt.Errorf("cannot seek backwards from %v to %v", now, t)
+	}
+	c.base = t
+	c.anchor = time.Now()
+	c.notify()
+	return nil
+}
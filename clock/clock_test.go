@@ -163,3 +163,111 @@ func TestWallClock(t *testing.T) {
 		t.Fatal("expected return from c.Until")
 	}
 }
+
+func TestScaledClock(t *testing.T) {
+
+	c := clock.Scaled(100)
+
+	done := make(chan bool, 1)
+	go func() {
+		// 1s of clock time is 10ms of realtime
+		til := c.Zero().Add(time.Second)
+		c.Until(til)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("expected return from c.Until")
+	}
+}
+
+func TestScaledClockPauseResume(t *testing.T) {
+
+	c := clock.Scaled(1)
+	c.Pause()
+	if !c.Paused() {
+		t.Fatal("expected clock to be paused")
+	}
+
+	done := make(chan bool, 1)
+	go func() {
+		til := c.Zero().Add(10 * time.Millisecond)
+		c.Until(til)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("unexpected return from c.Until")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.Resume()
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("expected return from c.Until")
+	}
+}
+
+func TestScaledClockStep(t *testing.T) {
+
+	c := clock.Scaled(1)
+	c.Pause()
+	start := c.Now()
+
+	done := make(chan bool, 1)
+	go func() {
+		til := c.Zero().Add(time.Hour)
+		c.Until(til)
+		done <- true
+	}()
+
+	c.Step(30 * time.Minute)
+	select {
+	case <-done:
+		t.Fatal("unexpected return from c.Until")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if got, exp := c.Now(), start.Add(30*time.Minute); !got.Equal(exp) {
+		t.Errorf("unexpected clock time got %v exp %v", got, exp)
+	}
+
+	c.Step(30 * time.Minute)
+	select {
+	case <-done:
+	case <-time.After(20 * time.Millisecond):
+		t.Fatal("expected return from c.Until")
+	}
+	if !c.Paused() {
+		t.Error("expected clock to remain paused after step")
+	}
+}
+
+func TestScaledClockSeek(t *testing.T) {
+
+	c := clock.Scaled(1)
+	c.Pause()
+
+	done := make(chan bool, 1)
+	go func() {
+		til := c.Zero().Add(time.Hour)
+		c.Until(til)
+		done <- true
+	}()
+
+	if err := c.Seek(c.Zero().Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(20 * time.Millisecond):
+		t.Fatal("expected return from c.Until")
+	}
+
+	if err := c.Seek(c.Zero().Add(time.Hour)); err == nil {
+		t.Error("expected error seeking backwards")
+	}
+}
@@ -0,0 +1,124 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro"
+	"github.com/pkg/errors"
+)
+
+// The Confluent wire format prefixes the record with a magic byte and the ID of its schema.
+const (
+	confluentMagicByte  = 0
+	confluentHeaderSize = 5
+)
+
+// avroDecoder decodes Avro records with a fixed schema or the schemas of a schema registry.
+type avroDecoder struct {
+	codec *goavro.Codec
+
+	registry string
+	client   *http.Client
+
+	mu     sync.Mutex
+	codecs map[uint32]*goavro.Codec
+}
+
+func newAvroDecoder(schema, registry string) (*avroDecoder, error) {
+	a := &avroDecoder{
+		registry: strings.TrimSuffix(registry, "/"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		codecs:   make(map[uint32]*goavro.Codec),
+	}
+	if registry == "" {
+		codec, err := goavro.NewCodec(schema)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid avro-schema")
+		}
+		a.codec = codec
+	}
+	return a, nil
+}
+
+// decode decodes all records of the payload.
+func (a *avroDecoder) decode(payload []byte) ([]map[string]interface{}, error) {
+	codec := a.codec
+	if a.registry != "" {
+		if len(payload) < confluentHeaderSize || payload[0] != confluentMagicByte {
+			return nil, errors.New("payload is not in the Confluent wire format")
+		}
+		var err error
+		codec, err = a.schema(binary.BigEndian.Uint32(payload[1:confluentHeaderSize]))
+		if err != nil {
+			return nil, err
+		}
+		payload = payload[confluentHeaderSize:]
+	}
+
+	var objects []map[string]interface{}
+	for len(payload) > 0 {
+		native, rest, err := codec.NativeFromBinary(payload)
+		if err != nil {
+			return nil, err
+		}
+		o, ok := native.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected avro record, got %T", native)
+		}
+		objects = append(objects, o)
+		payload = rest
+	}
+	return objects, nil
+}
+
+// schema returns the codec of the schema with the ID, fetching the schema from the registry once.
+func (a *avroDecoder) schema(id uint32) (*goavro.Codec, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if codec, ok := a.codecs[id]; ok {
+		return codec, nil
+	}
+
+	resp, err := a.client.Get(fmt.Sprintf("%s/schemas/ids/%d", a.registry, id))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch avro schema %d", id)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch avro schema %d, got status code %d", id, resp.StatusCode)
+	}
+	var s struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode avro schema %d", id)
+	}
+	codec, err := goavro.NewCodec(s.Schema)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid avro schema %d", id)
+	}
+	a.codecs[id] = codec
+	return codec, nil
+}
+
+// unwrapUnion returns the value of a union of a primitive type,
+// which goavro represents as a map from the name of the type to the value.
+func unwrapUnion(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return v
+	}
+	for t, uv := range m {
+		switch t {
+		case "boolean", "int", "long", "float", "double", "string":
+			return uv
+		}
+	}
+	return v
+}
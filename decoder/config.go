@@ -0,0 +1,106 @@
+package decoder
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatLineProtocol decodes payloads as line protocol.
+	FormatLineProtocol = "line-protocol"
+	// FormatJSON decodes payloads as a JSON object or an array of JSON objects.
+	FormatJSON = "json"
+	// FormatAvro decodes payloads as one or more Avro encoded records.
+	FormatAvro = "avro"
+	// FormatRegex decodes each line of the payloads with a regular expression.
+	FormatRegex = "regex"
+	// FormatGrok decodes each line of the payloads with a grok pattern.
+	FormatGrok = "grok"
+)
+
+// Config describes how payloads are decoded into points.
+type Config struct {
+	// Format of the payloads, one of line-protocol, json, avro, regex or grok.
+	Format string `toml:"format"`
+	// Precision of line protocol timestamps, one of n, u, ms, s, m or h.
+	Precision string `toml:"precision"`
+
+	// Measurement of the points decoded from JSON, Avro, regex and grok payloads.
+	Measurement string `toml:"measurement"`
+	// Dot separated paths of the values used as time, tags and fields.
+	// Tags and fields are named after the last element of their path.
+	// All top level numbers and booleans not used as time or tags are fields if no field paths are given.
+	// Avro unions of primitive types are unwrapped, other unions are addressed by the name of their type.
+	TimePath   string   `toml:"time-path"`
+	TagPaths   []string `toml:"tag-paths"`
+	FieldPaths []string `toml:"field-paths"`
+	// TimeFormat is the format of the time, one of unix, unix_ms, unix_us, unix_ns
+	// or a Go time layout, defaults to RFC3339 for strings and unix seconds for numbers.
+	TimeFormat string `toml:"time-format"`
+
+	// AvroSchema is the schema of the Avro records.
+	AvroSchema string `toml:"avro-schema"`
+	// AvroSchemaRegistry is the URL of a schema registry.
+	// If set payloads are expected in the Confluent wire format,
+	// prefixed with the ID of their schema, instead of using AvroSchema.
+	AvroSchemaRegistry string `toml:"avro-schema-registry"`
+
+	// Regex is matched against each line of the payloads of the regex format.
+	// Its named groups are the values addressed by the paths.
+	Regex string `toml:"regex"`
+	// GrokPattern is matched against each line of the payloads of the grok format,
+	// e.g. %{IP:client} %{WORD:method} %{NUMBER:duration:float}.
+	// The named captures are the values addressed by the paths,
+	// a capture may be converted to an int or float by a third element.
+	GrokPattern string `toml:"grok-pattern"`
+	// GrokCustomPatterns define additional named patterns for the grok pattern,
+	// each of the form "NAME regex".
+	GrokCustomPatterns []string `toml:"grok-custom-patterns"`
+}
+
+func (c Config) Validate() error {
+	switch c.Format {
+	case "", FormatLineProtocol:
+		switch c.Precision {
+		case "", "n", "ns", "u", "ms", "s", "m", "h":
+		default:
+			return fmt.Errorf("invalid precision %q", c.Precision)
+		}
+	case FormatJSON, FormatAvro, FormatRegex, FormatGrok:
+		if c.Measurement == "" {
+			return fmt.Errorf("must specify measurement for %s format", c.Format)
+		}
+		if c.Format == FormatAvro {
+			if c.AvroSchema == "" && c.AvroSchemaRegistry == "" {
+				return errors.New("must specify avro-schema or avro-schema-registry for avro format")
+			}
+			if c.AvroSchemaRegistry != "" {
+				if _, err := url.Parse(c.AvroSchemaRegistry); err != nil {
+					return errors.Wrap(err, "invalid avro-schema-registry")
+				}
+			}
+		}
+		if c.Format == FormatRegex {
+			if c.Regex == "" {
+				return errors.New("must specify regex for regex format")
+			}
+			if _, err := regexp.Compile(c.Regex); err != nil {
+				return errors.Wrap(err, "invalid regex")
+			}
+		}
+		if c.Format == FormatGrok {
+			if c.GrokPattern == "" {
+				return errors.New("must specify grok-pattern for grok format")
+			}
+			if _, _, err := compileGrok(c.GrokPattern, c.GrokCustomPatterns); err != nil {
+				return errors.Wrap(err, "invalid grok-pattern")
+			}
+		}
+	default:
+		return fmt.Errorf("invalid format %q, must be one of %s, %s, %s, %s or %s", c.Format, FormatLineProtocol, FormatJSON, FormatAvro, FormatRegex, FormatGrok)
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+// Package decoder decodes payloads of line protocol, JSON, Avro or lines of text into points.
+package decoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// unixTimeFormats maps the unix time formats to the duration of their unit.
+var unixTimeFormats = map[string]time.Duration{
+	"unix":    time.Second,
+	"unix_ms": time.Millisecond,
+	"unix_us": time.Microsecond,
+	"unix_ns": time.Nanosecond,
+}
+
+// Decoder decodes payloads into points according to its configuration.
+// It is safe for concurrent use.
+type Decoder struct {
+	c    Config
+	avro *avroDecoder
+	// regex matches the lines of the regex and grok formats.
+	regex *regexp.Regexp
+	// types of the grok captures that are converted.
+	types map[string]string
+}
+
+// New returns a decoder for the configuration, which must be valid.
+func New(c Config) (*Decoder, error) {
+	d := &Decoder{c: c}
+	switch c.Format {
+	case FormatAvro:
+		a, err := newAvroDecoder(c.AvroSchema, c.AvroSchemaRegistry)
+		if err != nil {
+			return nil, err
+		}
+		d.avro = a
+	case FormatRegex:
+		re, err := regexp.Compile(c.Regex)
+		if err != nil {
+			return nil, err
+		}
+		d.regex = re
+	case FormatGrok:
+		re, types, err := compileGrok(c.GrokPattern, c.GrokCustomPatterns)
+		if err != nil {
+			return nil, err
+		}
+		d.regex = re
+		d.types = types
+	}
+	return d, nil
+}
+
+// Decode decodes the payload into points, now is the time of points without a time.
+func (d *Decoder) Decode(payload []byte, now time.Time) ([]models.Point, error) {
+	var objects []map[string]interface{}
+	switch d.c.Format {
+	case FormatJSON:
+		var err error
+		if objects, err = decodeJSON(payload); err != nil {
+			return nil, err
+		}
+	case FormatAvro:
+		var err error
+		if objects, err = d.avro.decode(payload); err != nil {
+			return nil, err
+		}
+	case FormatRegex, FormatGrok:
+		var err error
+		if objects, err = d.decodeLines(payload); err != nil {
+			return nil, err
+		}
+	default:
+		precision := d.c.Precision
+		if precision == "" {
+			precision = "n"
+		}
+		return models.ParsePointsWithPrecision(payload, now, precision)
+	}
+
+	points := make([]models.Point, 0, len(objects))
+	for _, o := range objects {
+		p, err := d.point(o, now)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// decodeJSON decodes a JSON object or an array of JSON objects.
+func decodeJSON(payload []byte) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	payload = bytes.TrimSpace(payload)
+	// Keep numbers as json.Number so that unix times do not lose precision.
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if len(payload) > 0 && payload[0] == '[' {
+		if err := dec.Decode(&objects); err != nil {
+			return nil, err
+		}
+	} else {
+		var o map[string]interface{}
+		if err := dec.Decode(&o); err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// decodeLines matches each line of the payload, lines that do not match are skipped.
+// Captures are strings unless their grok type converts them, empty captures are omitted.
+func (d *Decoder) decodeLines(payload []byte) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	names := d.regex.SubexpNames()
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		m := d.regex.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+		o := make(map[string]interface{}, len(names))
+		for i, name := range names {
+			if name == "" || len(m[i]) == 0 {
+				continue
+			}
+			v := string(m[i])
+			switch d.types[name] {
+			case grokTypeInt:
+				i, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid int value of capture %q: %v", name, err)
+				}
+				o[name] = i
+			case grokTypeFloat:
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid float value of capture %q: %v", name, err)
+				}
+				o[name] = f
+			default:
+				o[name] = v
+			}
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// point creates a point from the values of a decoded object.
+func (d *Decoder) point(o map[string]interface{}, now time.Time) (models.Point, error) {
+	c := d.c
+	t := now
+	if c.TimePath != "" {
+		v, ok := d.lookup(o, c.TimePath)
+		if !ok {
+			return nil, fmt.Errorf("missing time %q", c.TimePath)
+		}
+		var err error
+		if t, err = parseTime(v, c.TimeFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	tags := make(map[string]string, len(c.TagPaths))
+	for _, path := range c.TagPaths {
+		v, ok := d.lookup(o, path)
+		if !ok || v == nil {
+			continue
+		}
+		tag, ok := tagValue(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid value of tag %q", path)
+		}
+		tags[pathKey(path)] = tag
+	}
+
+	fields := make(models.Fields)
+	if len(c.FieldPaths) > 0 {
+		for _, path := range c.FieldPaths {
+			v, ok := d.lookup(o, path)
+			if !ok || v == nil {
+				continue
+			}
+			f, ok := fieldValue(v)
+			if !ok {
+				return nil, fmt.Errorf("invalid value of field %q", path)
+			}
+			if d.regex != nil {
+				f = numberValue(f)
+			}
+			fields[pathKey(path)] = f
+		}
+	} else {
+		for k, v := range o {
+			if k == c.TimePath || containsString(c.TagPaths, k) {
+				continue
+			}
+			if d.avro != nil {
+				v = unwrapUnion(v)
+			}
+			f, ok := fieldValue(v)
+			if !ok {
+				continue
+			}
+			// All captures of lines are fields, their numbers are not typed.
+			if d.regex != nil {
+				fields[k] = numberValue(f)
+			} else if isNumberOrBool(f) {
+				fields[k] = f
+			}
+		}
+	}
+	for k, v := range fields {
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			delete(fields, k)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields found")
+	}
+	return models.NewPoint(c.Measurement, models.NewTags(tags), fields, t)
+}
+
+// lookup returns the value at the dot separated path of nested objects.
+func (d *Decoder) lookup(o map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	for i, k := range keys {
+		v, ok := o[k]
+		if !ok {
+			return nil, false
+		}
+		if i == len(keys)-1 {
+			if d.avro != nil {
+				v = unwrapUnion(v)
+			}
+			return v, true
+		}
+		if o, ok = v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// pathKey returns the name of a tag or field, the last element of its path.
+func pathKey(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+func tagValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// fieldValue converts a decoded value into a field value.
+// JSON numbers are always floats, since JSON does not tell integers apart from floats.
+func fieldValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case int32:
+		return int64(v), true
+	case float32:
+		return float64(v), true
+	case string, bool, int64, float64:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// numberValue converts strings holding an integer or a float into numbers.
+func numberValue(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return v
+}
+
+func isNumberOrBool(v interface{}) bool {
+	switch v.(type) {
+	case bool, int64, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseTime(v interface{}, format string) (time.Time, error) {
+	unit := time.Second
+	if u, ok := unixTimeFormats[format]; ok {
+		unit = u
+	}
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC(), nil
+	case json.Number:
+		if _, ok := unixTimeFormats[format]; !ok && format != "" {
+			return time.Time{}, fmt.Errorf("numeric time with time-format %q", format)
+		}
+		return unixTime(string(v), unit)
+	case int32:
+		return time.Unix(0, int64(v)*int64(unit)).UTC(), nil
+	case int64:
+		return time.Unix(0, v*int64(unit)).UTC(), nil
+	case float64:
+		return time.Unix(0, int64(v*float64(unit))).UTC(), nil
+	case string:
+		if _, ok := unixTimeFormats[format]; ok {
+			return unixTime(v, unit)
+		}
+		layout := format
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid time value %v", v)
+	}
+}
+
+// unixTime parses an integer or fractional unix time of the unit.
+func unixTime(v string, unit time.Duration) (time.Time, error) {
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(0, i*int64(unit)).UTC(), nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(f*float64(unit))).UTC(), nil
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package decoder_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/linkedin/goavro"
+)
+
+const avroSchema = `{
+  "type": "record",
+  "name": "Reading",
+  "fields": [
+    {"name": "ts", "type": "long"},
+    {"name": "id", "type": "string"},
+    {"name": "temperature", "type": ["null", "double"]},
+    {"name": "count", "type": "int"}
+  ]
+}`
+
+func avroRecords(t *testing.T, records ...map[string]interface{}) []byte {
+	codec, err := goavro.NewCodec(avroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf []byte
+	for _, r := range records {
+		if buf, err = codec.BinaryFromNative(buf, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	now := time.Unix(100, 0).UTC()
+	records := avroRecords(t,
+		map[string]interface{}{"ts": int64(1500), "id": "a", "temperature": goavro.Union("double", 21.5), "count": int32(3)},
+		map[string]interface{}{"ts": int64(2500), "id": "b", "temperature": nil, "count": int32(4)},
+	)
+	testCases := []struct {
+		name    string
+		c       decoder.Config
+		payload []byte
+		points  []string
+	}{
+		{
+			name:    "line protocol",
+			c:       decoder.Config{Precision: "ms"},
+			payload: []byte("cpu,host=a value=1 1500\ncpu,host=b value=2"),
+			points: []string{
+				"cpu,host=a value=1 1500000000",
+				"cpu,host=b value=2 100000000000",
+			},
+		},
+		{
+			name: "json",
+			c: decoder.Config{
+				Format:      decoder.FormatJSON,
+				Measurement: "sensor",
+				TimePath:    "time",
+				TimeFormat:  "unix_ns",
+				TagPaths:    []string{"meta.id"},
+			},
+			payload: []byte(`{"time": 1500000000000000001, "meta": {"id": "a"}, "temperature": 21.5, "ok": true, "name": "kitchen"}`),
+			points: []string{
+				"sensor,id=a ok=true,temperature=21.5 1500000000000000001",
+			},
+		},
+		{
+			name: "json without time",
+			c: decoder.Config{
+				Format:      decoder.FormatJSON,
+				Measurement: "sensor",
+				FieldPaths:  []string{"name"},
+			},
+			payload: []byte(`[{"name": "kitchen"}]`),
+			points: []string{
+				`sensor name="kitchen" 100000000000`,
+			},
+		},
+		{
+			name: "avro",
+			c: decoder.Config{
+				Format:      decoder.FormatAvro,
+				AvroSchema:  avroSchema,
+				Measurement: "sensor",
+				TimePath:    "ts",
+				TimeFormat:  "unix_ms",
+				TagPaths:    []string{"id"},
+			},
+			payload: records,
+			points: []string{
+				"sensor,id=a count=3i,temperature=21.5 1500000000",
+				"sensor,id=b count=4i 2500000000",
+			},
+		},
+		{
+			name: "regex",
+			c: decoder.Config{
+				Format:      decoder.FormatRegex,
+				Measurement: "log",
+				Regex:       `^(?P<level>[A-Z]+) (?P<msg>.*?)(?: took (?P<duration>[0-9.]+)ms)?$`,
+				TagPaths:    []string{"level"},
+			},
+			payload: []byte("ERROR connection refused\r\nnot a log line\nINFO request took 12.5ms\n"),
+			points: []string{
+				`log,level=ERROR msg="connection refused" 100000000000`,
+				`log,level=INFO duration=12.5,msg="request" 100000000000`,
+			},
+		},
+		{
+			name: "grok",
+			c: decoder.Config{
+				Format:      decoder.FormatGrok,
+				Measurement: "http",
+				GrokPattern: `%{COMMONAPACHELOG} %{MILLIS:duration:float}`,
+				GrokCustomPatterns: []string{
+					`MILLIS \d+`,
+				},
+				TimePath:   "timestamp",
+				TimeFormat: "02/Jan/2006:15:04:05 -0700",
+				TagPaths:   []string{"verb"},
+				FieldPaths: []string{"response", "bytes", "duration", "request"},
+			},
+			payload: []byte(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 7`),
+			points: []string{
+				`http,verb=GET bytes=2326i,duration=7,request="/apache_pb.gif",response=200i 971211336000000000`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.c.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			d, err := decoder.New(tc.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			points, err := d.Decode(tc.payload, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(points))
+			for i, p := range points {
+				got[i] = p.String()
+			}
+			if !reflect.DeepEqual(got, tc.points) {
+				t.Errorf("unexpected points\ngot\n%v\nexp\n%v", got, tc.points)
+			}
+		})
+	}
+}
+
+func TestDecoder_Decode_AvroSchemaRegistry(t *testing.T) {
+	requests := 0
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/schemas/ids/7" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"schema": avroSchema})
+	}))
+	defer registry.Close()
+
+	c := decoder.Config{
+		Format:             decoder.FormatAvro,
+		AvroSchemaRegistry: registry.URL,
+		Measurement:        "sensor",
+		TagPaths:           []string{"id"},
+		FieldPaths:         []string{"temperature"},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := decoder.New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(payload[1:], 7)
+	payload = append(payload, avroRecords(t, map[string]interface{}{"ts": int64(1), "id": "a", "temperature": goavro.Union("double", 20.0), "count": int32(1)})...)
+
+	for i := 0; i < 2; i++ {
+		points, err := d.Decode(payload, time.Unix(1, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, exp := len(points), 1; got != exp {
+			t.Fatalf("unexpected number of points got %d exp %d", got, exp)
+		}
+		if got, exp := points[0].String(), "sensor,id=a temperature=20 1000000000"; got != exp {
+			t.Errorf("unexpected point got %s exp %s", got, exp)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected schema to be fetched once, got %d requests", requests)
+	}
+
+	if _, err := d.Decode([]byte{1, 2}, time.Now()); err == nil {
+		t.Error("expected error for payload not in the Confluent wire format")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name string
+		c    decoder.Config
+	}{
+		{
+			name: "invalid format",
+			c:    decoder.Config{Format: "xml"},
+		},
+		{
+			name: "invalid precision",
+			c:    decoder.Config{Precision: "d"},
+		},
+		{
+			name: "json without measurement",
+			c:    decoder.Config{Format: decoder.FormatJSON},
+		},
+		{
+			name: "avro without schema",
+			c:    decoder.Config{Format: decoder.FormatAvro, Measurement: "m"},
+		},
+		{
+			name: "invalid regex",
+			c:    decoder.Config{Format: decoder.FormatRegex, Measurement: "m", Regex: "(?P<a>"},
+		},
+		{
+			name: "unknown grok pattern",
+			c:    decoder.Config{Format: decoder.FormatGrok, Measurement: "m", GrokPattern: "%{UNKNOWN:a}"},
+		},
+		{
+			name: "invalid grok type",
+			c:    decoder.Config{Format: decoder.FormatGrok, Measurement: "m", GrokPattern: "%{INT:a:date}"},
+		},
+		{
+			name: "recursive grok pattern",
+			c:    decoder.Config{Format: decoder.FormatGrok, Measurement: "m", GrokPattern: "%{A}", GrokCustomPatterns: []string{"A %{A}"}},
+		},
+	}
+	for _, tc := range testCases {
+		if err := tc.c.Validate(); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
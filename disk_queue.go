@@ -0,0 +1,244 @@
+package kapacitor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/pkg/errors"
+)
+
+const (
+	statQueueDepth       = "queue_depth"
+	statQueueBytes       = "queue_bytes"
+	statQueueOldestAgeMs = "queue_oldest_age_ms"
+	statQueueDropped     = "queue_dropped"
+)
+
+// permanentError marks a failed write that must not be retried, i.e. the destination rejected the data itself.
+type permanentError struct {
+	error
+}
+
+// diskQueue is an on-disk write-ahead queue for the writes of an output node.
+// Writes are queued while their destination is unavailable and replayed in order once it recovers.
+// Each entry is stored in its own file named by its sequence number, so the queue survives restarts.
+type diskQueue struct {
+	dir           string
+	maxSize       int64
+	retryInterval time.Duration
+	// send writes an entry to the destination.
+	send func(data []byte) error
+	diag NodeDiagnostic
+
+	dropped *expvar.Int
+
+	mu      sync.Mutex
+	entries []diskQueueEntry
+	size    int64
+	next    uint64
+
+	wakeup   chan struct{}
+	stopping chan struct{}
+	wg       sync.WaitGroup
+}
+
+type diskQueueEntry struct {
+	seq  uint64
+	size int64
+	time time.Time
+}
+
+// newDiskQueue opens the queue in dir, loading any entries left from a previous run.
+func newDiskQueue(dir string, maxSize int64, retryInterval time.Duration, send func([]byte) error, d NodeDiagnostic) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create disk queue directory")
+	}
+	q := &diskQueue{
+		dir:           dir,
+		maxSize:       maxSize,
+		retryInterval: retryInterval,
+		send:          send,
+		diag:          d,
+		dropped:       new(expvar.Int),
+		wakeup:        make(chan struct{}, 1),
+		stopping:      make(chan struct{}),
+	}
+	// ReadDir sorts by name and the names are zero padded, so the entries are in order.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read disk queue directory")
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		seq, err := strconv.ParseUint(f.Name(), 10, 64)
+		if err != nil {
+			// Not an entry, remove partially written temporary files.
+			if filepath.Ext(f.Name()) == ".tmp" {
+				os.Remove(filepath.Join(dir, f.Name()))
+			}
+			continue
+		}
+		q.entries = append(q.entries, diskQueueEntry{
+			seq:  seq,
+			size: f.Size(),
+			time: f.ModTime(),
+		})
+		q.size += f.Size()
+		q.next = seq + 1
+	}
+	return q, nil
+}
+
+// newNodeDiskQueue opens the disk queue of an output node of the task.
+func newNodeDiskQueue(et *ExecutingTask, node string, maxSize int64, retryInterval time.Duration, send func([]byte) error, d NodeDiagnostic) (*diskQueue, error) {
+	if et.tm.DiskQueueDir == "" {
+		return nil, errors.New("no disk queue directory configured")
+	}
+	dir := filepath.Join(et.tm.DiskQueueDir, et.tm.ID(), et.Task.ID, node)
+	return newDiskQueue(dir, maxSize, retryInterval, send, d)
+}
+
+func (q *diskQueue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d", seq))
+}
+
+// setStats exposes the state of the queue on the stats of its node.
+func (q *diskQueue) setStats(statMap *expvar.Map) {
+	statMap.Set(statQueueDepth, expvar.NewIntFuncGauge(func() int64 {
+		return int64(q.Len())
+	}))
+	statMap.Set(statQueueBytes, expvar.NewIntFuncGauge(func() int64 {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.size
+	}))
+	statMap.Set(statQueueOldestAgeMs, expvar.NewIntFuncGauge(func() int64 {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if len(q.entries) == 0 {
+			return 0
+		}
+		return int64(time.Since(q.entries[0].time) / time.Millisecond)
+	}))
+	statMap.Set(statQueueDropped, q.dropped)
+}
+
+// Len returns the number of queued entries.
+// While the queue is not empty all new writes must be queued, so that they are written in order.
+func (q *diskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// enqueue appends an entry to the queue.
+// The entry is dropped if the queue would grow beyond its maximum size.
+func (q *diskQueue) enqueue(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	size := int64(len(data))
+	if q.maxSize > 0 && q.size+size > q.maxSize {
+		q.dropped.Add(1)
+		return fmt.Errorf("disk queue is full, %d of %d bytes used", q.size, q.maxSize)
+	}
+	seq := q.next
+	// Write to a temporary file first, so that a partially written entry is never replayed.
+	tmp := filepath.Join(q.dir, fmt.Sprintf("%020d.tmp", seq))
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "failed to write disk queue entry")
+	}
+	if err := os.Rename(tmp, q.path(seq)); err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "failed to write disk queue entry")
+	}
+	q.next++
+	q.entries = append(q.entries, diskQueueEntry{
+		seq:  seq,
+		size: size,
+		time: time.Now(),
+	})
+	q.size += size
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// open starts replaying the queued entries.
+func (q *diskQueue) open() {
+	q.wg.Add(1)
+	go q.run()
+}
+
+// close stops replaying, the remaining entries are kept on disk.
+func (q *diskQueue) close() {
+	close(q.stopping)
+	q.wg.Wait()
+}
+
+func (q *diskQueue) head() (diskQueueEntry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries) == 0 {
+		return diskQueueEntry{}, false
+	}
+	return q.entries[0], true
+}
+
+func (q *diskQueue) pop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e := q.entries[0]
+	q.entries = q.entries[1:]
+	q.size -= e.size
+	os.Remove(q.path(e.seq))
+}
+
+func (q *diskQueue) run() {
+	defer q.wg.Done()
+	for {
+		e, ok := q.head()
+		if !ok {
+			select {
+			case <-q.wakeup:
+				continue
+			case <-q.stopping:
+				return
+			}
+		}
+		data, err := ioutil.ReadFile(q.path(e.seq))
+		if err != nil {
+			q.diag.Error("failed to read disk queue entry, dropping it", err)
+			q.dropped.Add(1)
+			q.pop()
+			continue
+		}
+		err = q.send(data)
+		if err == nil {
+			q.pop()
+			continue
+		}
+		if perr, ok := err.(permanentError); ok {
+			q.diag.Error("failed to replay disk queue entry, dropping it", perr.error)
+			q.dropped.Add(1)
+			q.pop()
+			continue
+		}
+		q.diag.Error("failed to replay disk queue entry, retrying", err, keyvalue.KV("retry", q.retryInterval.String()))
+		select {
+		case <-time.After(q.retryInterval):
+		case <-q.stopping:
+			return
+		}
+	}
+}
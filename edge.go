@@ -2,6 +2,7 @@ package kapacitor
 
 import (
 	"errors"
+	"path/filepath"
 	"sync"
 
 	"github.com/influxdata/kapacitor/edge"
@@ -13,6 +14,7 @@ import (
 const (
 	statCollected = "collected"
 	statEmitted   = "emitted"
+	statDropped   = "dropped"
 
 	defaultEdgeBufferSize = 1000
 )
@@ -23,6 +25,22 @@ type EdgeDiagnostic interface {
 	ClosingEdge(collected, emitted int64)
 }
 
+// EdgePolicies determines what the edges of a task do with new data while their buffer is full.
+type EdgePolicies struct {
+	// Default is the policy of all edges of the task, including its input.
+	Default edge.Policy
+	// Nodes overrides the policy of the edges into specific nodes, keyed by node name.
+	Nodes map[string]edge.Policy
+}
+
+// policy returns the policy of the edge into the child node.
+func (p EdgePolicies) policy(child string) edge.Policy {
+	if policy, ok := p.Nodes[child]; ok {
+		return policy
+	}
+	return p.Default
+}
+
 type Edge struct {
 	edge.StatsEdge
 
@@ -34,8 +52,11 @@ type Edge struct {
 	diag     EdgeDiagnostic
 }
 
-func newEdge(taskName, parentName, childName string, t pipeline.EdgeType, size int, d EdgeDiagnostic) edge.StatsEdge {
-	e := edge.NewStatsEdge(edge.NewChannelEdge(t, defaultEdgeBufferSize))
+// newEdge creates an edge between two nodes of a task.
+// Edges with the spill to disk policy spill into a file below spillDir.
+func newEdge(taskName, parentName, childName string, t pipeline.EdgeType, size int, policy edge.Policy, spillDir string, d EdgeDiagnostic) edge.StatsEdge {
+	spillPath := filepath.Join(spillDir, taskName, parentName+"-"+childName)
+	e := edge.NewStatsEdge(edge.NewPolicyEdge(t, size, policy, spillPath))
 	tags := map[string]string{
 		"task":   taskName,
 		"parent": parentName,
@@ -45,6 +66,10 @@ func newEdge(taskName, parentName, childName string, t pipeline.EdgeType, size i
 	key, sm := vars.NewStatistic("edges", tags)
 	sm.Set(statCollected, e.CollectedVar())
 	sm.Set(statEmitted, e.EmittedVar())
+	sm.Set(statDropped, e.DroppedVar())
+	sm.Set(statQueueDepth, expvar.NewIntFuncGauge(func() int64 {
+		return e.Collected() - e.Emitted() - e.Dropped()
+	}))
 	return &Edge{
 		StatsEdge: e,
 		statsKey:  key,
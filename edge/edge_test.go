@@ -1,6 +1,9 @@
 package edge_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -110,6 +113,100 @@ func TestEdge_CollectBatch(t *testing.T) {
 	}
 }
 
+func pointAt(i int) edge.PointMessage {
+	p := point.ShallowCopy()
+	p.SetTime(now.Add(time.Duration(i) * time.Second))
+	return p
+}
+
+func emitAll(t *testing.T, e edge.Edge) []edge.Message {
+	t.Helper()
+	var msgs []edge.Message
+	for {
+		msg, ok := e.Emit()
+		if !ok {
+			return msgs
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestPolicyEdge_DropNewest(t *testing.T) {
+	e := edge.NewStatsEdge(edge.NewPolicyEdge(pipeline.StreamEdge, 2, edge.DropNewest, ""))
+	for i := 0; i < 4; i++ {
+		if err := e.Collect(pointAt(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Close()
+	msgs := emitAll(t, e)
+	if exp := []edge.Message{pointAt(0), pointAt(1)}; !reflect.DeepEqual(msgs, exp) {
+		t.Errorf("unexpected messages:\ngot:\n%v\nexp:\n%v\n", msgs, exp)
+	}
+	if got, exp := e.Dropped(), int64(2); got != exp {
+		t.Errorf("unexpected dropped count got %d exp %d", got, exp)
+	}
+}
+
+func TestPolicyEdge_DropOldest(t *testing.T) {
+	e := edge.NewStatsEdge(edge.NewPolicyEdge(pipeline.StreamEdge, 2, edge.DropOldest, ""))
+	barrier := edge.NewBarrierMessage(now)
+	for _, m := range []edge.Message{barrier, pointAt(0), pointAt(1), pointAt(2)} {
+		if err := e.Collect(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Close()
+	// The barrier is never dropped
+	msgs := emitAll(t, e)
+	if exp := []edge.Message{barrier, pointAt(2)}; !reflect.DeepEqual(msgs, exp) {
+		t.Errorf("unexpected messages:\ngot:\n%v\nexp:\n%v\n", msgs, exp)
+	}
+	if got, exp := e.Dropped(), int64(2); got != exp {
+		t.Errorf("unexpected dropped count got %d exp %d", got, exp)
+	}
+}
+
+func TestPolicyEdge_SpillToDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edge_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spillPath := filepath.Join(dir, "task", "spill")
+
+	// Times read back from disk have no monotonic clock reading
+	tmax := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	begin := edge.NewBeginBatchMessage(name, groupTags, false, tmax, 1)
+	bp := edge.NewBatchPointMessage(models.Fields{"field1": 42.0, "field2": int64(42)}, groupTags, tmax)
+	end := edge.NewEndBatchMessage()
+
+	e := edge.NewPolicyEdge(pipeline.BatchEdge, 1, edge.SpillToDisk, spillPath)
+	exp := []edge.Message{
+		edge.NewBufferedBatchMessage(begin, []edge.BatchPointMessage{bp}, end),
+		edge.NewBarrierMessage(tmax),
+		begin,
+		bp,
+		end,
+	}
+	for _, m := range exp {
+		if err := e.Collect(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(spillPath); err != nil {
+		t.Fatalf("expected messages to be spilled to disk: %v", err)
+	}
+	e.Close()
+	msgs := emitAll(t, e)
+	if !reflect.DeepEqual(msgs, exp) {
+		t.Errorf("unexpected messages:\ngot:\n%v\nexp:\n%v\n", msgs, exp)
+	}
+	if _, err := os.Stat(spillPath); !os.IsNotExist(err) {
+		t.Errorf("expected spill file to be removed once drained, got %v", err)
+	}
+}
+
 var emittedMsg edge.Message
 var emittedOK bool
 
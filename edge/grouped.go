@@ -21,6 +21,15 @@ type GroupedReceiver interface {
 	NewGroup(group GroupInfo, first PointMeta) (Receiver, error)
 }
 
+// GroupLimiter limits the number of groups of a grouped consumer.
+// If the edge of a grouped consumer implements GroupLimiter,
+// the limiter is asked before each new group is created.
+type GroupLimiter interface {
+	// AllowGroup reports whether another group may be created given the current number of groups.
+	// Messages of groups that are not allowed are dropped.
+	AllowGroup(groups int64) (bool, error)
+}
+
 // GroupInfo identifies and contians information about a specific group.
 type GroupInfo struct {
 	ID         models.GroupID
@@ -34,6 +43,7 @@ type groupedConsumer struct {
 	groups      map[models.GroupID]Receiver
 	current     Receiver
 	cardinality *expvar.Int
+	limiter     GroupLimiter
 }
 
 // NewGroupedConsumer creates a new grouped consumer for edge e and grouped receiver r.
@@ -43,6 +53,7 @@ func NewGroupedConsumer(e Edge, r GroupedReceiver) GroupedConsumer {
 		groups:      make(map[models.GroupID]Receiver),
 		cardinality: new(expvar.Int),
 	}
+	gc.limiter, _ = e.(GroupLimiter)
 	gc.consumer = NewConsumerWithReceiver(e, gc)
 	return gc
 }
@@ -57,6 +68,16 @@ func (c *groupedConsumer) CardinalityVar() expvar.IntVar {
 func (c *groupedConsumer) getOrCreateGroup(group GroupInfo, first PointMeta) (Receiver, error) {
 	r, ok := c.groups[group.ID]
 	if !ok {
+		if c.limiter != nil {
+			allowed, err := c.limiter.AllowGroup(int64(len(c.groups)))
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				// The group is not created, so that it may be created once other groups are deleted.
+				return dropReceiver{}, nil
+			}
+		}
 		c.cardinality.Add(1)
 		recv, err := c.gr.NewGroup(group, first)
 		if err != nil {
@@ -127,3 +148,25 @@ func (c *groupedConsumer) DeleteGroup(d DeleteGroupMessage) error {
 	}
 	return nil
 }
+
+// dropReceiver drops all messages.
+type dropReceiver struct{}
+
+func (dropReceiver) BeginBatch(BeginBatchMessage) error {
+	return nil
+}
+func (dropReceiver) BatchPoint(BatchPointMessage) error {
+	return nil
+}
+func (dropReceiver) EndBatch(EndBatchMessage) error {
+	return nil
+}
+func (dropReceiver) Point(PointMessage) error {
+	return nil
+}
+func (dropReceiver) Barrier(BarrierMessage) error {
+	return nil
+}
+func (dropReceiver) DeleteGroup(DeleteGroupMessage) error {
+	return nil
+}
@@ -0,0 +1,221 @@
+package edge
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+// Policy determines what an edge does with new messages while its buffer is full.
+type Policy int
+
+const (
+	// Block waits until the buffer has room, slowing down the parent node.
+	Block Policy = iota
+	// DropOldest drops the oldest buffered data to make room for the new message.
+	DropOldest
+	// DropNewest drops the new message.
+	DropNewest
+	// SpillToDisk writes new messages to disk until the buffer has room again.
+	SpillToDisk
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	case SpillToDisk:
+		return "spill-to-disk"
+	default:
+		return "unknown"
+	}
+}
+
+func (p Policy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Policy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "block":
+		*p = Block
+	case "drop-oldest":
+		*p = DropOldest
+	case "drop-newest":
+		*p = DropNewest
+	case "spill-to-disk":
+		*p = SpillToDisk
+	default:
+		return fmt.Errorf("unknown edge policy %s", string(text))
+	}
+	return nil
+}
+
+// NewPolicyEdge returns a new edge that buffers up to size messages and applies the policy once its buffer is full.
+// Edges with the SpillToDisk policy write the messages that do not fit into the buffer to the file at spillPath.
+//
+// Only data messages, i.e. points and buffered batches, are ever dropped.
+// Messages that structure the data, like barriers or the beginning and end of a batch, wait for room in the buffer.
+func NewPolicyEdge(typ pipeline.EdgeType, size int, policy Policy, spillPath string) Edge {
+	if policy == Block {
+		return NewChannelEdge(typ, size)
+	}
+	e := &queueEdge{
+		typ:     typ,
+		size:    size,
+		policy:  policy,
+		dropped: new(expvar.Int),
+		state:   edgeOpen,
+	}
+	if policy == SpillToDisk {
+		e.spill = &spillFile{path: spillPath}
+	}
+	e.cond = sync.NewCond(&e.mu)
+	return e
+}
+
+// queueEdge is an implementation of Edge using a bounded queue that never blocks on data messages.
+type queueEdge struct {
+	typ    pipeline.EdgeType
+	size   int
+	policy Policy
+
+	dropped *expvar.Int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	messages []Message
+	spill    *spillFile
+	state    edgeState
+}
+
+// droppable reports whether the message carries data and may be dropped.
+func droppable(m Message) bool {
+	switch m.Type() {
+	case Point, BufferedBatch:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *queueEdge) Collect(m Message) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for {
+		switch e.state {
+		case edgeAborted:
+			return ErrAborted
+		case edgeClosed:
+			panic("collect on closed edge")
+		}
+		// Once spilling has begun all messages go to disk, so that they are emitted in order.
+		if e.spill != nil && e.spill.pending > 0 {
+			return e.spill.write(m)
+		}
+		if len(e.messages) < e.size {
+			e.messages = append(e.messages, m)
+			e.cond.Broadcast()
+			return nil
+		}
+		switch e.policy {
+		case DropNewest:
+			if droppable(m) {
+				e.dropped.Add(1)
+				return nil
+			}
+		case DropOldest:
+			if i := e.oldestDroppable(); i >= 0 {
+				e.messages = append(e.messages[:i], e.messages[i+1:]...)
+				e.dropped.Add(1)
+				continue
+			}
+		case SpillToDisk:
+			return e.spill.write(m)
+		}
+		e.cond.Wait()
+	}
+}
+
+// oldestDroppable returns the index of the oldest buffered message that may be dropped or -1.
+func (e *queueEdge) oldestDroppable() int {
+	for i, m := range e.messages {
+		if droppable(m) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *queueEdge) Emit() (Message, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for {
+		if e.state == edgeAborted {
+			return nil, false
+		}
+		// Refill the buffer from disk in the order the messages were spilled.
+		if e.spill != nil && e.spill.pending > 0 && (len(e.messages) < e.size || len(e.messages) == 0) {
+			m, err := e.spill.read()
+			if err != nil {
+				// The spilled messages cannot be recovered, count them as dropped.
+				e.dropped.Add(int64(e.spill.pending))
+				e.spill.remove()
+			} else {
+				e.messages = append(e.messages, m)
+			}
+		}
+		if len(e.messages) > 0 {
+			m := e.messages[0]
+			e.messages[0] = nil
+			e.messages = e.messages[1:]
+			e.cond.Broadcast()
+			return m, true
+		}
+		if e.state == edgeClosed {
+			return nil, false
+		}
+		e.cond.Wait()
+	}
+}
+
+func (e *queueEdge) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state != edgeOpen {
+		return errors.New("edge not open cannot close")
+	}
+	e.state = edgeClosed
+	e.cond.Broadcast()
+	return nil
+}
+
+func (e *queueEdge) Abort() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state == edgeAborted {
+		//nothing to do, already aborted
+		return
+	}
+	e.state = edgeAborted
+	e.messages = nil
+	if e.spill != nil {
+		e.spill.remove()
+	}
+	e.cond.Broadcast()
+}
+
+func (e *queueEdge) Type() pipeline.EdgeType {
+	return e.typ
+}
+
+func (e *queueEdge) droppedVar() *expvar.Int {
+	return e.dropped
+}
@@ -0,0 +1,187 @@
+package edge
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/influxdata/kapacitor/models"
+)
+
+// spillFile is a FIFO of messages on disk.
+// The file is created on the first write and removed once all messages have been read back.
+type spillFile struct {
+	path string
+
+	w   *os.File
+	r   *os.File
+	enc *gob.Encoder
+	dec *gob.Decoder
+
+	// Number of messages written but not yet read.
+	pending int
+}
+
+// spilledMessage is the encoding of any message on disk.
+type spilledMessage struct {
+	Type            MessageType
+	Name            string
+	Database        string
+	RetentionPolicy string
+	Dimensions      models.Dimensions
+	Tags            models.Tags
+	Fields          models.Fields
+	Time            time.Time
+	SizeHint        int
+	GroupID         models.GroupID
+	Points          []spilledPoint
+}
+
+type spilledPoint struct {
+	Fields models.Fields
+	Tags   models.Tags
+	Time   time.Time
+}
+
+func (s *spillFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	w, err := os.OpenFile(s.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	r, err := os.Open(s.path)
+	if err != nil {
+		w.Close()
+		return err
+	}
+	s.w = w
+	s.r = r
+	s.enc = gob.NewEncoder(w)
+	s.dec = gob.NewDecoder(r)
+	return nil
+}
+
+func (s *spillFile) write(m Message) error {
+	if s.w == nil {
+		if err := s.open(); err != nil {
+			return fmt.Errorf("failed to open spill file: %v", err)
+		}
+	}
+	sm, err := toSpilled(m)
+	if err != nil {
+		return err
+	}
+	if err := s.enc.Encode(sm); err != nil {
+		return fmt.Errorf("failed to spill message: %v", err)
+	}
+	s.pending++
+	return nil
+}
+
+func (s *spillFile) read() (Message, error) {
+	var sm spilledMessage
+	if err := s.dec.Decode(&sm); err != nil {
+		return nil, err
+	}
+	s.pending--
+	if s.pending == 0 {
+		// Start over with an empty file next time.
+		s.remove()
+	}
+	return fromSpilled(sm), nil
+}
+
+// remove discards all spilled messages.
+func (s *spillFile) remove() {
+	if s.w == nil {
+		return
+	}
+	s.w.Close()
+	s.r.Close()
+	os.Remove(s.path)
+	s.w = nil
+	s.r = nil
+	s.enc = nil
+	s.dec = nil
+	s.pending = 0
+}
+
+func toSpilled(m Message) (spilledMessage, error) {
+	sm := spilledMessage{Type: m.Type()}
+	switch msg := m.(type) {
+	case PointMessage:
+		sm.Name = msg.Name()
+		sm.Database = msg.Database()
+		sm.RetentionPolicy = msg.RetentionPolicy()
+		sm.Dimensions = msg.Dimensions()
+		sm.Tags = msg.Tags()
+		sm.Fields = msg.Fields()
+		sm.Time = msg.Time()
+	case BeginBatchMessage:
+		sm.Name = msg.Name()
+		sm.Dimensions = msg.Dimensions()
+		sm.Tags = msg.Tags()
+		sm.Time = msg.Time()
+		sm.SizeHint = msg.SizeHint()
+	case BatchPointMessage:
+		sm.Tags = msg.Tags()
+		sm.Fields = msg.Fields()
+		sm.Time = msg.Time()
+	case EndBatchMessage:
+	case BufferedBatchMessage:
+		begin := msg.Begin()
+		sm.Name = begin.Name()
+		sm.Dimensions = begin.Dimensions()
+		sm.Tags = begin.Tags()
+		sm.Time = begin.Time()
+		sm.SizeHint = begin.SizeHint()
+		sm.Points = make([]spilledPoint, len(msg.Points()))
+		for i, p := range msg.Points() {
+			sm.Points[i] = spilledPoint{
+				Fields: p.Fields(),
+				Tags:   p.Tags(),
+				Time:   p.Time(),
+			}
+		}
+	case BarrierMessage:
+		sm.Time = msg.Time()
+	case DeleteGroupMessage:
+		sm.GroupID = msg.GroupID()
+	default:
+		return sm, fmt.Errorf("cannot spill message of type %v", m.Type())
+	}
+	return sm, nil
+}
+
+func fromSpilled(sm spilledMessage) Message {
+	switch sm.Type {
+	case Point:
+		return NewPointMessage(sm.Name, sm.Database, sm.RetentionPolicy, sm.Dimensions, sm.Fields, sm.Tags, sm.Time)
+	case BeginBatch:
+		return newSpilledBegin(sm)
+	case BatchPoint:
+		return NewBatchPointMessage(sm.Fields, sm.Tags, sm.Time)
+	case EndBatch:
+		return NewEndBatchMessage()
+	case BufferedBatch:
+		points := make([]BatchPointMessage, len(sm.Points))
+		for i, p := range sm.Points {
+			points[i] = NewBatchPointMessage(p.Fields, p.Tags, p.Time)
+		}
+		return NewBufferedBatchMessage(newSpilledBegin(sm), points, NewEndBatchMessage())
+	case Barrier:
+		return NewBarrierMessage(sm.Time)
+	default:
+		return &deleteGroupMessage{groupID: sm.GroupID}
+	}
+}
+
+func newSpilledBegin(sm spilledMessage) BeginBatchMessage {
+	begin := NewBeginBatchMessage(sm.Name, sm.Tags, sm.Dimensions.ByName, sm.Time, sm.SizeHint)
+	begin.SetTagsAndDimensions(sm.Tags, sm.Dimensions)
+	return begin
+}
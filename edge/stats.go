@@ -19,6 +19,10 @@ type StatsEdge interface {
 	CollectedVar() expvar.IntVar
 	// EmittedVar is an exported var the represents the number of messages emitted by this edge.
 	EmittedVar() expvar.IntVar
+	// Dropped returns the number of messages dropped by the policy of this edge.
+	Dropped() int64
+	// DroppedVar is an exported var the represents the number of messages dropped by this edge.
+	DroppedVar() expvar.IntVar
 	// ReadGroupStats allows for the reading of the current statistics by group.
 	ReadGroupStats(func(*GroupStats))
 }
@@ -30,8 +34,17 @@ type GroupStats struct {
 	Emitted   int64
 }
 
+// dropper is implemented by edges that drop messages.
+type dropper interface {
+	droppedVar() *expvar.Int
+}
+
 // NewStatsEdge creates an edge that tracks statistics about the message passing through the edge.
 func NewStatsEdge(e Edge) StatsEdge {
+	dropped := new(expvar.Int)
+	if d, ok := e.(dropper); ok {
+		dropped = d.droppedVar()
+	}
 	switch e.Type() {
 	case pipeline.StreamEdge:
 		return &streamStatsEdge{
@@ -40,6 +53,7 @@ func NewStatsEdge(e Edge) StatsEdge {
 				groupStats: make(map[models.GroupID]*GroupStats),
 				collected:  new(expvar.Int),
 				emitted:    new(expvar.Int),
+				dropped:    dropped,
 			},
 		}
 	case pipeline.BatchEdge:
@@ -49,6 +63,7 @@ func NewStatsEdge(e Edge) StatsEdge {
 				groupStats: make(map[models.GroupID]*GroupStats),
 				collected:  new(expvar.Int),
 				emitted:    new(expvar.Int),
+				dropped:    dropped,
 			},
 		}
 	}
@@ -60,6 +75,7 @@ type statsEdge struct {
 
 	collected *expvar.Int
 	emitted   *expvar.Int
+	dropped   *expvar.Int
 
 	mu         sync.RWMutex
 	groupStats map[models.GroupID]*GroupStats
@@ -78,6 +94,12 @@ func (e *statsEdge) CollectedVar() expvar.IntVar {
 func (e *statsEdge) EmittedVar() expvar.IntVar {
 	return e.emitted
 }
+func (e *statsEdge) Dropped() int64 {
+	return e.dropped.IntValue()
+}
+func (e *statsEdge) DroppedVar() expvar.IntVar {
+	return e.dropped
+}
 
 func (e *statsEdge) Close() error {
 	return e.edge.Close()
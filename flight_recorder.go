@@ -0,0 +1,76 @@
+package kapacitor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+)
+
+// FlightRecorderOptions configures the rolling recording of a stream task's input.
+type FlightRecorderOptions struct {
+	// Window is how much of the most recent input data to keep, based on point time.
+	// A zero window disables the flight recorder.
+	Window time.Duration
+	// FreezeOnCritical freezes the flight recorder into a recording
+	// whenever an alert node of the task changes to the critical level.
+	FreezeOnCritical bool
+}
+
+// flightRecorder keeps the points received by a task within a rolling window.
+type flightRecorder struct {
+	mu     sync.Mutex
+	window time.Duration
+	// points holds the recorded points in arrival order, starting at head.
+	points []edge.PointMessage
+	head   int
+}
+
+func newFlightRecorder(window time.Duration) *flightRecorder {
+	return &flightRecorder{
+		window: window,
+	}
+}
+
+// record adds the point to the buffer and evicts all points that have fallen out of the window.
+func (r *flightRecorder) record(p edge.PointMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.points = append(r.points, p)
+	cutoff := p.Time().Add(-r.window)
+	for r.head < len(r.points) && r.points[r.head].Time().Before(cutoff) {
+		r.points[r.head] = nil
+		r.head++
+	}
+	// Reclaim the evicted space once it dominates the buffer.
+	if r.head > len(r.points)/2 {
+		n := copy(r.points, r.points[r.head:])
+		for i := n; i < len(r.points); i++ {
+			r.points[i] = nil
+		}
+		r.points = r.points[:n]
+		r.head = 0
+	}
+}
+
+// snapshot returns a copy of the points currently within the window.
+func (r *flightRecorder) snapshot() []edge.PointMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	points := make([]edge.PointMessage, len(r.points)-r.head)
+	copy(points, r.points[r.head:])
+	return points
+}
+
+// flightRecorderEdge tees all points collected by a fork into a flight recorder.
+type flightRecorderEdge struct {
+	edge.StatsEdge
+	recorder *flightRecorder
+}
+
+func (e flightRecorderEdge) Collect(m edge.Message) error {
+	if p, ok := m.(edge.PointMessage); ok {
+		e.recorder.record(p)
+	}
+	return e.StatsEdge.Collect(m)
+}
@@ -0,0 +1,33 @@
+package kapacitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/models"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	r := newFlightRecorder(10 * time.Second)
+
+	for i := 0; i < 100; i++ {
+		r.record(edge.NewPointMessage(
+			"name", "db", "rp",
+			models.Dimensions{},
+			models.Fields{"value": int64(i)},
+			models.Tags{},
+			time.Unix(int64(i), 0),
+		))
+	}
+
+	points := r.snapshot()
+	if got, exp := len(points), 11; got != exp {
+		t.Fatalf("unexpected number of points got %d exp %d", got, exp)
+	}
+	for i, p := range points {
+		if got, exp := p.Time(), time.Unix(int64(89+i), 0); !got.Equal(exp) {
+			t.Errorf("unexpected time of point %d got %v exp %v", i, got, exp)
+		}
+	}
+}
@@ -42,6 +42,16 @@ func (n *HTTPOutNode) Endpoint() string {
 	return n.endpoint
 }
 
+// Result returns a copy of the most recent result cached by the node.
+func (n *HTTPOutNode) Result() models.Result {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	r := *n.result
+	r.Series = make(models.Rows, len(n.result.Series))
+	copy(r.Series, n.result.Series)
+	return r
+}
+
 func (n *HTTPOutNode) runOut([]byte) error {
 	hndl := func(w http.ResponseWriter, req *http.Request) {
 		n.mu.RLock()
@@ -1,8 +1,11 @@
 package kapacitor
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -23,6 +26,9 @@ type HTTPPostNode struct {
 	endpoint *httppost.Endpoint
 	mu       sync.RWMutex
 	bp       *bufpool.Pool
+
+	// queue holds the posts that failed while the endpoint was unavailable, if enabled.
+	queue *diskQueue
 }
 
 // Create a new  HTTPPostNode which submits received items via POST to an HTTP endpoint
@@ -50,7 +56,16 @@ func newHTTPPostNode(et *ExecutingTask, n *pipeline.HTTPPostNode, d NodeDiagnost
 		hn.endpoint = e
 	}
 
+	if n.DiskQueueFlag {
+		var err error
+		hn.queue, err = newNodeDiskQueue(et, n.Name(), n.DiskQueueMaxSize, n.DiskQueueRetryInterval, hn.replay, d)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open disk queue")
+		}
+	}
+
 	hn.node.runF = hn.runPost
+	hn.node.stopF = hn.stopPost
 	return hn, nil
 }
 
@@ -61,10 +76,22 @@ func (n *HTTPPostNode) runPost([]byte) error {
 	)
 	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
 
+	// Start replaying posts queued by a previous run
+	if n.queue != nil {
+		n.queue.setStats(n.statMap)
+		n.queue.open()
+	}
+
 	return consumer.Consume()
 
 }
 
+func (n *HTTPPostNode) stopPost() {
+	if n.queue != nil {
+		n.queue.close()
+	}
+}
+
 func (n *HTTPPostNode) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.Receiver, error) {
 	g := &httpPostGroup{
 		n:      n,
@@ -135,12 +162,35 @@ func (g *httpPostGroup) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, er
 }
 
 func (n *HTTPPostNode) doPost(row *models.Row) int {
-	resp, err := n.postRow(row)
+	body := n.bp.Get()
+	defer n.bp.Put(body)
+
+	contentType, err := n.renderRow(body, row)
+	if err != nil {
+		n.diag.Error("failed to POST data", err)
+		return 0
+	}
+
+	// Queue posts behind the ones already queued, so that they are posted in order.
+	if n.queue != nil && n.queue.Len() > 0 {
+		n.enqueue(body.Bytes(), contentType)
+		return 0
+	}
+
+	resp, err := n.post(bytes.NewReader(body.Bytes()), contentType)
 	if err != nil {
 		n.diag.Error("failed to POST data", err)
+		if n.queue != nil {
+			n.enqueue(body.Bytes(), contentType)
+		}
 		return 0
 	}
 	defer resp.Body.Close()
+	if n.queue != nil && retryableStatus(resp.StatusCode) {
+		n.diag.Error("failed to POST data, queuing it", fmt.Errorf("endpoint unavailable, status code %d", resp.StatusCode))
+		n.enqueue(body.Bytes(), contentType)
+		return resp.StatusCode
+	}
 	if resp.StatusCode/100 != 2 {
 		var err error
 		if n.c.CaptureResponseFlag {
@@ -158,27 +208,73 @@ func (n *HTTPPostNode) doPost(row *models.Row) int {
 	return resp.StatusCode
 }
 
-func (n *HTTPPostNode) postRow(row *models.Row) (*http.Response, error) {
-	body := n.bp.Get()
-	defer n.bp.Put(body)
-
-	var contentType string
+// renderRow writes the body of the POST of the row and returns its content type.
+func (n *HTTPPostNode) renderRow(body *bytes.Buffer, row *models.Row) (string, error) {
 	if n.endpoint.RowTemplate() != nil {
 		mr := newMappedRow(row)
 		err := n.endpoint.RowTemplate().Execute(body, mr)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to execute template")
+			return "", errors.Wrap(err, "failed to execute template")
 		}
-	} else {
-		result := new(models.Result)
-		result.Series = []*models.Row{row}
-		err := json.NewEncoder(body).Encode(result)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to marshal row data json")
-		}
-		contentType = "application/json"
+		return "", nil
+	}
+	result := new(models.Result)
+	result.Series = []*models.Row{row}
+	err := json.NewEncoder(body).Encode(result)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal row data json")
+	}
+	return "application/json", nil
+}
+
+// queuedPost is a POST in the disk queue.
+type queuedPost struct {
+	Body        []byte
+	ContentType string
+}
+
+// enqueue queues the post on disk, so that it is replayed once the endpoint is available.
+func (n *HTTPPostNode) enqueue(body []byte, contentType string) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(queuedPost{
+		Body:        body,
+		ContentType: contentType,
+	})
+	if err == nil {
+		err = n.queue.enqueue(buf.Bytes())
 	}
+	if err != nil {
+		n.diag.Error("failed to queue POST", err)
+	}
+}
+
+// replay posts a queued post.
+func (n *HTTPPostNode) replay(data []byte) error {
+	var qp queuedPost
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&qp); err != nil {
+		return permanentError{errors.Wrap(err, "failed to decode queued POST")}
+	}
+	resp, err := n.post(bytes.NewReader(qp.Body), qp.ContentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = fmt.Errorf("POST returned status code %d", resp.StatusCode)
+	if retryableStatus(resp.StatusCode) {
+		return err
+	}
+	return permanentError{err}
+}
+
+// retryableStatus reports whether the status code indicates that the endpoint is unavailable.
+func retryableStatus(code int) bool {
+	return code/100 == 5 || code == http.StatusTooManyRequests
+}
 
+func (n *HTTPPostNode) post(body io.Reader, contentType string) (*http.Response, error) {
 	req, err := n.endpoint.NewHTTPRequest(body)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal row data json")
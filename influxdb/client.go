@@ -266,9 +266,9 @@ func (c *HTTPClient) do(req *http.Request, result interface{}, codes ...int) (*h
 			return nil, err
 		}
 		if rp.Error != "" {
-			return nil, errors.New(rp.Error)
+			return nil, StatusError{Code: resp.StatusCode, Message: rp.Error}
 		}
-		return nil, fmt.Errorf("invalid response: code %d: body: %s", resp.StatusCode, string(body))
+		return nil, StatusError{Code: resp.StatusCode, Message: fmt.Sprintf("invalid response: code %d: body: %s", resp.StatusCode, string(body))}
 	}
 	if result != nil {
 		d := json.NewDecoder(resp.Body)
@@ -341,6 +341,16 @@ func (c *HTTPClient) Write(bp BatchPoints) error {
 	return err
 }
 
+// StatusError is returned when InfluxDB responds with an unexpected status code.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e StatusError) Error() string {
+	return e.Message
+}
+
 // Response represents a list of statement results.
 type Response struct {
 	Results []Result
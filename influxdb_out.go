@@ -2,6 +2,8 @@ package kapacitor
 
 import (
 	"bytes"
+	"encoding/gob"
+	"net/http"
 	"sync"
 	"time"
 
@@ -28,6 +30,9 @@ type InfluxDBOutNode struct {
 	writeErrors   *expvar.Int
 
 	batchBuffer *edge.BatchBuffer
+
+	// queue holds the writes that failed while InfluxDB was unavailable, if enabled.
+	queue *diskQueue
 }
 
 func newInfluxDBOutNode(et *ExecutingTask, n *pipeline.InfluxDBOutNode, d NodeDiagnostic) (*InfluxDBOutNode, error) {
@@ -47,6 +52,12 @@ func newInfluxDBOutNode(et *ExecutingTask, n *pipeline.InfluxDBOutNode, d NodeDi
 	in.node.runF = in.runOut
 	in.node.stopF = in.stopOut
 	in.wb.i = in
+	if n.DiskQueueFlag {
+		in.queue, err = newNodeDiskQueue(et, n.Name(), n.DiskQueueMaxSize, n.DiskQueueRetryInterval, in.replay, d)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open disk queue")
+		}
+	}
 	return in, nil
 }
 
@@ -57,6 +68,12 @@ func (n *InfluxDBOutNode) runOut([]byte) error {
 	n.statMap.Set(statsInfluxDBPointsWritten, n.pointsWritten)
 	n.statMap.Set(statsInfluxDBWriteErrors, n.writeErrors)
 
+	// Start replaying writes queued by a previous run
+	if n.queue != nil {
+		n.queue.setStats(n.statMap)
+		n.queue.open()
+	}
+
 	// Start the write buffer
 	n.wb.start()
 
@@ -145,6 +162,64 @@ func (n *InfluxDBOutNode) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message,
 func (n *InfluxDBOutNode) stopOut() {
 	n.wb.flush()
 	n.wb.abort()
+	if n.queue != nil {
+		n.queue.close()
+	}
+}
+
+// queuedWrite is a write to InfluxDB in the disk queue.
+type queuedWrite struct {
+	Config influxdb.BatchPointsConfig
+	Points []influxdb.Point
+}
+
+// enqueue queues the write on disk, so that it is replayed once InfluxDB is available.
+func (n *InfluxDBOutNode) enqueue(bp influxdb.BatchPoints) error {
+	var buf bytes.Buffer
+	qw := queuedWrite{
+		Config: influxdb.BatchPointsConfig{
+			Precision:        bp.Precision(),
+			Database:         bp.Database(),
+			RetentionPolicy:  bp.RetentionPolicy(),
+			WriteConsistency: bp.WriteConsistency(),
+		},
+		Points: bp.Points(),
+	}
+	if err := gob.NewEncoder(&buf).Encode(qw); err != nil {
+		return errors.Wrap(err, "failed to encode queued write")
+	}
+	return n.queue.enqueue(buf.Bytes())
+}
+
+// replay writes a queued write to InfluxDB.
+func (n *InfluxDBOutNode) replay(data []byte) error {
+	var qw queuedWrite
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&qw); err != nil {
+		return permanentError{errors.Wrap(err, "failed to decode queued write")}
+	}
+	bp, err := influxdb.NewBatchPoints(qw.Config)
+	if err != nil {
+		return permanentError{err}
+	}
+	bp.AddPoints(qw.Points)
+	if err := n.wb.cli.Write(bp); err != nil {
+		n.writeErrors.Add(1)
+		if !retryableWriteError(err) {
+			return permanentError{err}
+		}
+		return err
+	}
+	n.pointsWritten.Add(int64(len(bp.Points())))
+	return nil
+}
+
+// retryableWriteError reports whether a write failed because InfluxDB was unavailable,
+// rather than because it rejected the data.
+func retryableWriteError(err error) bool {
+	if serr, ok := errors.Cause(err).(influxdb.StatusError); ok {
+		return serr.Code/100 == 5 || serr.Code == http.StatusTooManyRequests
+	}
+	return true
 }
 
 func (n *InfluxDBOutNode) write(db, rp string, batch edge.BufferedBatchMessage) error {
@@ -302,9 +377,19 @@ func (w *writeBuffer) writeAll() {
 }
 
 func (w *writeBuffer) write(bp influxdb.BatchPoints) error {
+	// Queue writes behind the ones already queued, so that they are written in order.
+	if w.i.queue != nil && w.i.queue.Len() > 0 {
+		return w.i.enqueue(bp)
+	}
 	err := w.cli.Write(bp)
 	if err != nil {
 		w.i.writeErrors.Add(1)
+		if w.i.queue != nil && retryableWriteError(err) {
+			if qerr := w.i.enqueue(bp); qerr != nil {
+				return errors.Wrapf(qerr, "failed to queue write after %v", err)
+			}
+			return nil
+		}
 		return err
 	}
 	w.i.pointsWritten.Add(int64(len(bp.Points())))
@@ -14,6 +14,9 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"text/template"
@@ -41,6 +44,10 @@ import (
 	"github.com/influxdata/kapacitor/services/httppost/httpposttest"
 	k8s "github.com/influxdata/kapacitor/services/k8s/client"
 	"github.com/influxdata/kapacitor/services/k8s/k8stest"
+	"github.com/influxdata/kapacitor/services/kafka"
+	"github.com/influxdata/kapacitor/services/kafka/kafkatest"
+	"github.com/influxdata/kapacitor/services/mqtt"
+	"github.com/influxdata/kapacitor/services/mqtt/mqtttest"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/opsgenie/opsgenietest"
 	"github.com/influxdata/kapacitor/services/pagerduty"
@@ -2420,6 +2427,64 @@ stream
 	}
 }
 
+func TestStream_HttpPost_DiskQueue(t *testing.T) {
+	var requests int32
+	posted := make(chan float64, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The endpoint is unavailable for the first two posts
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		result := models.Result{}
+		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+			t.Error(err)
+			return
+		}
+		posted <- result.Series[0].Values[0][1].(float64)
+	}))
+	defer ts.Close()
+
+	var script = `
+stream
+	|from()
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+		.groupBy('host')
+	|httpPost('` + ts.URL + `')
+		.diskQueue()
+		.diskQueueRetryInterval(10ms)
+`
+
+	dir, err := ioutil.TempDir("", "TestStream_HttpPost_DiskQueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmInit := func(tm *kapacitor.TaskMaster) {
+		tm.DiskQueueDir = dir
+	}
+	clock, et, replayErr, tm := testStreamer(t, "TestStream_HttpPost", script, tmInit)
+	defer tm.Close()
+	if err := fastForwardTask(clock, et, replayErr, tm, 13*time.Second); err != nil {
+		t.Error(err)
+	}
+
+	// All posts arrive in order once the endpoint is available
+	exp := []float64{97.1, 92.6, 95.6, 93.1, 92.6, 95.8}
+	for i := range exp {
+		select {
+		case got := <-posted:
+			if got != exp[i] {
+				t.Errorf("unexpected value of post %d got %v exp %v", i, got, exp[i])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d posts, got %d", len(exp), i)
+		}
+	}
+}
+
 func TestStream_HttpPostEndpoint(t *testing.T) {
 	headers := map[string]string{"my": "header"}
 	requestCount := int32(0)
@@ -9084,6 +9149,46 @@ stream
 }
 
 func TestStream_Autoscale(t *testing.T) {
+	// The scaling API of the httpAutoscale node, its setup sets the channel of the updates.
+	type httpScaleUpdate struct {
+		Name     string
+		Replicas int
+	}
+	var httpUpdatesMu sync.Mutex
+	var httpUpdates chan httpScaleUpdate
+	httpScaler := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/services/")
+		switch r.Method {
+		case http.MethodGet:
+			var replicas int
+			switch name {
+			case "serviceA":
+				replicas = 1
+			case "serviceB":
+				replicas = 10
+			}
+			fmt.Fprintf(w, `{"name":%q,"spec":{"replicas":%d}}`, name, replicas)
+		case http.MethodPut:
+			var body struct {
+				Replicas int `json:"replicas"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			httpUpdatesMu.Lock()
+			httpUpdates <- httpScaleUpdate{Name: name, Replicas: body.Replicas}
+			httpUpdatesMu.Unlock()
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer httpScaler.Close()
+
 	testCases := map[string]struct {
 		script           string
 		result           models.Result
@@ -9103,11 +9208,14 @@ func TestStream_Autoscale(t *testing.T) {
 							"kind":       "deployments",
 							"resource":   "serviceA",
 						},
-						Columns: []string{"time", "new", "old"},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
 						Values: [][]interface{}{[]interface{}{
 							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
 							2.0,
 							1000.0,
+							"replicas expression evaluated to 2",
 						}},
 					},
 					{
@@ -9118,11 +9226,14 @@ func TestStream_Autoscale(t *testing.T) {
 							"kind":       "deployments",
 							"resource":   "serviceB",
 						},
-						Columns: []string{"time", "new", "old"},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
 						Values: [][]interface{}{[]interface{}{
 							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
 							20.0,
 							1000.0,
+							"replicas expression evaluated to 20",
 						}},
 					},
 				},
@@ -9137,11 +9248,14 @@ func TestStream_Autoscale(t *testing.T) {
 							"kind":       "deployments",
 							"resource":   "serviceA",
 						},
-						Columns: []string{"time", "new", "old"},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
 						Values: [][]interface{}{[]interface{}{
 							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
 							3.0,
 							500.0,
+							"replicas expression evaluated to 2, limited to min 3",
 						}},
 					},
 					{
@@ -9152,11 +9266,14 @@ func TestStream_Autoscale(t *testing.T) {
 							"kind":       "deployments",
 							"resource":   "serviceB",
 						},
-						Columns: []string{"time", "new", "old"},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
 						Values: [][]interface{}{[]interface{}{
 							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
 							20.0,
 							500.0,
+							"replicas expression evaluated to 20",
 						}},
 					},
 				},
@@ -9208,11 +9325,14 @@ func TestStream_Autoscale(t *testing.T) {
 						Tags: map[string]string{
 							"deployment": "serviceA",
 						},
-						Columns: []string{"time", "new", "old"},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
 						Values: [][]interface{}{[]interface{}{
 							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
 							2.0,
 							1000.0,
+							"replicas expression evaluated to 2",
 						}},
 					},
 					{
@@ -9220,11 +9340,14 @@ func TestStream_Autoscale(t *testing.T) {
 						Tags: map[string]string{
 							"deployment": "serviceB",
 						},
-						Columns: []string{"time", "new", "old"},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
 						Values: [][]interface{}{[]interface{}{
 							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
 							20.0,
 							1000.0,
+							"replicas expression evaluated to 20",
 						}},
 					},
 				},
@@ -9236,11 +9359,14 @@ func TestStream_Autoscale(t *testing.T) {
 						Tags: map[string]string{
 							"deployment": "serviceA",
 						},
-						Columns: []string{"time", "new", "old"},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
 						Values: [][]interface{}{[]interface{}{
 							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
 							3.0,
 							500.0,
+							"replicas expression evaluated to 2, limited to min 3",
 						}},
 					},
 					{
@@ -9248,11 +9374,14 @@ func TestStream_Autoscale(t *testing.T) {
 						Tags: map[string]string{
 							"deployment": "serviceB",
 						},
-						Columns: []string{"time", "new", "old"},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
 						Values: [][]interface{}{[]interface{}{
 							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
 							20.0,
 							500.0,
+							"replicas expression evaluated to 20",
 						}},
 					},
 				},
@@ -9297,6 +9426,97 @@ func TestStream_Autoscale(t *testing.T) {
 				return updatesByService
 			},
 		},
+		"httpAutoscale": {
+			script: `|httpAutoscale()
+		.resourceNameTag('deployment')
+		.getURL('` + httpScaler.URL + `/services/{{.Name}}')
+		.replicasPath('spec.replicas')
+		.header('Authorization', 'Bearer token')`,
+			result: models.Result{
+				Series: models.Rows{
+					{
+						Name: "scale",
+						Tags: map[string]string{
+							"deployment": "serviceA",
+						},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
+						Values: [][]interface{}{[]interface{}{
+							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
+							2.0,
+							1000.0,
+							"replicas expression evaluated to 2",
+						}},
+					},
+					{
+						Name: "scale",
+						Tags: map[string]string{
+							"deployment": "serviceB",
+						},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
+						Values: [][]interface{}{[]interface{}{
+							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
+							20.0,
+							1000.0,
+							"replicas expression evaluated to 20",
+						}},
+					},
+				},
+			},
+			minMaxResult: models.Result{
+				Series: models.Rows{
+					{
+						Name: "scale",
+						Tags: map[string]string{
+							"deployment": "serviceA",
+						},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
+						Values: [][]interface{}{[]interface{}{
+							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
+							3.0,
+							500.0,
+							"replicas expression evaluated to 2, limited to min 3",
+						}},
+					},
+					{
+						Name: "scale",
+						Tags: map[string]string{
+							"deployment": "serviceB",
+						},
+						Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
+						Values: [][]interface{}{[]interface{}{
+							time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+							false,
+							false,
+							20.0,
+							500.0,
+							"replicas expression evaluated to 20",
+						}},
+					},
+				},
+			},
+			setup: func(tm *kapacitor.TaskMaster) context.Context {
+				updates := make(chan httpScaleUpdate, 100)
+				httpUpdatesMu.Lock()
+				httpUpdates = updates
+				httpUpdatesMu.Unlock()
+				return context.WithValue(nil, "updates", updates)
+			},
+			updatesByService: func(ctx context.Context) map[string][]int {
+				updates := ctx.Value("updates").(chan httpScaleUpdate)
+				close(updates)
+				updatesByService := make(map[string][]int)
+				for u := range updates {
+					updatesByService[u.Name] = append(updatesByService[u.Name], u.Replicas)
+				}
+				return updatesByService
+			},
+		},
 	}
 	expUpdatesByService := map[string][]int{
 		"serviceA": []int{2, 1, 1000, 2},
@@ -9362,6 +9582,262 @@ stream
 	}
 }
 
+func TestStream_AutoscaleDryRun(t *testing.T) {
+	var script = `
+stream
+	|from()
+		.measurement('scale')
+		.groupBy('deployment')
+	|k8sAutoscale()
+		.resourceNameTag('deployment')
+		.replicas(lambda: int("replicas"))
+		.dryRun()
+	|httpOut('TestStream_Autoscale')
+`
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name: "scale",
+				Tags: map[string]string{
+					"deployment": "serviceA",
+					"namespace":  "default",
+					"kind":       "deployments",
+					"resource":   "serviceA",
+				},
+				Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+					false,
+					true,
+					2.0,
+					1000.0,
+					"replicas expression evaluated to 2",
+				}},
+			},
+			{
+				Name: "scale",
+				Tags: map[string]string{
+					"deployment": "serviceB",
+					"namespace":  "default",
+					"kind":       "deployments",
+					"resource":   "serviceB",
+				},
+				Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+					false,
+					true,
+					20.0,
+					1000.0,
+					"replicas expression evaluated to 20",
+				}},
+			},
+		},
+	}
+
+	var updates int32
+	tmInit := func(tm *kapacitor.TaskMaster) {
+		k8sAutoscale := k8stest.Client{}
+		k8sAutoscale.ScalesGetFunc = func(kind, name string) (*k8s.Scale, error) {
+			var replicas int32
+			switch name {
+			case "serviceA":
+				replicas = 1
+			case "serviceB":
+				replicas = 10
+			}
+			return &k8s.Scale{
+				ObjectMeta: k8s.ObjectMeta{
+					Name: name,
+				},
+				Spec: k8s.ScaleSpec{
+					Replicas: replicas,
+				},
+			}, nil
+		}
+		k8sAutoscale.ScalesUpdateFunc = func(kind string, scale *k8s.Scale) error {
+			atomic.AddInt32(&updates, 1)
+			return nil
+		}
+		tm.K8sService = k8sAutoscale
+	}
+
+	testStreamerWithOutput(t, "TestStream_Autoscale", script, 13*time.Second, er, false, tmInit)
+
+	if got := atomic.LoadInt32(&updates); got != 0 {
+		t.Errorf("unexpected updates in dry run mode, got %d", got)
+	}
+}
+
+func TestStream_AutoscaleHistory(t *testing.T) {
+	var script = `
+stream
+	|from()
+		.measurement('scale')
+		.groupBy('deployment')
+	|k8sAutoscale()
+		.resourceNameTag('deployment')
+		.replicas(lambda: int("replicas"))
+		.dryRun()
+		.historySize(2)
+`
+	tmInit := func(tm *kapacitor.TaskMaster) {
+		k8sAutoscale := k8stest.Client{}
+		k8sAutoscale.ScalesGetFunc = func(kind, name string) (*k8s.Scale, error) {
+			return &k8s.Scale{
+				ObjectMeta: k8s.ObjectMeta{
+					Name: name,
+				},
+				Spec: k8s.ScaleSpec{
+					Replicas: 1,
+				},
+			}, nil
+		}
+		tm.K8sService = k8sAutoscale
+	}
+
+	clock, et, replayErr, tm := testStreamer(t, "TestStream_Autoscale", script, tmInit)
+	defer tm.Close()
+	if err := fastForwardTask(clock, et, replayErr, tm, 13*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(tm.HTTPDService.URL() + "/tasks/TestStream_Autoscale/k8s_autoscale2/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, exp := resp.StatusCode, http.StatusOK; got != exp {
+		t.Fatalf("unexpected status code got %d exp %d", got, exp)
+	}
+	var got interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// Only the two most recent of the decisions are kept
+	var exp interface{}
+	if err := json.Unmarshal([]byte(`{"decisions":[
+		{"time":"1971-01-01T00:00:04Z","resource":"serviceA","old":1000,"new":2,"reason":"replicas expression evaluated to 2","cooldown":false,"dry-run":true},
+		{"time":"1971-01-01T00:00:04Z","resource":"serviceB","old":1000,"new":20,"reason":"replicas expression evaluated to 20","cooldown":false,"dry-run":true}
+	]}`), &exp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected history:\ngot\n%v\nexp\n%v", got, exp)
+	}
+
+	resp, err = http.Get(tm.HTTPDService.URL() + "/tasks/TestStream_Autoscale/k8s_autoscale9/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, exp := resp.StatusCode, http.StatusNotFound; got != exp {
+		t.Errorf("unexpected status code for unknown node got %d exp %d", got, exp)
+	}
+}
+
+func TestStream_K8sAutoscaleStabilization(t *testing.T) {
+	var script = `
+stream
+	|from()
+		.measurement('scale')
+		.groupBy('deployment')
+	|k8sAutoscale()
+		.kind('statefulsets')
+		.resourceNameTag('deployment')
+		.replicas(lambda: int("replicas"))
+		.decreaseStabilizationWindow(10s)
+		.maxIncreaseStep(5)
+	|httpOut('TestStream_Autoscale')
+`
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name: "scale",
+				Tags: map[string]string{
+					"deployment": "serviceA",
+					"namespace":  "default",
+					"kind":       "statefulsets",
+					"resource":   "serviceA",
+				},
+				Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 3, 0, time.UTC),
+					false,
+					false,
+					7.0,
+					2.0,
+					"replicas expression evaluated to 1000, limited to increase step 5",
+				}},
+			},
+			{
+				Name: "scale",
+				Tags: map[string]string{
+					"deployment": "serviceB",
+					"namespace":  "default",
+					"kind":       "statefulsets",
+					"resource":   "serviceB",
+				},
+				Columns: []string{"time", "cooldown", "dry_run", "new", "old", "reason"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 3, 0, time.UTC),
+					false,
+					false,
+					20.0,
+					15.0,
+					"replicas expression evaluated to 1000, limited to increase step 5",
+				}},
+			},
+		},
+	}
+
+	scaleUpdates := make(chan k8s.Scale, 100)
+	tmInit := func(tm *kapacitor.TaskMaster) {
+		k8sAutoscale := k8stest.Client{}
+		k8sAutoscale.ScalesGetFunc = func(kind, name string) (*k8s.Scale, error) {
+			if kind != k8s.StatefulSetsKind {
+				return nil, fmt.Errorf("unexpected kind %q", kind)
+			}
+			var replicas int32
+			switch name {
+			case "serviceA":
+				replicas = 1
+			case "serviceB":
+				replicas = 10
+			}
+			return &k8s.Scale{
+				ObjectMeta: k8s.ObjectMeta{
+					Name: name,
+				},
+				Spec: k8s.ScaleSpec{
+					Replicas: replicas,
+				},
+			}, nil
+		}
+		k8sAutoscale.ScalesUpdateFunc = func(kind string, scale *k8s.Scale) error {
+			scaleUpdates <- *scale
+			return nil
+		}
+		tm.K8sService = k8sAutoscale
+	}
+
+	testStreamerWithOutput(t, "TestStream_Autoscale", script, 13*time.Second, er, false, tmInit)
+
+	close(scaleUpdates)
+	updatesByService := make(map[string][]int)
+	for scale := range scaleUpdates {
+		updatesByService[scale.Name] = append(updatesByService[scale.Name], int(scale.Spec.Replicas))
+	}
+	// Decreases are held back by the stabilization window and increases are limited to steps of 5.
+	expUpdatesByService := map[string][]int{
+		"serviceA": []int{2, 7},
+		"serviceB": []int{15, 20},
+	}
+	if !reflect.DeepEqual(updatesByService, expUpdatesByService) {
+		t.Errorf("unexpected updates\ngot\n%v\nexp\n%v\n", updatesByService, expUpdatesByService)
+	}
+}
+
 func TestStream_KapacitorLoopback_PreventLoop(t *testing.T) {
 
 	var script = `
@@ -9738,6 +10214,201 @@ stream
 		}
 	}
 }
+func TestStream_InfluxDBOut_DiskQueue(t *testing.T) {
+
+	var script = `
+stream
+	|from()
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+	|window()
+		.period(10s)
+		.every(10s)
+	|count('value')
+	|influxDBOut()
+		.database('db')
+		.retentionPolicy('rp')
+		.measurement('m')
+		.precision('s')
+		.flushInterval(1ms)
+		.diskQueue()
+		.diskQueueRetryInterval(10ms)
+`
+	written := make(chan []imodels.Point, 1)
+	var requests int32
+
+	influxdb := NewMockInfluxDBService(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// InfluxDB is unavailable for the first two writes
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"unavailable"}`))
+			return
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		points, err := imodels.ParsePointsWithPrecision(b, time.Unix(0, 0), r.URL.Query().Get("precision"))
+		if err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		written <- points
+	}))
+
+	dir, err := ioutil.TempDir("", "TestStream_InfluxDBOut_DiskQueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmInit := func(tm *kapacitor.TaskMaster) {
+		tm.InfluxDBService = influxdb
+		tm.DiskQueueDir = dir
+	}
+	clock, et, replayErr, tm := testStreamer(t, "TestStream_InfluxDBOut", script, tmInit)
+	defer tm.Close()
+	if err := fastForwardTask(clock, et, replayErr, tm, 15*time.Second); err != nil {
+		t.Error(err)
+	}
+
+	// The write is replayed from disk once InfluxDB is available
+	select {
+	case points := <-written:
+		if len(points) != 1 {
+			t.Fatalf("got %v exp %v", len(points), 1)
+		}
+		if p := points[0]; p.Fields()["count"] != int64(10) {
+			t.Errorf("got %v exp %v", p.Fields()["count"], 10)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected queued write to be replayed")
+	}
+}
+
+func TestStream_MQTTOut(t *testing.T) {
+
+	var script = `
+var counts = stream
+	|from()
+		.measurement('cpu')
+		.where(lambda: "host" != 'serverC')
+		.groupBy('host')
+	|window()
+		.period(10s)
+		.every(10s)
+	|count('value')
+
+counts
+	|mqttOut()
+		.topic('hosts/{{.Tags.host}}/count')
+		.qos(1)
+
+counts
+	|mqttOut()
+		.brokerName('test')
+		.topic('{{.TaskName}}/{{.Name}}')
+		.retained(TRUE)
+		.format('json')
+`
+	cc := new(mqtttest.ClientCreator)
+	ms, err := mqtt.NewService(mqtt.Configs{{
+		Enabled:    true,
+		Name:       "test",
+		URL:        "tcp://mqtt.example.com:1883",
+		NewClientF: cc.NewClient,
+	}}, diagService.NewMQTTHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Close()
+
+	tmInit := func(tm *kapacitor.TaskMaster) {
+		tm.MQTTService = ms
+	}
+	testStreamerNoOutput(t, "TestStream_InfluxDBOut", script, 15*time.Second, tmInit)
+
+	got := cc.Clients[0].PublishData
+	sort.Slice(got, func(i, j int) bool {
+		return got[i].Topic+string(got[i].Message) < got[j].Topic+string(got[j].Message)
+	})
+	exp := []mqtttest.PublishData{
+		{
+			Topic:    "TestStream_InfluxDBOut/cpu",
+			QoS:      mqtt.AtMostOnce,
+			Retained: true,
+			Message:  []byte(`{"name":"cpu","time":"1971-01-01T00:00:10Z","tags":{"host":"serverA"},"fields":{"count":10}}`),
+		},
+		{
+			Topic:    "TestStream_InfluxDBOut/cpu",
+			QoS:      mqtt.AtMostOnce,
+			Retained: true,
+			Message:  []byte(`{"name":"cpu","time":"1971-01-01T00:00:10Z","tags":{"host":"serverB"},"fields":{"count":10}}`),
+		},
+		{
+			Topic:   "hosts/serverA/count",
+			QoS:     mqtt.AtLeastOnce,
+			Message: []byte("cpu,host=serverA count=10i 31536010000000000"),
+		},
+		{
+			Topic:   "hosts/serverB/count",
+			QoS:     mqtt.AtLeastOnce,
+			Message: []byte("cpu,host=serverB count=10i 31536010000000000"),
+		},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected publish data:\ngot\n%+v\nexp\n%+v", got, exp)
+	}
+}
+
+func TestStream_KafkaOut(t *testing.T) {
+
+	var script = `
+stream
+	|from()
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+	|window()
+		.period(10s)
+		.every(10s)
+	|count('value')
+	|kafkaOut()
+		.cluster('test')
+		.topic('counts')
+`
+	pc := new(kafkatest.ProducerCreator)
+	c := kafka.NewConfig()
+	c.Enabled = true
+	c.ID = "test"
+	c.Brokers = []string{"kafka.example.com:9092"}
+	c.NewProducerF = pc.NewProducer
+	ks := kafka.NewService(kafka.Configs{c}, diagService.NewKafkaHandler())
+	if err := ks.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer ks.Close()
+
+	tmInit := func(tm *kapacitor.TaskMaster) {
+		tm.KafkaService = ks
+	}
+	testStreamerNoOutput(t, "TestStream_InfluxDBOut", script, 15*time.Second, tmInit)
+
+	exp := []kafkatest.Message{{
+		Topic: "counts",
+		Value: "cpu count=10i 31536010000000000",
+	}}
+	if got := pc.Messages(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected messages:\ngot\n%+v\nexp\n%+v", got, exp)
+	}
+}
+
 func TestStream_InfluxDBOut_CreateDatabase(t *testing.T) {
 
 	var script = `
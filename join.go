@@ -111,6 +111,9 @@ type srcPoint struct {
 }
 
 func (n *JoinNode) doMessage(src int, m messageMeta) error {
+	if ok, err := n.et.limiter.allowBuffering(); !ok {
+		return err
+	}
 	n.timer.Start()
 	defer n.timer.Stop()
 	if len(n.j.Dimensions) > 0 {
@@ -178,6 +181,7 @@ func (n *JoinNode) matchPoints(p srcPoint) {
 			}
 		}
 		// Remove all sent points.
+		n.releasePoints(buf[:i])
 		n.specificGroupsBuffer[groupId] = buf[i:]
 	}
 
@@ -208,6 +212,7 @@ func (n *JoinNode) matchPoints(p srcPoint) {
 		if n.allReported {
 			// Can't trust lowMark until all parents have reported.
 			// Remove any unneeded match points.
+			n.releasePoints(matches[:i])
 			n.matchGroupsBuffer[groupId] = matches[i:]
 		}
 
@@ -220,12 +225,12 @@ func (n *JoinNode) matchPoints(p srcPoint) {
 			} else {
 				// Option 2
 				// Cache this point for when its match arrives.
-				n.specificGroupsBuffer[groupId] = append(n.specificGroupsBuffer[groupId], p)
+				n.specificGroupsBuffer[groupId] = n.bufferPoint(n.specificGroupsBuffer[groupId], p)
 			}
 		}
 	} else {
 		// Cache match point.
-		n.matchGroupsBuffer[groupId] = append(n.matchGroupsBuffer[groupId], p)
+		n.matchGroupsBuffer[groupId] = n.bufferPoint(n.matchGroupsBuffer[groupId], p)
 
 		// Send all specific points that match, to the group.
 		var i int
@@ -240,10 +245,26 @@ func (n *JoinNode) matchPoints(p srcPoint) {
 			}
 		}
 		// Remove all sent points
+		n.releasePoints(buf[:i])
 		n.specificGroupsBuffer[groupId] = buf[i:]
 	}
 }
 
+// bufferPoint caches the point in buf and accounts for it in the buffered points of the task.
+func (n *JoinNode) bufferPoint(buf []srcPoint, p srcPoint) []srcPoint {
+	n.et.limiter.addBufferedPoints(pointCount(p.Msg))
+	return append(buf, p)
+}
+
+// releasePoints accounts for cached points that were removed from a buffer.
+func (n *JoinNode) releasePoints(removed []srcPoint) {
+	var count int64
+	for _, p := range removed {
+		count += pointCount(p.Msg)
+	}
+	n.et.limiter.addBufferedPoints(-count)
+}
+
 // Add the specific tags from the specific point to the matched point
 // and then send both on to the group.
 func (n *JoinNode) sendMatchPoint(specific, matched srcPoint) {
@@ -375,6 +396,7 @@ func (g *joinGroup) emit(onlyReadySets bool) error {
 			if err != nil {
 				return err
 			}
+			g.n.et.limiter.addBufferedPoints(-sets[i].points)
 		} else {
 			break
 		}
@@ -458,6 +480,8 @@ type joinset struct {
 	expected int
 	size     int
 	finished int
+	// Number of points held by the values
+	points int64
 
 	first int
 
@@ -507,6 +531,8 @@ func (js *joinset) Set(i int, v edge.Message) {
 	}
 	js.values[i] = v
 	js.size++
+	js.points += pointCount(v)
+	js.j.et.limiter.addBufferedPoints(pointCount(v))
 }
 
 // a valid point in the set
@@ -0,0 +1,137 @@
+package kapacitor
+
+import (
+	"time"
+
+	imodels "github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/services/kafka"
+	"github.com/pkg/errors"
+)
+
+const (
+	statsKafkaMessagesWritten = "messages_written"
+	statsKafkaWriteErrors     = "write_errors"
+)
+
+type KafkaOutNode struct {
+	node
+	k *pipeline.KafkaOutNode
+
+	messagesWritten *expvar.Int
+	writeErrors     *expvar.Int
+
+	batchBuffer *edge.BatchBuffer
+}
+
+func newKafkaOutNode(et *ExecutingTask, n *pipeline.KafkaOutNode, d NodeDiagnostic) (*KafkaOutNode, error) {
+	if et.tm.KafkaService == nil {
+		return nil, errors.New("kafka service is not available")
+	}
+	kn := &KafkaOutNode{
+		node:        node{Node: n, et: et, diag: d},
+		k:           n,
+		batchBuffer: new(edge.BatchBuffer),
+	}
+	kn.node.runF = kn.runOut
+	return kn, nil
+}
+
+func (n *KafkaOutNode) runOut([]byte) error {
+	n.messagesWritten = &expvar.Int{}
+	n.writeErrors = &expvar.Int{}
+	n.statMap.Set(statsKafkaMessagesWritten, n.messagesWritten)
+	n.statMap.Set(statsKafkaWriteErrors, n.writeErrors)
+
+	consumer := edge.NewConsumerWithReceiver(
+		n.ins[0],
+		n,
+	)
+	return consumer.Consume()
+}
+
+func (n *KafkaOutNode) Point(p edge.PointMessage) error {
+	n.timer.Start()
+	defer n.timer.Stop()
+
+	m, err := newKafkaMessage(p.GroupID(), p.Name(), p.Tags(), p.Fields(), p.Time())
+	if err != nil {
+		n.diag.Error("failed to encode point", err)
+		return nil
+	}
+	n.write(m)
+	return nil
+}
+
+func (n *KafkaOutNode) BeginBatch(begin edge.BeginBatchMessage) error {
+	return n.batchBuffer.BeginBatch(begin)
+}
+
+func (n *KafkaOutNode) BatchPoint(bp edge.BatchPointMessage) error {
+	return n.batchBuffer.BatchPoint(bp)
+}
+
+func (n *KafkaOutNode) EndBatch(end edge.EndBatchMessage) error {
+	return n.BufferedBatch(n.batchBuffer.BufferedBatchMessage(end))
+}
+
+// BufferedBatch writes all points of the batch at once.
+func (n *KafkaOutNode) BufferedBatch(batch edge.BufferedBatchMessage) error {
+	n.timer.Start()
+	defer n.timer.Stop()
+
+	messages := make([]kafka.Message, 0, len(batch.Points()))
+	for _, bp := range batch.Points() {
+		m, err := newKafkaMessage(batch.GroupID(), batch.Name(), bp.Tags(), bp.Fields(), bp.Time())
+		if err != nil {
+			n.diag.Error("failed to encode point", err)
+			continue
+		}
+		messages = append(messages, m)
+	}
+	n.write(messages...)
+	return nil
+}
+
+func (n *KafkaOutNode) Barrier(edge.BarrierMessage) error {
+	return nil
+}
+
+func (n *KafkaOutNode) DeleteGroup(edge.DeleteGroupMessage) error {
+	return nil
+}
+
+func (n *KafkaOutNode) write(messages ...kafka.Message) {
+	if len(messages) == 0 {
+		return
+	}
+	n.timer.Pause()
+	err := n.et.tm.KafkaService.Write(n.k.Cluster, n.k.Topic, messages...)
+	n.timer.Resume()
+
+	if err != nil {
+		n.diag.Error("failed to write messages to kafka", err)
+		n.writeErrors.Add(1)
+		return
+	}
+	n.messagesWritten.Add(int64(len(messages)))
+}
+
+// newKafkaMessage returns a message holding the point in line protocol, keyed by its group.
+// Ungrouped points have no key and are distributed across all partitions.
+func newKafkaMessage(group models.GroupID, name string, tags models.Tags, fields models.Fields, t time.Time) (kafka.Message, error) {
+	p, err := imodels.NewPoint(name, imodels.NewTags(tags), imodels.Fields(fields), t)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	m := kafka.Message{
+		Value: []byte(p.String()),
+	}
+	if group != models.NilGroup {
+		m.Key = []byte(group)
+	}
+	return m, nil
+}
@@ -0,0 +1,218 @@
+package kapacitor
+
+import (
+	"bytes"
+	"encoding/json"
+	text "text/template"
+	"time"
+
+	imodels "github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/bufpool"
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/services/mqtt"
+	"github.com/pkg/errors"
+)
+
+const (
+	statsMQTTMessagesPublished = "messages_published"
+	statsMQTTPublishErrors     = "publish_errors"
+)
+
+type MQTTOutNode struct {
+	node
+	m *pipeline.MQTTOutNode
+
+	topicTmpl *text.Template
+	bp        *bufpool.Pool
+
+	messagesPublished *expvar.Int
+	publishErrors     *expvar.Int
+
+	batchBuffer *edge.BatchBuffer
+}
+
+func newMQTTOutNode(et *ExecutingTask, n *pipeline.MQTTOutNode, d NodeDiagnostic) (*MQTTOutNode, error) {
+	if et.tm.MQTTService == nil {
+		return nil, errors.New("mqtt service is not available")
+	}
+	tmpl, err := text.New("topic").Parse(n.Topic)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse topic template")
+	}
+	mn := &MQTTOutNode{
+		node:        node{Node: n, et: et, diag: d},
+		m:           n,
+		topicTmpl:   tmpl,
+		bp:          bufpool.New(),
+		batchBuffer: new(edge.BatchBuffer),
+	}
+	mn.node.runF = mn.runOut
+	return mn, nil
+}
+
+func (n *MQTTOutNode) runOut([]byte) error {
+	n.messagesPublished = &expvar.Int{}
+	n.publishErrors = &expvar.Int{}
+	n.statMap.Set(statsMQTTMessagesPublished, n.messagesPublished)
+	n.statMap.Set(statsMQTTPublishErrors, n.publishErrors)
+
+	consumer := edge.NewConsumerWithReceiver(
+		n.ins[0],
+		n,
+	)
+	return consumer.Consume()
+}
+
+func (n *MQTTOutNode) Point(p edge.PointMessage) error {
+	n.timer.Start()
+	defer n.timer.Stop()
+
+	topic, err := n.renderTopic(p.Name(), p.GroupID(), p.Tags())
+	if err != nil {
+		n.diag.Error("failed to render topic", err)
+		return nil
+	}
+	// The message is not pooled, since the client may keep it to redeliver it.
+	buf := new(bytes.Buffer)
+	switch n.m.Format {
+	case pipeline.MQTTOutFormatJSON:
+		err = json.NewEncoder(buf).Encode(newMQTTOutPoint(p.Name(), p.Tags(), p.Fields(), p.Time()))
+	default:
+		err = writeLineProtocol(buf, p.Name(), p.Tags(), p.Fields(), p.Time())
+	}
+	if err != nil {
+		n.diag.Error("failed to encode point", err)
+		return nil
+	}
+	n.publish(topic, buf.Bytes())
+	return nil
+}
+
+func (n *MQTTOutNode) BeginBatch(begin edge.BeginBatchMessage) error {
+	return n.batchBuffer.BeginBatch(begin)
+}
+
+func (n *MQTTOutNode) BatchPoint(bp edge.BatchPointMessage) error {
+	return n.batchBuffer.BatchPoint(bp)
+}
+
+func (n *MQTTOutNode) EndBatch(end edge.EndBatchMessage) error {
+	return n.BufferedBatch(n.batchBuffer.BufferedBatchMessage(end))
+}
+
+// BufferedBatch publishes all points of the batch as a single message.
+func (n *MQTTOutNode) BufferedBatch(batch edge.BufferedBatchMessage) error {
+	n.timer.Start()
+	defer n.timer.Stop()
+
+	if len(batch.Points()) == 0 {
+		return nil
+	}
+	topic, err := n.renderTopic(batch.Name(), batch.GroupID(), batch.Tags())
+	if err != nil {
+		n.diag.Error("failed to render topic", err)
+		return nil
+	}
+	// The message is not pooled, since the client may keep it to redeliver it.
+	buf := new(bytes.Buffer)
+	switch n.m.Format {
+	case pipeline.MQTTOutFormatJSON:
+		points := make([]mqttOutPoint, len(batch.Points()))
+		for i, bp := range batch.Points() {
+			points[i] = newMQTTOutPoint(batch.Name(), bp.Tags(), bp.Fields(), bp.Time())
+		}
+		err = json.NewEncoder(buf).Encode(points)
+	default:
+		for _, bp := range batch.Points() {
+			if err = writeLineProtocol(buf, batch.Name(), bp.Tags(), bp.Fields(), bp.Time()); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		n.diag.Error("failed to encode batch", err)
+		return nil
+	}
+	n.publish(topic, buf.Bytes())
+	return nil
+}
+
+func (n *MQTTOutNode) Barrier(edge.BarrierMessage) error {
+	return nil
+}
+
+func (n *MQTTOutNode) DeleteGroup(edge.DeleteGroupMessage) error {
+	return nil
+}
+
+func (n *MQTTOutNode) renderTopic(name string, group models.GroupID, tags models.Tags) (string, error) {
+	g := string(group)
+	if group == models.NilGroup {
+		g = "nil"
+	}
+	info := struct {
+		Name     string
+		TaskName string
+		Group    string
+		Tags     map[string]string
+	}{
+		Name:     name,
+		TaskName: n.et.Task.ID,
+		Group:    g,
+		Tags:     tags,
+	}
+	buf := n.bp.Get()
+	defer n.bp.Put(buf)
+	if err := n.topicTmpl.Execute(buf, info); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (n *MQTTOutNode) publish(topic string, message []byte) {
+	// Trailing newlines are written by the encoders, they are not part of the message.
+	if l := len(message); l > 0 && message[l-1] == '\n' {
+		message = message[:l-1]
+	}
+	n.timer.Pause()
+	err := n.et.tm.MQTTService.Publish(n.m.BrokerName, topic, mqtt.QoSLevel(n.m.Qos), n.m.Retained, message)
+	n.timer.Resume()
+
+	if err != nil {
+		n.diag.Error("failed to publish message to mqtt", err)
+		n.publishErrors.Add(1)
+		return
+	}
+	n.messagesPublished.Add(1)
+}
+
+// mqttOutPoint is the JSON representation of a published point.
+type mqttOutPoint struct {
+	Name   string        `json:"name"`
+	Time   time.Time     `json:"time"`
+	Tags   models.Tags   `json:"tags,omitempty"`
+	Fields models.Fields `json:"fields"`
+}
+
+func newMQTTOutPoint(name string, tags models.Tags, fields models.Fields, t time.Time) mqttOutPoint {
+	return mqttOutPoint{
+		Name:   name,
+		Time:   t.UTC(),
+		Tags:   tags,
+		Fields: fields,
+	}
+}
+
+// writeLineProtocol writes the point in line protocol followed by a newline.
+func writeLineProtocol(buf *bytes.Buffer, name string, tags models.Tags, fields models.Fields, t time.Time) error {
+	p, err := imodels.NewPoint(name, imodels.NewTags(tags), imodels.Fields(fields), t)
+	if err != nil {
+		return err
+	}
+	buf.WriteString(p.String())
+	buf.WriteByte('\n')
+	return nil
+}
@@ -0,0 +1,89 @@
+// Package namespace partitions the IDs of tasks, templates, recordings and alert topics.
+//
+// A namespaced ID has the form <namespace>/<name>.
+// IDs without a namespace belong to the default namespace, which has the empty name.
+package namespace
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Separator separates the namespace from the name of an ID.
+const Separator = "/"
+
+var validName = regexp.MustCompile(`^[-\._\p{L}0-9]+$`)
+
+// Valid reports whether ns is a valid name of a namespace.
+func Valid(ns string) bool {
+	return validName.MatchString(ns) && ns != "." && ns != ".."
+}
+
+// Validate returns an error if ns is neither the default namespace nor a valid namespace name.
+func Validate(ns string) error {
+	if ns != "" && !Valid(ns) {
+		return fmt.Errorf("invalid namespace %q, must contain only letters, numbers, '-', '.' and '_'", ns)
+	}
+	return nil
+}
+
+// Split returns the namespace and the name of the ID.
+func Split(id string) (ns, name string) {
+	if i := strings.Index(id, Separator); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+	return "", id
+}
+
+// Of returns the namespace of the ID.
+func Of(id string) string {
+	ns, _ := Split(id)
+	return ns
+}
+
+// Join returns the ID of name within the namespace.
+func Join(ns, name string) string {
+	if ns == "" {
+		return name
+	}
+	return ns + Separator + name
+}
+
+// ValidID reports whether id is a valid, optionally namespaced, ID
+// whose name matches validName.
+func ValidID(id string, validName *regexp.Regexp) bool {
+	i := strings.Index(id, Separator)
+	if i < 0 {
+		return validName.MatchString(id)
+	}
+	return Valid(id[:i]) && validName.MatchString(id[i+1:])
+}
+
+// Qualify returns the ID within the namespace ns.
+// Unqualified IDs are moved into ns, qualified IDs must already be within ns.
+// IDs are returned unchanged for the default namespace.
+func Qualify(ns, id string) (string, error) {
+	if ns == "" {
+		return id, nil
+	}
+	if !strings.Contains(id, Separator) {
+		return Join(ns, id), nil
+	}
+	if Of(id) != ns {
+		return "", fmt.Errorf("ID %q is not in namespace %q", id, ns)
+	}
+	return id, nil
+}
+
+// Pattern returns the list pattern that matches pattern within the namespace ns.
+// An empty pattern matches all IDs within ns.
+func Pattern(ns, pattern string) string {
+	if ns == "" {
+		return pattern
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	return Join(ns, pattern)
+}
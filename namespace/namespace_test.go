@@ -0,0 +1,96 @@
+package namespace_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/influxdata/kapacitor/namespace"
+)
+
+func TestValid(t *testing.T) {
+	testCases := []struct {
+		ns    string
+		valid bool
+	}{
+		{ns: "team-a", valid: true},
+		{ns: "team_a.prod", valid: true},
+		{ns: "", valid: false},
+		{ns: ".", valid: false},
+		{ns: "..", valid: false},
+		{ns: "team/a", valid: false},
+		{ns: "team*", valid: false},
+	}
+	for _, tc := range testCases {
+		if got := namespace.Valid(tc.ns); got != tc.valid {
+			t.Errorf("unexpected validity of %q got %v exp %v", tc.ns, got, tc.valid)
+		}
+	}
+}
+
+func TestValidID(t *testing.T) {
+	validName := regexp.MustCompile(`^[-\._\p{L}0-9]+$`)
+	testCases := []struct {
+		id    string
+		valid bool
+	}{
+		{id: "cpu", valid: true},
+		{id: "team-a/cpu", valid: true},
+		{id: "/cpu", valid: false},
+		{id: "team-a/", valid: false},
+		{id: "team-a/b/cpu", valid: false},
+		{id: "../cpu", valid: false},
+	}
+	for _, tc := range testCases {
+		if got := namespace.ValidID(tc.id, validName); got != tc.valid {
+			t.Errorf("unexpected validity of %q got %v exp %v", tc.id, got, tc.valid)
+		}
+	}
+}
+
+func TestQualify(t *testing.T) {
+	testCases := []struct {
+		ns    string
+		id    string
+		exp   string
+		fails bool
+	}{
+		{ns: "", id: "cpu", exp: "cpu"},
+		{ns: "", id: "team-a/cpu", exp: "team-a/cpu"},
+		{ns: "team-a", id: "cpu", exp: "team-a/cpu"},
+		{ns: "team-a", id: "team-a/cpu", exp: "team-a/cpu"},
+		{ns: "team-a", id: "team-b/cpu", fails: true},
+	}
+	for _, tc := range testCases {
+		got, err := namespace.Qualify(tc.ns, tc.id)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("expected error qualifying %q in namespace %q", tc.id, tc.ns)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.exp {
+			t.Errorf("unexpected ID qualifying %q in namespace %q got %q exp %q", tc.id, tc.ns, got, tc.exp)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	testCases := []struct {
+		ns      string
+		pattern string
+		exp     string
+	}{
+		{ns: "", pattern: "", exp: ""},
+		{ns: "", pattern: "cpu*", exp: "cpu*"},
+		{ns: "team-a", pattern: "", exp: "team-a/*"},
+		{ns: "team-a", pattern: "cpu*", exp: "team-a/cpu*"},
+	}
+	for _, tc := range testCases {
+		if got := namespace.Pattern(tc.ns, tc.pattern); got != tc.exp {
+			t.Errorf("unexpected pattern for %q in namespace %q got %q exp %q", tc.pattern, tc.ns, got, tc.exp)
+		}
+	}
+}
@@ -100,6 +100,9 @@ type Node interface {
 
 	emittedCount() int64
 
+	// the largest number of messages queued on any input edge
+	queueDepth() int64
+
 	incrementErrorCount()
 
 	stats() map[string]interface{}
@@ -152,6 +155,9 @@ func (n *node) init() {
 	n.diag = newNodeDiagnostic(n, n.diag)
 	n.statMap.Set(statCardinalityGauge, kexpvar.NewIntFuncGauge(nil))
 	n.timer = n.et.tm.TimingService.NewTimer(avgExecVar)
+	if n.et.Task.Limits.CPU.Max > 0 {
+		n.timer = &cpuTimer{Timer: n.timer, limiter: n.et.limiter}
+	}
 	n.errCh = make(chan error, 1)
 }
 
@@ -215,10 +221,11 @@ func (n *node) addChild(c Node) (edge.StatsEdge, error) {
 	n.children = append(n.children, c)
 
 	d := n.et.tm.diag.WithEdgeContext(n.et.Task.ID, n.Name(), c.Name())
-	edge := newEdge(n.et.Task.ID, n.Name(), c.Name(), n.Provides(), defaultEdgeBufferSize, d)
+	edge := newEdge(n.et.Task.ID, n.Name(), c.Name(), n.Provides(), defaultEdgeBufferSize, n.et.Task.EdgePolicies.policy(c.Name()), n.et.tm.edgeSpillDir(), d)
 	if edge == nil {
 		return nil, fmt.Errorf("unknown edge type %s", n.Provides())
 	}
+	edge = n.et.limiter.edge(edge)
 	c.addParentEdge(edge)
 	return edge, nil
 }
@@ -338,6 +345,16 @@ func (n *node) emittedCount() (count int64) {
 	return
 }
 
+// node queue depth is the largest number of messages queued on its parent edges
+func (n *node) queueDepth() (depth int64) {
+	for _, in := range n.ins {
+		if d := in.Collected() - in.Emitted() - in.Dropped(); d > depth {
+			depth = d
+		}
+	}
+	return
+}
+
 // node increment error count increments a nodes error_count stat
 func (n *node) incrementErrorCount() {
 	n.nodeErrors.Add(1)
@@ -9,6 +9,8 @@ import (
 // A node that handles creating several child QueryNodes.
 // Each call to `query` creates a child batch node that
 // can further be configured. See QueryNode
+// Each call to `sqlQuery` creates a child batch node querying
+// a relational database instead. See SQLQueryNode
 // The `batch` variable in batch tasks is an instance of
 // a BatchNode.
 //
@@ -52,6 +54,15 @@ func (b *BatchNode) Query(q string) *QueryNode {
 	return n
 }
 
+// The SQL query to execute against a relational database.
+// See SQLQueryNode
+func (b *BatchNode) SqlQuery(q string) *SQLQueryNode {
+	n := newSQLQueryNode()
+	n.QueryStr = q
+	b.linkChild(n)
+	return n
+}
+
 // Do not add the source batch node to the dot output
 // since its not really an edge.
 // tick:ignore
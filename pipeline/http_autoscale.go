@@ -0,0 +1,214 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influxdata/kapacitor/tick/ast"
+)
+
+const (
+	DefaultHTTPAutoscaleGetMethod    = http.MethodGet
+	DefaultHTTPAutoscaleSetMethod    = http.MethodPut
+	DefaultHTTPAutoscaleReplicasPath = "replicas"
+	DefaultHTTPAutoscaleSetBody      = `{"replicas":{{.Replicas}}}`
+	DefaultHTTPAutoscaleTimeout      = 10 * time.Second
+)
+
+// HTTPAutoscaleNode triggers autoscale events for a resource managed by an HTTP API,
+// i.e. a Nomad job, an ECS service or any in-house scheduler.
+// The node also outputs points for the triggered events.
+//
+// The current replica count of a resource is read from the JSON response of a request to the GetURL.
+// New replica counts are set with a request to the SetURL, sending the SetBody.
+// The URLs and the body are templates, which have access to the name of the resource as .Name,
+// the tags of the point as .Tags and, when setting the replica count, the new count as .Replicas.
+//
+// Example:
+//     // Target 100 requests per second per allocation
+//     var target = 100.0
+//     stream
+//         |from()
+//             .measurement('requests')
+//             .groupBy('job')
+//         |window()
+//             .period(5m)
+//             .every(5m)
+//         |mean('requests_per_second')
+//             .as('requests_per_second')
+//         |httpAutoscale()
+//             // Get the name of the job from the 'job' tag.
+//             .resourceNameTag('job')
+//             .getURL('http://scheduler:8080/jobs/{{.Name}}')
+//             // The replica count is read from the "count" of the "scale" object of the response.
+//             .replicasPath('scale.count')
+//             .setURL('http://scheduler:8080/jobs/{{.Name}}/scale')
+//             .setMethod('POST')
+//             .setBody('{"count":{{.Replicas}}}')
+//             .header('Authorization', 'Bearer secret')
+//             .min(1)
+//             .max(20)
+//             .replicas(lambda: int(ceil("requests_per_second" / target)))
+//         |influxDBOut()
+//             .database('deployments')
+//             .measurement('scale_events')
+//             .precision('s')
+//
+// Any time the httpAutoscale node changes a replica count, it emits a point.
+// The point is tagged with the resource name, using the OutputResourceNameTag property.
+// In addition the group by tags will be preserved on the emitted point.
+// The point contains the fields `old` and `new` representing the change in the replicas,
+// `reason` describing how the new replica count was determined,
+// `cooldown` which is true if the change was dropped because of a cooldown timer
+// and `dry_run` which is true if the change was not applied because of dry run mode.
+// Changes dropped because of a cooldown timer are emitted as well.
+//
+// Available Statistics:
+//
+//    * increase_events -- number of times the replica count was increased.
+//    * decrease_events -- number of times the replica count was decreased.
+//    * cooldown_drops  -- number of times an event was dropped because of a cooldown timer.
+//    * errors          -- number of errors encountered, typically related to communicating with the HTTP API.
+//
+type HTTPAutoscaleNode struct {
+	chainnode
+
+	// ResourceName is the name of the resource to autoscale.
+	ResourceName string
+	// ResourceNameTag is the name of a tag which contains the name of the resource to autoscale.
+	ResourceNameTag string
+	// OutputResourceNameTag is the name of a tag into which the resource name will be written for output autoscale events.
+	// Defaults to the value of ResourceNameTag if its not empty.
+	OutputResourceNameTag string
+
+	// GetURL is the template of the URL requested to get the current replica count of a resource.
+	GetURL string
+	// GetMethod is the HTTP method of the request to get the current replica count.
+	// Default: GET
+	GetMethod string
+	// ReplicasPath is the dot separated path of the replica count in the JSON response of the GetURL.
+	// Elements of arrays are addressed by their index.
+	// Default: replicas
+	ReplicasPath string
+
+	// SetURL is the template of the URL requested to set the replica count of a resource.
+	// Defaults to the value of GetURL.
+	SetURL string
+	// SetMethod is the HTTP method of the request to set the replica count.
+	// Default: PUT
+	SetMethod string
+	// SetBody is the template of the body of the request to set the replica count.
+	// Default: {"replicas":{{.Replicas}}}
+	SetBody string
+
+	// Timeout of the requests.
+	// Default: 10s
+	Timeout time.Duration
+
+	// tick:ignore
+	Headers map[string]string `tick:"Header"`
+
+	// CurrentField is the name of a field into which the current replica count will be set as an int.
+	// If empty no field will be set.
+	// Useful for computing deltas on the current state.
+	//
+	// Example:
+	//    |httpAutoscale()
+	//        .currentField('replicas')
+	//        // Increase the replicas by 1 if the qps is over the threshold
+	//        .replicas(lambda: if("qps" > threshold, "replicas" + 1, "replicas"))
+	//
+	CurrentField string
+
+	// The maximum scale factor to set.
+	// If 0 then there is no upper limit.
+	// Default: 0, a.k.a no limit.
+	Max int64
+
+	// The minimum scale factor to set.
+	// Default: 1
+	Min int64
+
+	// Replicas is a lambda expression that should evaluate to the desired number of replicas for the resource.
+	Replicas *ast.LambdaNode
+
+	// Only one increase event can be triggered per resource every IncreaseCooldown interval.
+	IncreaseCooldown time.Duration
+	// Only one decrease event can be triggered per resource every DecreaseCooldown interval.
+	DecreaseCooldown time.Duration
+
+	// Compute the scaling decisions without applying them.
+	// tick:ignore
+	DryRunFlag bool `tick:"DryRun"`
+
+	// HistorySize limits the decisions kept for the history endpoint of the node,
+	// the oldest decisions are dropped first.
+	// Default: 100
+	HistorySize int64
+}
+
+func newHTTPAutoscaleNode(e EdgeType) *HTTPAutoscaleNode {
+	return &HTTPAutoscaleNode{
+		chainnode:    newBasicChainNode("http_autoscale", e, StreamEdge),
+		GetMethod:    DefaultHTTPAutoscaleGetMethod,
+		ReplicasPath: DefaultHTTPAutoscaleReplicasPath,
+		SetMethod:    DefaultHTTPAutoscaleSetMethod,
+		SetBody:      DefaultHTTPAutoscaleSetBody,
+		Timeout:      DefaultHTTPAutoscaleTimeout,
+		Min:          1,
+		HistorySize:  DefaultAutoscaleHistorySize,
+	}
+}
+
+// Add a header to the requests.
+// Header can be called more than once.
+//
+// tick:property
+func (n *HTTPAutoscaleNode) Header(k, v string) *HTTPAutoscaleNode {
+	if n.Headers == nil {
+		n.Headers = make(map[string]string)
+	}
+	n.Headers[k] = v
+	return n
+}
+
+// DryRun computes the scaling decisions without sending the set requests,
+// the get requests for the current replica counts are still sent.
+//
+// tick:property
+func (n *HTTPAutoscaleNode) DryRun() *HTTPAutoscaleNode {
+	n.DryRunFlag = true
+	return n
+}
+
+func (n *HTTPAutoscaleNode) validate() error {
+	if (n.ResourceName == "" && n.ResourceNameTag == "") ||
+		(n.ResourceName != "" && n.ResourceNameTag != "") {
+		return fmt.Errorf("must specify exactly one of ResourceName or ResourceNameTag")
+	}
+	if n.GetURL == "" {
+		return errors.New("must specify a getURL")
+	}
+	if n.GetMethod == "" || n.SetMethod == "" {
+		return errors.New("must specify the getMethod and setMethod")
+	}
+	if strings.Trim(n.ReplicasPath, ".") == "" {
+		return errors.New("must specify a replicasPath")
+	}
+	if n.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %v", n.Timeout)
+	}
+	if n.Min < 1 {
+		return fmt.Errorf("min must be >= 1, got %d", n.Min)
+	}
+	if n.Replicas == nil {
+		return errors.New("must provide a replicas lambda expression")
+	}
+	if n.HistorySize < 0 {
+		return fmt.Errorf("historySize cannot be negative, got %d", n.HistorySize)
+	}
+	return nil
+}
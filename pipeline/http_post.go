@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // An HTTPPostNode will take the incoming data stream and POST it to an HTTP endpoint.
@@ -29,6 +30,22 @@ import (
 //        |httpPost()
 //            .endpoint('example')
 //
+// Posts that fail while the endpoint is unavailable can be queued on disk and replayed once it recovers.
+//
+// Example:
+//    stream
+//        |httpPost()
+//            .endpoint('example')
+//            .diskQueue()
+//            .diskQueueRetryInterval(30s)
+//
+// Available Statistics:
+//
+//    * queue_depth -- number of posts queued on disk
+//    * queue_bytes -- number of bytes queued on disk
+//    * queue_oldest_age_ms -- age of the oldest post queued on disk in milliseconds
+//    * queue_dropped -- number of posts dropped because the disk queue was full or the endpoint rejected them
+//
 type HTTPPostNode struct {
 	chainnode
 
@@ -47,12 +64,26 @@ type HTTPPostNode struct {
 
 	// tick:ignore
 	URLs []string
+
+	// Queue posts on disk while the endpoint is unavailable.
+	// tick:ignore
+	DiskQueueFlag bool `tick:"DiskQueue"`
+
+	// Maximum number of bytes queued on disk, zero is unlimited.
+	// Default: 100MB
+	DiskQueueMaxSize int64
+
+	// How often to retry the queued posts while the endpoint is unavailable.
+	// Default: 10s
+	DiskQueueRetryInterval time.Duration
 }
 
 func newHTTPPostNode(wants EdgeType, urls ...string) *HTTPPostNode {
 	return &HTTPPostNode{
-		chainnode: newBasicChainNode("http_post", wants, wants),
-		URLs:      urls,
+		chainnode:              newBasicChainNode("http_post", wants, wants),
+		URLs:                   urls,
+		DiskQueueMaxSize:       DefaultDiskQueueMaxSize,
+		DiskQueueRetryInterval: DefaultDiskQueueRetryInterval,
 	}
 }
 
@@ -80,6 +111,14 @@ func (p *HTTPPostNode) validate() error {
 		}
 	}
 
+	if p.DiskQueueMaxSize < 0 {
+		return fmt.Errorf("diskQueueMaxSize cannot be negative, got %d", p.DiskQueueMaxSize)
+	}
+
+	if p.DiskQueueRetryInterval <= 0 {
+		return fmt.Errorf("diskQueueRetryInterval must be positive, got %v", p.DiskQueueRetryInterval)
+	}
+
 	return nil
 }
 
@@ -119,3 +158,14 @@ func (p *HTTPPostNode) CaptureResponse() *HTTPPostNode {
 	p.CaptureResponseFlag = true
 	return p
 }
+
+// DiskQueue queues posts on disk while the endpoint is unavailable,
+// i.e. the connection fails or it responds with a 5xx or 429 status code,
+// and replays them in order once it recovers.
+// The code field of queued posts is 0.
+//
+// tick:property
+func (p *HTTPPostNode) DiskQueue() *HTTPPostNode {
+	p.DiskQueueFlag = true
+	return p
+}
@@ -1,9 +1,14 @@
 package pipeline
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const DefaultBufferSize = 1000
 const DefaultFlushInterval = time.Second * 10
+const DefaultDiskQueueMaxSize = 100 * 1024 * 1024
+const DefaultDiskQueueRetryInterval = time.Second * 10
 
 // Writes the data to InfluxDB as it is received.
 //
@@ -21,10 +26,26 @@ const DefaultFlushInterval = time.Second * 10
 //            .tag('kapacitor', 'true')
 //            .tag('version', '0.2')
 //
+// Writes that fail while InfluxDB is unavailable can be queued on disk and replayed once it recovers.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('requests')
+//        |influxDBOut()
+//            .database('mydb')
+//            .measurement('requests')
+//            .diskQueue()
+//            .diskQueueMaxSize(10000000)
+//
 // Available Statistics:
 //
 //    * points_written -- number of points written to InfluxDB
 //    * write_errors -- number of errors attempting to write to InfluxDB
+//    * queue_depth -- number of writes queued on disk
+//    * queue_bytes -- number of bytes queued on disk
+//    * queue_oldest_age_ms -- age of the oldest write queued on disk in milliseconds
+//    * queue_dropped -- number of writes dropped because the disk queue was full or InfluxDB rejected them
 //
 type InfluxDBOutNode struct {
 	node
@@ -54,6 +75,15 @@ type InfluxDBOutNode struct {
 	// Create the specified database and retention policy
 	// tick:ignore
 	CreateFlag bool `tick:"Create"`
+	// Queue writes on disk while InfluxDB is unavailable.
+	// tick:ignore
+	DiskQueueFlag bool `tick:"DiskQueue"`
+	// Maximum number of bytes queued on disk, zero is unlimited.
+	// Default: 100MB
+	DiskQueueMaxSize int64
+	// How often to retry writing the queued data while InfluxDB is unavailable.
+	// Default: 10s
+	DiskQueueRetryInterval time.Duration
 }
 
 func newInfluxDBOutNode(wants EdgeType) *InfluxDBOutNode {
@@ -63,9 +93,11 @@ func newInfluxDBOutNode(wants EdgeType) *InfluxDBOutNode {
 			wants:    wants,
 			provides: NoEdge,
 		},
-		Tags:          make(map[string]string),
-		Buffer:        DefaultBufferSize,
-		FlushInterval: DefaultFlushInterval,
+		Tags:                   make(map[string]string),
+		Buffer:                 DefaultBufferSize,
+		FlushInterval:          DefaultFlushInterval,
+		DiskQueueMaxSize:       DefaultDiskQueueMaxSize,
+		DiskQueueRetryInterval: DefaultDiskQueueRetryInterval,
 	}
 }
 
@@ -91,3 +123,24 @@ func (i *InfluxDBOutNode) Create() *InfluxDBOutNode {
 	i.CreateFlag = true
 	return i
 }
+
+// DiskQueue queues writes on disk while InfluxDB is unavailable
+// and replays them in order once it recovers.
+// Writes InfluxDB rejects, i.e. because of a field type conflict, are not queued.
+//
+// tick:property
+func (i *InfluxDBOutNode) DiskQueue() *InfluxDBOutNode {
+	i.DiskQueueFlag = true
+	return i
+}
+
+// tick:ignore
+func (i *InfluxDBOutNode) validate() error {
+	if i.DiskQueueMaxSize < 0 {
+		return fmt.Errorf("diskQueueMaxSize cannot be negative, got %d", i.DiskQueueMaxSize)
+	}
+	if i.DiskQueueRetryInterval <= 0 {
+		return fmt.Errorf("diskQueueRetryInterval must be positive, got %v", i.DiskQueueRetryInterval)
+	}
+	return nil
+}
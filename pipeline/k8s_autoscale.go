@@ -13,6 +13,9 @@ const (
 	DefaultNamespaceTag = "namespace"
 	DefaultKindTag      = "kind"
 	DefaultResourceTag  = "resource"
+
+	// DefaultAutoscaleHistorySize is the number of recent decisions kept by autoscale nodes.
+	DefaultAutoscaleHistorySize = 100
 )
 
 // K8sAutoscaleNode triggers autoscale events for a resource on a Kubernetes cluster.
@@ -67,7 +70,23 @@ const (
 // The point is tagged with the namespace, kind and resource name,
 // using the NamespaceTag, KindTag, and ResourceTag properties respectively.
 // In addition the group by tags will be preserved on the emitted point.
-// The point contains two fields: `old`, and `new` representing change in the replicas.
+// The point contains the fields `old` and `new` representing the change in the replicas,
+// `reason` describing how the new replica count was determined,
+// `cooldown` which is true if the change was dropped because of a cooldown timer
+// and `dry_run` which is true if the change was not applied because of dry run mode.
+// Changes dropped because of a cooldown timer are emitted as well.
+//
+// Similar to the Horizontal Pod Autoscaler of Kubernetes, flapping of the replica count can be limited
+// with stabilization windows and the size of each change can be limited with max steps.
+//
+// Example:
+//         |k8sAutoscale()
+//             .kind('statefulsets')
+//             .resourceNameTag('statefulset')
+//             // Only scale down when the desired replica count was lower for the last 5 minutes.
+//             .decreaseStabilizationWindow(5m)
+//             // Add at most 4 replicas at a time.
+//             .maxIncreaseStep(4)
 //
 // Available Statistics:
 //
@@ -86,7 +105,9 @@ type K8sAutoscaleNode struct {
 	Namespace string
 
 	// Kind is the type of resources to autoscale.
-	// Currently only "deployments", "replicasets" and "replicationcontrollers" are supported.
+	// One of "deployments", "replicasets", "replicationcontrollers", "statefulsets"
+	// or a custom resource with a scale subresource of the form <group>/<version>/<resource>,
+	// i.e. "example.com/v1/workers".
 	// Default: "deployments"
 	Kind string
 
@@ -125,6 +146,33 @@ type K8sAutoscaleNode struct {
 	// Only one decrease event can be triggered per resource every DecreaseCooldown interval.
 	DecreaseCooldown time.Duration
 
+	// IncreaseStabilizationWindow is the period over which the desired replica counts are considered
+	// before increasing the replica count.
+	// The replica count is only increased to the lowest desired replica count within the window.
+	// If 0 the replica count is increased immediately.
+	IncreaseStabilizationWindow time.Duration
+	// DecreaseStabilizationWindow is the period over which the desired replica counts are considered
+	// before decreasing the replica count.
+	// The replica count is only decreased to the highest desired replica count within the window.
+	// If 0 the replica count is decreased immediately.
+	DecreaseStabilizationWindow time.Duration
+
+	// MaxIncreaseStep is the maximum number of replicas added by a single increase event.
+	// If 0 then there is no limit.
+	MaxIncreaseStep int64
+	// MaxDecreaseStep is the maximum number of replicas removed by a single decrease event.
+	// If 0 then there is no limit.
+	MaxDecreaseStep int64
+
+	// Compute the scaling decisions without applying them.
+	// tick:ignore
+	DryRunFlag bool `tick:"DryRun"`
+
+	// HistorySize is the number of recent decisions kept in the history of the node,
+	// available from GET /kapacitor/v1/tasks/<task_id>/k8s_autoscale<n>/history.
+	// Default: 100
+	HistorySize int64
+
 	// NamespaceTag is the name of a tag to use when tagging emitted points with the namespace.
 	// If empty the point will not be tagged with the resource.
 	// Default: namespace
@@ -145,6 +193,7 @@ func newK8sAutoscaleNode(e EdgeType) *K8sAutoscaleNode {
 	k := &K8sAutoscaleNode{
 		chainnode:    newBasicChainNode("k8s_autoscale", e, StreamEdge),
 		Min:          1,
+		HistorySize:  DefaultAutoscaleHistorySize,
 		Kind:         client.DeploymentsKind,
 		NamespaceTag: DefaultNamespaceTag,
 		KindTag:      DefaultKindTag,
@@ -153,13 +202,22 @@ func newK8sAutoscaleNode(e EdgeType) *K8sAutoscaleNode {
 	return k
 }
 
+// DryRun computes the scaling decisions without updating the scale of the Kubernetes resources.
+// Use it to trial a new scaling rule, the decisions are emitted and kept in the history as usual.
+//
+// tick:property
+func (n *K8sAutoscaleNode) DryRun() *K8sAutoscaleNode {
+	n.DryRunFlag = true
+	return n
+}
+
 func (n *K8sAutoscaleNode) validate() error {
 	if (n.ResourceName != "" && n.ResourceNameTag != "") ||
 		(n.ResourceNameTag == "" && n.ResourceName == "") {
 		return fmt.Errorf("must specify exactly one of ResourceName or ResourceNameTag")
 	}
-	if n.Kind != client.DeploymentsKind && n.Kind != client.ReplicationControllerKind && n.Kind != client.ReplicaSetsKind {
-		return fmt.Errorf("invalid Kind, must be 'deployments', 'replicasets' or 'replicationcontrollers', got %s", n.Kind)
+	if err := client.ValidateKind(n.Kind); err != nil {
+		return err
 	}
 	if n.Min < 1 {
 		return fmt.Errorf("min must be >= 1, got %d", n.Min)
@@ -167,5 +225,14 @@ func (n *K8sAutoscaleNode) validate() error {
 	if n.Replicas == nil {
 		return errors.New("must provide a replicas lambda expression")
 	}
+	if n.IncreaseStabilizationWindow < 0 || n.DecreaseStabilizationWindow < 0 {
+		return errors.New("stabilization windows cannot be negative")
+	}
+	if n.MaxIncreaseStep < 0 || n.MaxDecreaseStep < 0 {
+		return errors.New("max steps cannot be negative")
+	}
+	if n.HistorySize < 0 {
+		return fmt.Errorf("historySize cannot be negative, got %d", n.HistorySize)
+	}
 	return nil
 }
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"errors"
+)
+
+// Writes the data to a Kafka topic as it is received.
+// Each point is written as a message holding the point in line protocol,
+// keyed by the group of the point, so that the points of a group stay in order.
+//
+// The Kafka cluster must be configured in the `[[kafka]]` section of the configuration file.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('requests')
+//        |eval(lambda: "errors" / "total")
+//            .as('error_percent')
+//        // Write the transformed data to Kafka
+//        |kafkaOut()
+//            .cluster('default')
+//            .topic('errors')
+//
+// Available Statistics:
+//
+//    * messages_written -- number of messages written to Kafka
+//    * write_errors -- number of errors attempting to write to Kafka
+//
+type KafkaOutNode struct {
+	node
+
+	// The ID of the configured Kafka cluster.
+	// If empty the only configured cluster will be used.
+	Cluster string
+	// The topic the messages are written to.
+	Topic string
+}
+
+func newKafkaOutNode(wants EdgeType) *KafkaOutNode {
+	return &KafkaOutNode{
+		node: node{
+			desc:     "kafka_out",
+			wants:    wants,
+			provides: NoEdge,
+		},
+	}
+}
+
+func (k *KafkaOutNode) validate() error {
+	if k.Topic == "" {
+		return errors.New("must specify a topic")
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MQTTOutFormatLineProtocol publishes the data as line protocol.
+	MQTTOutFormatLineProtocol = "line-protocol"
+	// MQTTOutFormatJSON publishes the data as JSON.
+	MQTTOutFormatJSON = "json"
+)
+
+// Publishes the data to an MQTT topic as it is received.
+// Each point of a stream is published as a message.
+// Each batch is published as a single message, holding a line per point
+// in line protocol or an array of points in JSON.
+//
+// The topic is a template, which has access to the measurement name,
+// the task name, the group and the tags of the point or batch.
+//
+// The broker must be configured in the `[[mqtt]]` section of the configuration file.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('power')
+//            .groupBy('site')
+//        |window()
+//            .period(1m)
+//            .every(1m)
+//        |mean('watts')
+//        // Publish the mean power of each site
+//        |mqttOut()
+//            .brokerName('edge')
+//            .topic('site/{{.Tags.site}}/power')
+//            .qos(1)
+//            .format('json')
+//
+// The JSON representation of a point is an object holding its name, time, tags and fields.
+//
+//    {"name":"power","time":"2018-01-01T00:01:00Z","tags":{"site":"a"},"fields":{"mean":42.5}}
+//
+// Available Statistics:
+//
+//    * messages_published -- number of messages published to the broker
+//    * publish_errors -- number of errors attempting to publish to the broker
+//
+type MQTTOutNode struct {
+	node
+
+	// BrokerName is the name of the configured MQTT broker.
+	// If empty the configured default broker will be used.
+	BrokerName string
+
+	// The topic the data is published to.
+	// The topic is a template with access to .Name, .TaskName, .Group and .Tags.
+	Topic string
+
+	// The Qos that will be used to deliver the messages.
+	//
+	// Valid values are:
+	//
+	//    * 0 - At most once delivery
+	//    * 1 - At least once delivery
+	//    * 2 - Exactly once delivery
+	//
+	Qos int64
+
+	// Retained indicates whether the last message of a topic should be delivered to
+	// clients that subscribe to the topic later.
+	Retained bool
+
+	// Format of the messages, one of line-protocol or json.
+	// Default: line-protocol
+	Format string
+}
+
+func newMQTTOutNode(wants EdgeType) *MQTTOutNode {
+	return &MQTTOutNode{
+		node: node{
+			desc:     "mqtt_out",
+			wants:    wants,
+			provides: NoEdge,
+		},
+		Format: MQTTOutFormatLineProtocol,
+	}
+}
+
+func (m *MQTTOutNode) validate() error {
+	if m.Topic == "" {
+		return errors.New("must specify a topic")
+	}
+	if m.Qos < 0 || m.Qos > 2 {
+		return fmt.Errorf("invalid qos %d, must be 0, 1 or 2", m.Qos)
+	}
+	switch m.Format {
+	case MQTTOutFormatLineProtocol, MQTTOutFormatJSON:
+	default:
+		return fmt.Errorf("invalid format %q, must be one of %s or %s", m.Format, MQTTOutFormatLineProtocol, MQTTOutFormatJSON)
+	}
+	return nil
+}
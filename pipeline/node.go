@@ -342,6 +342,20 @@ func (n *chainnode) InfluxDBOut() *InfluxDBOutNode {
 	return i
 }
 
+// Create a kafka output node that will write the incoming data to a Kafka topic.
+func (n *chainnode) KafkaOut() *KafkaOutNode {
+	k := newKafkaOutNode(n.provides)
+	n.linkChild(k)
+	return k
+}
+
+// Create an mqtt output node that will publish the incoming data to an MQTT topic.
+func (n *chainnode) MqttOut() *MQTTOutNode {
+	m := newMQTTOutNode(n.provides)
+	n.linkChild(m)
+	return m
+}
+
 // Create an kapacitor loopback node that will send data back into Kapacitor as a stream.
 func (n *chainnode) KapacitorLoopback() *KapacitorLoopbackNode {
 	k := newKapacitorLoopbackNode(n.provides)
@@ -475,6 +489,13 @@ func (n *chainnode) SwarmAutoscale() *SwarmAutoscaleNode {
 	return k
 }
 
+// Create a node that can trigger autoscale events for a resource managed by an HTTP API.
+func (n *chainnode) HttpAutoscale() *HTTPAutoscaleNode {
+	h := newHTTPAutoscaleNode(n.Provides())
+	n.linkChild(h)
+	return h
+}
+
 // Create a node that tracks duration in a given state.
 func (n *chainnode) StateDuration(expression *ast.LambdaNode) *StateDurationNode {
 	sd := newStateDurationNode(n.provides, expression)
@@ -56,7 +56,11 @@ import (
 // The point is tagged with the service name,
 // using the serviceName respectively
 // In addition the group by tags will be preserved on the emitted point.
-// The point contains two fields: `old`, and `new` representing change in the replicas.
+// The point contains the fields `old` and `new` representing the change in the replicas,
+// `reason` describing how the new replica count was determined,
+// `cooldown` which is true if the change was dropped because of a cooldown timer
+// and `dry_run` which is true if the change was not applied because of dry run mode.
+// Changes dropped because of a cooldown timer are emitted as well.
 //
 // Available Statistics:
 //
@@ -108,16 +112,33 @@ type SwarmAutoscaleNode struct {
 	IncreaseCooldown time.Duration
 	// Only one decrease event can be triggered per resource every DecreaseCooldown interval.
 	DecreaseCooldown time.Duration
+
+	// Compute the scaling decisions without applying them.
+	// tick:ignore
+	DryRunFlag bool `tick:"DryRun"`
+
+	// HistorySize is the number of recent decisions served by the history endpoint of the node.
+	// Default: 100
+	HistorySize int64
 }
 
 func newSwarmAutoscaleNode(e EdgeType) *SwarmAutoscaleNode {
 	k := &SwarmAutoscaleNode{
-		chainnode: newBasicChainNode("swarm_autoscale", e, StreamEdge),
-		Min:       1,
+		chainnode:   newBasicChainNode("swarm_autoscale", e, StreamEdge),
+		Min:         1,
+		HistorySize: DefaultAutoscaleHistorySize,
 	}
 	return k
 }
 
+// DryRun computes the scaling decisions without updating the Swarm services.
+//
+// tick:property
+func (n *SwarmAutoscaleNode) DryRun() *SwarmAutoscaleNode {
+	n.DryRunFlag = true
+	return n
+}
+
 func (n *SwarmAutoscaleNode) validate() error {
 	if (n.ServiceName == "" && n.ServiceNameTag == "") ||
 		(n.ServiceName != "" && n.ServiceNameTag != "") {
@@ -129,5 +150,8 @@ func (n *SwarmAutoscaleNode) validate() error {
 	if n.Replicas == nil {
 		return errors.New("must provide a replicas lambda expression")
 	}
+	if n.HistorySize < 0 {
+		return fmt.Errorf("historySize cannot be negative, got %d", n.HistorySize)
+	}
 	return nil
 }
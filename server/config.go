@@ -13,6 +13,7 @@ import (
 
 	"github.com/influxdata/kapacitor/command"
 	"github.com/influxdata/kapacitor/services/alerta"
+	"github.com/influxdata/kapacitor/services/audit"
 	"github.com/influxdata/kapacitor/services/azure"
 	"github.com/influxdata/kapacitor/services/config"
 	"github.com/influxdata/kapacitor/services/consul"
@@ -21,19 +22,23 @@ import (
 	"github.com/influxdata/kapacitor/services/dns"
 	"github.com/influxdata/kapacitor/services/ec2"
 	"github.com/influxdata/kapacitor/services/file_discovery"
+	"github.com/influxdata/kapacitor/services/filetail"
 	"github.com/influxdata/kapacitor/services/gce"
 	"github.com/influxdata/kapacitor/services/hipchat"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/httppost"
 	"github.com/influxdata/kapacitor/services/influxdb"
 	"github.com/influxdata/kapacitor/services/k8s"
+	"github.com/influxdata/kapacitor/services/kafka"
 	"github.com/influxdata/kapacitor/services/load"
 	"github.com/influxdata/kapacitor/services/marathon"
 	"github.com/influxdata/kapacitor/services/mqtt"
 	"github.com/influxdata/kapacitor/services/nerve"
 	"github.com/influxdata/kapacitor/services/opsgenie"
+	"github.com/influxdata/kapacitor/services/otlp"
 	"github.com/influxdata/kapacitor/services/pagerduty"
 	"github.com/influxdata/kapacitor/services/pushover"
+	"github.com/influxdata/kapacitor/services/remote_write"
 	"github.com/influxdata/kapacitor/services/replay"
 	"github.com/influxdata/kapacitor/services/reporting"
 	"github.com/influxdata/kapacitor/services/scraper"
@@ -46,12 +51,14 @@ import (
 	"github.com/influxdata/kapacitor/services/stats"
 	"github.com/influxdata/kapacitor/services/storage"
 	"github.com/influxdata/kapacitor/services/swarm"
+	"github.com/influxdata/kapacitor/services/syslog"
 	"github.com/influxdata/kapacitor/services/talk"
 	"github.com/influxdata/kapacitor/services/task_store"
 	"github.com/influxdata/kapacitor/services/telegram"
 	"github.com/influxdata/kapacitor/services/triton"
 	"github.com/influxdata/kapacitor/services/udf"
 	"github.com/influxdata/kapacitor/services/udp"
+	"github.com/influxdata/kapacitor/services/userauth"
 	"github.com/influxdata/kapacitor/services/victorops"
 	"github.com/pkg/errors"
 
@@ -65,6 +72,8 @@ type Config struct {
 	HTTP           httpd.Config      `toml:"http"`
 	Replay         replay.Config     `toml:"replay"`
 	Storage        storage.Config    `toml:"storage"`
+	Auth           userauth.Config   `toml:"auth"`
+	Audit          audit.Config      `toml:"audit"`
 	Task           task_store.Config `toml:"task"`
 	Load           load.Config       `toml:"load"`
 	InfluxDB       []influxdb.Config `toml:"influxdb" override:"influxdb,element-key=name"`
@@ -76,10 +85,16 @@ type Config struct {
 	Collectd collectd.Config   `toml:"collectd"`
 	OpenTSDB opentsdb.Config   `toml:"opentsdb"`
 	UDP      []udp.Config      `toml:"udp"`
+	FileTail []filetail.Config `toml:"file-tail"`
+	Syslog   []syslog.Config   `toml:"syslog"`
+
+	RemoteWrite remote_write.Config `toml:"remote-write"`
+	OTLP        otlp.Config         `toml:"otlp"`
 
 	// Alert handlers
 	Alerta    alerta.Config    `toml:"alerta" override:"alerta"`
 	HipChat   hipchat.Config   `toml:"hipchat" override:"hipchat"`
+	Kafka     kafka.Configs    `toml:"kafka" override:"kafka,element-key=id"`
 	MQTT      mqtt.Configs     `toml:"mqtt" override:"mqtt,element-key=name"`
 	OpsGenie  opsgenie.Config  `toml:"opsgenie" override:"opsgenie"`
 	PagerDuty pagerduty.Config `toml:"pagerduty" override:"pagerduty"`
@@ -133,6 +148,8 @@ func NewConfig() *Config {
 
 	c.HTTP = httpd.NewConfig()
 	c.Storage = storage.NewConfig()
+	c.Auth = userauth.NewConfig()
+	c.Audit = audit.NewConfig()
 	c.Replay = replay.NewConfig()
 	c.Task = task_store.NewConfig()
 	c.InfluxDB = []influxdb.Config{influxdb.NewConfig()}
@@ -141,9 +158,12 @@ func NewConfig() *Config {
 
 	c.Collectd = collectd.NewConfig()
 	c.OpenTSDB = opentsdb.NewConfig()
+	c.RemoteWrite = remote_write.NewConfig()
+	c.OTLP = otlp.NewConfig()
 
 	c.Alerta = alerta.NewConfig()
 	c.HipChat = hipchat.NewConfig()
+	c.Kafka = kafka.Configs{}
 	c.MQTT = mqtt.Configs{}
 	c.OpsGenie = opsgenie.NewConfig()
 	c.PagerDuty = pagerduty.NewConfig()
@@ -184,6 +204,7 @@ func NewDemoConfig() (*Config, error) {
 	c.Replay.Dir = filepath.Join(homeDir, ".kapacitor", c.Replay.Dir)
 	c.Task.Dir = filepath.Join(homeDir, ".kapacitor", c.Task.Dir)
 	c.Storage.BoltDBPath = filepath.Join(homeDir, ".kapacitor", c.Storage.BoltDBPath)
+	c.Storage.LevelDBPath = filepath.Join(homeDir, ".kapacitor", c.Storage.LevelDBPath)
 	c.DataDir = filepath.Join(homeDir, ".kapacitor", c.DataDir)
 	c.Load.Dir = filepath.Join(homeDir, ".kapacitor", c.Load.Dir)
 
@@ -204,6 +225,12 @@ func (c *Config) Validate() error {
 	if err := c.Storage.Validate(); err != nil {
 		return errors.Wrap(err, "storage")
 	}
+	if err := c.Auth.Validate(); err != nil {
+		return errors.Wrap(err, "auth")
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return errors.Wrap(err, "audit")
+	}
 	if err := c.HTTP.Validate(); err != nil {
 		return errors.Wrap(err, "http")
 	}
@@ -249,6 +276,29 @@ func (c *Config) Validate() error {
 			return errors.Wrap(err, "graphite")
 		}
 	}
+	if err := c.RemoteWrite.Validate(); err != nil {
+		return errors.Wrap(err, "remote-write")
+	}
+	if err := c.OTLP.Validate(); err != nil {
+		return errors.Wrap(err, "otlp")
+	}
+	fileTailNames := make(map[string]bool, len(c.FileTail))
+	for _, f := range c.FileTail {
+		if err := f.Validate(); err != nil {
+			return errors.Wrap(err, "file-tail")
+		}
+		if f.Enabled {
+			if fileTailNames[f.Name] {
+				return fmt.Errorf("file-tail: duplicate name %q", f.Name)
+			}
+			fileTailNames[f.Name] = true
+		}
+	}
+	for _, sc := range c.Syslog {
+		if err := sc.Validate(); err != nil {
+			return errors.Wrap(err, "syslog")
+		}
+	}
 
 	// Validate alert handlers
 	if err := c.Alerta.Validate(); err != nil {
@@ -257,6 +307,9 @@ func (c *Config) Validate() error {
 	if err := c.HipChat.Validate(); err != nil {
 		return errors.Wrap(err, "hipchat")
 	}
+	if err := c.Kafka.Validate(); err != nil {
+		return errors.Wrap(err, "kafka")
+	}
 	if err := c.MQTT.Validate(); err != nil {
 		return errors.Wrap(err, "mqtt")
 	}
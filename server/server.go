@@ -23,6 +23,7 @@ import (
 	"github.com/influxdata/kapacitor/server/vars"
 	"github.com/influxdata/kapacitor/services/alert"
 	"github.com/influxdata/kapacitor/services/alerta"
+	"github.com/influxdata/kapacitor/services/audit"
 	"github.com/influxdata/kapacitor/services/azure"
 	"github.com/influxdata/kapacitor/services/config"
 	"github.com/influxdata/kapacitor/services/consul"
@@ -31,20 +32,24 @@ import (
 	"github.com/influxdata/kapacitor/services/dns"
 	"github.com/influxdata/kapacitor/services/ec2"
 	"github.com/influxdata/kapacitor/services/file_discovery"
+	"github.com/influxdata/kapacitor/services/filetail"
 	"github.com/influxdata/kapacitor/services/gce"
 	"github.com/influxdata/kapacitor/services/hipchat"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/httppost"
 	"github.com/influxdata/kapacitor/services/influxdb"
 	"github.com/influxdata/kapacitor/services/k8s"
+	"github.com/influxdata/kapacitor/services/kafka"
 	"github.com/influxdata/kapacitor/services/load"
 	"github.com/influxdata/kapacitor/services/marathon"
 	"github.com/influxdata/kapacitor/services/mqtt"
 	"github.com/influxdata/kapacitor/services/nerve"
 	"github.com/influxdata/kapacitor/services/noauth"
 	"github.com/influxdata/kapacitor/services/opsgenie"
+	"github.com/influxdata/kapacitor/services/otlp"
 	"github.com/influxdata/kapacitor/services/pagerduty"
 	"github.com/influxdata/kapacitor/services/pushover"
+	"github.com/influxdata/kapacitor/services/remote_write"
 	"github.com/influxdata/kapacitor/services/replay"
 	"github.com/influxdata/kapacitor/services/reporting"
 	"github.com/influxdata/kapacitor/services/scraper"
@@ -58,12 +63,14 @@ import (
 	"github.com/influxdata/kapacitor/services/stats"
 	"github.com/influxdata/kapacitor/services/storage"
 	"github.com/influxdata/kapacitor/services/swarm"
+	"github.com/influxdata/kapacitor/services/syslog"
 	"github.com/influxdata/kapacitor/services/talk"
 	"github.com/influxdata/kapacitor/services/task_store"
 	"github.com/influxdata/kapacitor/services/telegram"
 	"github.com/influxdata/kapacitor/services/triton"
 	"github.com/influxdata/kapacitor/services/udf"
 	"github.com/influxdata/kapacitor/services/udp"
+	"github.com/influxdata/kapacitor/services/userauth"
 	"github.com/influxdata/kapacitor/services/victorops"
 	"github.com/influxdata/kapacitor/uuid"
 	"github.com/influxdata/kapacitor/waiter"
@@ -191,6 +198,8 @@ func New(c *Config, buildInfo BuildInfo, diagService *diagnostic.Service) (*Serv
 	kd := diagService.NewKapacitorHandler()
 	s.TaskMaster = kapacitor.NewTaskMaster(kapacitor.MainTaskMaster, vars.Info, kd)
 	s.TaskMaster.DefaultRetentionPolicy = c.DefaultRetentionPolicy
+	s.TaskMaster.EdgeSpillDir = filepath.Join(c.DataDir, "edges")
+	s.TaskMaster.DiskQueueDir = filepath.Join(c.DataDir, "queues")
 	s.TaskMaster.Commander = s.Commander
 	s.TaskMasterLookup.Set(s.TaskMaster)
 	if err := s.TaskMaster.Open(); err != nil {
@@ -201,6 +210,7 @@ func New(c *Config, buildInfo BuildInfo, diagService *diagnostic.Service) (*Serv
 	s.initHTTPDService()
 	s.appendStorageService()
 	s.appendAuthService()
+	s.appendAuditService()
 	s.appendConfigOverrideService()
 	s.appendTesterService()
 
@@ -222,6 +232,7 @@ func New(c *Config, buildInfo BuildInfo, diagService *diagnostic.Service) (*Serv
 	// Append Alert integration services
 	s.appendAlertaService()
 	s.appendHipChatService()
+	s.appendKafkaService()
 	if err := s.appendMQTTService(); err != nil {
 		return nil, errors.Wrap(err, "mqtt service")
 	}
@@ -255,6 +266,16 @@ func New(c *Config, buildInfo BuildInfo, diagService *diagnostic.Service) (*Serv
 		return nil, errors.Wrap(err, "collectd service")
 	}
 	s.appendUDPServices()
+	if err := s.appendFileTailServices(); err != nil {
+		return nil, errors.Wrap(err, "file tail service")
+	}
+	if err := s.appendSyslogServices(); err != nil {
+		return nil, errors.Wrap(err, "syslog service")
+	}
+	if err := s.appendRemoteWriteService(); err != nil {
+		return nil, errors.Wrap(err, "remote write service")
+	}
+	s.appendOTLPService()
 	if err := s.appendOpenTSDBService(); err != nil {
 		return nil, errors.Wrap(err, "opentsdb service")
 	}
@@ -468,6 +489,8 @@ func (s *Server) appendReplayService() {
 	srv.TaskMaster = s.TaskMaster
 	srv.TaskMasterLookup = s.TaskMasterLookup
 
+	s.TaskMaster.FlightRecorderService = srv
+
 	s.ReplayService = srv
 	s.AppendService("replay", srv)
 }
@@ -516,6 +539,17 @@ func (s *Server) appendUDFService() {
 }
 
 func (s *Server) appendAuthService() {
+	if s.config.Auth.Enabled {
+		d := s.DiagService.NewUserAuthHandler()
+		srv := userauth.NewService(s.config.Auth, d)
+		srv.StorageService = s.StorageService
+		srv.HTTPDService = s.HTTPDService
+
+		s.AuthService = srv
+		s.HTTPDService.Handler.AuthService = srv
+		s.AppendService("auth", srv)
+		return
+	}
 	d := s.DiagService.NewNoAuthHandler()
 	srv := noauth.NewService(d)
 
@@ -524,6 +558,32 @@ func (s *Server) appendAuthService() {
 	s.AppendService("auth", srv)
 }
 
+func (s *Server) appendAuditService() {
+	if !s.config.Audit.Enabled {
+		return
+	}
+	d := s.DiagService.NewAuditHandler()
+	srv := audit.NewService(s.config.Audit, d)
+	srv.StorageService = s.StorageService
+	srv.HTTPDService = s.HTTPDService
+
+	s.HTTPDService.Handler.AuditService = srv
+	s.AppendService("audit", srv)
+}
+
+func (s *Server) appendKafkaService() {
+	cs := s.config.Kafka
+	d := s.DiagService.NewKafkaHandler()
+	srv := kafka.NewService(cs, d)
+	srv.PointsWriter = s.TaskMaster
+
+	s.TaskMaster.KafkaService = srv
+	s.AlertService.KafkaService = srv
+
+	s.SetDynamicService("kafka", srv)
+	s.AppendService("kafka", srv)
+}
+
 func (s *Server) appendMQTTService() error {
 	cs := s.config.MQTT
 	d := s.DiagService.NewMQTTHandler()
@@ -531,6 +591,7 @@ func (s *Server) appendMQTTService() error {
 	if err != nil {
 		return err
 	}
+	srv.PointsWriter = s.TaskMaster
 
 	s.TaskMaster.MQTTService = srv
 	s.AlertService.MQTTService = srv
@@ -755,6 +816,33 @@ func (s *Server) appendGraphiteServices() error {
 	return nil
 }
 
+func (s *Server) appendRemoteWriteService() error {
+	c := s.config.RemoteWrite
+	if !c.Enabled {
+		return nil
+	}
+	d := s.DiagService.NewRemoteWriteHandler()
+	srv, err := remote_write.NewService(c, d)
+	if err != nil {
+		return err
+	}
+	srv.PointsWriter = s.TaskMaster
+	srv.HTTPDService = s.HTTPDService
+	s.AppendService("remote_write", srv)
+	return nil
+}
+
+func (s *Server) appendOTLPService() {
+	c := s.config.OTLP
+	if !c.Enabled {
+		return
+	}
+	d := s.DiagService.NewOTLPHandler()
+	srv := otlp.NewService(c, d)
+	srv.PointsWriter = s.TaskMaster
+	s.AppendService("otlp", srv)
+}
+
 func (s *Server) appendUDPServices() {
 	for i, c := range s.config.UDP {
 		if !c.Enabled {
@@ -767,6 +855,39 @@ func (s *Server) appendUDPServices() {
 	}
 }
 
+func (s *Server) appendFileTailServices() error {
+	for i, c := range s.config.FileTail {
+		if !c.Enabled {
+			continue
+		}
+		d := s.DiagService.NewFileTailHandler(c.Name)
+		srv, err := filetail.NewService(c, d)
+		if err != nil {
+			return err
+		}
+		srv.StorageService = s.StorageService
+		srv.PointsWriter = s.TaskMaster
+		s.AppendService(fmt.Sprintf("file_tail%d", i), srv)
+	}
+	return nil
+}
+
+func (s *Server) appendSyslogServices() error {
+	for i, c := range s.config.Syslog {
+		if !c.Enabled {
+			continue
+		}
+		d := s.DiagService.NewSyslogHandler()
+		srv, err := syslog.NewService(c, d)
+		if err != nil {
+			return err
+		}
+		srv.PointsWriter = s.TaskMaster
+		s.AppendService(fmt.Sprintf("syslog%d", i), srv)
+	}
+	return nil
+}
+
 func (s *Server) appendStatsService() {
 	c := s.config.Stats
 	if c.Enabled {
@@ -795,6 +916,7 @@ func (s *Server) appendScraperService() {
 	d := s.DiagService.NewScraperHandler()
 	srv := scraper.NewService(c, d)
 	srv.PointsWriter = s.TaskMaster
+	srv.HTTPDService = s.HTTPDService
 	s.ScraperService = srv
 	s.SetDynamicService("scraper", srv)
 	s.AppendService("scraper", srv)
@@ -2,11 +2,13 @@ package server_test
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/mail"
 	"net/url"
 	"os"
@@ -38,6 +40,8 @@ import (
 	"github.com/influxdata/kapacitor/services/httppost"
 	"github.com/influxdata/kapacitor/services/httppost/httpposttest"
 	"github.com/influxdata/kapacitor/services/k8s"
+	"github.com/influxdata/kapacitor/services/kafka"
+	"github.com/influxdata/kapacitor/services/kafka/kafkatest"
 	"github.com/influxdata/kapacitor/services/mqtt"
 	"github.com/influxdata/kapacitor/services/mqtt/mqtttest"
 	"github.com/influxdata/kapacitor/services/opsgenie"
@@ -45,10 +49,12 @@ import (
 	"github.com/influxdata/kapacitor/services/pagerduty"
 	"github.com/influxdata/kapacitor/services/pagerduty/pagerdutytest"
 	"github.com/influxdata/kapacitor/services/pushover/pushovertest"
+	"github.com/influxdata/kapacitor/services/scraper"
 	"github.com/influxdata/kapacitor/services/sensu/sensutest"
 	"github.com/influxdata/kapacitor/services/slack/slacktest"
 	"github.com/influxdata/kapacitor/services/smtp/smtptest"
 	"github.com/influxdata/kapacitor/services/snmptrap/snmptraptest"
+	"github.com/influxdata/kapacitor/services/static_discovery"
 	"github.com/influxdata/kapacitor/services/swarm"
 	"github.com/influxdata/kapacitor/services/talk/talktest"
 	"github.com/influxdata/kapacitor/services/telegram"
@@ -58,6 +64,7 @@ import (
 	"github.com/influxdata/kapacitor/services/victorops/victoropstest"
 	"github.com/k-sone/snmpgo"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var udfDir string
@@ -288,6 +295,469 @@ func TestServer_Authenticate_Bearer(t *testing.T) {
 	}
 }
 
+func TestServer_UserAuth(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.AuthEnabled = true
+	conf.Auth.Enabled = true
+	conf.Auth.BcryptCost = bcrypt.MinCost
+	conf.Auth.BootstrapUsername = "admin"
+	conf.Auth.BootstrapPassword = "admin password"
+	s := OpenServer(conf)
+	defer s.Close()
+
+	newClient := func(username, password string) *client.Client {
+		cli, err := client.New(client.Config{
+			URL: s.URL(),
+			Credentials: &client.Credentials{
+				Method:   client.UserAuthentication,
+				Username: username,
+				Password: password,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cli
+	}
+
+	if _, _, err := newClient("admin", "wrong password").Ping(); err == nil {
+		t.Error("expected authentication error")
+	}
+
+	admin := newClient("admin", "admin password")
+	if _, err := admin.CreateRole(client.CreateRoleOptions{
+		Name: "dashboard",
+		Permissions: map[string][]client.Privilege{
+			"/api": {client.ReadPrivilege},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.CreateUser(client.CreateUserOptions{
+		Name:     "bob",
+		Password: "bob password",
+		Roles:    []string{"dashboard"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	bob := newClient("bob", "bob password")
+	if _, err := bob.ListTasks(nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err := bob.CreateTask(client.CreateTaskOptions{
+		ID:         "testTaskID",
+		Type:       client.StreamTask,
+		DBRPs:      []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+		TICKscript: "stream|from()",
+	})
+	if err == nil {
+		t.Error("expected error creating task without write privilege")
+	}
+	if _, err := bob.UpdateUser(bob.UserLink("bob"), client.UpdateUserOptions{Roles: &[]string{}}); err == nil {
+		t.Error("expected error updating user as a non admin user")
+	}
+
+	if _, err := admin.UpdateUser(admin.UserLink("bob"), client.UpdateUserOptions{Password: "new password"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bob.Ping(); err == nil {
+		t.Error("expected authentication error with old password")
+	}
+	if _, _, err := newClient("bob", "new password").Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Users and roles are persisted
+	s.Restart()
+	users, err := newClient("admin", "admin password").ListUsers(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []client.User{
+		{
+			Link:  client.Link{Relation: client.Self, Href: "/kapacitor/v1/users/admin"},
+			Name:  "admin",
+			Admin: true,
+			Roles: []string{},
+		},
+		{
+			Link:  client.Link{Relation: client.Self, Href: "/kapacitor/v1/users/bob"},
+			Name:  "bob",
+			Roles: []string{"dashboard"},
+		},
+	}
+	if !reflect.DeepEqual(users, exp) {
+		t.Errorf("unexpected users:\ngot\n%v\nexp\n%v\n", users, exp)
+	}
+}
+
+func TestServer_APITokens(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.AuthEnabled = true
+	conf.Auth.Enabled = true
+	conf.Auth.BcryptCost = bcrypt.MinCost
+	conf.Auth.BootstrapUsername = "admin"
+	conf.Auth.BootstrapPassword = "admin password"
+	s := OpenServer(conf)
+	defer s.Close()
+
+	newClient := func(credentials *client.Credentials) *client.Client {
+		cli, err := client.New(client.Config{
+			URL:         s.URL(),
+			Credentials: credentials,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cli
+	}
+	tokenClient := func(token string) *client.Client {
+		return newClient(&client.Credentials{
+			Method: client.TokenAuthentication,
+			Token:  token,
+		})
+	}
+
+	admin := newClient(&client.Credentials{
+		Method:   client.UserAuthentication,
+		Username: "admin",
+		Password: "admin password",
+	})
+	if _, err := admin.CreateRole(client.CreateRoleOptions{
+		Name: "ci",
+		Permissions: map[string][]client.Privilege{
+			"/api/tasks":  {client.AllPrivileges},
+			"/api/tokens": {client.AllPrivileges},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.CreateUser(client.CreateUserOptions{
+		Name:     "ci",
+		Password: "ci password",
+		Roles:    []string{"ci"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ci := newClient(&client.Credentials{
+		Method:   client.UserAuthentication,
+		Username: "ci",
+		Password: "ci password",
+	})
+	token, err := ci.CreateAPIToken(client.CreateAPITokenOptions{
+		Description: "team a pipeline",
+		Scopes:      []string{"/kapacitor/v1/tasks/team-a-*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ci.CreateAPIToken(client.CreateAPITokenOptions{Username: "admin"}); err == nil {
+		t.Error("expected error creating token for another user as a non admin user")
+	}
+
+	if _, _, err := tokenClient(token.ID + ".wrong").Ping(); err == nil {
+		t.Error("expected authentication error with wrong token")
+	}
+	scoped := tokenClient(token.Token)
+	for _, id := range []string{"team-a-cpu", "team-b-cpu"} {
+		if _, err := ci.CreateTask(client.CreateTaskOptions{
+			ID:         id,
+			Type:       client.StreamTask,
+			DBRPs:      []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+			TICKscript: "stream|from()",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := scoped.UpdateTask(scoped.TaskLink("team-a-cpu"), client.UpdateTaskOptions{Status: client.Enabled}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scoped.UpdateTask(scoped.TaskLink("team-b-cpu"), client.UpdateTaskOptions{Status: client.Enabled}); err == nil {
+		t.Error("expected error updating task outside of the token scopes")
+	}
+	if _, err := scoped.ListTasks(nil); err == nil {
+		t.Error("expected error listing tasks outside of the token scopes")
+	}
+	if _, err := scoped.CreateAPIToken(client.CreateAPITokenOptions{}); err == nil {
+		t.Error("expected error creating token with a scoped token")
+	}
+
+	tokens, err := ci.ListAPITokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].ID != token.ID || tokens[0].Token != "" {
+		t.Errorf("unexpected tokens %v", tokens)
+	}
+
+	// Tokens are persisted and can be revoked
+	s.Restart()
+	scoped = tokenClient(token.Token)
+	if _, err := scoped.Task(scoped.TaskLink("team-a-cpu"), nil); err != nil {
+		t.Fatal(err)
+	}
+	admin = newClient(&client.Credentials{
+		Method:   client.UserAuthentication,
+		Username: "admin",
+		Password: "admin password",
+	})
+	if err := admin.DeleteAPIToken(token.Link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scoped.Task(scoped.TaskLink("team-a-cpu"), nil); err == nil {
+		t.Error("expected authentication error with revoked token")
+	}
+}
+
+func TestServer_Namespaces(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.AuthEnabled = true
+	conf.Auth.Enabled = true
+	conf.Auth.BcryptCost = bcrypt.MinCost
+	conf.Auth.BootstrapUsername = "admin"
+	conf.Auth.BootstrapPassword = "admin password"
+	s := OpenServer(conf)
+	defer s.Close()
+
+	newClient := func(username, password string) *client.Client {
+		cli, err := client.New(client.Config{
+			URL: s.URL(),
+			Credentials: &client.Credentials{
+				Method:   client.UserAuthentication,
+				Username: username,
+				Password: password,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cli
+	}
+
+	admin := newClient("admin", "admin password")
+	if _, err := admin.CreateNamespace(client.CreateNamespaceOptions{
+		Name:  "team-a",
+		DBRPs: []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.CreateNamespace(client.CreateNamespaceOptions{Name: "team/b"}); err == nil {
+		t.Error("expected error creating namespace with invalid name")
+	}
+	if _, err := admin.CreateRole(client.CreateRoleOptions{
+		Name: "team-a",
+		Permissions: map[string][]client.Privilege{
+			"/api/tasks/team-a": {client.AllPrivileges},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.CreateUser(client.CreateUserOptions{
+		Name:     "alice",
+		Password: "alice password",
+		Roles:    []string{"team-a"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	alice := newClient("alice", "alice password")
+
+	// Tasks are created in the namespace of the request
+	task, err := alice.CreateTask(client.CreateTaskOptions{
+		ID:         "team-a/cpu",
+		Type:       client.StreamTask,
+		DBRPs:      []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+		TICKscript: "stream|from()",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := task.Link.Href, "/kapacitor/v1/tasks/team-a/cpu"; got != exp {
+		t.Errorf("unexpected task link got %s exp %s", got, exp)
+	}
+	if _, err := alice.CreateTask(client.CreateTaskOptions{
+		ID:         "team-a/disk",
+		Type:       client.StreamTask,
+		DBRPs:      []client.DBRP{{Database: "otherdb", RetentionPolicy: "myrp"}},
+		TICKscript: "stream|from()",
+	}); err == nil {
+		t.Error("expected error creating task with DBRP outside of the namespace")
+	}
+	if _, err := alice.CreateTask(client.CreateTaskOptions{
+		ID:         "team-a/mem",
+		Type:       client.StreamTask,
+		DBRPs:      []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+		TICKscript: "stream|from()|influxDBOut().database('otherdb').retentionPolicy('myrp')",
+	}); err == nil {
+		t.Error("expected error creating task writing to DBRP outside of the namespace")
+	}
+	if _, err := alice.CreateTask(client.CreateTaskOptions{
+		ID:         "cpu",
+		Type:       client.StreamTask,
+		DBRPs:      []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+		TICKscript: "stream|from()",
+	}); err == nil {
+		t.Error("expected error creating task outside of the namespace")
+	}
+	if _, err := admin.CreateTask(client.CreateTaskOptions{
+		ID:         "team-b/cpu",
+		Type:       client.StreamTask,
+		DBRPs:      []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+		TICKscript: "stream|from()",
+	}); err == nil {
+		t.Error("expected error creating task in unknown namespace")
+	}
+	if _, err := admin.CreateTask(client.CreateTaskOptions{
+		ID:         "cpu",
+		Type:       client.StreamTask,
+		DBRPs:      []client.DBRP{{Database: "otherdb", RetentionPolicy: "myrp"}},
+		TICKscript: "stream|from()",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := alice.ListTasks(&client.ListTasksOptions{Namespace: "team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != "team-a/cpu" {
+		t.Errorf("unexpected tasks in namespace %v", tasks)
+	}
+	if _, err := alice.ListTasks(nil); err == nil {
+		t.Error("expected error listing tasks outside of the namespace")
+	}
+	tasks, err = admin.ListTasks(&client.ListTasksOptions{Pattern: "cpu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != "cpu" {
+		t.Errorf("unexpected tasks in default namespace %v", tasks)
+	}
+
+	// Alert topics can be namespaced
+	h, err := admin.CreateTopicHandler(admin.TopicHandlersLink("team-a/cpu"), client.TopicHandlerOptions{
+		ID:      "slack",
+		Kind:    "slack",
+		Options: map[string]interface{}{"channel": "#team-a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := h.Link.Href, "/kapacitor/v1preview/alerts/topics/team-a/cpu/handlers/slack"; got != exp {
+		t.Errorf("unexpected handler link got %s exp %s", got, exp)
+	}
+	handlers, err := admin.ListTopicHandlers(admin.TopicHandlersLink("team-a/cpu"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handlers.Handlers) != 1 || handlers.Handlers[0].ID != "slack" {
+		t.Errorf("unexpected handlers of namespaced topic %v", handlers.Handlers)
+	}
+
+	// Namespaces with tasks cannot be deleted
+	if err := admin.DeleteNamespace(admin.NamespaceLink("team-a")); err == nil {
+		t.Error("expected error deleting namespace with tasks")
+	}
+
+	// Namespaces are persisted
+	s.Restart()
+	admin = newClient("admin", "admin password")
+	namespaces, err := admin.ListNamespaces(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []client.Namespace{{
+		Link:  client.Link{Relation: client.Self, Href: "/kapacitor/v1/namespaces/team-a"},
+		Name:  "team-a",
+		DBRPs: []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+	}}
+	if !reflect.DeepEqual(namespaces, exp) {
+		t.Errorf("unexpected namespaces got %v exp %v", namespaces, exp)
+	}
+	if _, err := admin.TopicHandler(admin.TopicHandlerLink("team-a/cpu", "slack")); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.DeleteTask(admin.TaskLink("team-a/cpu")); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.DeleteNamespace(admin.NamespaceLink("team-a")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_Audit(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.AuthEnabled = true
+	conf.Auth.Enabled = true
+	conf.Auth.BcryptCost = bcrypt.MinCost
+	conf.Auth.BootstrapUsername = "admin"
+	conf.Auth.BootstrapPassword = "admin password"
+	conf.Audit.Enabled = true
+	s := OpenServer(conf)
+	defer s.Close()
+	cli, err := client.New(client.Config{
+		URL: s.URL(),
+		Credentials: &client.Credentials{
+			Method:   client.UserAuthentication,
+			Username: "admin",
+			Password: "admin password",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:         "testTaskID",
+		Type:       client.StreamTask,
+		DBRPs:      []client.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}},
+		TICKscript: "stream|from()",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.UpdateTask(task.Link, client.UpdateTaskOptions{TICKscript: "stream|from().measurement('cpu')"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.DeleteTask(task.Link); err != nil {
+		t.Fatal(err)
+	}
+	// Writes are not audited
+	if _, err := s.Write("mydb", "myrp", "cpu value=1", url.Values{"u": {"admin"}, "p": {"admin password"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := cli.ListAuditEntries(&client.ListAuditEntriesOptions{Resource: "testTaskID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := len(entries), 3; got != exp {
+		t.Fatalf("unexpected number of entries got %d exp %d: %v", got, exp, entries)
+	}
+	for i, method := range []string{"DELETE", "PATCH", "POST"} {
+		e := entries[i]
+		if e.Method != method || e.User != "admin" || e.Status/100 != 2 {
+			t.Errorf("unexpected entry %d: %v", i, e)
+		}
+	}
+	update, del := entries[1], entries[0]
+	if strings.Contains(update.Before, `measurement('cpu')`) || !strings.Contains(update.After, `measurement('cpu')`) {
+		t.Errorf("unexpected update summaries:\nbefore: %s\nafter: %s", update.Before, update.After)
+	}
+	if !strings.Contains(del.Before, `measurement('cpu')`) || del.After != "" {
+		t.Errorf("unexpected delete summaries:\nbefore: %s\nafter: %s", del.Before, del.After)
+	}
+	all, err := cli.ListAuditEntries(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range all {
+		if strings.HasSuffix(e.Path, "/write") {
+			t.Errorf("unexpected audit entry for write %v", e)
+		}
+	}
+}
+
 func TestServer_CreateTask(t *testing.T) {
 	s, cli := OpenDefaultServer()
 	defer s.Close()
@@ -1852,24 +2322,141 @@ test value=1 0000000011
 	}
 }
 
-func TestServer_StreamTask_NoRP(t *testing.T) {
-	conf := NewConfig()
-	conf.DefaultRetentionPolicy = "myrp"
-	s := OpenServer(conf)
+func TestServer_ScraperTargets(t *testing.T) {
+	metrics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# TYPE requests counter\nrequests 42\n")
+	}))
+	defer metrics.Close()
+	up, err := url.Parse(metrics.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing listens on the port of the second target.
+	down := "127.0.0.1:1"
+
+	c := NewConfig()
+	c.StaticDiscovery = []static_discovery.Config{{
+		Enabled: true,
+		ID:      "local",
+		Targets: []string{up.Host, down},
+	}}
+	sc := scraper.Config{}
+	sc.Init()
+	sc.Enabled = true
+	sc.Name = "local"
+	sc.Database = "mydb"
+	sc.RetentionPolicy = "myrp"
+	sc.DiscoverID = "local"
+	sc.DiscoverService = "static-discovery"
+	sc.ScrapeInterval = toml.Duration(100 * time.Millisecond)
+	sc.ScrapeTimeout = toml.Duration(100 * time.Millisecond)
+	c.Scraper = []scraper.Config{sc}
+	s := OpenServer(c)
 	defer s.Close()
 	cli := Client(s)
 
-	id := "testStreamTask"
-	ttype := client.StreamTask
-	dbrps := []client.DBRP{{
-		Database:        "mydb",
-		RetentionPolicy: "myrp",
-	}}
-	tick := `stream
+	// The up points of the scrapes are written to the stream
+	_, err = cli.CreateTask(client.CreateTaskOptions{
+		ID:   "scrapes",
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: `stream
     |from()
-        .measurement('test')
-    |window()
-        .period(10s)
+        .measurement('up')
+        .groupBy('instance')
+    |httpOut('up')
+`,
+		Status: client.Enabled,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Targets are sorted by URL.
+	// The discovered targets are only applied every 5s by the target manager.
+	var targets []client.ScraperTarget
+	var upTarget, downTarget client.ScraperTarget
+	for i := 0; i < 200; i++ {
+		targets, err = cli.ListScraperTargets(cli.ScraperTargetsLink("local"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(targets) == 2 {
+			downTarget, upTarget = targets[0], targets[1]
+			if upTarget.Samples > 0 && downTarget.Health == "down" {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("unexpected targets: %+v", targets)
+	}
+	if got, exp := upTarget.URL, metrics.URL+"/metrics"; got != exp {
+		t.Errorf("unexpected URL: got %s exp %s", got, exp)
+	}
+	if got, exp := upTarget.Labels, map[string]string{"instance": up.Host}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected labels: got %v exp %v", got, exp)
+	}
+	if upTarget.Health != "up" || upTarget.Samples != 1 || upTarget.LastError != "" || upTarget.LastScrape.IsZero() || upTarget.Duration <= 0 {
+		t.Errorf("unexpected status of the up target: %+v", upTarget)
+	}
+	if got, exp := downTarget.URL, "http://"+down+"/metrics"; got != exp {
+		t.Errorf("unexpected URL: got %s exp %s", got, exp)
+	}
+	if downTarget.Health != "down" || downTarget.Samples != 0 || downTarget.LastError == "" {
+		t.Errorf("unexpected status of the down target: %+v", downTarget)
+	}
+
+	if _, err := cli.ListScraperTargets(cli.ScraperTargetsLink("unknown")); err == nil {
+		t.Error("expected error listing the targets of an unknown scraper")
+	}
+
+	// Check the up points of both targets
+	endpoint := fmt.Sprintf("%s/tasks/scrapes/up", s.URL())
+	values := make(map[string]float64)
+	for i := 0; i < 100 && len(values) < 2; i++ {
+		resp, err := http.Get(endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result models.Result
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range result.Series {
+			values[row.Tags["instance"]] = row.Values[0][1].(float64)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if exp := map[string]float64{up.Host: 1, down: 0}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("unexpected up points: got %v exp %v", values, exp)
+	}
+}
+
+func TestServer_StreamTask_NoRP(t *testing.T) {
+	conf := NewConfig()
+	conf.DefaultRetentionPolicy = "myrp"
+	s := OpenServer(conf)
+	defer s.Close()
+	cli := Client(s)
+
+	id := "testStreamTask"
+	ttype := client.StreamTask
+	dbrps := []client.DBRP{{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}}
+	tick := `stream
+    |from()
+        .measurement('test')
+    |window()
+        .period(10s)
         .every(10s)
     |count('value')
     |httpOut('count')
@@ -3184,6 +3771,83 @@ func TestServer_BatchTask_InfluxDBConfigUpdate(t *testing.T) {
 	}
 }
 
+func TestServer_SQLQueryBatchTask(t *testing.T) {
+	registered := false
+	for _, d := range sql.Drivers() {
+		registered = registered || d == "sqlite3"
+	}
+	if !registered {
+		t.Skip("sqlite3 driver requires the sqlite build tag")
+	}
+	dir, err := ioutil.TempDir("", "sql_query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dsn := "file:" + filepath.Join(dir, "kpi.db")
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+CREATE TABLE cpu (time TEXT, host TEXT, value REAL, count INTEGER);
+INSERT INTO cpu VALUES
+    ('2018-01-01T00:00:00Z', 'serverA', 0.5, 1),
+    ('2018-01-01T00:00:10Z', 'serverA', 0.7, 2),
+    ('2018-01-01T00:00:00Z', 'serverB', 0.1, NULL);
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	s := OpenServer(c)
+	defer s.Close()
+	cli := Client(s)
+
+	id := "testSQLQueryBatchTask"
+	dbrps := []client.DBRP{{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}}
+	tick := fmt.Sprintf(`batch
+    |sqlQuery('SELECT time, host, value, count FROM cpu ORDER BY host, time')
+        .driver('sqlite3')
+        .dsn('%s')
+        .every(5ms)
+        .measurement('cpu')
+        .timeColumn('time')
+        .tags('host')
+    |groupBy('host')
+    |httpOut('cpu')
+`, dsn)
+
+	_, err = cli.CreateTask(client.CreateTaskOptions{
+		ID:         id,
+		Type:       client.BatchTask,
+		DBRPs:      dbrps,
+		TICKscript: tick,
+		Status:     client.Enabled,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint := fmt.Sprintf("%s/tasks/%s/cpu", s.URL(), id)
+	exp := fmt.Sprintf(`{"series":[`+
+		`{"name":"cpu","tags":{"host":"serverA"},"columns":["time","count","value"],"values":[["%s",1,0.5],["%s",2,0.7]]},`+
+		`{"name":"cpu","tags":{"host":"serverB"},"columns":["time","value"],"values":[["%s",0.1]]}`+
+		`]}`,
+		time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).Local().Format(time.RFC3339Nano),
+		time.Date(2018, 1, 1, 0, 0, 10, 0, time.UTC).Local().Format(time.RFC3339Nano),
+		time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).Local().Format(time.RFC3339Nano),
+	)
+	if err := s.HTTPGetRetry(endpoint, exp, 100, time.Millisecond*5); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestServer_InvalidBatchTask(t *testing.T) {
 	c := NewConfig()
 	c.InfluxDB[0].Enabled = true
@@ -3335,108 +3999,983 @@ test value=1 0000000012
 	if err != nil {
 		t.Fatal(err)
 	}
-	if exp, got := "/kapacitor/v1/replays/replayid", replay.Link.Href; exp != got {
-		t.Errorf("unexpected replay.Link.Href got %s exp %s", got, exp)
-	}
-	if exp, got := id, replay.Task; exp != got {
-		t.Errorf("unexpected replay.Task got %s exp %s", got, exp)
+	if exp, got := "/kapacitor/v1/replays/replayid", replay.Link.Href; exp != got {
+		t.Errorf("unexpected replay.Link.Href got %s exp %s", got, exp)
+	}
+	if exp, got := id, replay.Task; exp != got {
+		t.Errorf("unexpected replay.Task got %s exp %s", got, exp)
+	}
+
+	retry = 0
+	for replay.Status == client.Running {
+		time.Sleep(100 * time.Millisecond)
+		replay, err = cli.Replay(replay.Link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		retry++
+		if retry > 10 {
+			t.Fatal("failed to finish replay")
+		}
+	}
+	if replay.Status != client.Finished || replay.Error != "" {
+		t.Errorf("replay failed: %s", replay.Error)
+	}
+
+	f, err := os.Open(filepath.Join(tmpDir, "alert.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	type response struct {
+		ID      string          `json:"id"`
+		Message string          `json:"message"`
+		Time    time.Time       `json:"time"`
+		Level   string          `json:"level"`
+		Data    influxql.Result `json:"data"`
+	}
+	exp := response{
+		ID:      "test-count",
+		Message: "test-count got: 15",
+		Time:    time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC),
+		Level:   "CRITICAL",
+		Data: influxql.Result{
+			Series: imodels.Rows{
+				{
+					Name:    "test",
+					Columns: []string{"time", "count"},
+					Values: [][]interface{}{
+						{
+							time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC).Format(time.RFC3339Nano),
+							15.0,
+						},
+					},
+				},
+			},
+		},
+	}
+	got := response{}
+	d := json.NewDecoder(f)
+	d.Decode(&got)
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected alert log:\ngot %v\nexp %v", got, exp)
+	}
+
+	recordings, err := cli.ListRecordings(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if exp, got := 1, len(recordings); exp != got {
+		t.Fatalf("unexpected recordings list:\ngot %v\nexp %v\nrecordings %v", got, exp, recordings)
+	}
+
+	err = cli.DeleteRecording(recordings[0].Link)
+	if err != nil {
+		t.Error(err)
+	}
+
+	recordings, err = cli.ListRecordings(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if exp, got := 0, len(recordings); exp != got {
+		t.Errorf("unexpected recordings list after delete:\ngot %v\nexp %v\nrecordings %v", got, exp, recordings)
+	}
+
+	replays, err := cli.ListReplays(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if exp, got := 1, len(replays); exp != got {
+		t.Fatalf("unexpected replays list:\ngot %v\nexp %v\nreplays %v", got, exp, replays)
+	}
+
+	err = cli.DeleteReplay(replays[0].Link)
+	if err != nil {
+		t.Error(err)
+	}
+
+	replays, err = cli.ListReplays(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if exp, got := 0, len(replays); exp != got {
+		t.Errorf("unexpected replays list after delete:\ngot %v\nexp %v\nreplays %v", got, exp, replays)
+	}
+}
+
+func TestServer_TestTask(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	tick := `stream
+    |from()
+        .measurement('test')
+    |window()
+        .period(10s)
+        .every(10s)
+    |count('value')
+    |httpOut('count')
+    |alert()
+        .id('test-count')
+        .message('{{ .ID }} got: {{ index .Fields "count" }}')
+        .crit(lambda: TRUE)
+    |influxDBOut()
+        .database('out')
+        .retentionPolicy('autogen')
+        .measurement('counts')
+`
+	points := `test value=1 0000000000
+test value=1 0000000001
+test value=1 0000000002
+test value=1 0000000003
+test value=1 0000000004
+test value=1 0000000005
+test value=1 0000000006
+test value=1 0000000007
+test value=1 0000000008
+test value=1 0000000009
+test value=1 0000000010
+test value=1 0000000011
+test value=1 0000000012
+test value=1 0000000013
+test value=1 0000000014
+test value=1 0000000015
+test value=1 0000000016
+test value=1 0000000017
+test value=1 0000000018
+test value=1 0000000019
+test value=1 0000000020
+`
+	opt := client.TestTaskOptions{
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: tick,
+		Data:       points,
+		Precision:  "s",
+		Expected: client.TestTaskExpectations{
+			Alerts: []client.TestAlert{
+				{
+					ID:      "test-count",
+					Message: "test-count got: 10",
+					Level:   "CRITICAL",
+					Time:    time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC),
+				},
+				{
+					ID:      "test-count",
+					Message: "test-count got: 10",
+					Level:   "CRITICAL",
+					Time:    time.Date(1970, 1, 1, 0, 0, 20, 0, time.UTC),
+				},
+			},
+			Writes: []client.TestPoint{
+				{
+					Database:        "out",
+					RetentionPolicy: "autogen",
+					Name:            "counts",
+					Fields:          map[string]interface{}{"count": 10.0},
+					Time:            time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC),
+				},
+				{
+					Database:        "out",
+					RetentionPolicy: "autogen",
+					Name:            "counts",
+					Fields:          map[string]interface{}{"count": 10.0},
+					Time:            time.Date(1970, 1, 1, 0, 0, 20, 0, time.UTC),
+				},
+			},
+			HTTPOut: map[string]*influxql.Result{
+				"count": {
+					Series: imodels.Rows{{
+						Name:    "test",
+						Columns: []string{"time", "count"},
+						Values: [][]interface{}{{
+							"1970-01-01T00:00:20Z",
+							10.0,
+						}},
+					}},
+				},
+			},
+		},
+	}
+	result, err := cli.TestTask(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Error != "" {
+		t.Fatalf("unexpected test error: %s", result.Error)
+	}
+	if !result.Passed {
+		t.Errorf("expected test to pass, got failures: %v", result.Failures)
+	}
+
+	// Expect a different level for the second alert
+	opt.Expected.Alerts[1].Level = "WARNING"
+	result, err = cli.TestTask(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Passed {
+		t.Error("expected test to fail")
+	}
+	if got, exp := len(result.Failures), 1; got != exp {
+		t.Fatalf("unexpected number of failures got %d exp %d: %v", got, exp, result.Failures)
+	}
+	if got, exp := result.Failures[0].Output, "alerts"; got != exp {
+		t.Errorf("unexpected failure output got %s exp %s", got, exp)
+	}
+	if got, exp := result.Failures[0].Index, 1; got != exp {
+		t.Errorf("unexpected failure index got %d exp %d", got, exp)
+	}
+
+	// The tested task must not be saved
+	tasks, err := cli.ListTasks(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := len(tasks), 0; got != exp {
+		t.Errorf("unexpected number of tasks got %d exp %d", got, exp)
+	}
+}
+
+func TestServer_TestTask_ExternalNodes(t *testing.T) {
+	requests := make(chan string, 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	c := NewConfig()
+	c.Kubernetes = k8s.Configs{k8s.NewConfig()}
+	c.Kubernetes[0].Enabled = true
+	c.Kubernetes[0].APIServers = []string{ts.URL}
+	s := OpenServer(c)
+	defer s.Close()
+	cli := Client(s)
+
+	testCases := map[string]string{
+		"http_post2": fmt.Sprintf(`stream
+    |from()
+        .measurement('test')
+    |httpPost('%s')
+`, ts.URL),
+		"k8s_autoscale2": `stream
+    |from()
+        .measurement('test')
+    |k8sAutoscale()
+        .resourceName('serviceA')
+        .replicas(lambda: int("value"))
+`,
+	}
+	for node, tick := range testCases {
+		result, err := cli.TestTask(client.TestTaskOptions{
+			DBRPs: []client.DBRP{{
+				Database:        "mydb",
+				RetentionPolicy: "myrp",
+			}},
+			TICKscript: tick,
+			Data:       "test value=2 0000000000\ntest value=3 0000000001\n",
+			Precision:  "s",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := "cannot test task with node " + node + ", it acts on an external system"; result.Error != exp {
+			t.Errorf("%s: unexpected test error got %q exp %q", node, result.Error, exp)
+		}
+	}
+	if got := len(requests); got != 0 {
+		t.Errorf("unexpected requests to external system: %d", got)
+	}
+}
+
+func TestServer_RecordReplayStream_ScaledClock(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	id := "testStreamTask"
+	tick := `stream
+    |from()
+        .measurement('test')
+    |window()
+        .period(10s)
+        .every(10s)
+    |count('value')
+    |httpOut('count')
+`
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:   id,
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: tick,
+		Status:     client.Disabled,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording, err := cli.RecordStream(client.RecordStreamOptions{
+		ID:   "recordingid",
+		Task: task.ID,
+		Stop: time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := `test value=1 0000000000
+test value=1 0000000001
+test value=1 0000000005
+test value=1 0000000009
+test value=1 0000000010
+test value=1 0000000011
+`
+	v := url.Values{}
+	v.Add("precision", "s")
+	s.MustWrite("mydb", "myrp", points, v)
+
+	retry := 0
+	for recording.Status == client.Running {
+		time.Sleep(100 * time.Millisecond)
+		recording, err = cli.Recording(recording.Link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		retry++
+		if retry > 10 {
+			t.Fatal("failed to finish recording")
+		}
+	}
+	if recording.Status != client.Finished || recording.Error != "" {
+		t.Fatalf("recording failed: %s", recording.Error)
+	}
+
+	replay, err := cli.CreateReplay(client.CreateReplayOptions{
+		ID:            "replayid",
+		Task:          id,
+		Recording:     recording.ID,
+		Clock:         client.Scaled,
+		Scale:         0.1,
+		RecordingTime: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, got := client.Scaled, replay.Clock; exp != got {
+		t.Errorf("unexpected replay.Clock got %v exp %v", got, exp)
+	}
+	if exp, got := 0.1, replay.Scale; exp != got {
+		t.Errorf("unexpected replay.Scale got %v exp %v", got, exp)
+	}
+
+	replay, err = cli.UpdateReplay(replay.Link, client.UpdateReplayOptions{Action: client.PauseReplay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replay.Paused {
+		t.Error("expected replay to be paused")
+	}
+	position := replay.Position
+
+	replay, err = cli.UpdateReplay(replay.Link, client.UpdateReplayOptions{
+		Action: client.StepReplay,
+		Step:   client.Duration(5 * time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, got := position+client.Duration(5*time.Second), replay.Position; exp != got {
+		t.Errorf("unexpected replay.Position after step got %v exp %v", got, exp)
+	}
+	if !replay.Paused {
+		t.Error("expected replay to remain paused after step")
+	}
+
+	if _, err := cli.UpdateReplay(replay.Link, client.UpdateReplayOptions{
+		Action:   client.SeekReplay,
+		Position: client.Duration(time.Second),
+	}); err == nil {
+		t.Error("expected error seeking backwards")
+	}
+
+	replay, err = cli.UpdateReplay(replay.Link, client.UpdateReplayOptions{
+		Action:   client.SeekReplay,
+		Position: client.Duration(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retry = 0
+	for replay.Status == client.Running {
+		time.Sleep(100 * time.Millisecond)
+		replay, err = cli.Replay(replay.Link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		retry++
+		if retry > 10 {
+			t.Fatal("failed to finish replay")
+		}
+	}
+	if replay.Status != client.Finished || replay.Error != "" {
+		t.Errorf("replay failed: %s", replay.Error)
+	}
+
+	if _, err := cli.UpdateReplay(replay.Link, client.UpdateReplayOptions{Action: client.ResumeReplay}); err == nil {
+		t.Error("expected error controlling a finished replay")
+	}
+}
+
+func TestServer_RecordStream_Truncated(t *testing.T) {
+	c := NewConfig()
+	c.Replay.MaxRecordingSize = 150
+	s := OpenServer(c)
+	cli := Client(s)
+	defer s.Close()
+
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:   "testStreamTask",
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: `stream
+    |from()
+        .measurement('test')
+`,
+		Status: client.Disabled,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording, err := cli.RecordStream(client.RecordStreamOptions{
+		ID:   "recordingid",
+		Task: task.ID,
+		Stop: time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := `test value=1 0000000000
+test value=1 0000000001
+test value=1 0000000002
+test value=1 0000000003
+test value=1 0000000004
+test value=1 0000000005
+test value=1 0000000006
+test value=1 0000000007
+`
+	v := url.Values{}
+	v.Add("precision", "s")
+	s.MustWrite("mydb", "myrp", points, v)
+
+	retry := 0
+	for recording.Status == client.Running {
+		time.Sleep(100 * time.Millisecond)
+		recording, err = cli.Recording(recording.Link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		retry++
+		if retry > 10 {
+			t.Fatal("failed to finish recording")
+		}
+	}
+	if got, exp := recording.Status, client.Truncated; got != exp {
+		t.Fatalf("unexpected recording status got %v exp %v: %s", got, exp, recording.Error)
+	}
+	if recording.Error == "" {
+		t.Error("expected truncated recording to report the size limit")
+	}
+	if recording.Size <= 0 {
+		t.Errorf("expected truncated recording to contain data, got size %d", recording.Size)
+	}
+
+	// A truncated recording can still be replayed.
+	replay, err := cli.CreateReplay(client.CreateReplayOptions{
+		ID:            "replayid",
+		Task:          task.ID,
+		Recording:     recording.ID,
+		Clock:         client.Fast,
+		RecordingTime: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry = 0
+	for replay.Status == client.Running {
+		time.Sleep(100 * time.Millisecond)
+		replay, err = cli.Replay(replay.Link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		retry++
+		if retry > 10 {
+			t.Fatal("failed to finish replay")
+		}
+	}
+	if replay.Status != client.Finished || replay.Error != "" {
+		t.Errorf("replay failed: %s", replay.Error)
+	}
+}
+
+func TestServer_RecordingRetention_MaxCount(t *testing.T) {
+	c := NewConfig()
+	c.Replay.MaxCount = 1
+	c.Replay.RetentionCheckInterval = toml.Duration(10 * time.Millisecond)
+	s := OpenServer(c)
+	cli := Client(s)
+	defer s.Close()
+
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:   "testStreamTask",
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: `stream
+    |from()
+        .measurement('test')
+`,
+		Status: client.Disabled,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := url.Values{}
+	v.Add("precision", "s")
+	for i, id := range []string{"first", "second"} {
+		recording, err := cli.RecordStream(client.RecordStreamOptions{
+			ID:   id,
+			Task: task.ID,
+			Stop: time.Unix(int64(i*10+5), 0).UTC(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.MustWrite("mydb", "myrp", fmt.Sprintf("test value=1 %d\ntest value=1 %d\n", i*10, i*10+10), v)
+		retry := 0
+		for recording.Status == client.Running {
+			time.Sleep(100 * time.Millisecond)
+			recording, err = cli.Recording(recording.Link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			retry++
+			if retry > 10 {
+				t.Fatal("failed to finish recording")
+			}
+		}
+	}
+
+	retry := 0
+	for {
+		recordings, err := cli.ListRecordings(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recordings) == 1 {
+			if got, exp := recordings[0].ID, "second"; got != exp {
+				t.Errorf("unexpected remaining recording got %s exp %s", got, exp)
+			}
+			break
+		}
+		retry++
+		if retry > 10 {
+			t.Fatalf("expected oldest recording to be deleted, got %d recordings", len(recordings))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestServer_FlightRecorder(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	id := "testFlightTask"
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:   id,
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: `stream
+    |from()
+        .measurement('test')
+    |alert()
+        .crit(lambda: "value" > 10)
+`,
+		Status: client.Enabled,
+		FlightRecorder: &client.FlightRecorder{
+			Window:           client.Duration(time.Minute),
+			FreezeOnCritical: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := task.FlightRecorder, (client.FlightRecorder{Window: client.Duration(time.Minute), FreezeOnCritical: true}); got != exp {
+		t.Errorf("unexpected flight recorder got %v exp %v", got, exp)
+	}
+
+	points := `test value=1 0000000000
+test value=1 0000000001
+test value=1 0000000002
+test value=20 0000000003
+test value=1 0000000004
+`
+	v := url.Values{}
+	v.Add("precision", "s")
+	s.MustWrite("mydb", "myrp", points, v)
+
+	// The critical alert freezes the flight recorder
+	retry := 0
+	for {
+		recordings, err := cli.ListRecordings(&client.ListRecordingsOptions{
+			Pattern: id + "-critical-*",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recordings) == 1 && recordings[0].Status == client.Finished {
+			if recordings[0].Size <= 0 {
+				t.Errorf("expected frozen recording to contain data, got size %d", recordings[0].Size)
+			}
+			break
+		}
+		retry++
+		if retry > 10 {
+			t.Fatalf("expected flight recorder to be frozen on critical alert, got %v", recordings)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	recording, err := cli.RecordFlightRecorder(client.RecordFlightRecorderOptions{
+		ID:   "frozen",
+		Task: id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recording.Status != client.Finished || recording.Error != "" {
+		t.Errorf("recording failed: %s", recording.Error)
+	}
+	if recording.Size <= 0 {
+		t.Errorf("expected frozen recording to contain data, got size %d", recording.Size)
+	}
+
+	// Tasks without a flight recorder cannot be frozen
+	if _, err := cli.RecordFlightRecorder(client.RecordFlightRecorderOptions{Task: "unknown"}); err == nil {
+		t.Error("expected error freezing flight recorder of unknown task")
+	}
+}
+
+func TestServer_TaskLimits(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	dbrps := []client.DBRP{{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}}
+	limits := client.TaskLimits{
+		BufferedPoints: client.Limit{Max: 3, Policy: client.LimitDrop},
+	}
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:    "testDropTask",
+		Type:  client.StreamTask,
+		DBRPs: dbrps,
+		TICKscript: `stream
+    |from()
+        .measurement('test')
+    |window()
+        .period(1h)
+        .every(1h)
+    |count('value')
+`,
+		Status: client.Enabled,
+		Limits: &limits,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := task.Limits, limits; got != exp {
+		t.Errorf("unexpected limits got %v exp %v", got, exp)
+	}
+
+	errTask, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:    "testErrorTask",
+		Type:  client.StreamTask,
+		DBRPs: dbrps,
+		TICKscript: `stream
+    |from()
+        .measurement('test')
+        .groupBy('host')
+    |window()
+        .period(1h)
+        .every(1h)
+`,
+		Status: client.Enabled,
+		Limits: &client.TaskLimits{
+			Groups: client.Limit{Max: 2, Policy: client.LimitError},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := `test,host=a value=1 0000000000
+test,host=b value=1 0000000001
+test,host=c value=1 0000000002
+test,host=a value=1 0000000003
+test,host=b value=1 0000000004
+test,host=c value=1 0000000005
+`
+	v := url.Values{}
+	v.Add("precision", "s")
+	s.MustWrite("mydb", "myrp", points, v)
+
+	// The window buffers no more points than its limit
+	retry := 0
+	for {
+		task, err = cli.Task(task.Link, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := task.ExecutionStats.TaskStats
+		if stats["limit_dropped"] == 3.0 {
+			if got, exp := stats["buffered_points"], 3.0; got != exp {
+				t.Errorf("unexpected buffered points got %v exp %v", got, exp)
+			}
+			break
+		}
+		retry++
+		if retry > 10 {
+			t.Fatalf("expected points to be dropped by limit, got stats %v", stats)
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
 
+	// The third group stops the task with an error
 	retry = 0
-	for replay.Status == client.Running {
-		time.Sleep(100 * time.Millisecond)
-		replay, err = cli.Replay(replay.Link)
+	for {
+		errTask, err = cli.Task(errTask.Link, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if !errTask.Executing {
+			if got, exp := errTask.Error, "window2: task groups limit of 2 reached"; got != exp {
+				t.Errorf("unexpected task error got %q exp %q", got, exp)
+			}
+			break
+		}
 		retry++
 		if retry > 10 {
-			t.Fatal("failed to finish replay")
+			t.Fatal("expected task to stop once its groups limit was reached")
 		}
+		time.Sleep(100 * time.Millisecond)
 	}
-	if replay.Status != client.Finished || replay.Error != "" {
-		t.Errorf("replay failed: %s", replay.Error)
+
+	// Negative limits are invalid
+	if _, err := cli.UpdateTask(task.Link, client.UpdateTaskOptions{
+		Limits: &client.TaskLimits{CPU: client.Limit{Max: -1}},
+	}); err == nil {
+		t.Error("expected error updating task with negative limit")
 	}
+}
 
-	f, err := os.Open(filepath.Join(tmpDir, "alert.log"))
+func TestServer_EdgePolicies(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	policies := client.EdgePolicies{
+		Default: client.EdgeDropOldest,
+		Nodes: map[string]client.EdgePolicy{
+			"window2": client.EdgeSpillToDisk,
+		},
+	}
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:   "testEdgePolicies",
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: `stream
+    |from()
+        .measurement('test')
+    |window()
+        .period(10s)
+        .every(10s)
+    |count('value')
+`,
+		Status:       client.Enabled,
+		EdgePolicies: &policies,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer f.Close()
-	type response struct {
-		ID      string          `json:"id"`
-		Message string          `json:"message"`
-		Time    time.Time       `json:"time"`
-		Level   string          `json:"level"`
-		Data    influxql.Result `json:"data"`
-	}
-	exp := response{
-		ID:      "test-count",
-		Message: "test-count got: 15",
-		Time:    time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC),
-		Level:   "CRITICAL",
-		Data: influxql.Result{
-			Series: imodels.Rows{
-				{
-					Name:    "test",
-					Columns: []string{"time", "count"},
-					Values: [][]interface{}{
-						{
-							time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC).Format(time.RFC3339Nano),
-							15.0,
-						},
-					},
-				},
-			},
-		},
-	}
-	got := response{}
-	d := json.NewDecoder(f)
-	d.Decode(&got)
-	if !reflect.DeepEqual(exp, got) {
-		t.Errorf("unexpected alert log:\ngot %v\nexp %v", got, exp)
+	if got, exp := task.EdgePolicies, policies; !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected edge policies got %v exp %v", got, exp)
 	}
 
-	recordings, err := cli.ListRecordings(nil)
+	points := `test value=1 0000000000
+test value=1 0000000001
+test value=1 0000000011
+`
+	v := url.Values{}
+	v.Add("precision", "s")
+	s.MustWrite("mydb", "myrp", points, v)
+
+	task, err = cli.UpdateTask(task.Link, client.UpdateTaskOptions{
+		EdgePolicies: &client.EdgePolicies{Default: client.EdgeDropNewest},
+	})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if exp, got := 1, len(recordings); exp != got {
-		t.Fatalf("unexpected recordings list:\ngot %v\nexp %v\nrecordings %v", got, exp, recordings)
+	if got, exp := task.EdgePolicies, (client.EdgePolicies{Default: client.EdgeDropNewest}); !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected edge policies got %v exp %v", got, exp)
 	}
-
-	err = cli.DeleteRecording(recordings[0].Link)
-	if err != nil {
-		t.Error(err)
+	if !task.Executing {
+		t.Errorf("expected task to be executing, error: %s", task.Error)
 	}
 
-	recordings, err = cli.ListRecordings(nil)
-	if err != nil {
-		t.Error(err)
+	// Unknown node names are rejected
+	_, err = cli.UpdateTask(task.Link, client.UpdateTaskOptions{
+		EdgePolicies: &client.EdgePolicies{
+			Nodes: map[string]client.EdgePolicy{"windw2": client.EdgeSpillToDisk},
+		},
+	})
+	if got, exp := fmt.Sprint(err), `edge policy of unknown node "windw2", the task has no such node`; got != exp {
+		t.Errorf("unexpected update error got %q exp %q", got, exp)
 	}
-	if exp, got := 0, len(recordings); exp != got {
-		t.Errorf("unexpected recordings list after delete:\ngot %v\nexp %v\nrecordings %v", got, exp, recordings)
+	_, err = cli.CreateTask(client.CreateTaskOptions{
+		ID:   "testEdgePoliciesUnknownNode",
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: `stream
+    |from()
+        .measurement('test')
+`,
+		EdgePolicies: &client.EdgePolicies{
+			Nodes: map[string]client.EdgePolicy{"window2": client.EdgeSpillToDisk},
+		},
+	})
+	if got, exp := fmt.Sprint(err), `edge policy of unknown node "window2", the task has no such node`; got != exp {
+		t.Errorf("unexpected create error got %q exp %q", got, exp)
 	}
+}
 
-	replays, err := cli.ListReplays(nil)
+func TestServer_Metrics(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	tmpDir, err := ioutil.TempDir("", "TestServer_Metrics")
 	if err != nil {
-		t.Error(err)
-	}
-	if exp, got := 1, len(replays); exp != got {
-		t.Fatalf("unexpected replays list:\ngot %v\nexp %v\nreplays %v", got, exp, replays)
+		t.Fatal(err)
 	}
+	defer os.RemoveAll(tmpDir)
 
-	err = cli.DeleteReplay(replays[0].Link)
-	if err != nil {
-		t.Error(err)
+	if _, err := cli.CreateTopicHandler(cli.TopicHandlersLink("test"), client.TopicHandlerOptions{
+		ID:   "testMetricsHandler",
+		Kind: "log",
+		Options: map[string]interface{}{
+			"path": filepath.Join(tmpDir, "alert.log"),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:   "testMetrics",
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: `stream
+    |from()
+        .measurement('test')
+    |alert()
+        .topic('test')
+        .id('id')
+        .crit(lambda: TRUE)
+`,
+		Status: client.Enabled,
+	}); err != nil {
+		t.Fatal(err)
 	}
 
-	replays, err = cli.ListReplays(nil)
-	if err != nil {
-		t.Error(err)
+	v := url.Values{}
+	v.Add("precision", "s")
+	s.MustWrite("mydb", "myrp", "test value=1 0000000000", v)
+
+	exp := []string{
+		"# TYPE kapacitor_nodes_alerts_triggered counter\n",
+		`kapacitor_nodes_alerts_triggered{cluster_id="`,
+		`kind="alert",node="alert2",server_id="`,
+		`task="testMetrics",type="stream"} 1` + "\n",
+		`kapacitor_edges_queue_depth{child="alert2",cluster_id="`,
+		`kapacitor_topics_collected{cluster_id="`,
+		`id="test",server_id="`,
+		`kapacitor_handlers_handled{cluster_id="`,
+		`handler="testMetricsHandler",host="`,
+		`kind="log",server_id="`,
+		`topic="test"} 1` + "\n",
+		"# TYPE kapacitor_edges_queue_depth gauge\n",
+		"# TYPE kapacitor_num_tasks gauge\n",
+		"kapacitor_num_tasks 1\n",
+		"kapacitor_runtime_num_goroutine ",
+		"process_start_time_seconds ",
+	}
+	// The alert is handled asynchronously, retry until all metrics are present.
+	var body string
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get("http://" + s.HTTPDService.Addr().String() + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, exp := resp.StatusCode, http.StatusOK; got != exp {
+			t.Fatalf("unexpected status code got %d exp %d: %s", got, exp, string(b))
+		}
+		if got, exp := resp.Header.Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8"; got != exp {
+			t.Fatalf("unexpected content type got %q exp %q", got, exp)
+		}
+		body = string(b)
+		missing := false
+		for _, e := range exp {
+			if !strings.Contains(body, e) {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
-	if exp, got := 0, len(replays); exp != got {
-		t.Errorf("unexpected replays list after delete:\ngot %v\nexp %v\nreplays %v", got, exp, replays)
+	for _, e := range exp {
+		if !strings.Contains(body, e) {
+			t.Errorf("missing %q in metrics:\n%s", e, body)
+		}
 	}
 }
 
@@ -5825,7 +7364,7 @@ func TestServer_CreateTask_ValidIDs(t *testing.T) {
 			valid: false,
 		},
 		{
-			id:    "task/id7",
+			id:    "task/id/7",
 			valid: false,
 		},
 	}
@@ -5949,7 +7488,7 @@ func TestServer_CreateRecording_ValidIDs(t *testing.T) {
 			valid: false,
 		},
 		{
-			id:    "recording/id7",
+			id:    "recording/id/7",
 			valid: false,
 		},
 	}
@@ -6791,14 +8330,15 @@ func TestServer_UpdateConfig(t *testing.T) {
 				Elements: []client.ConfigElement{{
 					Link: client.Link{Relation: client.Self, Href: "/kapacitor/v1/config/kubernetes/"},
 					Options: map[string]interface{}{
-						"id":          "",
-						"api-servers": []interface{}{"http://localhost:80001"},
-						"ca-path":     "",
-						"enabled":     false,
-						"in-cluster":  false,
-						"namespace":   "",
-						"token":       false,
-						"resource":    "",
+						"id":                   "",
+						"api-servers":          []interface{}{"http://localhost:80001"},
+						"annotation-discovery": false,
+						"ca-path":              "",
+						"enabled":              false,
+						"in-cluster":           false,
+						"namespace":            "",
+						"token":                false,
+						"resource":             "",
 					},
 					Redacted: []string{
 						"token",
@@ -6808,14 +8348,15 @@ func TestServer_UpdateConfig(t *testing.T) {
 			expDefaultElement: client.ConfigElement{
 				Link: client.Link{Relation: client.Self, Href: "/kapacitor/v1/config/kubernetes/"},
 				Options: map[string]interface{}{
-					"id":          "",
-					"api-servers": []interface{}{"http://localhost:80001"},
-					"ca-path":     "",
-					"enabled":     false,
-					"in-cluster":  false,
-					"namespace":   "",
-					"token":       false,
-					"resource":    "",
+					"id":                   "",
+					"api-servers":          []interface{}{"http://localhost:80001"},
+					"annotation-discovery": false,
+					"ca-path":              "",
+					"enabled":              false,
+					"in-cluster":           false,
+					"namespace":            "",
+					"token":                false,
+					"resource":             "",
 				},
 				Redacted: []string{
 					"token",
@@ -6833,14 +8374,15 @@ func TestServer_UpdateConfig(t *testing.T) {
 						Elements: []client.ConfigElement{{
 							Link: client.Link{Relation: client.Self, Href: "/kapacitor/v1/config/kubernetes/"},
 							Options: map[string]interface{}{
-								"id":          "",
-								"api-servers": []interface{}{"http://localhost:80001"},
-								"ca-path":     "",
-								"enabled":     false,
-								"in-cluster":  false,
-								"namespace":   "",
-								"token":       true,
-								"resource":    "",
+								"id":                   "",
+								"api-servers":          []interface{}{"http://localhost:80001"},
+								"annotation-discovery": false,
+								"ca-path":              "",
+								"enabled":              false,
+								"in-cluster":           false,
+								"namespace":            "",
+								"token":                true,
+								"resource":             "",
 							},
 							Redacted: []string{
 								"token",
@@ -6850,14 +8392,15 @@ func TestServer_UpdateConfig(t *testing.T) {
 					expElement: client.ConfigElement{
 						Link: client.Link{Relation: client.Self, Href: "/kapacitor/v1/config/kubernetes/"},
 						Options: map[string]interface{}{
-							"id":          "",
-							"api-servers": []interface{}{"http://localhost:80001"},
-							"ca-path":     "",
-							"enabled":     false,
-							"in-cluster":  false,
-							"namespace":   "",
-							"token":       true,
-							"resource":    "",
+							"id":                   "",
+							"api-servers":          []interface{}{"http://localhost:80001"},
+							"annotation-discovery": false,
+							"ca-path":              "",
+							"enabled":              false,
+							"in-cluster":           false,
+							"namespace":            "",
+							"token":                true,
+							"resource":             "",
 						},
 						Redacted: []string{
 							"token",
@@ -8051,6 +9594,16 @@ func TestServer_ListServiceTests(t *testing.T) {
 					"cluster": "",
 				},
 			},
+			{
+				Link: client.Link{Relation: client.Self, Href: "/kapacitor/v1/service-tests/kafka"},
+				Name: "kafka",
+				Options: client.ServiceTestOptions{
+					"cluster": "",
+					"topic":   "",
+					"key":     "",
+					"message": "test kafka message",
+				},
+			},
 			{
 				Link: client.Link{Relation: client.Self, Href: "/kapacitor/v1/service-tests/kubernetes"},
 				Name: "kubernetes",
@@ -8398,6 +9951,17 @@ func TestServer_DoServiceTest(t *testing.T) {
 				Message: "cluster \"default\" is not enabled or does not exist",
 			},
 		},
+		{
+			service: "kafka",
+			options: client.ServiceTestOptions{
+				"cluster": "default",
+				"topic":   "test",
+			},
+			exp: client.ServiceTestResult{
+				Success: false,
+				Message: "unknown or disabled kafka cluster \"default\"",
+			},
+		},
 		{
 			service: "kubernetes",
 			options: client.ServiceTestOptions{
@@ -8805,6 +10369,43 @@ func TestServer_AlertHandlers(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			handler: client.TopicHandler{
+				Kind: "kafka",
+				Options: map[string]interface{}{
+					"cluster": "test",
+					"topic":   "alerts",
+				},
+			},
+			setup: func(c *server.Config, ha *client.TopicHandler) (context.Context, error) {
+				pc := new(kafkatest.ProducerCreator)
+				ctxt := context.WithValue(nil, "producerCreator", pc)
+
+				kc := kafka.NewConfig()
+				kc.Enabled = true
+				kc.ID = "test"
+				kc.Brokers = []string{"kafka.example.com:9092"}
+				kc.NewProducerF = pc.NewProducer
+				c.Kafka = kafka.Configs{kc}
+				return ctxt, nil
+			},
+			result: func(ctxt context.Context) error {
+				pc := ctxt.Value("producerCreator").(*kafkatest.ProducerCreator)
+				if got, exp := len(pc.Producers), 1; got != exp {
+					return fmt.Errorf("unexpected number of producers created : exp %d got: %d", exp, got)
+				}
+				got := pc.Messages()
+				exp := []kafkatest.Message{{
+					Topic: "alerts",
+					Key:   "id",
+					Value: string(adJSON),
+				}}
+				if !reflect.DeepEqual(exp, got) {
+					return fmt.Errorf("unexpected kafka messages:\nexp\n%+v\ngot\n%+v\n", exp, got)
+				}
+				return nil
+			},
+		},
 		{
 			handler: client.TopicHandler{
 				Kind: "log",
@@ -10758,6 +12359,58 @@ func TestStorage_Backup(t *testing.T) {
 	}
 }
 
+func TestStorage_LevelDB(t *testing.T) {
+	c := NewConfig()
+	c.Storage.Backend = "leveldb"
+	c.Storage.LevelDBPath = filepath.Join(filepath.Dir(c.Storage.BoltDBPath), "kapacitor.leveldb")
+	s := OpenServer(c)
+	cli := Client(s)
+	defer s.Close()
+
+	tick := `stream
+    |from()
+        .measurement('test')
+`
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:   "testTaskID",
+		Type: client.StreamTask,
+		DBRPs: []client.DBRP{{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		}},
+		TICKscript: tick,
+		Status:     client.Disabled,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Backups are only supported by the bolt backend
+	resp, err := http.Get(s.URL() + "/storage/backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, exp := resp.StatusCode, http.StatusNotImplemented; got != exp {
+		t.Errorf("unexpected backup status code got %d exp %d", got, exp)
+	}
+
+	// Restart the server and check the task was persisted
+	s.Stop()
+	s.Start()
+
+	ti, err := cli.Task(task.Link, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.ID != task.ID {
+		t.Fatalf("unexpected id got %s exp %s", ti.ID, task.ID)
+	}
+	if ti.TICKscript != tick {
+		t.Fatalf("unexpected TICKscript got %s exp %s", ti.TICKscript, tick)
+	}
+}
+
 func TestLoadService(t *testing.T) {
 	s, c, cli := OpenLoadServer()
 
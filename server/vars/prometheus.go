@@ -0,0 +1,237 @@
+package vars
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PrometheusContentType is the content type of the Prometheus text exposition format.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Kinds of the values of the statistics, by their key.
+// Values of other keys are exposed as untyped metrics.
+var (
+	promCounters = map[string]bool{
+		// Nodes, edges and tasks
+		"collected":            true,
+		"emitted":              true,
+		"dropped":              true,
+		"errors":               true,
+		"limit_dropped":        true,
+		"alerts_triggered":     true,
+		"oks_triggered":        true,
+		"infos_triggered":      true,
+		"warns_triggered":      true,
+		"crits_triggered":      true,
+		"events_dropped":       true,
+		"handled":              true,
+		"batches_queried":      true,
+		"points_queried":       true,
+		"query_errors":         true,
+		"points_written":       true,
+		"write_errors":         true,
+		"messages_written":     true,
+		"messages_published":   true,
+		"publish_errors":       true,
+		"fields_defaulted":     true,
+		"tags_defaulted":       true,
+		"fields_deleted":       true,
+		"tags_deleted":         true,
+		"increase_events":      true,
+		"decrease_events":      true,
+		"cooldown_drops":       true,
+		"queue_dropped":        true,
+		"recordings_deleted":   true,
+		"recordings_truncated": true,
+		"bytes_deleted":        true,
+		// Services
+		"req":                 true,
+		"write_req":           true,
+		"write_req_bytes":     true,
+		"ping_req":            true,
+		"auth_fail":           true,
+		"points_written_ok":   true,
+		"points_written_fail": true,
+		"points_received":     true,
+		"points_rx":           true,
+		"points_tx":           true,
+		"points_parse_fail":   true,
+		"bytes_rx":            true,
+		"lines_rx":            true,
+		"messages_rx":         true,
+		"messages_parse_fail": true,
+		"samples_rx":          true,
+		"samples_invalid":     true,
+		"req_parse_fail":      true,
+		"read_fail":           true,
+		"tx_fail":             true,
+		// Go runtime
+		"TotalAlloc":   true,
+		"Lookups":      true,
+		"Mallocs":      true,
+		"Frees":        true,
+		"PauseTotalNs": true,
+		"NumGC":        true,
+	}
+	promGauges = map[string]bool{
+		NumTasksVarName:         true,
+		NumEnabledTasksVarName:  true,
+		NumSubscriptionsVarName: true,
+		UptimeVarName:           true,
+		// Nodes, edges and tasks
+		"avg_exec_time_ns":    true,
+		"working_cardinality": true,
+		"queue_depth":         true,
+		"queue_bytes":         true,
+		"queue_oldest_age_ms": true,
+		"edge_queue_depth":    true,
+		"buffered_points":     true,
+		"groups":              true,
+		"cpu_share":           true,
+		"paused":              true,
+		// Go runtime
+		"Alloc":        true,
+		"Sys":          true,
+		"HeapAlloc":    true,
+		"HeapSys":      true,
+		"HeapIdle":     true,
+		"HeapInUse":    true,
+		"HeapReleased": true,
+		"HeapObjects":  true,
+		"NumGoroutine": true,
+	}
+)
+
+// WritePrometheus writes all stats data in the Prometheus text exposition format.
+// The server serves it at the /metrics path, outside of the API base path.
+//
+// Each value of a statistic becomes a metric named kapacitor_<statistic>_<value>,
+// labeled with the tags of the statistic, e.g. the errors of a node are exposed as
+// kapacitor_nodes_errors{task="cpu",node="alert2",...}.
+// The global values are exposed as kapacitor_<value>, e.g. kapacitor_num_tasks.
+// Metrics are typed as counters or gauges if the kind of their value is known.
+func WritePrometheus(w io.Writer) error {
+	data, err := GetStatsData()
+	if err != nil {
+		return err
+	}
+
+	// Group the samples by metric name, since all samples of a metric must be written together.
+	samples := make(map[string][]string)
+	types := make(map[string]string)
+	for _, d := range data {
+		prefix := Product + "_"
+		if d.Name != Product {
+			prefix += promName(d.Name) + "_"
+		}
+		labels := promLabels(d.Tags)
+		for k, v := range d.Values {
+			value, ok := promValue(v)
+			if !ok {
+				continue
+			}
+			name := prefix + promName(k)
+			samples[name] = append(samples[name], name+labels+" "+value)
+			types[name] = promType(k)
+		}
+	}
+	types["process_start_time_seconds"] = "gauge"
+	samples["process_start_time_seconds"] = []string{
+		"process_start_time_seconds " + strconv.FormatFloat(float64(startTime.UnixNano())/1e9, 'f', -1, 64),
+	}
+
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		lines := samples[name]
+		sort.Strings(lines)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, types[name])
+		for _, l := range lines {
+			bw.WriteString(l)
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// promType returns the Prometheus type of the values of a key.
+func promType(key string) string {
+	switch {
+	case promCounters[key]:
+		return "counter"
+	case promGauges[key]:
+		return "gauge"
+	default:
+		return "untyped"
+	}
+}
+
+// promName converts a stats name into a valid metric or label name in snake case,
+// e.g. HeapAlloc becomes heap_alloc.
+func promName(s string) string {
+	runes := []rune(s)
+	var b bytes.Buffer
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		if r > unicode.MaxASCII || !(r == '_' || unicode.IsLetter(r) || (unicode.IsDigit(r) && i > 0)) {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabels formats the tags as a sorted label set.
+func promLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(promName(k))
+		b.WriteString(`="`)
+		b.WriteString(promLabelEscaper.Replace(tags[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func promValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
@@ -13,6 +13,7 @@ import (
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/influxdata/kapacitor/alert"
 	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/namespace"
 	"github.com/influxdata/kapacitor/services/httpd"
 )
 
@@ -28,10 +29,11 @@ const (
 	topicEventsPath   = "events"
 	topicHandlersPath = "handlers"
 
-	eventsPattern   = "*/" + topicEventsPath
-	eventPattern    = "*/" + topicEventsPath + "/*"
-	handlersPattern = "*/" + topicHandlersPath
-	handlerPattern  = "*/" + topicHandlersPath + "/*"
+	// Patterns of the path below a topic
+	eventsPattern   = topicEventsPath
+	eventPattern    = topicEventsPath + "/*"
+	handlersPattern = topicHandlersPath
+	handlerPattern  = topicHandlersPath + "/*"
 
 	eventsRelation   = "events"
 	handlersRelation = "handlers"
@@ -53,9 +55,10 @@ func (s *apiServer) Open() error {
 	// Define API routes
 	s.routes = []httpd.Route{
 		{
-			Method:      "GET",
-			Pattern:     topicsPath,
-			HandlerFunc: s.handleListTopics,
+			Method:              "GET",
+			Pattern:             topicsPath,
+			HandlerFunc:         s.handleListTopics,
+			NamespaceCollection: topicsPath,
 		},
 		{
 			Method:      "GET",
@@ -112,13 +115,18 @@ func (s *apiServer) handleListTopics(w http.ResponseWriter, r *http.Request) {
 		httpd.HttpError(w, fmt.Sprint("invalid pattern: ", err.Error()), true, http.StatusBadRequest)
 		return
 	}
+	ns := r.URL.Query().Get("namespace")
+	if err := namespace.Validate(ns); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
 	minLevelStr := r.URL.Query().Get("min-level")
 	minLevel, err := alert.ParseLevel(minLevelStr)
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
-	states, err := s.Topics.TopicStates(pattern, minLevel)
+	states, err := s.Topics.TopicStates(namespace.Pattern(ns, pattern), minLevel)
 	if err != nil {
 		httpd.HttpError(w, fmt.Sprint("failed to get topic states: ", err.Error()), true, http.StatusInternalServerError)
 		return
@@ -138,21 +146,27 @@ func (s *apiServer) handleListTopics(w http.ResponseWriter, r *http.Request) {
 	w.Write(httpd.MarshalJSON(topics, true))
 }
 
-func (s *apiServer) topicIDFromPath(p string) (id string) {
-	d := p
-	for d != "." {
-		id = d
-		d = path.Dir(d)
+// topicIDFromPath returns the topic ID and the remaining path below the topic.
+// The first element of the path is the namespace of a namespaced topic,
+// unless it is followed by the events or handlers of a topic in the default namespace.
+// As such namespaced topics named events or handlers cannot be addressed.
+func (s *apiServer) topicIDFromPath(p string) (id, rest string) {
+	elems := strings.SplitN(p, "/", 3)
+	n := 1
+	if len(elems) > 1 && elems[1] != "" && elems[1] != topicEventsPath && elems[1] != topicHandlersPath {
+		n = 2
 	}
+	id = strings.Join(elems[:n], "/")
+	rest = strings.TrimPrefix(strings.TrimPrefix(p, id), "/")
 	return
 }
 
-func (s *apiServer) handlerIDFromPath(p string) (id string) {
-	return path.Base(p)
+func (s *apiServer) handlerIDFromPath(rest string) (id string) {
+	return path.Base(rest)
 }
 
-func (s *apiServer) eventIDFromPath(p string) (id string) {
-	return path.Base(p)
+func (s *apiServer) eventIDFromPath(rest string) (id string) {
+	return path.Base(rest)
 }
 
 func pathMatch(pattern, p string) (match bool) {
@@ -170,18 +184,18 @@ func (s *apiServer) handleDeleteTopic(topic string, w http.ResponseWriter, r *ht
 
 func (s *apiServer) handleRouteTopicGet(w http.ResponseWriter, r *http.Request) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	id := s.topicIDFromPath(p)
+	id, rest := s.topicIDFromPath(p)
 
 	switch {
-	case pathMatch(eventsPattern, p):
+	case pathMatch(eventsPattern, rest):
 		s.handleListEvents(id, w, r)
-	case pathMatch(eventPattern, p):
-		event := s.eventIDFromPath(p)
+	case pathMatch(eventPattern, rest):
+		event := s.eventIDFromPath(rest)
 		s.handleGetEvent(id, event, w, r)
-	case pathMatch(handlersPattern, p):
+	case pathMatch(handlersPattern, rest):
 		s.handleListHandlers(id, w, r)
-	case pathMatch(handlerPattern, p):
-		handler := s.handlerIDFromPath(p)
+	case pathMatch(handlerPattern, rest):
+		handler := s.handlerIDFromPath(rest)
 		s.handleGetHandler(id, handler, w, r)
 	default:
 		s.handleGetTopic(id, w, r)
@@ -190,27 +204,27 @@ func (s *apiServer) handleRouteTopicGet(w http.ResponseWriter, r *http.Request)
 
 func (s *apiServer) handleRouteTopicPost(w http.ResponseWriter, r *http.Request) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	topic := s.topicIDFromPath(p)
+	topic, _ := s.topicIDFromPath(p)
 	s.handleCreateHandler(topic, w, r)
 }
 
 func (s *apiServer) handleRouteTopicPut(w http.ResponseWriter, r *http.Request) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	topic := s.topicIDFromPath(p)
-	handler := s.handlerIDFromPath(p)
+	topic, rest := s.topicIDFromPath(p)
+	handler := s.handlerIDFromPath(rest)
 	s.handlePutHandler(topic, handler, w, r)
 }
 func (s *apiServer) handleRouteTopicPatch(w http.ResponseWriter, r *http.Request) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	topic := s.topicIDFromPath(p)
-	handler := s.handlerIDFromPath(p)
+	topic, rest := s.topicIDFromPath(p)
+	handler := s.handlerIDFromPath(rest)
 	s.handlePatchHandler(topic, handler, w, r)
 }
 func (s *apiServer) handleRouteTopicDelete(w http.ResponseWriter, r *http.Request) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	topic := s.topicIDFromPath(p)
-	handler := s.handlerIDFromPath(p)
-	if topic == handler {
+	topic, rest := s.topicIDFromPath(p)
+	handler := s.handlerIDFromPath(rest)
+	if rest == "" {
 		s.handleDeleteTopic(topic, w, r)
 	} else {
 		s.handleDeleteHandler(topic, handler, w, r)
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/namespace"
 	"github.com/influxdata/kapacitor/services/storage"
 	"github.com/pkg/errors"
 )
@@ -75,8 +76,8 @@ var validHandlerID = regexp.MustCompile(`^[-\._\p{L}0-9]+$`)
 var validTopicID = regexp.MustCompile(`^[-:\._\p{L}0-9]+$`)
 
 func (h HandlerSpec) Validate() error {
-	if !validTopicID.MatchString(h.Topic) {
-		return fmt.Errorf("handler topic must contain only letters, numbers, '-', '.' and '_'. %q", h.ID)
+	if !namespace.ValidID(h.Topic, validTopicID) {
+		return fmt.Errorf("handler topic must contain only letters, numbers, '-', ':', '.' and '_'. %q", h.Topic)
 	}
 	if !validHandlerID.MatchString(h.ID) {
 		return fmt.Errorf("handler ID must contain only letters, numbers, '-', '.' and '_'. %q", h.ID)
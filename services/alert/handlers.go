@@ -15,6 +15,7 @@ import (
 	"github.com/influxdata/kapacitor/alert"
 	"github.com/influxdata/kapacitor/bufpool"
 	"github.com/influxdata/kapacitor/command"
+	kexpvar "github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/keyvalue"
 	"github.com/influxdata/kapacitor/tick/ast"
 	"github.com/influxdata/kapacitor/tick/stateful"
@@ -350,6 +351,33 @@ func (h *externalHandler) Handle(event alert.Event) {
 	}
 }
 
+const statHandled = "handled"
+
+// statsHandler counts the events delivered to a handler.
+type statsHandler struct {
+	h       alert.Handler
+	handled *kexpvar.Int
+}
+
+func newStatsHandler(h alert.Handler) *statsHandler {
+	return &statsHandler{
+		h:       h,
+		handled: new(kexpvar.Int),
+	}
+}
+
+func (h *statsHandler) Handle(event alert.Event) {
+	h.handled.Add(1)
+	h.h.Handle(event)
+}
+
+// Close closes the wrapped handler if it needs closing.
+func (h *statsHandler) Close() {
+	if c, ok := h.h.(closer); ok {
+		c.Close()
+	}
+}
+
 type matchHandler struct {
 	h alert.Handler
 
@@ -11,11 +11,14 @@ import (
 
 	"github.com/influxdata/kapacitor/alert"
 	"github.com/influxdata/kapacitor/command"
+	kexpvar "github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/influxdata/kapacitor/server/vars"
 	"github.com/influxdata/kapacitor/services/alerta"
 	"github.com/influxdata/kapacitor/services/hipchat"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/httppost"
+	"github.com/influxdata/kapacitor/services/kafka"
 	"github.com/influxdata/kapacitor/services/mqtt"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/pagerduty"
@@ -80,6 +83,9 @@ type Service struct {
 	HipChatService interface {
 		Handler(hipchat.HandlerConfig, ...keyvalue.T) alert.Handler
 	}
+	KafkaService interface {
+		Handler(kafka.HandlerConfig, ...keyvalue.T) alert.Handler
+	}
 	MQTTService interface {
 		Handler(mqtt.HandlerConfig, ...keyvalue.T) alert.Handler
 	}
@@ -319,23 +325,26 @@ func (s *Service) migrateHandlerSpecs(store storage.Interface) error {
 }
 
 func (s *Service) loadSavedHandlerSpecs() error {
-	offset := 0
-	limit := 100
-	for {
-		specs, err := s.specsDAO.List("*", "", offset, limit)
-		if err != nil {
-			return err
-		}
+	// Load the handlers of topics in the default namespace, then those of namespaced topics.
+	for _, topics := range []string{"*", "*/*"} {
+		offset := 0
+		limit := 100
+		for {
+			specs, err := s.specsDAO.List(topics, "", offset, limit)
+			if err != nil {
+				return err
+			}
 
-		for _, spec := range specs {
-			if err := s.loadHandlerSpec(spec); err != nil {
-				s.diag.Error("failed to load handler on startup", err)
+			for _, spec := range specs {
+				if err := s.loadHandlerSpec(spec); err != nil {
+					s.diag.Error("failed to load handler on startup", err)
+				}
 			}
-		}
 
-		offset += limit
-		if len(specs) != limit {
-			break
+			offset += limit
+			if len(specs) != limit {
+				break
+			}
 		}
 	}
 	return nil
@@ -408,9 +417,31 @@ func (s *Service) setTopicHandler(topic, id string, h handler) {
 	if s.handlers[topic] == nil {
 		s.handlers[topic] = make(map[string]handler)
 	}
+	s.deleteTopicHandler(topic, id)
+	if sh, ok := h.Handler.(*statsHandler); ok {
+		var statMap *kexpvar.Map
+		h.statsKey, statMap = vars.NewStatistic("handlers", map[string]string{
+			"topic":   topic,
+			"handler": id,
+			"kind":    h.Spec.Kind,
+		})
+		statMap.Set(statHandled, sh.handled)
+	}
 	s.handlers[topic][id] = h
 }
 
+// delete a topic handler and its stats from the internal map, caller must have lock.
+func (s *Service) deleteTopicHandler(topic, id string) {
+	h, ok := s.handlers[topic][id]
+	if !ok {
+		return
+	}
+	if h.statsKey != "" {
+		vars.DeleteStatistic(h.statsKey)
+	}
+	delete(s.handlers[topic], id)
+}
+
 func (s *Service) Collect(event alert.Event) error {
 	s.mu.RLock()
 	closed := s.closedTopics[event.Topic]
@@ -578,7 +609,7 @@ func (s *Service) DeregisterHandlerSpec(topic, handler string) error {
 			ha.Close()
 		}
 
-		delete(s.handlers[h.Spec.Topic], handler)
+		s.deleteTopicHandler(h.Spec.Topic, handler)
 	}
 	return nil
 }
@@ -615,7 +646,7 @@ func (s *Service) UpdateHandlerSpec(oldSpec, newSpec HandlerSpec) error {
 		}
 	}
 
-	delete(s.handlers[topic], oldSpec.ID)
+	s.deleteTopicHandler(topic, oldSpec.ID)
 	s.setTopicHandler(newSpec.Topic, newSpec.ID, newH)
 
 	s.topics.ReplaceHandler(topic, oldH.Handler, newH.Handler)
@@ -775,6 +806,14 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		}
 		h = s.HipChatService.Handler(c, ctx...)
 		h = newExternalHandler(h)
+	case "kafka":
+		c := kafka.HandlerConfig{}
+		err = decodeOptions(spec.Options, &c)
+		if err != nil {
+			return handler{}, err
+		}
+		h = s.KafkaService.Handler(c, ctx...)
+		h = newExternalHandler(h)
 	case "log":
 		c := DefaultLogHandlerConfig()
 		err = decodeOptions(spec.Options, &c)
@@ -911,5 +950,8 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		handlerDiag := s.diag.WithHandlerContext(ctx...)
 		h, err = newMatchHandler(spec.Match, h, handlerDiag)
 	}
+	if err == nil {
+		h = newStatsHandler(h)
+	}
 	return handler{Spec: spec, Handler: h}, err
 }
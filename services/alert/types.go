@@ -66,4 +66,6 @@ type TopicPersister interface {
 type handler struct {
 	Spec    HandlerSpec
 	Handler alert.Handler
+
+	statsKey string
 }
@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// Default age after which audit entries are deleted.
+	DefaultRetention = 30 * 24 * time.Hour
+	// Default interval between retention checks.
+	DefaultRetentionCheckInterval = 10 * time.Minute
+)
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// Audit entries older than Retention are deleted. Zero means entries are never deleted.
+	Retention toml.Duration `toml:"retention"`
+	// How often to check for audit entries that exceed the retention.
+	RetentionCheckInterval toml.Duration `toml:"retention-check-interval"`
+	// Also emit each audit entry as a structured log message.
+	LogEnabled bool `toml:"log-enabled"`
+}
+
+func NewConfig() Config {
+	return Config{
+		Retention:              toml.Duration(DefaultRetention),
+		RetentionCheckInterval: toml.Duration(DefaultRetentionCheckInterval),
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Retention < 0 {
+		return fmt.Errorf("retention cannot be negative")
+	}
+	if c.RetentionCheckInterval <= 0 {
+		return fmt.Errorf("retention-check-interval must be positive")
+	}
+	return nil
+}
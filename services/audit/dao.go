@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/influxdata/kapacitor/services/storage"
+)
+
+// Data access object for audit Entry data.
+type EntryDAO interface {
+	// Create an entry.
+	Create(e Entry) error
+
+	// Delete an entry.
+	// It is not an error to delete an non-existent entry.
+	Delete(id string) error
+
+	// List entries ordered from newest to oldest.
+	// Offset and limit are pagination bounds. Offset is inclusive starting at index 0.
+	// More results may exist while the number of returned items is equal to limit.
+	List(offset, limit int) ([]Entry, error)
+
+	// List entries ordered from oldest to newest.
+	ListOldest(offset, limit int) ([]Entry, error)
+
+	Rebuild() error
+}
+
+//--------------------------------------------------------------------
+// The following structures are stored in a database via JSON encoding.
+// Changes to the structures could break existing data.
+//
+// Many of these structures are exact copies of structures found elsewhere,
+// this is intentional so that all structures stored in the database are
+// defined here and nowhere else. So as to not accidentally change
+// the JSON serialization format in incompatible ways.
+
+// version is the current version of the stored structures.
+const version = 1
+
+type Entry struct {
+	// Unique ID of the entry, IDs sort in the order the entries were created.
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request-id"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route"`
+	Path       string    `json:"path"`
+	User       string    `json:"user"`
+	ResourceID string    `json:"resource-id"`
+	Status     int       `json:"status"`
+	Before     string    `json:"before"`
+	After      string    `json:"after"`
+}
+
+func (e Entry) ObjectID() string {
+	return e.ID
+}
+
+func (e Entry) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, e)
+}
+
+func (e *Entry) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(e)
+	})
+}
+
+// Key/Value store based implementation of the EntryDAO
+type entryKV struct {
+	store *storage.IndexedStore
+}
+
+func newEntryKV(store storage.Interface) (*entryKV, error) {
+	c := storage.DefaultIndexedStoreConfig("entries", func() storage.BinaryObject {
+		return new(Entry)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &entryKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *entryKV) Create(e Entry) error {
+	return kv.store.Create(&e)
+}
+
+func (kv *entryKV) Delete(id string) error {
+	return kv.store.Delete(id)
+}
+
+func (kv *entryKV) List(offset, limit int) ([]Entry, error) {
+	objects, err := kv.store.ReverseList(storage.DefaultIDIndex, "", offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return convertEntries(objects)
+}
+
+func (kv *entryKV) ListOldest(offset, limit int) ([]Entry, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, "", offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return convertEntries(objects)
+}
+
+func convertEntries(objects []storage.BinaryObject) ([]Entry, error) {
+	entries := make([]Entry, len(objects))
+	for i, o := range objects {
+		e, ok := o.(*Entry)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(e, o)
+		}
+		entries[i] = *e
+	}
+	return entries, nil
+}
+
+func (kv *entryKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
@@ -0,0 +1,291 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	auditPath = "/audit"
+
+	// Public name of the entries store
+	entriesAPIName = "audit"
+	// The storage namespace for all audit data.
+	auditNamespace = "audit"
+
+	// Number of entries read from the store at a time.
+	listBatchSize = 100
+)
+
+type Diagnostic interface {
+	Error(msg string, err error)
+	AuditEntry(e httpd.AuditEntry)
+}
+
+// Service records mutating API requests in storage,
+// and deletes them once they are older than the retention.
+type Service struct {
+	retention              time.Duration
+	retentionCheckInterval time.Duration
+	logEnabled             bool
+
+	entries EntryDAO
+	// Sequence number to keep the IDs of entries created at the same time unique.
+	seq uint64
+
+	routes []httpd.Route
+
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	diag Diagnostic
+
+	StorageService interface {
+		Store(namespace string) storage.Interface
+		Register(name string, store storage.StoreActioner)
+	}
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
+}
+
+func NewService(c Config, d Diagnostic) *Service {
+	return &Service{
+		retention:              time.Duration(c.Retention),
+		retentionCheckInterval: time.Duration(c.RetentionCheckInterval),
+		logEnabled:             c.LogEnabled,
+		diag:                   d,
+	}
+}
+
+func (s *Service) Open() error {
+	store := s.StorageService.Store(auditNamespace)
+	entries, err := newEntryKV(store)
+	if err != nil {
+		return err
+	}
+	s.entries = entries
+	s.StorageService.Register(entriesAPIName, s.entries)
+
+	// Define API routes
+	s.routes = []httpd.Route{
+		{
+			Method:      "GET",
+			Pattern:     auditPath,
+			HandlerFunc: s.handleListEntries,
+		},
+	}
+	if err := s.HTTPDService.AddRoutes(s.routes); err != nil {
+		return errors.Wrap(err, "failed to add API routes")
+	}
+
+	// Start deleting entries that are older than the retention
+	s.closing = make(chan struct{})
+	if s.retention > 0 {
+		s.wg.Add(1)
+		go s.runRetention()
+	}
+	return nil
+}
+
+func (s *Service) Close() error {
+	if s.closing != nil {
+		close(s.closing)
+		s.wg.Wait()
+	}
+	s.HTTPDService.DelRoutes(s.routes)
+	return nil
+}
+
+// Audit stores the entry and, if enabled, logs it.
+func (s *Service) Audit(e httpd.AuditEntry) {
+	if s.logEnabled {
+		s.diag.AuditEntry(e)
+	}
+	entry := Entry{
+		ID:         fmt.Sprintf("%019d-%06d", e.Time.UnixNano(), atomic.AddUint64(&s.seq, 1)%1000000),
+		Time:       e.Time,
+		RequestID:  e.RequestID,
+		Method:     e.Method,
+		Route:      e.Route,
+		Path:       e.Path,
+		User:       e.User,
+		ResourceID: e.ResourceID,
+		Status:     e.Status,
+		Before:     e.Before,
+		After:      e.After,
+	}
+	if err := s.entries.Create(entry); err != nil {
+		s.diag.Error("failed to store audit entry", err)
+	}
+}
+
+// runRetention periodically deletes entries that are older than the retention.
+func (s *Service) runRetention() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.retentionCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.deleteBefore(time.Now().Add(-s.retention)); err != nil {
+			s.diag.Error("failed to delete expired audit entries", err)
+		}
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteBefore deletes all entries older than t.
+func (s *Service) deleteBefore(t time.Time) error {
+	for {
+		entries, err := s.entries.ListOldest(0, listBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !e.Time.Before(t) {
+				return nil
+			}
+			if err := s.entries.Delete(e.ID); err != nil {
+				return err
+			}
+		}
+		if len(entries) != listBatchSize {
+			return nil
+		}
+	}
+}
+
+// filter selects entries from the query parameters of a list request.
+type filter struct {
+	user     string
+	method   string
+	resource string
+	start    time.Time
+	stop     time.Time
+}
+
+func (f filter) match(e Entry) bool {
+	return (f.user == "" || e.User == f.user) &&
+		(f.method == "" || e.Method == f.method) &&
+		(f.resource == "" || e.ResourceID == f.resource)
+}
+
+func filterFromQuery(r *http.Request) (f filter, offset, limit int, err error) {
+	q := r.URL.Query()
+	f.user = q.Get("user")
+	f.method = strings.ToUpper(q.Get("method"))
+	f.resource = q.Get("resource")
+	if startStr := q.Get("start"); startStr != "" {
+		f.start, err = time.Parse(time.RFC3339Nano, startStr)
+		if err != nil {
+			return filter{}, 0, 0, fmt.Errorf("invalid start parameter %q must be RFC3339 formatted: %s", startStr, err)
+		}
+	}
+	if stopStr := q.Get("stop"); stopStr != "" {
+		f.stop, err = time.Parse(time.RFC3339Nano, stopStr)
+		if err != nil {
+			return filter{}, 0, 0, fmt.Errorf("invalid stop parameter %q must be RFC3339 formatted: %s", stopStr, err)
+		}
+	}
+	if offsetStr := q.Get("offset"); offsetStr != "" {
+		o, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil {
+			return filter{}, 0, 0, fmt.Errorf("invalid offset parameter %q must be an integer: %s", offsetStr, err)
+		}
+		offset = int(o)
+	}
+	limit = 100
+	if limitStr := q.Get("limit"); limitStr != "" {
+		l, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil {
+			return filter{}, 0, 0, fmt.Errorf("invalid limit parameter %q must be an integer: %s", limitStr, err)
+		}
+		limit = int(l)
+	}
+	return
+}
+
+// list returns the entries matching the filter, newest first.
+func (s *Service) list(f filter, offset, limit int) ([]Entry, error) {
+	var matched []Entry
+	skipped := 0
+	for storeOffset := 0; ; storeOffset += listBatchSize {
+		entries, err := s.entries.List(storeOffset, listBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !f.stop.IsZero() && e.Time.After(f.stop) {
+				continue
+			}
+			if !f.start.IsZero() && e.Time.Before(f.start) {
+				return matched, nil
+			}
+			if !f.match(e) {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if limit >= 0 && len(matched) == limit {
+				return matched, nil
+			}
+			matched = append(matched, e)
+		}
+		if len(entries) != listBatchSize {
+			return matched, nil
+		}
+	}
+}
+
+func convertEntry(e Entry) client.AuditEntry {
+	return client.AuditEntry{
+		ID:         e.ID,
+		Time:       e.Time,
+		RequestID:  e.RequestID,
+		Method:     e.Method,
+		Route:      e.Route,
+		Path:       e.Path,
+		User:       e.User,
+		ResourceID: e.ResourceID,
+		Status:     e.Status,
+		Before:     e.Before,
+		After:      e.After,
+	}
+}
+
+func (s *Service) handleListEntries(w http.ResponseWriter, r *http.Request) {
+	f, offset, limit, err := filterFromQuery(r)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	entries, err := s.list(f, offset, limit)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	type response struct {
+		Entries []client.AuditEntry `json:"entries"`
+	}
+	resp := response{Entries: make([]client.AuditEntry, len(entries))}
+	for i, e := range entries {
+		resp.Entries[i] = convertEntry(e)
+	}
+	w.Write(httpd.MarshalJSON(resp, true))
+}
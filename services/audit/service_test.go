@@ -0,0 +1,192 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/audit"
+	"github.com/influxdata/kapacitor/services/diagnostic"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/httpd/httpdtest"
+	"github.com/influxdata/kapacitor/services/storage/storagetest"
+)
+
+var diagService *diagnostic.Service
+
+func init() {
+	diagService = diagnostic.NewService(diagnostic.NewConfig(), ioutil.Discard, ioutil.Discard)
+	diagService.Open()
+}
+
+type thing struct {
+	ID       string `json:"id"`
+	Value    string `json:"value"`
+	Password string `json:"password"`
+}
+
+// thingRoutes serves a single object that can be created, read and updated.
+func thingRoutes() []httpd.Route {
+	var current *thing
+	return []httpd.Route{
+		{
+			Method:  "GET",
+			Pattern: "/things/",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				if current == nil {
+					httpd.HttpError(w, "no thing", true, http.StatusNotFound)
+					return
+				}
+				w.Write(httpd.MarshalJSON(current, true))
+			},
+		},
+		{
+			Method:  "POST",
+			Pattern: "/things",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				t := new(thing)
+				json.NewDecoder(r.Body).Decode(t)
+				current = t
+				w.Write(httpd.MarshalJSON(current, true))
+			},
+		},
+		{
+			Method:  "PATCH",
+			Pattern: "/things/",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				t := new(thing)
+				json.NewDecoder(r.Body).Decode(t)
+				current.Value = t.Value
+				w.Write(httpd.MarshalJSON(current, true))
+			},
+		},
+	}
+}
+
+func TestService_Audit(t *testing.T) {
+	c := audit.NewConfig()
+	s := audit.NewService(c, diagService.NewAuditHandler())
+	s.StorageService = storagetest.New()
+	server := httpdtest.NewServer(testing.Verbose())
+	defer server.Close()
+	s.HTTPDService = server
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	server.Handler.AuditService = s
+	if err := server.AddRoutes(thingRoutes()); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, body string) {
+		req, err := http.NewRequest(method, server.Server.URL+httpd.BasePath+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	do("POST", "/things", `{"id":"a","value":"1","password":"secret"}`)
+	do("PATCH", "/things/a", `{"value":"2"}`)
+	do("GET", "/things/a", "")
+
+	cli, err := client.New(client.Config{URL: server.Server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := cli.ListAuditEntries(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := len(entries), 2; got != exp {
+		t.Fatalf("unexpected number of entries got %d exp %d: %v", got, exp, entries)
+	}
+	// Newest first
+	patch, post := entries[0], entries[1]
+	if got, exp := post.ResourceID, "a"; got != exp {
+		t.Errorf("unexpected created resource ID got %q exp %q", got, exp)
+	}
+	if got, exp := post.After, `{"id":"a","password":"REDACTED","value":"1"}`; got != exp {
+		t.Errorf("unexpected after summary got %s exp %s", got, exp)
+	}
+	if got, exp := patch.Method, "PATCH"; got != exp {
+		t.Errorf("unexpected method got %s exp %s", got, exp)
+	}
+	if got, exp := patch.Route, httpd.BasePath+"/things/"; got != exp {
+		t.Errorf("unexpected route got %s exp %s", got, exp)
+	}
+	if got, exp := patch.Before, `{"id":"a","password":"REDACTED","value":"1"}`; got != exp {
+		t.Errorf("unexpected before summary got %s exp %s", got, exp)
+	}
+	if got, exp := patch.After, `{"id":"a","password":"REDACTED","value":"2"}`; got != exp {
+		t.Errorf("unexpected after summary got %s exp %s", got, exp)
+	}
+	if got, exp := patch.Status, http.StatusOK; got != exp {
+		t.Errorf("unexpected status got %d exp %d", got, exp)
+	}
+
+	entries, err = cli.ListAuditEntries(&client.ListAuditEntriesOptions{Method: "post"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != post.ID {
+		t.Errorf("unexpected filtered entries %v", entries)
+	}
+	entries, err = cli.ListAuditEntries(&client.ListAuditEntriesOptions{Start: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("unexpected entries in the future %v", entries)
+	}
+}
+
+func TestService_Retention(t *testing.T) {
+	c := audit.NewConfig()
+	c.Retention.UnmarshalText([]byte("1h"))
+	c.RetentionCheckInterval.UnmarshalText([]byte("10ms"))
+	s := audit.NewService(c, diagService.NewAuditHandler())
+	s.StorageService = storagetest.New()
+	server := httpdtest.NewServer(testing.Verbose())
+	defer server.Close()
+	s.HTTPDService = server
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	now := time.Now().UTC()
+	s.Audit(httpd.AuditEntry{Time: now.Add(-2 * time.Hour), Method: "DELETE", Path: "/old"})
+	s.Audit(httpd.AuditEntry{Time: now, Method: "DELETE", Path: "/new"})
+
+	cli, err := client.New(client.Config{URL: server.Server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(time.Second)
+	for {
+		entries, err := cli.ListAuditEntries(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 1 {
+			if got, exp := entries[0].Path, "/new"; got != exp {
+				t.Fatalf("unexpected remaining entry got %s exp %s", got, exp)
+			}
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for expired entries to be deleted, got %v", entries)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -18,9 +18,11 @@ import (
 	alertservice "github.com/influxdata/kapacitor/services/alert"
 	"github.com/influxdata/kapacitor/services/alerta"
 	"github.com/influxdata/kapacitor/services/hipchat"
+	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/httppost"
 	"github.com/influxdata/kapacitor/services/influxdb"
 	"github.com/influxdata/kapacitor/services/k8s"
+	"github.com/influxdata/kapacitor/services/kafka"
 	"github.com/influxdata/kapacitor/services/mqtt"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/pagerduty"
@@ -751,6 +753,35 @@ func (h *MQTTHandler) WithContext(ctx ...keyvalue.T) mqtt.Diagnostic {
 	}
 }
 
+// Kafka handler
+
+type KafkaHandler struct {
+	l Logger
+}
+
+func (h *KafkaHandler) Error(msg string, err error) {
+	h.l.Error(msg, Error(err))
+}
+
+func (h *KafkaHandler) CreatingAlertHandler(c kafka.HandlerConfig) {
+	h.l.Debug("creating kafka handler",
+		String("cluster", c.Cluster),
+		String("topic", c.Topic),
+	)
+}
+
+func (h *KafkaHandler) HandlingEvent() {
+	h.l.Debug("handling event")
+}
+
+func (h *KafkaHandler) WithContext(ctx ...keyvalue.T) kafka.Diagnostic {
+	fields := logFieldsFromContext(ctx)
+
+	return &KafkaHandler{
+		l: h.l.With(fields...),
+	}
+}
+
 // Talk handler
 
 type TalkHandler struct {
@@ -927,6 +958,49 @@ func (h *NoAuthHandler) FakedSubscriptionUserToken() {
 	h.l.Warn("using noauth auth backend. Faked authentication for subscription user token")
 }
 
+func (h *NoAuthHandler) FakedTokenAuthentication() {
+	h.l.Warn("using noauth auth backend. Faked authentication for API token")
+}
+
+// UserAuth handler
+
+type UserAuthHandler struct {
+	l Logger
+}
+
+func (h *UserAuthHandler) Error(msg string, err error) {
+	h.l.Error(msg, Error(err))
+}
+
+func (h *UserAuthHandler) CreatedBootstrapUser(username string) {
+	h.l.Info("created bootstrap admin user", String("user", username))
+}
+
+// Audit handler
+
+type AuditHandler struct {
+	l Logger
+}
+
+func (h *AuditHandler) Error(msg string, err error) {
+	h.l.Error(msg, Error(err))
+}
+
+func (h *AuditHandler) AuditEntry(e httpd.AuditEntry) {
+	h.l.Info("audit",
+		Time("time", e.Time),
+		String("request_id", e.RequestID),
+		String("method", e.Method),
+		String("route", e.Route),
+		String("path", e.Path),
+		String("user", e.User),
+		String("resource_id", e.ResourceID),
+		Int("status", e.Status),
+		String("before", e.Before),
+		String("after", e.After),
+	)
+}
+
 // Stats handler
 
 type StatsHandler struct {
@@ -955,6 +1029,70 @@ func (h *UDPHandler) ClosedService() {
 	h.l.Info("closed service")
 }
 
+// OTLP handler
+
+type OTLPHandler struct {
+	l Logger
+}
+
+func (h *OTLPHandler) Error(msg string, err error, ctx ...keyvalue.T) {
+	Err(h.l, msg, err, ctx)
+}
+
+func (h *OTLPHandler) StartedListening(addr string) {
+	h.l.Info("started listening for OTLP metrics", String("address", addr))
+}
+
+func (h *OTLPHandler) ClosedService() {
+	h.l.Info("closed service")
+}
+
+// Syslog handler
+
+type SyslogHandler struct {
+	l Logger
+}
+
+func (h *SyslogHandler) Error(msg string, err error, ctx ...keyvalue.T) {
+	Err(h.l, msg, err, ctx)
+}
+
+func (h *SyslogHandler) StartedListening(network, addr string) {
+	h.l.Info("started listening for syslog messages", String("network", network), String("address", addr))
+}
+
+func (h *SyslogHandler) ClosedService() {
+	h.l.Info("closed service")
+}
+
+// File tail handler
+
+type FileTailHandler struct {
+	l Logger
+}
+
+func (h *FileTailHandler) Error(msg string, err error, ctx ...keyvalue.T) {
+	Err(h.l, msg, err, ctx)
+}
+
+func (h *FileTailHandler) TailingFile(path string, offset int64) {
+	h.l.Info("tailing file", String("path", path), Int64("offset", offset))
+}
+
+func (h *FileTailHandler) ClosedService() {
+	h.l.Info("closed service")
+}
+
+// Remote write handler
+
+type RemoteWriteHandler struct {
+	l Logger
+}
+
+func (h *RemoteWriteHandler) Error(msg string, err error, ctx ...keyvalue.T) {
+	Err(h.l, msg, err, ctx)
+}
+
 // InfluxDB handler
 
 type InfluxDBHandler struct {
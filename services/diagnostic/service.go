@@ -252,6 +252,12 @@ func (s *Service) NewMQTTHandler() *MQTTHandler {
 	}
 }
 
+func (s *Service) NewKafkaHandler() *KafkaHandler {
+	return &KafkaHandler{
+		l: s.logger.With(String("service", "kafka")),
+	}
+}
+
 func (s *Service) NewTalkHandler() *TalkHandler {
 	return &TalkHandler{
 		l: s.logger.With(String("service", "talk")),
@@ -300,6 +306,18 @@ func (s *Service) NewNoAuthHandler() *NoAuthHandler {
 	}
 }
 
+func (s *Service) NewUserAuthHandler() *UserAuthHandler {
+	return &UserAuthHandler{
+		l: s.logger.With(String("service", "userauth")),
+	}
+}
+
+func (s *Service) NewAuditHandler() *AuditHandler {
+	return &AuditHandler{
+		l: s.logger.With(String("service", "audit")),
+	}
+}
+
 func (s *Service) NewStatsHandler() *StatsHandler {
 	return &StatsHandler{
 		l: s.logger.With(String("service", "stats")),
@@ -312,6 +330,30 @@ func (s *Service) NewUDPHandler() *UDPHandler {
 	}
 }
 
+func (s *Service) NewOTLPHandler() *OTLPHandler {
+	return &OTLPHandler{
+		l: s.logger.With(String("service", "otlp")),
+	}
+}
+
+func (s *Service) NewSyslogHandler() *SyslogHandler {
+	return &SyslogHandler{
+		l: s.logger.With(String("service", "syslog")),
+	}
+}
+
+func (s *Service) NewFileTailHandler(name string) *FileTailHandler {
+	return &FileTailHandler{
+		l: s.logger.With(String("service", "file_tail"), String("name", name)),
+	}
+}
+
+func (s *Service) NewRemoteWriteHandler() *RemoteWriteHandler {
+	return &RemoteWriteHandler{
+		l: s.logger.With(String("service", "remote_write")),
+	}
+}
+
 func (s *Service) NewInfluxDBHandler() *InfluxDBHandler {
 	return &InfluxDBHandler{
 		l: s.logger.With(String("service", "influxdb")),
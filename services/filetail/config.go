@@ -0,0 +1,90 @@
+package filetail
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default interval at which files are checked for new lines, new files and rotations.
+	DefaultPollInterval = toml.Duration(time.Second)
+
+	// Default maximum size of a line, longer lines are dropped.
+	DefaultMaxLineSize = 64 * 1024
+
+	// Default maximum number of bytes read from a file per poll.
+	DefaultMaxReadSize = 1024 * 1024
+)
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// Name of the tailer, it identifies the persisted offsets of its files.
+	Name string `toml:"name"`
+	// Path of the tailed files, may be a glob pattern.
+	Path string `toml:"path"`
+	// PollInterval is the interval at which files are checked for new lines, new files and rotations.
+	PollInterval toml.Duration `toml:"poll-interval"`
+	// FromBeginning reads the files found on start without a persisted offset from their beginning
+	// instead of their end. Files created later are always read from their beginning.
+	FromBeginning bool `toml:"from-beginning"`
+	// MaxLineSize is the maximum size of a line in bytes, longer lines are dropped.
+	MaxLineSize int64 `toml:"max-line-size"`
+	// MaxReadSize is the maximum number of bytes read from a file per poll,
+	// so that a large backlog is read over several polls.
+	MaxReadSize int64 `toml:"max-read-size"`
+
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+
+	// PathTag is the name of a tag holding the path of the file, the path is not added if empty.
+	PathTag string `toml:"path-tag"`
+
+	// How the lines of the files are decoded.
+	decoder.Config
+}
+
+// withDefaults returns the config with the default poll interval and sizes if none are given.
+func (c Config) withDefaults() Config {
+	if c.PollInterval == 0 {
+		c.PollInterval = DefaultPollInterval
+	}
+	if c.MaxLineSize == 0 {
+		c.MaxLineSize = DefaultMaxLineSize
+	}
+	if c.MaxReadSize == 0 {
+		c.MaxReadSize = DefaultMaxReadSize
+	}
+	return c
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Name == "" {
+		return errors.New("must specify name")
+	}
+	if c.Path == "" {
+		return errors.New("must specify path")
+	}
+	if _, err := filepath.Match(c.Path, ""); err != nil {
+		return errors.Wrap(err, "invalid path pattern")
+	}
+	if c.PollInterval < 0 {
+		return errors.New("poll-interval must not be negative")
+	}
+	if c.MaxLineSize < 0 {
+		return errors.New("max-line-size must not be negative")
+	}
+	if c.MaxReadSize < 0 {
+		return errors.New("max-read-size must not be negative")
+	}
+	if c.Database == "" {
+		return errors.New("must specify database")
+	}
+	return c.Config.Validate()
+}
@@ -0,0 +1,398 @@
+package filetail
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/influxdata/kapacitor/server/vars"
+	"github.com/influxdata/kapacitor/services/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Namespace of the persisted offsets of the tailed files.
+	offsetsNamespace = "file_tail"
+
+	// Size of the chunks read from the files.
+	readSize = 32 * 1024
+)
+
+// statistics gathered for each tailer.
+const (
+	statLinesReceived     = "lines_rx"
+	statLinesDropped      = "lines_dropped"
+	statBytesReceived     = "bytes_rx"
+	statReadFail          = "read_fail"
+	statPointsReceived    = "points_rx"
+	statPointsParseFail   = "points_parse_fail"
+	statPointsTransmitted = "points_tx"
+	statTransmitFail      = "tx_fail"
+)
+
+type Diagnostic interface {
+	Error(msg string, err error, ctx ...keyvalue.T)
+	TailingFile(path string, offset int64)
+	ClosedService()
+}
+
+// Service tails the files matching a path and writes the points decoded from their lines.
+//
+// Files are polled for new lines. A file is rotated when its path refers to a new file,
+// the old file is read to its end before the new file is read from its beginning.
+// A file is truncated when it becomes smaller than its offset, it is then read from its beginning.
+// The offsets of the files are persisted, so that lines are not read twice across restarts.
+type Service struct {
+	config  Config
+	decoder *decoder.Decoder
+
+	store   storage.Interface
+	files   map[string]*tailedFile
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	StorageService interface {
+		Store(namespace string) storage.Interface
+	}
+	PointsWriter interface {
+		WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	Diag    Diagnostic
+	statMap *expvar.Map
+	statKey string
+}
+
+// tailedFile is an open file and the position up to which it has been read.
+type tailedFile struct {
+	path string
+	file *os.File
+	info os.FileInfo
+	// offset of the next byte read from the file.
+	offset int64
+	// partial is the incomplete last line read from the file.
+	partial []byte
+	// dropping is set while the rest of a line exceeding the maximum line size is skipped.
+	dropping bool
+	// saved is the persisted offset, -1 if none.
+	saved int64
+}
+
+// committed returns the offset of the end of the last complete line.
+func (f *tailedFile) committed() int64 {
+	return f.offset - int64(len(f.partial))
+}
+
+func NewService(c Config, diag Diagnostic) (*Service, error) {
+	c = c.withDefaults()
+	d, err := decoder.New(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		config:  c,
+		decoder: d,
+		Diag:    diag,
+	}, nil
+}
+
+func (s *Service) Open() error {
+	s.store = s.StorageService.Store(offsetsNamespace)
+	s.files = make(map[string]*tailedFile)
+	s.closing = make(chan struct{})
+	s.statKey, s.statMap = vars.NewStatistic("file_tail", map[string]string{"name": s.config.Name})
+
+	if err := s.discover(true); err != nil {
+		return err
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+func (s *Service) Close() error {
+	if s.closing == nil {
+		return errors.New("service already closed")
+	}
+	close(s.closing)
+	s.wg.Wait()
+
+	s.saveOffsets()
+	for _, f := range s.files {
+		f.file.Close()
+	}
+	vars.DeleteStatistic(s.statKey)
+	s.files = nil
+	s.closing = nil
+	s.Diag.ClosedService()
+	return nil
+}
+
+func (s *Service) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(s.config.PollInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			s.poll()
+		}
+	}
+}
+
+// poll reads the new lines of the files, handles their rotations and tails new files.
+func (s *Service) poll() {
+	for path, f := range s.files {
+		info, err := os.Stat(path)
+		rotated := err != nil || !os.SameFile(info, f.info)
+		s.read(f, rotated)
+		if rotated {
+			// The new file at the path, if any, is read from its beginning.
+			f.file.Close()
+			delete(s.files, path)
+			s.deleteOffset(path)
+		}
+	}
+	if err := s.discover(false); err != nil {
+		s.Diag.Error("failed to discover files", err, keyvalue.KV("path", s.config.Path))
+	}
+	s.saveOffsets()
+}
+
+// discover opens and reads the files matching the path that are not yet tailed.
+func (s *Service) discover(initial bool) error {
+	paths, err := filepath.Glob(s.config.Path)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, ok := s.files[path]; ok {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			s.Diag.Error("failed to open file", err, keyvalue.KV("path", path))
+			continue
+		}
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			file.Close()
+			continue
+		}
+		f := &tailedFile{
+			path:  path,
+			file:  file,
+			info:  info,
+			saved: -1,
+		}
+		if saved, ok := s.loadOffset(path); ok {
+			f.saved = saved
+			if saved <= info.Size() {
+				f.offset = saved
+			}
+		} else if initial && !s.config.FromBeginning {
+			f.offset = info.Size()
+		}
+		s.files[path] = f
+		s.Diag.TailingFile(path, f.offset)
+		s.read(f, false)
+	}
+	return nil
+}
+
+// read reads at most the maximum read size of the file and writes the points of its complete lines.
+// If final the file is read up to its end, writing the points of each chunk of the maximum read size,
+// and the incomplete last line is read as well.
+func (s *Service) read(f *tailedFile, final bool) {
+	if info, err := f.file.Stat(); err == nil && info.Size() < f.offset {
+		// The file was truncated.
+		f.offset = 0
+		f.partial = nil
+		f.dropping = false
+	}
+	var points []models.Point
+	buf := make([]byte, readSize)
+	var read int64
+	for {
+		n, err := f.file.ReadAt(buf, f.offset)
+		if n > 0 {
+			f.offset += int64(n)
+			read += int64(n)
+			s.statMap.Add(statBytesReceived, int64(n))
+			points = append(points, s.readLines(f, buf[:n])...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.statMap.Add(statReadFail, 1)
+			s.Diag.Error("failed to read file", err, keyvalue.KV("path", f.path))
+			break
+		}
+		if read >= s.config.MaxReadSize {
+			if !final {
+				// The rest of the file is read by the next polls.
+				break
+			}
+			s.write(points)
+			points = nil
+			read = 0
+		}
+	}
+	if final {
+		if len(f.partial) > 0 {
+			points = append(points, s.decode(f.path, f.partial)...)
+		}
+		f.partial = nil
+		f.dropping = false
+	}
+	s.write(points)
+}
+
+// readLines decodes the complete lines of the chunk read from the file,
+// keeping its incomplete last line as the partial line of the file.
+// A line exceeding the maximum line size is dropped.
+func (s *Service) readLines(f *tailedFile, chunk []byte) []models.Point {
+	if f.dropping {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			return nil
+		}
+		// The rest of the dropped line ends at the newline.
+		chunk = chunk[i+1:]
+		f.dropping = false
+	}
+	var points []models.Point
+	data := append(f.partial, chunk...)
+	if i := bytes.LastIndexByte(data, '\n'); i < 0 {
+		f.partial = data
+	} else {
+		points = s.decode(f.path, data[:i])
+		f.partial = append([]byte(nil), data[i+1:]...)
+	}
+	if int64(len(f.partial)) > s.config.MaxLineSize {
+		s.dropLine(f.path)
+		f.partial = nil
+		f.dropping = true
+	}
+	return points
+}
+
+// dropLine counts and logs a line of the file exceeding the maximum line size.
+func (s *Service) dropLine(path string) {
+	s.statMap.Add(statLinesDropped, 1)
+	s.Diag.Error("dropped line", errors.Errorf("line exceeds maximum size of %d bytes", s.config.MaxLineSize), keyvalue.KV("path", path))
+}
+
+// decode decodes the points of the lines, lines that fail to decode are skipped.
+func (s *Service) decode(path string, lines []byte) []models.Point {
+	var points []models.Point
+	now := time.Now().UTC()
+	for _, line := range bytes.Split(lines, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if int64(len(line)) > s.config.MaxLineSize {
+			s.dropLine(path)
+			continue
+		}
+		s.statMap.Add(statLinesReceived, 1)
+		pts, err := s.decoder.Decode(line, now)
+		if err != nil {
+			s.statMap.Add(statPointsParseFail, 1)
+			s.Diag.Error("failed to decode points of line", err, keyvalue.KV("path", path))
+			continue
+		}
+		if s.config.PathTag != "" {
+			for _, p := range pts {
+				p.AddTag(s.config.PathTag, path)
+			}
+		}
+		points = append(points, pts...)
+	}
+	return points
+}
+
+func (s *Service) write(points []models.Point) {
+	s.statMap.Add(statPointsReceived, int64(len(points)))
+	if len(points) == 0 {
+		return
+	}
+	if err := s.PointsWriter.WritePoints(
+		s.config.Database,
+		s.config.RetentionPolicy,
+		models.ConsistencyLevelAll,
+		points,
+	); err != nil {
+		s.Diag.Error("failed to write points to database", err, keyvalue.KV("database", s.config.Database))
+		s.statMap.Add(statTransmitFail, 1)
+		return
+	}
+	s.statMap.Add(statPointsTransmitted, int64(len(points)))
+}
+
+// offsetKey returns the key of the persisted offset of the file.
+func (s *Service) offsetKey(path string) string {
+	return s.config.Name + "/" + path
+}
+
+func (s *Service) loadOffset(path string) (int64, bool) {
+	var offset int64
+	err := s.store.View(func(tx storage.ReadOnlyTx) error {
+		kv, err := tx.Get(s.offsetKey(path))
+		if err != nil {
+			return err
+		}
+		offset, err = strconv.ParseInt(string(kv.Value), 10, 64)
+		return err
+	})
+	if err != nil {
+		if err != storage.ErrNoKeyExists {
+			s.Diag.Error("failed to load offset of file", err, keyvalue.KV("path", path))
+		}
+		return 0, false
+	}
+	return offset, true
+}
+
+// saveOffsets persists the offsets of the files that changed.
+func (s *Service) saveOffsets() {
+	err := s.store.Update(func(tx storage.Tx) error {
+		for path, f := range s.files {
+			if offset := f.committed(); offset != f.saved {
+				if err := tx.Put(s.offsetKey(path), []byte(strconv.FormatInt(offset, 10))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.Diag.Error("failed to save offsets of files", err)
+		return
+	}
+	for _, f := range s.files {
+		f.saved = f.committed()
+	}
+}
+
+func (s *Service) deleteOffset(path string) {
+	err := s.store.Update(func(tx storage.Tx) error {
+		return tx.Delete(s.offsetKey(path))
+	})
+	if err != nil {
+		s.Diag.Error("failed to delete offset of file", err, keyvalue.KV("path", path))
+	}
+}
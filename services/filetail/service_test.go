@@ -0,0 +1,267 @@
+package filetail_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/toml"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/influxdata/kapacitor/services/diagnostic"
+	"github.com/influxdata/kapacitor/services/filetail"
+	"github.com/influxdata/kapacitor/services/storage"
+)
+
+var diagService *diagnostic.Service
+
+func init() {
+	diagService = diagnostic.NewService(diagnostic.NewConfig(), ioutil.Discard, ioutil.Discard)
+	diagService.Open()
+}
+
+type pointsWriter struct {
+	points chan string
+}
+
+func (w *pointsWriter) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	for _, p := range points {
+		w.points <- p.String()
+	}
+	return nil
+}
+
+// storageService returns the same store for a namespace, so that offsets persist across services.
+type storageService struct {
+	stores map[string]storage.Interface
+}
+
+func (s *storageService) Store(namespace string) storage.Interface {
+	if _, ok := s.stores[namespace]; !ok {
+		s.stores[namespace] = storage.NewMemStore(namespace)
+	}
+	return s.stores[namespace]
+}
+
+func appendFile(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file_tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "ERROR before start\n")
+
+	c := filetail.Config{
+		Enabled:      true,
+		Name:         "app",
+		Path:         filepath.Join(dir, "*.log"),
+		PollInterval: toml.Duration(10 * time.Millisecond),
+		Database:     "logs",
+		Config: decoder.Config{
+			Format:      decoder.FormatRegex,
+			Measurement: "app",
+			Regex:       `^(?P<level>[A-Z]+) (?P<msg>.*)$`,
+			TagPaths:    []string{"level"},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	storageService := &storageService{stores: make(map[string]storage.Interface)}
+	w := &pointsWriter{points: make(chan string, 10)}
+	open := func() *filetail.Service {
+		s, err := filetail.NewService(c, diagService.NewFileTailHandler(c.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.StorageService = storageService
+		s.PointsWriter = w
+		if err := s.Open(); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	expect := func(msg, exp string) {
+		t.Helper()
+		select {
+		case p := <-w.points:
+			// Drop the time, which is the time the line was read.
+			if got := p[:strings.LastIndex(p, " ")]; got != exp {
+				t.Errorf("%s: unexpected point got %s exp %s", msg, got, exp)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out waiting for point %s", msg, exp)
+		}
+	}
+
+	s := open()
+	// Files found on start are read from their end.
+	appendFile(t, path, "ERROR connection refused\n")
+	expect("append", `app,level=ERROR msg="connection refused"`)
+
+	// Incomplete lines are read once complete.
+	appendFile(t, path, "INFO par")
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "tial\n")
+	expect("partial line", `app,level=INFO msg="partial"`)
+
+	// Rotated files are replaced by the new file at the path, which is read from its beginning.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "WARN rotated\n")
+	expect("rotation", `app,level=WARN msg="rotated"`)
+
+	// Truncated files are read from their beginning.
+	time.Sleep(50 * time.Millisecond)
+	if err := ioutil.WriteFile(path, []byte("ERROR x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("truncation", `app,level=ERROR msg="x"`)
+
+	// Lines written while closed are read from the persisted offset.
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "INFO while closed\n")
+	s = open()
+	defer s.Close()
+	expect("restart", `app,level=INFO msg="while closed"`)
+
+	select {
+	case p := <-w.points:
+		t.Errorf("unexpected point %s", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestService_MaxSizes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file_tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	// The backlog is larger than a single read of the file.
+	const backlog = 3000
+	appendFile(t, path, strings.Repeat("INFO backlog line\n", backlog))
+
+	c := filetail.Config{
+		Enabled:       true,
+		Name:          "app",
+		Path:          path,
+		PollInterval:  toml.Duration(10 * time.Millisecond),
+		FromBeginning: true,
+		MaxLineSize:   64,
+		MaxReadSize:   1,
+		Database:      "logs",
+		Config: decoder.Config{
+			Format:      decoder.FormatRegex,
+			Measurement: "app",
+			Regex:       `^(?P<level>[A-Z]+) (?P<msg>.*)$`,
+			TagPaths:    []string{"level"},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := filetail.NewService(c, diagService.NewFileTailHandler(c.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.StorageService = &storageService{stores: make(map[string]storage.Interface)}
+	w := &pointsWriter{points: make(chan string, backlog+10)}
+	s.PointsWriter = w
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	next := func() string {
+		t.Helper()
+		select {
+		case p := <-w.points:
+			return p[:strings.LastIndex(p, " ")]
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for point")
+		}
+		return ""
+	}
+	for i := 0; i < backlog; i++ {
+		if got, exp := next(), `app,level=INFO msg="backlog line"`; got != exp {
+			t.Fatalf("unexpected backlog point %d got %s exp %s", i, got, exp)
+		}
+	}
+
+	// A line exceeding the maximum line size without a newline is dropped up to its newline.
+	appendFile(t, path, "ERROR "+strings.Repeat("x", 100))
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, strings.Repeat("x", 100))
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "x\nWARN after\n")
+	if got, exp := next(), `app,level=WARN msg="after"`; got != exp {
+		t.Errorf("unexpected point got %s exp %s", got, exp)
+	}
+	select {
+	case p := <-w.points:
+		t.Errorf("unexpected point %s", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name string
+		c    filetail.Config
+	}{
+		{
+			name: "missing name",
+			c:    filetail.Config{Enabled: true, Path: "/var/log/*.log", Database: "logs"},
+		},
+		{
+			name: "invalid path",
+			c:    filetail.Config{Enabled: true, Name: "a", Path: "/var/log/[", Database: "logs"},
+		},
+		{
+			name: "negative max line size",
+			c:    filetail.Config{Enabled: true, Name: "a", Path: "/var/log/*.log", Database: "logs", MaxLineSize: -1},
+		},
+		{
+			name: "negative max read size",
+			c:    filetail.Config{Enabled: true, Name: "a", Path: "/var/log/*.log", Database: "logs", MaxReadSize: -1},
+		},
+		{
+			name: "invalid format",
+			c: filetail.Config{
+				Enabled:  true,
+				Name:     "a",
+				Path:     "/var/log/*.log",
+				Database: "logs",
+				Config:   decoder.Config{Format: decoder.FormatGrok, Measurement: "m"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		if err := tc.c.Validate(); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+	if err := (filetail.Config{}).Validate(); err != nil {
+		t.Errorf("unexpected error for disabled config: %v", err)
+	}
+}
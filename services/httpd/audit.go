@@ -0,0 +1,215 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/influxdata/kapacitor/auth"
+)
+
+const (
+	// Maximum size in bytes of the before and after summaries of an audit entry.
+	maxAuditSummarySize = 4096
+	// Maximum size in bytes of a response body captured to produce a summary.
+	maxAuditCaptureSize = 1 << 20
+)
+
+// auditRedacted replaces the values of sensitive fields in audit summaries.
+const auditRedacted = "REDACTED"
+
+// auditSensitiveFields are the JSON fields that are never included in audit summaries.
+var auditSensitiveFields = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"secret":        true,
+	"shared-secret": true,
+}
+
+// AuditEntry describes a single mutating API request.
+type AuditEntry struct {
+	Time      time.Time
+	RequestID string
+	Method    string
+	// Route is the pattern of the route that served the request.
+	Route string
+	Path  string
+	// User is the name of the authenticated user.
+	User string
+	// ResourceID is the ID of the object the request acted on, if known.
+	ResourceID string
+	// Status is the HTTP status code of the response.
+	Status int
+	// Before and After are JSON summaries of the object before and after the request.
+	// They are empty if the object did not exist or could not be retrieved.
+	Before string
+	After  string
+}
+
+// isMutatingMethod reports whether requests with the HTTP method may change state.
+func isMutatingMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "POST", "PATCH", "PUT", "DELETE":
+		return true
+	default:
+		return false
+	}
+}
+
+// audit wraps a handler of a mutating route and reports each request to the AuditService.
+// The state of the object before the request is retrieved via the GET route of the same path,
+// authorized as the same user.
+func (h *Handler) audit(r Route, inner AuthorizationHandler) AuthorizationHandler {
+	if r.NoAudit || !isMutatingMethod(r.Method) {
+		return inner
+	}
+	pattern := r.Pattern
+	return func(w http.ResponseWriter, req *http.Request, user auth.User) {
+		if h.AuditService == nil {
+			inner(w, req, user)
+			return
+		}
+		entry := AuditEntry{
+			Time:       time.Now().UTC(),
+			RequestID:  req.Header.Get("Request-Id"),
+			Method:     req.Method,
+			Route:      pattern,
+			Path:       req.URL.Path,
+			User:       user.Name(),
+			ResourceID: auditResourceID(pattern, req.URL.Path),
+		}
+		if req.Method != "POST" {
+			entry.Before = h.auditSnapshot(req.URL.Path, user)
+		}
+		aw := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		inner(aw, req, user)
+		entry.Status = aw.status
+		if aw.status/100 == 2 && req.Method != "DELETE" {
+			entry.After = auditSummary(aw.body.Bytes())
+			if entry.ResourceID == "" {
+				entry.ResourceID = auditIDFromSummary(aw.body.Bytes())
+			}
+		}
+		h.AuditService.Audit(entry)
+	}
+}
+
+// auditSnapshot returns the summary of the object served by the GET route of the path.
+func (h *Handler) auditSnapshot(path string, user auth.User) string {
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		return ""
+	}
+	_, pattern := h.methodMux["GET"].Handler(req)
+	h.auditMu.RLock()
+	get, ok := h.auditGetters[pattern]
+	h.auditMu.RUnlock()
+	if !ok {
+		return ""
+	}
+	rec := httptest.NewRecorder()
+	get(rec, req, user)
+	if rec.Code/100 != 2 {
+		return ""
+	}
+	return auditSummary(rec.Body.Bytes())
+}
+
+// auditResourceID returns the part of the path below an anchored route pattern.
+func auditResourceID(pattern, path string) string {
+	if !strings.HasSuffix(pattern, "/") || len(path) <= len(pattern) {
+		return ""
+	}
+	return strings.TrimPrefix(path, pattern)
+}
+
+// auditIDFromSummary returns the ID or name of a created object.
+func auditIDFromSummary(body []byte) string {
+	var o struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &o); err != nil {
+		return ""
+	}
+	if o.ID != "" {
+		return o.ID
+	}
+	return o.Name
+}
+
+// auditSummary returns the compact JSON body with sensitive fields redacted,
+// truncated to maxAuditSummarySize bytes.
+// Bodies that are not valid JSON have no summary, since they cannot be redacted.
+func auditSummary(body []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return ""
+	}
+	b, err := json.Marshal(redact(v))
+	if err != nil {
+		return ""
+	}
+	summary := string(b)
+	if len(summary) > maxAuditSummarySize {
+		summary = summary[:maxAuditSummarySize]
+	}
+	return summary
+}
+
+// redact replaces the values of sensitive fields of the decoded JSON value.
+func redact(v interface{}) interface{} {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		for k, e := range o {
+			if auditSensitiveFields[strings.ToLower(k)] {
+				o[k] = auditRedacted
+				continue
+			}
+			o[k] = redact(e)
+		}
+	case []interface{}:
+		for i, e := range o {
+			o[i] = redact(e)
+		}
+	}
+	return v
+}
+
+// auditResponseWriter captures the status code and the beginning of the body of a response.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if remaining := maxAuditCaptureSize - w.body.Len(); remaining > 0 {
+		if len(b) < remaining {
+			remaining = len(b)
+		}
+		w.body.Write(b[:remaining])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -24,6 +24,8 @@ type Config struct {
 	HttpsCertificate string        `toml:"https-certificate"`
 	ShutdownTimeout  toml.Duration `toml:"shutdown-timeout"`
 	SharedSecret     string        `toml:"shared-secret"`
+	// Path to a local JWKS file with the public keys of RSA or ECDSA signed bearer tokens.
+	JWKSPath string `toml:"jwks-path"`
 
 	// Enable gzipped encoding
 	// NOTE: this is ignored in toml since it is only consumed by the tests
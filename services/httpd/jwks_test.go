@@ -0,0 +1,151 @@
+package httpd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func writeJWKS(t *testing.T, keys ...jsonWebKey) string {
+	f, err := ioutil.TempFile("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(map[string]interface{}{"keys": keys}); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func Test_JWTKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksPath := writeJWKS(t,
+		jsonWebKey{
+			Kty: "RSA",
+			Kid: "rsa",
+			Use: "sig",
+			N:   encodeBigInt(rsaKey.N),
+			E:   encodeBigInt(big.NewInt(int64(rsaKey.E))),
+		},
+		jsonWebKey{
+			Kty: "EC",
+			Kid: "ec",
+			Crv: "P-256",
+			X:   encodeBigInt(ecKey.X),
+			Y:   encodeBigInt(ecKey.Y),
+		},
+		// Encryption keys are ignored
+		jsonWebKey{
+			Kty: "RSA",
+			Kid: "enc",
+			Use: "enc",
+			N:   encodeBigInt(otherKey.N),
+			E:   encodeBigInt(big.NewInt(int64(otherKey.E))),
+		},
+	)
+	defer os.Remove(jwksPath)
+
+	jwks, err := loadJWKS(jwksPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{
+		sharedSecret: "secret",
+		jwks:         jwks,
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{"username": "bob"})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	testCases := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{
+			name:  "HMAC",
+			token: sign(jwt.SigningMethodHS256, "", []byte("secret")),
+			valid: true,
+		},
+		{
+			name:  "HMAC wrong secret",
+			token: sign(jwt.SigningMethodHS256, "", []byte("wrong")),
+			valid: false,
+		},
+		{
+			name:  "RSA",
+			token: sign(jwt.SigningMethodRS256, "rsa", rsaKey),
+			valid: true,
+		},
+		{
+			name:  "ECDSA",
+			token: sign(jwt.SigningMethodES256, "ec", ecKey),
+			valid: true,
+		},
+		{
+			name:  "RSA signed with the EC key ID",
+			token: sign(jwt.SigningMethodRS256, "ec", rsaKey),
+			valid: false,
+		},
+		{
+			name:  "RSA unknown key",
+			token: sign(jwt.SigningMethodRS256, "rsa", otherKey),
+			valid: false,
+		},
+		{
+			name:  "RSA encryption key",
+			token: sign(jwt.SigningMethodRS256, "enc", otherKey),
+			valid: false,
+		},
+		{
+			name:  "RSA without key ID and multiple keys",
+			token: sign(jwt.SigningMethodRS256, "", rsaKey),
+			valid: false,
+		},
+	}
+	for _, tc := range testCases {
+		token, err := jwt.Parse(tc.token, h.jwtKey)
+		if got, exp := err == nil && token.Valid, tc.valid; got != exp {
+			t.Errorf("%s: unexpected validity got %t exp %t: %v", tc.name, got, exp, err)
+		}
+	}
+
+	// Without a JWKS only HMAC tokens can be validated.
+	h.jwks = nil
+	if _, err := jwt.Parse(sign(jwt.SigningMethodRS256, "rsa", rsaKey), h.jwtKey); err == nil {
+		t.Error("expected error validating RSA token without JWKS")
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 type Diagnostic interface {
@@ -66,6 +68,8 @@ type Service struct {
 
 	externalURL string
 
+	jwksPath string
+
 	server *http.Server
 	mu     sync.Mutex
 	wg     sync.WaitGroup
@@ -99,6 +103,7 @@ func NewService(c Config, hostname string, d Diagnostic) *Service {
 		https:           c.HttpsEnabled,
 		cert:            c.HttpsCertificate,
 		externalURL:     u.String(),
+		jwksPath:        c.JWKSPath,
 		err:             make(chan error, 1),
 		shutdownTimeout: time.Duration(c.ShutdownTimeout),
 		Handler: NewHandler(
@@ -124,6 +129,14 @@ func (s *Service) Open() error {
 	s.diag.StartingService()
 	s.diag.AuthenticationEnabled(s.Handler.requireAuthentication)
 
+	if s.jwksPath != "" {
+		jwks, err := loadJWKS(s.jwksPath)
+		if err != nil {
+			return errors.Wrap(err, "failed to load JWKS")
+		}
+		s.Handler.jwks = jwks
+	}
+
 	// Open listener.
 	if s.https {
 		cert, err := tls.LoadX509KeyPair(s.cert, s.cert)
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -24,6 +25,9 @@ const (
 
 	extensionsPath          = apisBasePath + "/extensions/v1beta1"
 	extensionsNamespacePath = extensionsPath + "/namespaces"
+	appsPath                = apisBasePath + "/apps/v1"
+	appsNamespacePath       = appsPath + "/namespaces"
+	namespacesEndpoint      = "/namespaces"
 	scaleEndpoint           = "/scale"
 
 	// Secrets
@@ -265,6 +269,31 @@ type ScalesInterface interface {
 	Update(kind string, scale *Scale) error
 }
 
+// ValidateKind reports whether the scale subresource of the kind can be accessed.
+// Besides the builtin kinds, the scale subresource of a custom resource
+// can be accessed using a kind of the form <group>/<version>/<resource>,
+// i.e. "example.com/v1/workers".
+func ValidateKind(kind string) error {
+	_, err := scalesPath("", kind)
+	return err
+}
+
+// scalesPath returns the path of the resources of the kind in the namespace.
+func scalesPath(namespace, kind string) (string, error) {
+	switch kind {
+	case DeploymentsKind, ReplicaSetsKind, ReplicationControllerKind:
+		return path.Join(extensionsNamespacePath, namespace, kind), nil
+	case StatefulSetsKind:
+		return path.Join(appsNamespacePath, namespace, kind), nil
+	}
+	parts := strings.Split(kind, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("invalid kind %q, must be one of %s, %s, %s, %s or a custom resource of the form <group>/<version>/<resource>",
+			kind, DeploymentsKind, ReplicaSetsKind, ReplicationControllerKind, StatefulSetsKind)
+	}
+	return path.Join(apisBasePath, parts[0], parts[1], namespacesEndpoint, namespace, parts[2]), nil
+}
+
 type Scales struct {
 	c         *httpClient
 	namespace string
@@ -281,9 +310,13 @@ func (c *httpClient) Scales(namespace string) ScalesInterface {
 }
 
 func (s Scales) Get(kind, name string) (*Scale, error) {
-	p := path.Join(extensionsNamespacePath, s.namespace, string(kind), name, scaleEndpoint)
+	p, err := scalesPath(s.namespace, kind)
+	if err != nil {
+		return nil, err
+	}
+	p = path.Join(p, name, scaleEndpoint)
 	scale := &Scale{}
-	err := s.c.Get(p, scale, http.StatusOK)
+	err = s.c.Get(p, scale, http.StatusOK)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get scale %s/%s/%s", s.namespace, kind, name)
 	}
@@ -296,7 +329,16 @@ func (s Scales) Update(kind string, scale *Scale) error {
 		Path:      "/spec/replicas",
 		Value:     scale.Spec.Replicas,
 	}
-	err := s.c.Patch(scale.SelfLink, patch, http.StatusOK)
+	// Not all API servers provide the self link of the scale subresource.
+	p := scale.SelfLink
+	if p == "" {
+		sp, err := scalesPath(s.namespace, kind)
+		if err != nil {
+			return err
+		}
+		p = path.Join(sp, scale.Name, scaleEndpoint)
+	}
+	err := s.c.Patch(p, patch, http.StatusOK)
 	if err != nil {
 		return errors.Wrapf(err, "failed to update scale %s/%s/%s", s.namespace, kind, scale.Name)
 	}
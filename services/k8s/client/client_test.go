@@ -0,0 +1,118 @@
+package client_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/kapacitor/services/k8s/client"
+)
+
+type request struct {
+	Method string
+	Path   string
+	Patch  []client.JSONPatch
+}
+
+func newServer(t *testing.T, requests chan<- request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+		}
+		if r.Method == "PATCH" {
+			if err := json.NewDecoder(r.Body).Decode(&req.Patch); err != nil {
+				t.Error(err)
+			}
+		}
+		requests <- req
+		fmt.Fprint(w, `{"metadata":{"name":"web"},"spec":{"replicas":3}}`)
+	}))
+}
+
+func TestScales(t *testing.T) {
+	testCases := []struct {
+		kind string
+		path string
+	}{
+		{
+			kind: client.DeploymentsKind,
+			path: "/apis/extensions/v1beta1/namespaces/ns/deployments/web/scale",
+		},
+		{
+			kind: client.StatefulSetsKind,
+			path: "/apis/apps/v1/namespaces/ns/statefulsets/web/scale",
+		},
+		{
+			kind: "example.com/v1alpha1/workers",
+			path: "/apis/example.com/v1alpha1/namespaces/ns/workers/web/scale",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.kind, func(t *testing.T) {
+			requests := make(chan request, 2)
+			s := newServer(t, requests)
+			defer s.Close()
+			c, err := client.New(client.Config{URLs: []string{s.URL}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			scales := c.Scales("ns")
+
+			scale, err := scales.Get(tc.kind, "web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, exp := scale.Spec.Replicas, int32(3); got != exp {
+				t.Errorf("unexpected replicas: got %d exp %d", got, exp)
+			}
+			if got, exp := <-requests, (request{Method: "GET", Path: tc.path}); !reflect.DeepEqual(got, exp) {
+				t.Errorf("unexpected get request:\ngot\n%+v\nexp\n%+v", got, exp)
+			}
+
+			// The self link is not set by the server, the path of the scale subresource is used.
+			scale.Spec.Replicas = 5
+			if err := scales.Update(tc.kind, scale); err != nil {
+				t.Fatal(err)
+			}
+			exp := request{
+				Method: "PATCH",
+				Path:   tc.path,
+				Patch: []client.JSONPatch{{
+					Operation: "replace",
+					Path:      "/spec/replicas",
+					Value:     5.0,
+				}},
+			}
+			if got := <-requests; !reflect.DeepEqual(got, exp) {
+				t.Errorf("unexpected update request:\ngot\n%+v\nexp\n%+v", got, exp)
+			}
+		})
+	}
+}
+
+func TestValidateKind(t *testing.T) {
+	testCases := map[string]bool{
+		client.DeploymentsKind:           true,
+		client.ReplicaSetsKind:           true,
+		client.ReplicationControllerKind: true,
+		client.StatefulSetsKind:          true,
+		"example.com/v1/workers":         true,
+		"daemonsets":                     false,
+		"example.com/workers":            false,
+		"example.com//workers":           false,
+		"":                               false,
+	}
+	for kind, valid := range testCases {
+		err := client.ValidateKind(kind)
+		if valid && err != nil {
+			t.Errorf("unexpected error for kind %q: %v", kind, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected error for kind %q", kind)
+		}
+	}
+}
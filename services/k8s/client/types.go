@@ -12,6 +12,7 @@ const (
 	DeploymentsKind           = "deployments"
 	ReplicaSetsKind           = "replicasets"
 	ReplicationControllerKind = "replicationcontroller"
+	StatefulSetsKind          = "statefulsets"
 )
 
 // ObjectMeta is metadata that all persisted resources must have, which includes all objects
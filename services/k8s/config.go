@@ -9,6 +9,7 @@ import (
 	"github.com/influxdata/kapacitor/listmap"
 	"github.com/influxdata/kapacitor/services/k8s/client"
 	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
 )
 
@@ -21,6 +22,10 @@ type Config struct {
 	CAPath     string   `toml:"ca-path" override:"ca-path"`
 	Namespace  string   `toml:"namespace" override:"namespace"`
 	Resource   string   `toml:"resource" override:"resource"`
+	// AnnotationDiscovery only scrapes the pods or endpoints annotated with prometheus.io/scrape = "true".
+	// The prometheus.io/scheme, prometheus.io/path and prometheus.io/port annotations
+	// override the scheme, metrics path and port of the targets.
+	AnnotationDiscovery bool `toml:"annotation-discovery" override:"annotation-discovery"`
 }
 
 func NewConfig() Config {
@@ -56,12 +61,19 @@ func (c Config) Validate() error {
 	}
 	if c.Resource != "" {
 		switch c.Resource {
-		case "node", "pod", "service", "endpoint":
+		case "node", "pod", "service", "endpoint", "endpoints":
 		default:
 			return errors.New("Resource must be one of node, pod, service, or endpoints")
 		}
 
 	}
+	if c.AnnotationDiscovery {
+		switch c.role() {
+		case config.KubernetesRolePod, config.KubernetesRoleEndpoint:
+		default:
+			return errors.New("annotation-discovery requires a resource of pod or endpoints")
+		}
+	}
 	return nil
 }
 
@@ -111,12 +123,24 @@ func (cs Configs) Validate() error {
 	return nil
 }
 
+// role returns the prometheus role of the discovered resources.
+func (c Config) role() config.KubernetesRole {
+	if c.Resource == "endpoint" {
+		return config.KubernetesRoleEndpoint
+	}
+	return config.KubernetesRole(c.Resource)
+}
+
 // Prom writes the prometheus configuration for discoverer into ScrapeConfig
 func (c Config) Prom(conf *config.ScrapeConfig) {
+	if c.AnnotationDiscovery {
+		// The annotations are applied before the relabel rules of the scraper.
+		conf.RelabelConfigs = append(annotationRelabelConfigs(c.role()), conf.RelabelConfigs...)
+	}
 	if len(c.APIServers) == 0 {
 		conf.ServiceDiscoveryConfig.KubernetesSDConfigs = []*config.KubernetesSDConfig{
 			&config.KubernetesSDConfig{
-				Role:        config.KubernetesRole(c.Resource),
+				Role:        c.role(),
 				BearerToken: c.Token,
 				TLSConfig: config.TLSConfig{
 					CAFile: c.CAPath,
@@ -133,7 +157,7 @@ func (c Config) Prom(conf *config.ScrapeConfig) {
 			APIServer: config.URL{
 				URL: url,
 			},
-			Role:        config.KubernetesRole(c.Resource),
+			Role:        c.role(),
 			BearerToken: c.Token,
 			TLSConfig: config.TLSConfig{
 				CAFile: c.CAPath,
@@ -143,6 +167,73 @@ func (c Config) Prom(conf *config.ScrapeConfig) {
 	conf.ServiceDiscoveryConfig.KubernetesSDConfigs = sds
 }
 
+// annotationRelabelConfigs returns the relabel rules following the prometheus.io annotation conventions.
+// Pods are discovered using their own annotations and endpoints using the annotations of their service.
+func annotationRelabelConfigs(role config.KubernetesRole) []*config.RelabelConfig {
+	object := "pod"
+	if role == config.KubernetesRoleEndpoint {
+		object = "service"
+	}
+	annotation := func(name string) model.LabelName {
+		return model.LabelName("__meta_kubernetes_" + object + "_annotation_prometheus_io_" + name)
+	}
+	return []*config.RelabelConfig{
+		{
+			Action:       config.RelabelKeep,
+			SourceLabels: model.LabelNames{annotation("scrape")},
+			Separator:    ";",
+			Regex:        config.MustNewRegexp("true"),
+			Replacement:  "$1",
+		},
+		{
+			Action:       config.RelabelReplace,
+			SourceLabels: model.LabelNames{annotation("scheme")},
+			Separator:    ";",
+			Regex:        config.MustNewRegexp("(https?)"),
+			TargetLabel:  model.SchemeLabel,
+			Replacement:  "$1",
+		},
+		{
+			Action:       config.RelabelReplace,
+			SourceLabels: model.LabelNames{annotation("path")},
+			Separator:    ";",
+			Regex:        config.MustNewRegexp("(.+)"),
+			TargetLabel:  model.MetricsPathLabel,
+			Replacement:  "$1",
+		},
+		{
+			Action:       config.RelabelReplace,
+			SourceLabels: model.LabelNames{model.AddressLabel, annotation("port")},
+			Separator:    ";",
+			Regex:        config.MustNewRegexp(`([^:]+)(?::\d+)?;(\d+)`),
+			TargetLabel:  model.AddressLabel,
+			Replacement:  "$1:$2",
+		},
+		{
+			Action:      config.RelabelLabelMap,
+			Separator:   ";",
+			Regex:       config.MustNewRegexp("__meta_kubernetes_" + object + "_label_(.+)"),
+			Replacement: "$1",
+		},
+		{
+			Action:       config.RelabelReplace,
+			SourceLabels: model.LabelNames{"__meta_kubernetes_namespace"},
+			Separator:    ";",
+			Regex:        config.MustNewRegexp("(.*)"),
+			TargetLabel:  "kubernetes_namespace",
+			Replacement:  "$1",
+		},
+		{
+			Action:       config.RelabelReplace,
+			SourceLabels: model.LabelNames{model.LabelName("__meta_kubernetes_" + object + "_name")},
+			Separator:    ";",
+			Regex:        config.MustNewRegexp("(.*)"),
+			TargetLabel:  "kubernetes_" + object + "_name",
+			Replacement:  "$1",
+		},
+	}
+}
+
 // Service return discoverer type
 func (c Config) Service() string {
 	return "kubernetes"
@@ -0,0 +1,124 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/kapacitor/services/scraper"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/relabel"
+)
+
+func TestConfig_PromAnnotationDiscovery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		resource string
+		labels   model.LabelSet
+		exp      model.LabelSet
+	}{
+		{
+			name:     "annotated pod",
+			resource: "pod",
+			labels: model.LabelSet{
+				"__address__":                     "10.0.0.1:8080",
+				"__scheme__":                      "http",
+				"__metrics_path__":                "/metrics",
+				"__meta_kubernetes_namespace":     "default",
+				"__meta_kubernetes_pod_name":      "web-1",
+				"__meta_kubernetes_pod_label_app": "web",
+				"__meta_kubernetes_pod_annotation_prometheus_io_scrape": "true",
+				"__meta_kubernetes_pod_annotation_prometheus_io_scheme": "https",
+				"__meta_kubernetes_pod_annotation_prometheus_io_path":   "/stats",
+				"__meta_kubernetes_pod_annotation_prometheus_io_port":   "9102",
+			},
+			exp: model.LabelSet{
+				"__address__":                     "10.0.0.1:9102",
+				"__scheme__":                      "https",
+				"__metrics_path__":                "/stats",
+				"__meta_kubernetes_namespace":     "default",
+				"__meta_kubernetes_pod_name":      "web-1",
+				"__meta_kubernetes_pod_label_app": "web",
+				"__meta_kubernetes_pod_annotation_prometheus_io_scrape": "true",
+				"__meta_kubernetes_pod_annotation_prometheus_io_scheme": "https",
+				"__meta_kubernetes_pod_annotation_prometheus_io_path":   "/stats",
+				"__meta_kubernetes_pod_annotation_prometheus_io_port":   "9102",
+				"app":                  "web",
+				"kubernetes_namespace": "default",
+				"kubernetes_pod_name":  "web-1",
+				"env":                  "prod",
+			},
+		},
+		{
+			name:     "pod without annotations",
+			resource: "pod",
+			labels: model.LabelSet{
+				"__address__":                "10.0.0.2:8080",
+				"__meta_kubernetes_pod_name": "db-1",
+			},
+			exp: nil,
+		},
+		{
+			name:     "annotated service endpoint",
+			resource: "endpoints",
+			labels: model.LabelSet{
+				"__address__":                    "10.0.0.3:8080",
+				"__metrics_path__":               "/metrics",
+				"__meta_kubernetes_namespace":    "kube-system",
+				"__meta_kubernetes_service_name": "dns",
+				"__meta_kubernetes_service_annotation_prometheus_io_scrape": "true",
+			},
+			exp: model.LabelSet{
+				"__address__":                    "10.0.0.3:8080",
+				"__metrics_path__":               "/metrics",
+				"__meta_kubernetes_namespace":    "kube-system",
+				"__meta_kubernetes_service_name": "dns",
+				"__meta_kubernetes_service_annotation_prometheus_io_scrape": "true",
+				"kubernetes_namespace":    "kube-system",
+				"kubernetes_service_name": "dns",
+				"env":                     "prod",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{
+				Enabled:             true,
+				InCluster:           true,
+				Resource:            tc.resource,
+				AnnotationDiscovery: true,
+			}
+			if err := c.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			// The relabel rules of the scraper are applied after the annotations.
+			s := scraper.Config{
+				Relabel: []scraper.RelabelConfig{{
+					TargetLabel: "env",
+					Replacement: "prod",
+				}},
+			}
+			sc := s.Prom()
+			c.Prom(sc)
+			if got, exp := sc.ServiceDiscoveryConfig.KubernetesSDConfigs[0].Role, config.KubernetesRole(tc.resource); got != exp {
+				t.Errorf("unexpected role: got %s exp %s", got, exp)
+			}
+			got := relabel.Process(tc.labels, sc.RelabelConfigs...)
+			if !reflect.DeepEqual(got, tc.exp) {
+				t.Errorf("unexpected labels:\ngot\n%v\nexp\n%v", got, tc.exp)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateAnnotationDiscovery(t *testing.T) {
+	c := Config{
+		Enabled:             true,
+		InCluster:           true,
+		Resource:            "node",
+		AnnotationDiscovery: true,
+	}
+	if err := c.Validate(); err == nil {
+		t.Error("expected error for annotation discovery of nodes")
+	}
+}
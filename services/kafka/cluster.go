@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// cluster holds the producer and the consumers of a Kafka cluster.
+type cluster struct {
+	c    Config
+	diag Diagnostic
+
+	mu       sync.Mutex
+	producer sarama.SyncProducer
+
+	consumers []*consumer
+}
+
+// write writes the messages to the topic, creating the producer on first use,
+// so that unavailable brokers do not prevent Kapacitor from starting.
+func (cl *cluster) write(topic string, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.producer == nil {
+		p, err := cl.c.NewProducer()
+		if err != nil {
+			return errors.Wrap(err, "failed to create kafka producer")
+		}
+		cl.producer = p
+	}
+
+	msgs := make([]*sarama.ProducerMessage, len(messages))
+	for i, m := range messages {
+		msgs[i] = &sarama.ProducerMessage{
+			Topic: topic,
+			Value: sarama.ByteEncoder(m.Value),
+		}
+		if m.Key != nil {
+			msgs[i].Key = sarama.ByteEncoder(m.Key)
+		}
+	}
+	if len(msgs) == 1 {
+		_, _, err := cl.producer.SendMessage(msgs[0])
+		return err
+	}
+	return cl.producer.SendMessages(msgs)
+}
+
+func (cl *cluster) close() {
+	for _, c := range cl.consumers {
+		c.close()
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.producer != nil {
+		if err := cl.producer.Close(); err != nil {
+			cl.diag.Error("failed to close kafka producer", err)
+		}
+		cl.producer = nil
+	}
+}
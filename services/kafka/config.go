@@ -0,0 +1,206 @@
+package kafka
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/influxdata/influxdb/toml"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/influxdata/kapacitor/tlsconfig"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTimeout is the timeout of the network requests to the brokers.
+	DefaultTimeout = 10 * time.Second
+	// DefaultVersion is the Kafka protocol version used.
+	// Consumer groups require at least version 0.10.2.0.
+	DefaultVersion = "1.0.0"
+	// DefaultGroup is the consumer group of consumers without a group.
+	DefaultGroup = "kapacitor"
+
+	// OffsetNewest starts consuming partitions without a committed offset at the newest message.
+	OffsetNewest = "newest"
+	// OffsetOldest starts consuming partitions without a committed offset at the oldest message.
+	OffsetOldest = "oldest"
+)
+
+type Config struct {
+	// Enabled indicates whether the service should be enabled
+	Enabled bool `toml:"enabled" override:"enabled"`
+	// ID is a unique identifier for this Kafka cluster config
+	ID string `toml:"id" override:"id"`
+	// Brokers is a list of host:port addresses of Kafka brokers.
+	Brokers []string `toml:"brokers" override:"brokers"`
+	// Timeout on network operations with the brokers.
+	// If 0 a default of 10s will be used.
+	Timeout toml.Duration `toml:"timeout" override:"timeout"`
+	// Version of the Kafka protocol, i.e. the version of the brokers.
+	Version string `toml:"version" override:"version"`
+
+	// UseSSL enables use of SSL/TLS to connect to the brokers.
+	UseSSL bool `toml:"use-ssl" override:"use-ssl"`
+	// Path to CA file
+	SSLCA string `toml:"ssl-ca" override:"ssl-ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl-cert" override:"ssl-cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl-key" override:"ssl-key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool `toml:"insecure-skip-verify" override:"insecure-skip-verify"`
+
+	// Consumers of topics of the cluster, whose messages are written as points.
+	// Consumers can only be configured in the configuration file.
+	Consumers []ConsumerConfig `toml:"consumer" override:"-"`
+
+	// NewProducerF is a function that returns a producer for a given config.
+	NewProducerF func(c Config) (sarama.SyncProducer, error) `toml:"-" override:"-"`
+}
+
+func NewConfig() Config {
+	return Config{
+		ID:      "default",
+		Timeout: toml.Duration(DefaultTimeout),
+		Version: DefaultVersion,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.ID == "" {
+		return errors.New("id must not be empty")
+	}
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Brokers) == 0 {
+		return errors.New("no brokers specified, must provide at least one broker address")
+	}
+	if c.Version != "" {
+		if _, err := sarama.ParseKafkaVersion(c.Version); err != nil {
+			return errors.Wrap(err, "invalid version")
+		}
+	}
+	for i, cc := range c.Consumers {
+		if err := cc.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid consumer %d of kafka cluster %q", i, c.ID)
+		}
+	}
+	return nil
+}
+
+func (c Config) Equal(o Config) bool {
+	// Functions cannot be compared
+	c.NewProducerF, o.NewProducerF = nil, nil
+	return reflect.DeepEqual(c, o)
+}
+
+// NewProducer creates a new producer based off this configuration.
+func (c Config) NewProducer() (sarama.SyncProducer, error) {
+	if c.NewProducerF != nil {
+		return c.NewProducerF(c)
+	}
+	sc, err := c.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+	return sarama.NewSyncProducer(c.Brokers, sc)
+}
+
+// saramaConfig returns the configuration of the clients of the cluster.
+func (c Config) saramaConfig() (*sarama.Config, error) {
+	sc := sarama.NewConfig()
+	sc.ClientID = "kapacitor"
+	version := c.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+	v, err := sarama.ParseKafkaVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	sc.Version = v
+	timeout := time.Duration(c.Timeout)
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	sc.Net.DialTimeout = timeout
+	sc.Net.ReadTimeout = timeout
+	sc.Net.WriteTimeout = timeout
+	if c.UseSSL {
+		tlsConfig, err := tlsconfig.Create(c.SSLCA, c.SSLCert, c.SSLKey, c.InsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		sc.Net.TLS.Enable = true
+		sc.Net.TLS.Config = tlsConfig
+	}
+
+	// Required by the sync producer
+	sc.Producer.Return.Successes = true
+	sc.Producer.RequiredAcks = sarama.WaitForAll
+	sc.Producer.Timeout = timeout
+
+	sc.Consumer.Return.Errors = true
+	return sc, nil
+}
+
+// ConsumerConfig describes a consumer of topics, which is a member of a consumer group,
+// and how the values of its messages are decoded into points.
+type ConsumerConfig struct {
+	Topics []string `toml:"topics"`
+	// Group is the consumer group, whose committed offsets are shared by all its members.
+	Group string `toml:"group"`
+	// Offset to start consuming partitions without a committed offset at, one of newest or oldest.
+	Offset string `toml:"offset"`
+
+	// Database and retention policy the points are written to.
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+
+	// TopicTag is the name of a tag holding the topic of the message, the topic is not added if empty.
+	TopicTag string `toml:"topic-tag"`
+
+	// How the values of the messages are decoded.
+	decoder.Config
+}
+
+func (c ConsumerConfig) Validate() error {
+	if len(c.Topics) == 0 {
+		return errors.New("must specify topics")
+	}
+	if c.Database == "" {
+		return errors.New("must specify database")
+	}
+	switch c.Offset {
+	case "", OffsetNewest, OffsetOldest:
+	default:
+		return fmt.Errorf("invalid offset %q, must be one of %s or %s", c.Offset, OffsetNewest, OffsetOldest)
+	}
+	return c.Config.Validate()
+}
+
+type Configs []Config
+
+// Validate calls config.Validate for each element in Configs
+func (cs Configs) Validate() error {
+	for _, c := range cs {
+		err := c.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index generates a map of configs by ID
+func (cs Configs) index() map[string]Config {
+	m := make(map[string]Config, len(cs))
+
+	for _, c := range cs {
+		m[c.ID] = c
+	}
+
+	return m
+}
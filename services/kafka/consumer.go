@@ -0,0 +1,198 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/influxdata/kapacitor/server/vars"
+)
+
+// statistics gathered for each consumer.
+const (
+	statMessagesReceived  = "messages_rx"
+	statPointsReceived    = "points_rx"
+	statPointsParseFail   = "points_parse_fail"
+	statPointsTransmitted = "points_tx"
+	statTransmitFail      = "tx_fail"
+)
+
+// retryInterval is how long a consumer waits before retrying to join its group after an error.
+var retryInterval = 5 * time.Second
+
+type pointsWriter interface {
+	WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+// consumer is a member of a consumer group, which writes the points decoded from the messages
+// of the partitions assigned to it. Offsets of consumed messages are committed periodically.
+type consumer struct {
+	cluster Config
+	c       ConsumerConfig
+	group   string
+
+	decoder      *decoder.Decoder
+	pointsWriter pointsWriter
+	diag         Diagnostic
+
+	statMap *expvar.Map
+	statKey string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newConsumer(cluster Config, c ConsumerConfig, w pointsWriter, d Diagnostic) (*consumer, error) {
+	dec, err := decoder.New(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	group := c.Group
+	if group == "" {
+		group = DefaultGroup
+	}
+	tags := map[string]string{
+		"cluster": cluster.ID,
+		"group":   group,
+		"topics":  strings.Join(c.Topics, ","),
+	}
+	statKey, statMap := vars.NewStatistic("kafka", tags)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &consumer{
+		cluster:      cluster,
+		c:            c,
+		group:        group,
+		decoder:      dec,
+		pointsWriter: w,
+		diag:         d.WithContext(keyvalue.KV("group", group), keyvalue.KV("topics", tags["topics"])),
+		statMap:      statMap,
+		statKey:      statKey,
+		ctx:          ctx,
+		cancel:       cancel,
+	}, nil
+}
+
+func (c *consumer) open() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.run()
+	}()
+}
+
+func (c *consumer) close() {
+	c.cancel()
+	c.wg.Wait()
+	vars.DeleteStatistic(c.statKey)
+}
+
+// run joins the consumer group and consumes the claimed partitions until the consumer is closed,
+// rejoining the group after rebalances and errors.
+func (c *consumer) run() {
+	sc, err := c.cluster.saramaConfig()
+	if err != nil {
+		c.diag.Error("invalid kafka configuration", err)
+		return
+	}
+	if c.c.Offset == OffsetOldest {
+		sc.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+
+	var group sarama.ConsumerGroup
+	for {
+		group, err = sarama.NewConsumerGroup(c.cluster.Brokers, c.group, sc)
+		if err == nil {
+			break
+		}
+		c.diag.Error("failed to create kafka consumer group", err)
+		if !c.wait() {
+			return
+		}
+	}
+	defer group.Close()
+
+	go func() {
+		for err := range group.Errors() {
+			c.diag.Error("kafka consumer error", err)
+		}
+	}()
+
+	for c.ctx.Err() == nil {
+		if err := group.Consume(c.ctx, c.c.Topics, c); err != nil {
+			c.diag.Error("failed to consume kafka topics", err)
+			if !c.wait() {
+				return
+			}
+		}
+	}
+}
+
+// wait waits for the retry interval and reports whether the consumer is still open.
+func (c *consumer) wait() bool {
+	select {
+	case <-c.ctx.Done():
+		return false
+	case <-time.After(retryInterval):
+		return true
+	}
+}
+
+func (c *consumer) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (c *consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (c *consumer) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		c.handle(msg)
+		// Messages that cannot be decoded or written are skipped as well,
+		// since they would fail again.
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func (c *consumer) handle(msg *sarama.ConsumerMessage) {
+	c.statMap.Add(statMessagesReceived, 1)
+	// Points without a time have the time of the message
+	now := msg.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	points, err := c.decoder.Decode(msg.Value, now.UTC())
+	if err != nil {
+		c.statMap.Add(statPointsParseFail, 1)
+		c.diag.Error("failed to decode points of message", err)
+		return
+	}
+	if c.c.TopicTag != "" {
+		for _, p := range points {
+			p.AddTag(c.c.TopicTag, msg.Topic)
+		}
+	}
+	c.statMap.Add(statPointsReceived, int64(len(points)))
+	if len(points) == 0 {
+		return
+	}
+	if err := c.pointsWriter.WritePoints(
+		c.c.Database,
+		c.c.RetentionPolicy,
+		models.ConsistencyLevelAll,
+		points,
+	); err != nil {
+		c.diag.Error("failed to write points to database", err)
+		c.statMap.Add(statTransmitFail, 1)
+		return
+	}
+	c.statMap.Add(statPointsTransmitted, int64(len(points)))
+}
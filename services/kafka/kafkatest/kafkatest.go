@@ -0,0 +1,107 @@
+package kafkatest
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/influxdata/kapacitor/services/kafka"
+)
+
+// ProducerCreator provides a NewProducer method for creating new MockProducers.
+// All configs and producers created are recorded.
+type ProducerCreator struct {
+	mu        sync.Mutex
+	Producers []*MockProducer
+	Configs   []kafka.Config
+}
+
+func (s *ProducerCreator) NewProducer(c kafka.Config) (sarama.SyncProducer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := new(MockProducer)
+	s.Producers = append(s.Producers, p)
+	s.Configs = append(s.Configs, c)
+	return p, nil
+}
+
+// Messages returns the messages written by all producers.
+func (s *ProducerCreator) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []Message
+	for _, p := range s.Producers {
+		messages = append(messages, p.Messages()...)
+	}
+	return messages
+}
+
+// MockProducer records the messages it writes.
+type MockProducer struct {
+	mu       sync.Mutex
+	closed   bool
+	messages []Message
+}
+
+func (p *MockProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, 0, errors.New("SendMessage() called after Close()")
+	}
+	m, err := newMessage(msg)
+	if err != nil {
+		return 0, 0, err
+	}
+	p.messages = append(p.messages, m)
+	return 0, int64(len(p.messages) - 1), nil
+}
+
+func (p *MockProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	for _, msg := range msgs {
+		if _, _, err := p.SendMessage(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *MockProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// Messages returns the messages written by the producer.
+func (p *MockProducer) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.messages...)
+}
+
+// Message is a message written by a MockProducer.
+type Message struct {
+	Topic string
+	Key   string
+	Value string
+}
+
+func newMessage(msg *sarama.ProducerMessage) (Message, error) {
+	m := Message{Topic: msg.Topic}
+	if msg.Key != nil {
+		k, err := msg.Key.Encode()
+		if err != nil {
+			return Message{}, err
+		}
+		m.Key = string(k)
+	}
+	if msg.Value != nil {
+		v, err := msg.Value.Encode()
+		if err != nil {
+			return Message{}, err
+		}
+		m.Value = string(v)
+	}
+	return m, nil
+}
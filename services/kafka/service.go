@@ -0,0 +1,235 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/pkg/errors"
+)
+
+type Diagnostic interface {
+	WithContext(ctx ...keyvalue.T) Diagnostic
+	Error(msg string, err error)
+	CreatingAlertHandler(c HandlerConfig)
+	HandlingEvent()
+}
+
+// Message is a message written to a topic.
+type Message struct {
+	// Key of the message, messages with the same key are written to the same partition.
+	// Messages without a key are distributed across all partitions.
+	Key   []byte
+	Value []byte
+}
+
+// Service writes messages to the topics of Kafka clusters
+// and runs the consumers of the clusters, which write the points decoded from the messages.
+type Service struct {
+	diag Diagnostic
+
+	mu       sync.RWMutex
+	configs  map[string]Config
+	clusters map[string]*cluster
+	opened   bool
+
+	// PointsWriter receives the points of the messages of all consumers.
+	PointsWriter interface {
+		WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+}
+
+func NewService(cs Configs, d Diagnostic) *Service {
+	return &Service{
+		diag:     d,
+		configs:  cs.index(),
+		clusters: make(map[string]*cluster, len(cs)),
+	}
+}
+
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, c := range s.configs {
+		if !c.Enabled {
+			continue
+		}
+		cl, err := s.newCluster(c)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open kafka cluster %q", id)
+		}
+		s.clusters[id] = cl
+	}
+	s.opened = true
+	return nil
+}
+
+func (s *Service) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, cl := range s.clusters {
+		cl.close()
+		delete(s.clusters, id)
+	}
+	s.opened = false
+	return nil
+}
+
+func (s *Service) newCluster(c Config) (*cluster, error) {
+	cl := &cluster{
+		c:    c,
+		diag: s.diag.WithContext(keyvalue.KV("cluster", c.ID)),
+	}
+	for _, cc := range c.Consumers {
+		cons, err := newConsumer(c, cc, s.PointsWriter, cl.diag)
+		if err != nil {
+			cl.close()
+			return nil, err
+		}
+		cl.consumers = append(cl.consumers, cons)
+		cons.open()
+	}
+	return cl, nil
+}
+
+func (s *Service) Update(newConfigs []interface{}) error {
+	cs := make(Configs, len(newConfigs))
+	for i, c := range newConfigs {
+		config, ok := c.(Config)
+		if !ok {
+			return fmt.Errorf("expected config object to be of type %T, got %T", config, c)
+		}
+		cs[i] = config
+	}
+	if err := cs.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configs := cs.index()
+	for id, c := range configs {
+		if old, ok := s.configs[id]; ok && old.Equal(c) {
+			continue
+		}
+		if cl, ok := s.clusters[id]; ok {
+			cl.close()
+			delete(s.clusters, id)
+		}
+		if c.Enabled && s.opened {
+			cl, err := s.newCluster(c)
+			if err != nil {
+				return err
+			}
+			s.clusters[id] = cl
+		}
+	}
+	// Close removed clusters
+	for id, cl := range s.clusters {
+		if _, ok := configs[id]; !ok {
+			cl.close()
+			delete(s.clusters, id)
+		}
+	}
+	s.configs = configs
+	return nil
+}
+
+// Write writes the messages to the topic of the cluster.
+// The cluster may be empty if only one cluster is configured.
+func (s *Service) Write(clusterID, topic string, messages ...Message) error {
+	if topic == "" {
+		return errors.New("missing kafka topic")
+	}
+	s.mu.RLock()
+	if clusterID == "" && len(s.configs) == 1 {
+		for id := range s.configs {
+			clusterID = id
+		}
+	}
+	cl, ok := s.clusters[clusterID]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown or disabled kafka cluster %q", clusterID)
+	}
+	return cl.write(topic, messages)
+}
+
+type HandlerConfig struct {
+	// Cluster is the ID of the configured Kafka cluster.
+	Cluster string `mapstructure:"cluster"`
+	// Topic the alerts are written to.
+	Topic string `mapstructure:"topic"`
+}
+
+// Handler returns a handler writing the JSON encoded alert data to the topic,
+// keyed by the ID of the alert, so that the events of an alert are in order.
+func (s *Service) Handler(c HandlerConfig, ctx ...keyvalue.T) alert.Handler {
+	d := s.diag.WithContext(ctx...)
+	d.CreatingAlertHandler(c)
+	return &handler{
+		s:    s,
+		c:    c,
+		diag: d,
+	}
+}
+
+type handler struct {
+	s    *Service
+	c    HandlerConfig
+	diag Diagnostic
+}
+
+func (h *handler) Handle(event alert.Event) {
+	h.diag.HandlingEvent()
+	value, err := json.Marshal(event.AlertData())
+	if err != nil {
+		h.diag.Error("failed to marshal alert data json", err)
+		return
+	}
+	m := Message{
+		Key:   []byte(event.State.ID),
+		Value: value,
+	}
+	if err := h.s.Write(h.c.Cluster, h.c.Topic, m); err != nil {
+		h.diag.Error("failed to write alert to kafka", err)
+	}
+}
+
+type testOptions struct {
+	Cluster string `json:"cluster"`
+	Topic   string `json:"topic"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (s *Service) TestOptions() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o := &testOptions{
+		Message: "test kafka message",
+	}
+	if len(s.configs) == 1 {
+		for id := range s.configs {
+			o.Cluster = id
+		}
+	}
+	return o
+}
+
+func (s *Service) Test(options interface{}) error {
+	o, ok := options.(*testOptions)
+	if !ok {
+		return fmt.Errorf("unexpected options type %T", options)
+	}
+	m := Message{
+		Value: []byte(o.Message),
+	}
+	if o.Key != "" {
+		m.Key = []byte(o.Key)
+	}
+	return s.Write(o.Cluster, o.Topic, m)
+}
@@ -0,0 +1,222 @@
+package kafka_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/services/diagnostic"
+	"github.com/influxdata/kapacitor/services/kafka"
+	"github.com/influxdata/kapacitor/services/kafka/kafkatest"
+)
+
+var diagService *diagnostic.Service
+
+func init() {
+	diagService = diagnostic.NewService(diagnostic.NewConfig(), ioutil.Discard, ioutil.Discard)
+	diagService.Open()
+}
+
+type pointsWriter struct {
+	mu              sync.Mutex
+	database        string
+	retentionPolicy string
+	points          []string
+}
+
+func (w *pointsWriter) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.database = database
+	w.retentionPolicy = retentionPolicy
+	for _, p := range points {
+		w.points = append(w.points, p.String())
+	}
+	sort.Strings(w.points)
+	return nil
+}
+
+func (w *pointsWriter) Points() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.points...)
+}
+
+// memberAssignment encodes the assignment of the partitions of the topic to a group member.
+func memberAssignment(topic string, partitions ...int32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, int16(0))
+	binary.Write(&buf, binary.BigEndian, int32(1))
+	binary.Write(&buf, binary.BigEndian, int16(len(topic)))
+	buf.WriteString(topic)
+	binary.Write(&buf, binary.BigEndian, int32(len(partitions)))
+	for _, p := range partitions {
+		binary.Write(&buf, binary.BigEndian, p)
+	}
+	// No user data
+	binary.Write(&buf, binary.BigEndian, int32(-1))
+	return buf.Bytes()
+}
+
+func TestService_Consumer(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("metrics", 0, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "sensors", broker),
+		"JoinGroupRequest": sarama.NewMockWrapper(&sarama.JoinGroupResponse{
+			GenerationId:  1,
+			GroupProtocol: "range",
+			LeaderId:      "leader",
+			MemberId:      "member",
+		}),
+		"SyncGroupRequest": sarama.NewMockWrapper(&sarama.SyncGroupResponse{
+			MemberAssignment: memberAssignment("metrics", 0),
+		}),
+		"HeartbeatRequest":  sarama.NewMockWrapper(&sarama.HeartbeatResponse{}),
+		"LeaveGroupRequest": sarama.NewMockWrapper(&sarama.LeaveGroupResponse{}),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("sensors", "metrics", 0, 0, "", sarama.ErrNoError),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("metrics", 0, sarama.OffsetOldest, 0).
+			SetOffset("metrics", 0, sarama.OffsetNewest, 2),
+		"FetchRequest": sarama.NewMockFetchResponse(t, 2).
+			SetVersion(3).
+			SetMessage("metrics", 0, 0, sarama.StringEncoder("cpu,host=a value=1 1")).
+			SetMessage("metrics", 0, 1, sarama.StringEncoder("cpu,host=b value=2 1")).
+			SetHighWaterMark("metrics", 0, 2),
+	})
+
+	c := kafka.NewConfig()
+	c.Enabled = true
+	c.Brokers = []string{broker.Addr()}
+	c.Version = "0.10.2.0"
+	c.Consumers = []kafka.ConsumerConfig{{
+		Topics:   []string{"metrics"},
+		Group:    "sensors",
+		Database: "mydb",
+		TopicTag: "topic",
+	}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := kafka.NewService(kafka.Configs{c}, diagService.NewKafkaHandler())
+	w := new(pointsWriter)
+	s.PointsWriter = w
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"cpu,host=a,topic=metrics value=1 1",
+		"cpu,host=b,topic=metrics value=2 1",
+	}
+	timeout := time.After(10 * time.Second)
+	for !reflect.DeepEqual(w.Points(), exp) {
+		select {
+		case <-timeout:
+			t.Fatalf("unexpected points\ngot\n%v\nexp\n%v", w.Points(), exp)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if w.database != "mydb" || w.retentionPolicy != "" {
+		t.Errorf("unexpected database and retention policy %s.%s", w.database, w.retentionPolicy)
+	}
+	// Closing the consumer commits the offsets of the consumed messages
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	committed := false
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.OffsetCommitRequest); ok {
+			committed = true
+		}
+	}
+	if !committed {
+		t.Error("expected offsets to be committed")
+	}
+}
+
+func TestService_Write(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("alerts", 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).
+			SetVersion(2),
+	})
+
+	c := kafka.NewConfig()
+	c.Enabled = true
+	c.Brokers = []string{broker.Addr()}
+	c.Version = "0.10.2.0"
+	s := kafka.NewService(kafka.Configs{c}, diagService.NewKafkaHandler())
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Write("", "alerts", kafka.Message{Value: []byte("a")}, kafka.Message{Value: []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+	produced := false
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+			produced = true
+		}
+	}
+	if !produced {
+		t.Error("expected messages to be produced")
+	}
+
+	if err := s.Write("missing", "alerts", kafka.Message{Value: []byte("a")}); err == nil {
+		t.Error("expected error writing to unknown cluster")
+	}
+}
+
+func TestService_Handler(t *testing.T) {
+	pc := new(kafkatest.ProducerCreator)
+	c := kafka.NewConfig()
+	c.Enabled = true
+	c.Brokers = []string{"localhost:9092"}
+	c.NewProducerF = pc.NewProducer
+	s := kafka.NewService(kafka.Configs{c}, diagService.NewKafkaHandler())
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	h := s.Handler(kafka.HandlerConfig{Topic: "alerts"})
+	h.Handle(alert.Event{
+		Topic: "main:task:alert2",
+		State: alert.EventState{
+			ID:      "id",
+			Message: "message",
+			Level:   alert.Critical,
+			Time:    time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	exp := []kafkatest.Message{{
+		Topic: "alerts",
+		Key:   "id",
+		Value: `{"id":"id","message":"message","details":"","time":"1970-01-01T00:00:00Z","duration":0,"level":"CRITICAL","data":{"series":null},"previousLevel":"OK"}`,
+	}}
+	if got := pc.Messages(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected messages\ngot\n%v\nexp\n%v", got, exp)
+	}
+}
@@ -1,6 +1,7 @@
 package mqtt
 
 import (
+	"sync"
 	"time"
 
 	pahomqtt "github.com/eclipse/paho.mqtt.golang"
@@ -13,8 +14,14 @@ type Client interface {
 	Connect() error
 	Disconnect()
 	Publish(topic string, qos QoSLevel, retained bool, message []byte) error
+	// Subscribe registers the callback for messages published to topics matching the filter.
+	// Subscriptions are restored when the client reconnects.
+	Subscribe(filter string, qos QoSLevel, callback MessageHandler) error
 }
 
+// MessageHandler is called for each message received on a subscription.
+type MessageHandler func(topic string, payload []byte)
+
 // newClient produces a disconnected MQTT client
 var newClient = func(c Config) (Client, error) {
 	opts := pahomqtt.NewClientOptions()
@@ -32,15 +39,25 @@ var newClient = func(c Config) (Client, error) {
 		return nil, err
 	}
 	opts.SetTLSConfig(tlsConfig)
+	opts.SetAutoReconnect(true)
 
 	return &PahoClient{
-		opts: opts,
+		opts:          opts,
+		subscriptions: make(map[string]subscription),
 	}, nil
 }
 
 type PahoClient struct {
 	opts   *pahomqtt.ClientOptions
 	client pahomqtt.Client
+
+	mu            sync.Mutex
+	subscriptions map[string]subscription
+}
+
+type subscription struct {
+	qos      QoSLevel
+	callback MessageHandler
 }
 
 // DefaultQuiesceTimeout is the duration the client will wait for outstanding
@@ -50,10 +67,11 @@ const DefaultQuiesceTimeout time.Duration = 250 * time.Millisecond
 func (p *PahoClient) Connect() error {
 	// Using a clean session forces the broker to dispose of client session
 	// information after disconnecting. Retention of this is useful for
-	// constrained clients.  Since Kapacitor is only publishing, it has no
-	// storage requirements and can reduce load on the broker by using a clean
-	// session.
+	// constrained clients.  Kapacitor has no storage requirements and can
+	// reduce load on the broker by using a clean session, subscriptions are
+	// restored by the client on every connect instead.
 	p.opts.SetCleanSession(true)
+	p.opts.SetOnConnectHandler(p.resubscribe)
 
 	p.client = pahomqtt.NewClient(p.opts)
 	token := p.client.Connect()
@@ -72,3 +90,34 @@ func (p *PahoClient) Publish(topic string, qos QoSLevel, retained bool, message
 	token.Wait()
 	return token.Error()
 }
+
+func (p *PahoClient) Subscribe(filter string, qos QoSLevel, callback MessageHandler) error {
+	p.mu.Lock()
+	p.subscriptions[filter] = subscription{qos: qos, callback: callback}
+	p.mu.Unlock()
+	if p.client == nil {
+		// Subscribed once connected
+		return nil
+	}
+	return p.subscribe(p.client, filter, qos, callback)
+}
+
+func (p *PahoClient) subscribe(c pahomqtt.Client, filter string, qos QoSLevel, callback MessageHandler) error {
+	token := c.Subscribe(filter, byte(qos), func(_ pahomqtt.Client, m pahomqtt.Message) {
+		callback(m.Topic(), m.Payload())
+	})
+	token.Wait()
+	return token.Error()
+}
+
+// resubscribe restores the subscriptions after the client (re)connected,
+// since the broker does not retain them for clean sessions.
+func (p *PahoClient) resubscribe(c pahomqtt.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for filter, sub := range p.subscriptions {
+		// Errors cannot be reported from the handler,
+		// a failed subscription is retried on the next reconnect.
+		p.subscribe(c, filter, sub.qos, sub.callback)
+	}
+}
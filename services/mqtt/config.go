@@ -1,7 +1,10 @@
 package mqtt
 
 import (
-	"errors"
+	"reflect"
+
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/pkg/errors"
 )
 
 type Config struct {
@@ -29,6 +32,10 @@ type Config struct {
 	Username string `toml:"username" override:"username"`
 	Password string `toml:"password" override:"password,redact"`
 
+	// Subscriptions to topics of the broker, whose messages are written as points.
+	// Subscriptions can only be configured in the configuration file.
+	Subscriptions []SubscriptionConfig `toml:"subscription" override:"-"`
+
 	// NewClientF is a function that returns a client for a given config.
 	NewClientF func(c Config) (Client, error) `toml:"-" override:"-"`
 }
@@ -46,6 +53,17 @@ func (c Config) Validate() error {
 			return errors.New("must specify a url for mqtt service")
 		}
 	}
+	// The client subscribes once per topic filter, so a topic can only be subscribed once.
+	topics := make(map[string]bool, len(c.Subscriptions))
+	for i, sc := range c.Subscriptions {
+		if err := sc.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid subscription %d of mqtt broker %q", i, c.Name)
+		}
+		if topics[sc.Topic] {
+			return errors.Errorf("duplicate subscription to topic %q of mqtt broker %q", sc.Topic, c.Name)
+		}
+		topics[sc.Topic] = true
+	}
 	return nil
 }
 
@@ -94,9 +112,40 @@ func (c Config) Equal(o Config) bool {
 	if c.Password != o.Password {
 		return false
 	}
+	if !reflect.DeepEqual(c.Subscriptions, o.Subscriptions) {
+		return false
+	}
 	return true
 }
 
+// SubscriptionConfig describes a subscription to a topic filter
+// and how the payloads of its messages are decoded into points.
+type SubscriptionConfig struct {
+	// Topic filter, may contain the + and # wildcards.
+	Topic string   `toml:"topic"`
+	QoS   QoSLevel `toml:"qos"`
+
+	// Database and retention policy the points are written to.
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+
+	// TopicTag is the name of a tag holding the topic of the message, the topic is not added if empty.
+	TopicTag string `toml:"topic-tag"`
+
+	// How the payloads of the messages are decoded.
+	decoder.Config
+}
+
+func (c SubscriptionConfig) Validate() error {
+	if c.Topic == "" {
+		return errors.New("must specify topic")
+	}
+	if c.Database == "" {
+		return errors.New("must specify database")
+	}
+	return c.Config.Validate()
+}
+
 type Configs []Config
 
 // Validate calls config.Validate for each element in Configs
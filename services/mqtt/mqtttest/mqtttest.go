@@ -2,6 +2,7 @@ package mqtttest
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/influxdata/kapacitor/services/mqtt"
 )
@@ -23,7 +24,8 @@ func (s *ClientCreator) NewClient(c mqtt.Config) (mqtt.Client, error) {
 type MockClient struct {
 	connected bool
 
-	PublishData []PublishData
+	PublishData   []PublishData
+	Subscriptions []Subscription
 }
 
 func NewClient(mqtt.Config) (mqtt.Client, error) {
@@ -58,3 +60,49 @@ type PublishData struct {
 	Retained bool
 	Message  []byte
 }
+
+func (m *MockClient) Subscribe(filter string, qos mqtt.QoSLevel, callback mqtt.MessageHandler) error {
+	if !m.connected {
+		return errors.New("Subscribe() called before Connect()")
+	}
+	m.Subscriptions = append(m.Subscriptions, Subscription{
+		Filter:   filter,
+		QoS:      qos,
+		Callback: callback,
+	})
+	return nil
+}
+
+// Receive delivers a message published to the topic to all matching subscriptions.
+func (m *MockClient) Receive(topic string, payload []byte) error {
+	if !m.connected {
+		return errors.New("Receive() called before Connect()")
+	}
+	for _, s := range m.Subscriptions {
+		if matchTopic(s.Filter, topic) {
+			s.Callback(topic, payload)
+		}
+	}
+	return nil
+}
+
+type Subscription struct {
+	Filter   string
+	QoS      mqtt.QoSLevel
+	Callback mqtt.MessageHandler
+}
+
+// matchTopic reports whether the topic matches the filter with its + and # wildcards.
+func matchTopic(filter, topic string) bool {
+	fs := strings.Split(filter, "/")
+	ts := strings.Split(topic, "/")
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) || (f != "+" && f != ts[i]) {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
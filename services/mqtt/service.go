@@ -4,12 +4,26 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/influxdata/kapacitor/server/vars"
 	"github.com/pkg/errors"
 )
 
+// statistics gathered for each subscription.
+const (
+	statMessagesReceived  = "messages_rx"
+	statPointsReceived    = "points_rx"
+	statPointsParseFail   = "points_parse_fail"
+	statPointsTransmitted = "points_tx"
+	statTransmitFail      = "tx_fail"
+)
+
 type Diagnostic interface {
 	WithContext(ctx ...keyvalue.T) Diagnostic
 	Error(msg string, err error)
@@ -64,11 +78,17 @@ const (
 type Service struct {
 	diag Diagnostic
 
-	mu      sync.RWMutex
-	clients map[string]Client
-	configs map[string]Config
+	mu          sync.RWMutex
+	clients     map[string]Client
+	configs     map[string]Config
+	subscribers map[string][]*subscriber
 
 	defaultBrokerName string
+
+	// PointsWriter receives the points of the messages of all subscriptions.
+	PointsWriter interface {
+		WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
 }
 
 func NewService(cs Configs, d Diagnostic) (*Service, error) {
@@ -96,6 +116,7 @@ func NewService(cs Configs, d Diagnostic) (*Service, error) {
 		diag:              d,
 		configs:           configs,
 		clients:           clients,
+		subscribers:       make(map[string][]*subscriber),
 		defaultBrokerName: defaultBrokerName,
 	}, nil
 }
@@ -110,6 +131,9 @@ func (s *Service) Open() error {
 		if err := client.Connect(); err != nil {
 			return errors.Wrapf(err, "failed to connect to MQTT broker %q", name)
 		}
+		if err := s.subscribe(name, client, s.configs[name]); err != nil {
+			return errors.Wrapf(err, "failed to subscribe to MQTT broker %q", name)
+		}
 	}
 	return nil
 }
@@ -117,16 +141,46 @@ func (s *Service) Open() error {
 func (s *Service) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	for _, client := range s.clients {
+	for name, client := range s.clients {
 		if client != nil {
 			client.Disconnect()
 		}
+		s.unsubscribe(name)
+	}
+	return nil
+}
+
+// subscribe subscribes the connected client to the topics of the broker's subscriptions.
+func (s *Service) subscribe(name string, client Client, bc Config) error {
+	for _, c := range bc.Subscriptions {
+		sub, err := newSubscriber(s, name, c)
+		if err != nil {
+			return errors.Wrapf(err, "topic %q", c.Topic)
+		}
+		s.subscribers[name] = append(s.subscribers[name], sub)
+		if err := client.Subscribe(c.Topic, c.QoS, sub.handle); err != nil {
+			return errors.Wrapf(err, "topic %q", c.Topic)
+		}
 	}
 	return nil
 }
 
+// unsubscribe removes the subscribers of the broker, the client must already be disconnected.
+func (s *Service) unsubscribe(name string) {
+	for _, sub := range s.subscribers[name] {
+		vars.DeleteStatistic(sub.statKey)
+	}
+	delete(s.subscribers, name)
+}
+
 func (s *Service) Alert(brokerName, topic string, qos QoSLevel, retained bool, message string) error {
 	log.Println("D! ALERT", topic, message)
+	return s.Publish(brokerName, topic, qos, retained, []byte(message))
+}
+
+// Publish publishes the message to the topic of the broker.
+// The broker may be empty to use the default broker.
+func (s *Service) Publish(brokerName, topic string, qos QoSLevel, retained bool, message []byte) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	if topic == "" {
@@ -139,7 +193,7 @@ func (s *Service) Alert(brokerName, topic string, qos QoSLevel, retained bool, m
 	if client == nil {
 		return fmt.Errorf("unknown MQTT broker %q", brokerName)
 	}
-	return client.Publish(topic, qos, retained, []byte(message))
+	return client.Publish(topic, qos, retained, message)
 }
 
 func (s *Service) Update(newConfigs []interface{}) error {
@@ -176,6 +230,7 @@ func (s *Service) update(cs Configs) error {
 		if client != nil {
 			client.Disconnect()
 		}
+		s.unsubscribe(name)
 		s.clients[name] = nil
 
 		if c.Enabled {
@@ -188,6 +243,9 @@ func (s *Service) update(cs Configs) error {
 				return err
 			}
 			s.clients[name] = client
+			if err := s.subscribe(name, client, c); err != nil {
+				return err
+			}
 		}
 	}
 	if len(cs) == 1 {
@@ -201,6 +259,7 @@ func (s *Service) update(cs Configs) error {
 			if client != nil {
 				client.Disconnect()
 			}
+			s.unsubscribe(name)
 			delete(s.clients, name)
 		}
 	}
@@ -262,3 +321,64 @@ func (s *Service) Test(o interface{}) error {
 	}
 	return s.Alert(options.BrokerName, options.Topic, options.QoS, options.Retained, options.Message)
 }
+
+// subscriber writes the points decoded from the messages of a subscription.
+type subscriber struct {
+	s       *Service
+	c       SubscriptionConfig
+	decoder *decoder.Decoder
+	diag    Diagnostic
+
+	statMap *expvar.Map
+	statKey string
+}
+
+func newSubscriber(s *Service, broker string, c SubscriptionConfig) (*subscriber, error) {
+	d, err := decoder.New(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]string{
+		"broker": broker,
+		"topic":  c.Topic,
+	}
+	statKey, statMap := vars.NewStatistic("mqtt", tags)
+	return &subscriber{
+		s:       s,
+		c:       c,
+		decoder: d,
+		diag:    s.diag.WithContext(keyvalue.KV("broker", broker), keyvalue.KV("topic", c.Topic)),
+		statMap: statMap,
+		statKey: statKey,
+	}, nil
+}
+
+func (sub *subscriber) handle(topic string, payload []byte) {
+	sub.statMap.Add(statMessagesReceived, 1)
+	points, err := sub.decoder.Decode(payload, time.Now().UTC())
+	if err != nil {
+		sub.statMap.Add(statPointsParseFail, 1)
+		sub.diag.Error("failed to decode points of message", err)
+		return
+	}
+	if sub.c.TopicTag != "" {
+		for _, p := range points {
+			p.AddTag(sub.c.TopicTag, topic)
+		}
+	}
+	sub.statMap.Add(statPointsReceived, int64(len(points)))
+	if len(points) == 0 {
+		return
+	}
+	if err := sub.s.PointsWriter.WritePoints(
+		sub.c.Database,
+		sub.c.RetentionPolicy,
+		models.ConsistencyLevelAll,
+		points,
+	); err != nil {
+		sub.diag.Error("failed to write points to database", err)
+		sub.statMap.Add(statTransmitFail, 1)
+		return
+	}
+	sub.statMap.Add(statPointsTransmitted, int64(len(points)))
+}
@@ -0,0 +1,174 @@
+package mqtt_test
+
+import (
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/influxdata/kapacitor/services/diagnostic"
+	"github.com/influxdata/kapacitor/services/mqtt"
+	"github.com/influxdata/kapacitor/services/mqtt/mqtttest"
+)
+
+var diagService *diagnostic.Service
+
+func init() {
+	diagService = diagnostic.NewService(diagnostic.NewConfig(), ioutil.Discard, ioutil.Discard)
+	diagService.Open()
+}
+
+type pointsWriter struct {
+	database        string
+	retentionPolicy string
+	points          []string
+}
+
+func (w *pointsWriter) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	w.database = database
+	w.retentionPolicy = retentionPolicy
+	for _, p := range points {
+		w.points = append(w.points, p.String())
+	}
+	sort.Strings(w.points)
+	return nil
+}
+
+func TestService_Subscription(t *testing.T) {
+	testCases := []struct {
+		name         string
+		subscription mqtt.SubscriptionConfig
+		topic        string
+		payload      string
+		points       []string
+	}{
+		{
+			name: "line protocol",
+			subscription: mqtt.SubscriptionConfig{
+				Topic:           "sensors/+/metrics",
+				Database:        "iot",
+				RetentionPolicy: "autogen",
+				TopicTag:        "topic",
+				Config:          decoder.Config{Precision: "s"},
+			},
+			topic:   "sensors/a/metrics",
+			payload: "temp,room=kitchen value=21.5 10\ntemp,room=hall value=19 10",
+			points: []string{
+				"temp,room=hall,topic=sensors/a/metrics value=19 10000000000",
+				"temp,room=kitchen,topic=sensors/a/metrics value=21.5 10000000000",
+			},
+		},
+		{
+			name: "json object",
+			subscription: mqtt.SubscriptionConfig{
+				Topic:    "sensors/#",
+				Database: "iot",
+				Config: decoder.Config{
+					Format:      decoder.FormatJSON,
+					Measurement: "sensor",
+					TimePath:    "ts",
+					TimeFormat:  "unix_ms",
+					TagPaths:    []string{"device.id"},
+					FieldPaths:  []string{"data.temperature", "data.ok"},
+				},
+			},
+			topic:   "sensors/a/json",
+			payload: `{"ts": 1500, "device": {"id": "a"}, "data": {"temperature": 20, "ok": true, "ignored": 1}}`,
+			points: []string{
+				"sensor,id=a ok=true,temperature=20 1500000000",
+			},
+		},
+		{
+			name: "json array",
+			subscription: mqtt.SubscriptionConfig{
+				Topic:    "sensors/json",
+				Database: "iot",
+				Config: decoder.Config{
+					Format:      decoder.FormatJSON,
+					Measurement: "sensor",
+					TimePath:    "time",
+					TagPaths:    []string{"id"},
+				},
+			},
+			topic:   "sensors/json",
+			payload: `[{"time": "1970-01-01T00:00:01Z", "id": "a", "temperature": 20, "name": "kitchen"}, {"time": "1970-01-01T00:00:02Z", "id": "b", "temperature": 21.5}]`,
+			points: []string{
+				"sensor,id=a temperature=20 1000000000",
+				"sensor,id=b temperature=21.5 2000000000",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cc := new(mqtttest.ClientCreator)
+			c := mqtt.NewConfig()
+			c.Enabled = true
+			c.Name = "default"
+			c.URL = "tcp://localhost:1883"
+			c.NewClientF = cc.NewClient
+			c.Subscriptions = []mqtt.SubscriptionConfig{tc.subscription}
+			if err := c.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			s, err := mqtt.NewService(mqtt.Configs{c}, diagService.NewMQTTHandler())
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := new(pointsWriter)
+			s.PointsWriter = w
+			if err := s.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := cc.Clients[0].Receive(tc.topic, []byte(tc.payload)); err != nil {
+				t.Fatal(err)
+			}
+			if w.database != tc.subscription.Database || w.retentionPolicy != tc.subscription.RetentionPolicy {
+				t.Errorf("unexpected database and retention policy %s.%s", w.database, w.retentionPolicy)
+			}
+			if !reflect.DeepEqual(w.points, tc.points) {
+				t.Errorf("unexpected points\ngot\n%v\nexp\n%v", w.points, tc.points)
+			}
+		})
+	}
+}
+
+func TestSubscriptionConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name string
+		c    mqtt.SubscriptionConfig
+	}{
+		{
+			name: "missing topic",
+			c:    mqtt.SubscriptionConfig{Database: "iot"},
+		},
+		{
+			name: "missing database",
+			c:    mqtt.SubscriptionConfig{Topic: "sensors"},
+		},
+		{
+			name: "invalid format",
+			c:    mqtt.SubscriptionConfig{Topic: "sensors", Database: "iot", Config: decoder.Config{Format: "xml"}},
+		},
+	}
+	for _, tc := range testCases {
+		if err := tc.c.Validate(); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestConfig_Validate_DuplicateTopics(t *testing.T) {
+	c := mqtt.NewConfig()
+	c.Name = "broker"
+	c.Subscriptions = []mqtt.SubscriptionConfig{
+		{Topic: "sensors/+", Database: "iot"},
+		{Topic: "sensors/+", Database: "other"},
+	}
+	if err := c.Validate(); err == nil {
+		t.Error("expected error for duplicate topics")
+	}
+}
@@ -7,6 +7,7 @@ import (
 type Diagnostic interface {
 	FakedUserAuthentication(username string)
 	FakedSubscriptionUserToken()
+	FakedTokenAuthentication()
 }
 
 // Provide an implentation of an Authentication service.
@@ -47,6 +48,12 @@ func (s *Service) SubscriptionUser(token string) (auth.User, error) {
 	return auth.NewUser("subscription-user", nil, true, nil), nil
 }
 
+// Return a user will all privileges.
+func (s *Service) TokenUser(token string) (auth.User, error) {
+	s.diag.FakedTokenAuthentication()
+	return auth.NewUser("token-user", nil, true, nil), nil
+}
+
 func (s *Service) GrantSubscriptionAccess(token, db, rp string) error {
 	return nil
 }
@@ -0,0 +1,54 @@
+package otlp
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default address of the OTLP/HTTP listener.
+	DefaultBindAddress = ":4318"
+	// Default database of the received metrics.
+	DefaultDatabase = "otlp"
+	// Default retention policy of the received metrics.
+	DefaultRetentionPolicy = "autogen"
+	// Default field holding the value of gauges and sums.
+	DefaultField = "value"
+)
+
+type Config struct {
+	Enabled     bool   `toml:"enabled"`
+	BindAddress string `toml:"bind-address"`
+
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+
+	// Field holding the value of gauges and sums.
+	Field string `toml:"field"`
+	// Resource attributes added as tags, all resource attributes are added if empty.
+	ResourceAttributes []string `toml:"resource-attributes"`
+}
+
+func NewConfig() Config {
+	return Config{
+		BindAddress:     DefaultBindAddress,
+		Database:        DefaultDatabase,
+		RetentionPolicy: DefaultRetentionPolicy,
+		Field:           DefaultField,
+	}
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.BindAddress == "" {
+		return errors.New("must specify bind-address")
+	}
+	if c.Database == "" {
+		return errors.New("must specify database")
+	}
+	if c.Field == "" {
+		return errors.New("must specify field")
+	}
+	return nil
+}
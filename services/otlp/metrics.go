@@ -0,0 +1,257 @@
+package otlp
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	proto "github.com/golang/protobuf/proto"
+)
+
+// The messages of the OTLP metrics export request, limited to the fields the receiver uses.
+// The fields of a oneof are pointers, so that a set zero value can be told apart from an unset field.
+//
+// The JSON encoding of OTLP uses lower camel case names and represents 64 bit integers as strings.
+
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []*ResourceMetrics `protobuf:"bytes,1,rep,name=resource_metrics" json:"resourceMetrics,omitempty"`
+}
+
+func (m *ExportMetricsServiceRequest) Reset()         { *m = ExportMetricsServiceRequest{} }
+func (m *ExportMetricsServiceRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportMetricsServiceRequest) ProtoMessage()    {}
+
+type ResourceMetrics struct {
+	Resource     *Resource       `protobuf:"bytes,1,opt,name=resource" json:"resource,omitempty"`
+	ScopeMetrics []*ScopeMetrics `protobuf:"bytes,2,rep,name=scope_metrics" json:"scopeMetrics,omitempty"`
+}
+
+func (m *ResourceMetrics) Reset()         { *m = ResourceMetrics{} }
+func (m *ResourceMetrics) String() string { return proto.CompactTextString(m) }
+func (*ResourceMetrics) ProtoMessage()    {}
+
+type Resource struct {
+	Attributes []*KeyValue `protobuf:"bytes,1,rep,name=attributes" json:"attributes,omitempty"`
+}
+
+func (m *Resource) Reset()         { *m = Resource{} }
+func (m *Resource) String() string { return proto.CompactTextString(m) }
+func (*Resource) ProtoMessage()    {}
+
+type ScopeMetrics struct {
+	Scope   *InstrumentationScope `protobuf:"bytes,1,opt,name=scope" json:"scope,omitempty"`
+	Metrics []*Metric             `protobuf:"bytes,2,rep,name=metrics" json:"metrics,omitempty"`
+}
+
+func (m *ScopeMetrics) Reset()         { *m = ScopeMetrics{} }
+func (m *ScopeMetrics) String() string { return proto.CompactTextString(m) }
+func (*ScopeMetrics) ProtoMessage()    {}
+
+type InstrumentationScope struct {
+	Name       string      `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Version    string      `protobuf:"bytes,2,opt,name=version" json:"version,omitempty"`
+	Attributes []*KeyValue `protobuf:"bytes,3,rep,name=attributes" json:"attributes,omitempty"`
+}
+
+func (m *InstrumentationScope) Reset()         { *m = InstrumentationScope{} }
+func (m *InstrumentationScope) String() string { return proto.CompactTextString(m) }
+func (*InstrumentationScope) ProtoMessage()    {}
+
+type Metric struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Unit string `protobuf:"bytes,3,opt,name=unit" json:"unit,omitempty"`
+	// Oneof data
+	Gauge     *Gauge     `protobuf:"bytes,5,opt,name=gauge" json:"gauge,omitempty"`
+	Sum       *Sum       `protobuf:"bytes,7,opt,name=sum" json:"sum,omitempty"`
+	Histogram *Histogram `protobuf:"bytes,9,opt,name=histogram" json:"histogram,omitempty"`
+}
+
+func (m *Metric) Reset()         { *m = Metric{} }
+func (m *Metric) String() string { return proto.CompactTextString(m) }
+func (*Metric) ProtoMessage()    {}
+
+type Gauge struct {
+	DataPoints []*NumberDataPoint `protobuf:"bytes,1,rep,name=data_points" json:"dataPoints,omitempty"`
+}
+
+func (m *Gauge) Reset()         { *m = Gauge{} }
+func (m *Gauge) String() string { return proto.CompactTextString(m) }
+func (*Gauge) ProtoMessage()    {}
+
+type Sum struct {
+	DataPoints []*NumberDataPoint `protobuf:"bytes,1,rep,name=data_points" json:"dataPoints,omitempty"`
+	// The temporality is an enum, which the JSON encoding may represent by name, it is not used.
+	AggregationTemporality int32 `protobuf:"varint,2,opt,name=aggregation_temporality" json:"-"`
+	IsMonotonic            bool  `protobuf:"varint,3,opt,name=is_monotonic" json:"isMonotonic,omitempty"`
+}
+
+func (m *Sum) Reset()         { *m = Sum{} }
+func (m *Sum) String() string { return proto.CompactTextString(m) }
+func (*Sum) ProtoMessage()    {}
+
+type Histogram struct {
+	DataPoints             []*HistogramDataPoint `protobuf:"bytes,1,rep,name=data_points" json:"dataPoints,omitempty"`
+	AggregationTemporality int32                 `protobuf:"varint,2,opt,name=aggregation_temporality" json:"-"`
+}
+
+func (m *Histogram) Reset()         { *m = Histogram{} }
+func (m *Histogram) String() string { return proto.CompactTextString(m) }
+func (*Histogram) ProtoMessage()    {}
+
+type NumberDataPoint struct {
+	Attributes        []*KeyValue `protobuf:"bytes,7,rep,name=attributes" json:"attributes,omitempty"`
+	StartTimeUnixNano uint64      `protobuf:"fixed64,2,opt,name=start_time_unix_nano" json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      uint64      `protobuf:"fixed64,3,opt,name=time_unix_nano" json:"timeUnixNano,omitempty"`
+	// Oneof value
+	AsDouble *float64 `protobuf:"fixed64,4,opt,name=as_double" json:"asDouble,omitempty"`
+	AsInt    *int64   `protobuf:"fixed64,6,opt,name=as_int" json:"asInt,omitempty"`
+}
+
+func (m *NumberDataPoint) Reset()         { *m = NumberDataPoint{} }
+func (m *NumberDataPoint) String() string { return proto.CompactTextString(m) }
+func (*NumberDataPoint) ProtoMessage()    {}
+
+func (m *NumberDataPoint) UnmarshalJSON(b []byte) error {
+	type alias NumberDataPoint
+	aux := struct {
+		*alias
+		StartTimeUnixNano jsonUint64 `json:"startTimeUnixNano"`
+		TimeUnixNano      jsonUint64 `json:"timeUnixNano"`
+		AsInt             *jsonInt64 `json:"asInt"`
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	m.StartTimeUnixNano = uint64(aux.StartTimeUnixNano)
+	m.TimeUnixNano = uint64(aux.TimeUnixNano)
+	if aux.AsInt != nil {
+		i := int64(*aux.AsInt)
+		m.AsInt = &i
+	}
+	return nil
+}
+
+type HistogramDataPoint struct {
+	Attributes        []*KeyValue `protobuf:"bytes,9,rep,name=attributes" json:"attributes,omitempty"`
+	StartTimeUnixNano uint64      `protobuf:"fixed64,2,opt,name=start_time_unix_nano" json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      uint64      `protobuf:"fixed64,3,opt,name=time_unix_nano" json:"timeUnixNano,omitempty"`
+	Count             uint64      `protobuf:"fixed64,4,opt,name=count" json:"count,omitempty"`
+	Sum               *float64    `protobuf:"fixed64,5,opt,name=sum" json:"sum,omitempty"`
+	// BucketCounts has one more element than ExplicitBounds, the count of the values above the last bound.
+	BucketCounts   []uint64  `protobuf:"fixed64,6,rep,packed,name=bucket_counts" json:"bucketCounts,omitempty"`
+	ExplicitBounds []float64 `protobuf:"fixed64,7,rep,packed,name=explicit_bounds" json:"explicitBounds,omitempty"`
+	Min            *float64  `protobuf:"fixed64,11,opt,name=min" json:"min,omitempty"`
+	Max            *float64  `protobuf:"fixed64,12,opt,name=max" json:"max,omitempty"`
+}
+
+func (m *HistogramDataPoint) Reset()         { *m = HistogramDataPoint{} }
+func (m *HistogramDataPoint) String() string { return proto.CompactTextString(m) }
+func (*HistogramDataPoint) ProtoMessage()    {}
+
+func (m *HistogramDataPoint) UnmarshalJSON(b []byte) error {
+	type alias HistogramDataPoint
+	aux := struct {
+		*alias
+		StartTimeUnixNano jsonUint64   `json:"startTimeUnixNano"`
+		TimeUnixNano      jsonUint64   `json:"timeUnixNano"`
+		Count             jsonUint64   `json:"count"`
+		BucketCounts      []jsonUint64 `json:"bucketCounts"`
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	m.StartTimeUnixNano = uint64(aux.StartTimeUnixNano)
+	m.TimeUnixNano = uint64(aux.TimeUnixNano)
+	m.Count = uint64(aux.Count)
+	m.BucketCounts = make([]uint64, len(aux.BucketCounts))
+	for i, c := range aux.BucketCounts {
+		m.BucketCounts[i] = uint64(c)
+	}
+	return nil
+}
+
+type KeyValue struct {
+	Key   string    `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value *AnyValue `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *KeyValue) Reset()         { *m = KeyValue{} }
+func (m *KeyValue) String() string { return proto.CompactTextString(m) }
+func (*KeyValue) ProtoMessage()    {}
+
+// AnyValue is the value of an attribute, array, key-value list and bytes values are ignored.
+type AnyValue struct {
+	// Oneof value
+	StringValue *string  `protobuf:"bytes,1,opt,name=string_value" json:"stringValue,omitempty"`
+	BoolValue   *bool    `protobuf:"varint,2,opt,name=bool_value" json:"boolValue,omitempty"`
+	IntValue    *int64   `protobuf:"varint,3,opt,name=int_value" json:"intValue,omitempty"`
+	DoubleValue *float64 `protobuf:"fixed64,4,opt,name=double_value" json:"doubleValue,omitempty"`
+}
+
+func (m *AnyValue) Reset()         { *m = AnyValue{} }
+func (m *AnyValue) String() string { return proto.CompactTextString(m) }
+func (*AnyValue) ProtoMessage()    {}
+
+func (m *AnyValue) UnmarshalJSON(b []byte) error {
+	type alias AnyValue
+	aux := struct {
+		*alias
+		IntValue *jsonInt64 `json:"intValue"`
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	if aux.IntValue != nil {
+		i := int64(*aux.IntValue)
+		m.IntValue = &i
+	}
+	return nil
+}
+
+// Text returns the value as a tag value.
+func (m *AnyValue) Text() (string, bool) {
+	switch {
+	case m == nil:
+		return "", false
+	case m.StringValue != nil:
+		return *m.StringValue, true
+	case m.BoolValue != nil:
+		return strconv.FormatBool(*m.BoolValue), true
+	case m.IntValue != nil:
+		return strconv.FormatInt(*m.IntValue, 10), true
+	case m.DoubleValue != nil:
+		return strconv.FormatFloat(*m.DoubleValue, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// jsonUint64 decodes an unsigned 64 bit integer encoded as a string or a number.
+type jsonUint64 uint64
+
+func (u *jsonUint64) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := strconv.ParseUint(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*u = jsonUint64(v)
+	return nil
+}
+
+// jsonInt64 decodes a signed 64 bit integer encoded as a string or a number.
+type jsonInt64 int64
+
+func (i *jsonInt64) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := strconv.ParseInt(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = jsonInt64(v)
+	return nil
+}
@@ -0,0 +1,335 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/influxdata/kapacitor/server/vars"
+)
+
+const (
+	metricsPath = "/v1/metrics"
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+
+	// Tags of the instrumentation scope.
+	scopeNameTag    = "otel.scope.name"
+	scopeVersionTag = "otel.scope.version"
+)
+
+// statistics gathered by the OTLP package.
+const (
+	statRequests          = "req"
+	statBytesReceived     = "bytes_rx"
+	statRequestParseFail  = "req_parse_fail"
+	statPointsReceived    = "points_rx"
+	statPointsParseFail   = "points_parse_fail"
+	statPointsTransmitted = "points_tx"
+	statTransmitFail      = "tx_fail"
+)
+
+type Diagnostic interface {
+	Error(msg string, err error, ctx ...keyvalue.T)
+	StartedListening(addr string)
+	ClosedService()
+}
+
+// Service receives metrics via OTLP/HTTP, encoded as protobuf or JSON, and writes them as points.
+//
+// Each data point becomes a point of the measurement of the metric name,
+// tagged with the resource, scope and data point attributes.
+// Gauges and sums have a single field holding their value,
+// histograms have the fields count, sum, min, max and one field per bucket
+// named by its upper bound, holding the cumulative count of the bucket like a Prometheus histogram.
+type Service struct {
+	config Config
+	// Resource attributes added as tags, nil means all.
+	resourceAttributes map[string]bool
+
+	ln     net.Listener
+	server *http.Server
+	done   chan struct{}
+
+	PointsWriter interface {
+		WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	Diag    Diagnostic
+	statMap *expvar.Map
+	statKey string
+}
+
+func NewService(c Config, diag Diagnostic) *Service {
+	s := &Service{
+		config: c,
+		Diag:   diag,
+	}
+	if len(c.ResourceAttributes) > 0 {
+		s.resourceAttributes = make(map[string]bool, len(c.ResourceAttributes))
+		for _, a := range c.ResourceAttributes {
+			s.resourceAttributes[a] = true
+		}
+	}
+	return s
+}
+
+func (s *Service) Open() error {
+	ln, err := net.Listen("tcp", s.config.BindAddress)
+	if err != nil {
+		s.Diag.Error("failed to set up OTLP listener at address", err, keyvalue.KV("address", s.config.BindAddress))
+		return err
+	}
+	s.ln = ln
+
+	tags := map[string]string{"bind": s.ln.Addr().String()}
+	s.statKey, s.statMap = vars.NewStatistic("otlp", tags)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, s.handleMetrics)
+	s.server = &http.Server{Handler: mux}
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.server.Serve(ln)
+	}()
+
+	s.Diag.StartedListening(s.ln.Addr().String())
+	return nil
+}
+
+func (s *Service) Close() error {
+	if s.ln == nil {
+		return errors.New("Service already closed")
+	}
+	vars.DeleteStatistic(s.statKey)
+
+	s.server.Close()
+	<-s.done
+	s.ln = nil
+
+	s.Diag.ClosedService()
+	return nil
+}
+
+// Addr returns the address the service listens on.
+func (s *Service) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+func (s *Service) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.statMap.Add(statRequests, 1)
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		http.Error(w, "unsupported content type "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			s.statMap.Add(statRequestParseFail, 1)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		s.statMap.Add(statRequestParseFail, 1)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.statMap.Add(statBytesReceived, int64(len(b)))
+
+	req := new(ExportMetricsServiceRequest)
+	if contentType == contentTypeJSON {
+		err = json.Unmarshal(b, req)
+	} else {
+		err = proto.Unmarshal(b, req)
+	}
+	if err != nil {
+		s.statMap.Add(statRequestParseFail, 1)
+		s.Diag.Error("failed to decode metrics", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	points := s.points(req)
+	s.statMap.Add(statPointsReceived, int64(len(points)))
+	if len(points) > 0 {
+		if err := s.PointsWriter.WritePoints(
+			s.config.Database,
+			s.config.RetentionPolicy,
+			models.ConsistencyLevelAll,
+			points,
+		); err != nil {
+			s.Diag.Error("failed to write points to database", err, keyvalue.KV("database", s.config.Database))
+			s.statMap.Add(statTransmitFail, 1)
+			// Let the client retry
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		s.statMap.Add(statPointsTransmitted, int64(len(points)))
+	}
+
+	// Respond with an empty ExportMetricsServiceResponse.
+	w.Header().Set("Content-Type", contentType)
+	if contentType == contentTypeJSON {
+		w.Write([]byte("{}"))
+	}
+}
+
+// points converts all data points of the request into points.
+func (s *Service) points(req *ExportMetricsServiceRequest) []models.Point {
+	var points []models.Point
+	for _, rm := range req.ResourceMetrics {
+		resourceTags := make(map[string]string)
+		if rm.Resource != nil {
+			for _, kv := range rm.Resource.Attributes {
+				if s.resourceAttributes != nil && !s.resourceAttributes[kv.Key] {
+					continue
+				}
+				addTag(resourceTags, kv)
+			}
+		}
+		for _, sm := range rm.ScopeMetrics {
+			scopeTags := copyTags(resourceTags)
+			if sm.Scope != nil {
+				if sm.Scope.Name != "" {
+					scopeTags[scopeNameTag] = sm.Scope.Name
+				}
+				if sm.Scope.Version != "" {
+					scopeTags[scopeVersionTag] = sm.Scope.Version
+				}
+				for _, kv := range sm.Scope.Attributes {
+					addTag(scopeTags, kv)
+				}
+			}
+			for _, m := range sm.Metrics {
+				points = s.appendMetric(points, m, scopeTags)
+			}
+		}
+	}
+	return points
+}
+
+func (s *Service) appendMetric(points []models.Point, m *Metric, tags map[string]string) []models.Point {
+	var dataPoints []*NumberDataPoint
+	switch {
+	case m.Gauge != nil:
+		dataPoints = m.Gauge.DataPoints
+	case m.Sum != nil:
+		dataPoints = m.Sum.DataPoints
+	case m.Histogram != nil:
+		for _, dp := range m.Histogram.DataPoints {
+			points = s.appendPoint(points, m.Name, tags, dp.Attributes, histogramFields(dp), dp.TimeUnixNano)
+		}
+		return points
+	default:
+		// Other metric types are not supported.
+		return points
+	}
+	for _, dp := range dataPoints {
+		var fields models.Fields
+		switch {
+		case dp.AsDouble != nil:
+			fields = models.Fields{s.config.Field: *dp.AsDouble}
+		case dp.AsInt != nil:
+			fields = models.Fields{s.config.Field: *dp.AsInt}
+		default:
+			s.statMap.Add(statPointsParseFail, 1)
+			continue
+		}
+		points = s.appendPoint(points, m.Name, tags, dp.Attributes, fields, dp.TimeUnixNano)
+	}
+	return points
+}
+
+func (s *Service) appendPoint(points []models.Point, name string, tags map[string]string, attributes []*KeyValue, fields models.Fields, timeUnixNano uint64) []models.Point {
+	pointTags := copyTags(tags)
+	for _, kv := range attributes {
+		addTag(pointTags, kv)
+	}
+	// Drop values that cannot be written as fields, e.g. the NaN sum of an empty histogram.
+	for k, v := range fields {
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			delete(fields, k)
+		}
+	}
+	if len(fields) == 0 {
+		s.statMap.Add(statPointsParseFail, 1)
+		return points
+	}
+	t := time.Now().UTC()
+	if timeUnixNano != 0 {
+		t = time.Unix(0, int64(timeUnixNano)).UTC()
+	}
+	p, err := models.NewPoint(name, models.NewTags(pointTags), fields, t)
+	if err != nil {
+		s.statMap.Add(statPointsParseFail, 1)
+		s.Diag.Error("failed to create point", err, keyvalue.KV("metric", name))
+		return points
+	}
+	return append(points, p)
+}
+
+// histogramFields returns the fields of a histogram data point.
+func histogramFields(dp *HistogramDataPoint) models.Fields {
+	fields := models.Fields{
+		"count": int64(dp.Count),
+	}
+	if dp.Sum != nil {
+		fields["sum"] = *dp.Sum
+	}
+	if dp.Min != nil {
+		fields["min"] = *dp.Min
+	}
+	if dp.Max != nil {
+		fields["max"] = *dp.Max
+	}
+	var cumulative int64
+	for i, c := range dp.BucketCounts {
+		cumulative += int64(c)
+		bound := "+Inf"
+		if i < len(dp.ExplicitBounds) {
+			bound = strconv.FormatFloat(dp.ExplicitBounds[i], 'f', -1, 64)
+		}
+		fields[bound] = cumulative
+	}
+	return fields
+}
+
+func addTag(tags map[string]string, kv *KeyValue) {
+	if v, ok := kv.Value.Text(); ok && kv.Key != "" && v != "" {
+		tags[kv.Key] = v
+	}
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
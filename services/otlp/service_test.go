@@ -0,0 +1,228 @@
+package otlp_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/services/diagnostic"
+	"github.com/influxdata/kapacitor/services/otlp"
+)
+
+var diagService *diagnostic.Service
+
+func init() {
+	diagService = diagnostic.NewService(diagnostic.NewConfig(), ioutil.Discard, ioutil.Discard)
+	diagService.Open()
+}
+
+type pointsWriter struct {
+	mu              sync.Mutex
+	database        string
+	retentionPolicy string
+	points          []string
+}
+
+func (w *pointsWriter) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.database = database
+	w.retentionPolicy = retentionPolicy
+	for _, p := range points {
+		w.points = append(w.points, p.String())
+	}
+	sort.Strings(w.points)
+	return nil
+}
+
+func openService(t *testing.T) (*otlp.Service, *pointsWriter) {
+	c := otlp.NewConfig()
+	c.Enabled = true
+	c.BindAddress = "127.0.0.1:0"
+	c.ResourceAttributes = []string{"service.name"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := otlp.NewService(c, diagService.NewOTLPHandler())
+	w := new(pointsWriter)
+	s.PointsWriter = w
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	return s, w
+}
+
+func stringValue(s string) *otlp.AnyValue {
+	return &otlp.AnyValue{StringValue: &s}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func integer(i int64) *int64 {
+	return &i
+}
+
+var expPoints = []string{
+	"http.server.duration,otel.scope.name=http,service.name=api +Inf=4i,0.1=1i,0.5=3i,count=4i,sum=2.5 2000000000",
+	"process.runtime.go.goroutines,otel.scope.name=runtime,otel.scope.version=1.0,service.name=api value=12i 1000000000",
+	"queue.depth,otel.scope.name=runtime,otel.scope.version=1.0,queue=jobs,service.name=api value=0.5 1000000000",
+}
+
+func TestService_Protobuf(t *testing.T) {
+	s, w := openService(t)
+	defer s.Close()
+
+	req := &otlp.ExportMetricsServiceRequest{
+		ResourceMetrics: []*otlp.ResourceMetrics{{
+			Resource: &otlp.Resource{
+				Attributes: []*otlp.KeyValue{
+					{Key: "service.name", Value: stringValue("api")},
+					{Key: "host.name", Value: stringValue("a")},
+				},
+			},
+			ScopeMetrics: []*otlp.ScopeMetrics{
+				{
+					Scope: &otlp.InstrumentationScope{Name: "runtime", Version: "1.0"},
+					Metrics: []*otlp.Metric{
+						{
+							Name: "process.runtime.go.goroutines",
+							Sum: &otlp.Sum{
+								DataPoints: []*otlp.NumberDataPoint{{TimeUnixNano: 1e9, AsInt: integer(12)}},
+							},
+						},
+						{
+							Name: "queue.depth",
+							Gauge: &otlp.Gauge{
+								DataPoints: []*otlp.NumberDataPoint{{
+									Attributes:   []*otlp.KeyValue{{Key: "queue", Value: stringValue("jobs")}},
+									TimeUnixNano: 1e9,
+									AsDouble:     float(0.5),
+								}},
+							},
+						},
+					},
+				},
+				{
+					Scope: &otlp.InstrumentationScope{Name: "http"},
+					Metrics: []*otlp.Metric{{
+						Name: "http.server.duration",
+						Histogram: &otlp.Histogram{
+							DataPoints: []*otlp.HistogramDataPoint{{
+								TimeUnixNano:   2e9,
+								Count:          4,
+								Sum:            float(2.5),
+								BucketCounts:   []uint64{1, 2, 1},
+								ExplicitBounds: []float64{0.1, 0.5},
+							}},
+						},
+					}},
+				},
+			},
+		}},
+	}
+	b, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post("http://"+s.Addr().String()+"/v1/metrics", "application/x-protobuf", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, exp := resp.StatusCode, http.StatusOK; got != exp {
+		t.Fatalf("unexpected status code got %d exp %d", got, exp)
+	}
+	if w.database != "otlp" || w.retentionPolicy != "autogen" {
+		t.Errorf("unexpected database and retention policy %s.%s", w.database, w.retentionPolicy)
+	}
+	if !reflect.DeepEqual(w.points, expPoints) {
+		t.Errorf("unexpected points\ngot\n%v\nexp\n%v", w.points, expPoints)
+	}
+}
+
+func TestService_JSON(t *testing.T) {
+	s, w := openService(t)
+	defer s.Close()
+
+	body := `{
+  "resourceMetrics": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "api"}},
+      {"key": "host.name", "value": {"stringValue": "a"}}
+    ]},
+    "scopeMetrics": [
+      {
+        "scope": {"name": "runtime", "version": "1.0"},
+        "metrics": [
+          {
+            "name": "process.runtime.go.goroutines",
+            "sum": {
+              "aggregationTemporality": "AGGREGATION_TEMPORALITY_CUMULATIVE",
+              "isMonotonic": false,
+              "dataPoints": [{"timeUnixNano": "1000000000", "asInt": "12"}]
+            }
+          },
+          {
+            "name": "queue.depth",
+            "gauge": {"dataPoints": [{
+              "attributes": [{"key": "queue", "value": {"stringValue": "jobs"}}],
+              "timeUnixNano": "1000000000",
+              "asDouble": 0.5
+            }]}
+          }
+        ]
+      },
+      {
+        "scope": {"name": "http"},
+        "metrics": [{
+          "name": "http.server.duration",
+          "histogram": {
+            "aggregationTemporality": 2,
+            "dataPoints": [{
+              "timeUnixNano": "2000000000",
+              "count": "4",
+              "sum": 2.5,
+              "bucketCounts": ["1", "2", "1"],
+              "explicitBounds": [0.1, 0.5]
+            }]
+          }
+        }]
+      }
+    ]
+  }]
+}`
+	resp, err := http.Post("http://"+s.Addr().String()+"/v1/metrics", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, exp := resp.StatusCode, http.StatusOK; got != exp {
+		t.Fatalf("unexpected status code got %d exp %d", got, exp)
+	}
+	if !reflect.DeepEqual(w.points, expPoints) {
+		t.Errorf("unexpected points\ngot\n%v\nexp\n%v", w.points, expPoints)
+	}
+}
+
+func TestService_UnsupportedContentType(t *testing.T) {
+	s, _ := openService(t)
+	defer s.Close()
+
+	resp, err := http.Post("http://"+s.Addr().String()+"/v1/metrics", "text/plain", strings.NewReader("cpu value=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, exp := resp.StatusCode, http.StatusUnsupportedMediaType; got != exp {
+		t.Fatalf("unexpected status code got %d exp %d", got, exp)
+	}
+}
@@ -0,0 +1,84 @@
+package remote_write
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default database of the written points.
+	DefaultDatabase = "prometheus"
+	// Default retention policy of the written points.
+	DefaultRetentionPolicy = "autogen"
+	// Default name of the field holding the sample value.
+	DefaultField = "value"
+)
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// Database and retention policy of the points,
+	// unless the request sets them via the X-Kapacitor-Database and X-Kapacitor-Retention-Policy headers.
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+	// Field holding the sample value, unless a mapping sets it.
+	Field string `toml:"field"`
+	// Mappings of metrics to points, the first mapping matching the metric name applies.
+	// Metrics without a matching mapping become a measurement of the metric name,
+	// with all labels as tags.
+	Mappings []Mapping `toml:"mapping"`
+}
+
+// Mapping maps matching metrics to measurement, tags and fields.
+type Mapping struct {
+	// Regular expression the whole metric name must match.
+	Match string `toml:"match"`
+	// Measurement of the points, defaults to the metric name.
+	// It may refer to submatches of the metric name, e.g. "${1}".
+	Measurement string `toml:"measurement"`
+	// Field holding the sample value, defaults to the field of the config.
+	// It may refer to submatches of the metric name.
+	Field string `toml:"field"`
+	// Label whose value is the field holding the sample value instead, e.g. "mode" for node_cpu{mode="idle"}.
+	// The label is not added as a tag.
+	FieldLabel string `toml:"field-label"`
+	// Labels that are not added as tags.
+	DropLabels []string `toml:"drop-labels"`
+}
+
+func NewConfig() Config {
+	return Config{
+		Database:        DefaultDatabase,
+		RetentionPolicy: DefaultRetentionPolicy,
+		Field:           DefaultField,
+	}
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Database == "" {
+		return errors.New("must specify database")
+	}
+	if c.Field == "" {
+		return errors.New("must specify field")
+	}
+	for i, m := range c.Mappings {
+		if err := m.Validate(); err != nil {
+			return errors.Wrapf(err, "mapping %d", i)
+		}
+	}
+	return nil
+}
+
+func (m Mapping) Validate() error {
+	if m.Match == "" {
+		return errors.New("must specify match")
+	}
+	if _, err := regexp.Compile(m.Match); err != nil {
+		return fmt.Errorf("invalid match %q: %v", m.Match, err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package remote_write
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/auth"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/influxdata/kapacitor/server/vars"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/pkg/errors"
+)
+
+const (
+	writePath = "/prometheus/write"
+
+	// Headers selecting the database and retention policy of a request.
+	DatabaseHeader        = "X-Kapacitor-Database"
+	RetentionPolicyHeader = "X-Kapacitor-Retention-Policy"
+
+	// Label holding the metric name.
+	metricNameLabel = "__name__"
+)
+
+// statistics gathered by the remote write service.
+const (
+	statWriteRequest      = "write_req"           // Number of write requests served
+	statSamplesReceived   = "samples_rx"          // Number of samples received
+	statSamplesInvalid    = "samples_invalid"     // Number of samples that could not be converted to points
+	statPointsWrittenOK   = "points_written_ok"   // Number of points written OK
+	statPointsWrittenFail = "points_written_fail" // Number of points that failed to be written
+)
+
+type Diagnostic interface {
+	Error(msg string, err error, ctx ...keyvalue.T)
+}
+
+// Service receives samples via the Prometheus remote write protocol and writes them as points.
+type Service struct {
+	database        string
+	retentionPolicy string
+	field           string
+	mappings        []mapping
+
+	routes []httpd.Route
+
+	statsKey string
+	statMap  *expvar.Map
+
+	diag Diagnostic
+
+	PointsWriter interface {
+		WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
+}
+
+type mapping struct {
+	Mapping
+	match      *regexp.Regexp
+	dropLabels map[string]bool
+}
+
+func NewService(c Config, d Diagnostic) (*Service, error) {
+	s := &Service{
+		database:        c.Database,
+		retentionPolicy: c.RetentionPolicy,
+		field:           c.Field,
+		diag:            d,
+	}
+	for _, m := range c.Mappings {
+		// Match the whole metric name
+		match, err := regexp.Compile("^(?:" + m.Match + ")$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid match %q", m.Match)
+		}
+		dropLabels := make(map[string]bool, len(m.DropLabels))
+		for _, l := range m.DropLabels {
+			dropLabels[l] = true
+		}
+		s.mappings = append(s.mappings, mapping{
+			Mapping:    m,
+			match:      match,
+			dropLabels: dropLabels,
+		})
+	}
+	return s, nil
+}
+
+func (s *Service) Open() error {
+	s.statsKey, s.statMap = vars.NewStatistic("remote_write", nil)
+
+	s.routes = []httpd.Route{
+		{
+			Method:      "POST",
+			Pattern:     writePath,
+			HandlerFunc: s.handleWrite,
+			NoAudit:     true,
+		},
+	}
+	if err := s.HTTPDService.AddRoutes(s.routes); err != nil {
+		return errors.Wrap(err, "failed to add API routes")
+	}
+	return nil
+}
+
+func (s *Service) Close() error {
+	s.HTTPDService.DelRoutes(s.routes)
+	vars.DeleteStatistic(s.statsKey)
+	return nil
+}
+
+func (s *Service) handleWrite(w http.ResponseWriter, r *http.Request, user auth.User) {
+	s.statMap.Add(statWriteRequest, 1)
+
+	compressed, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	b, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		httpd.HttpError(w, "failed to decompress request: "+err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	req := new(WriteRequest)
+	if err := proto.Unmarshal(b, req); err != nil {
+		httpd.HttpError(w, "failed to decode request: "+err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	database := r.Header.Get(DatabaseHeader)
+	if database == "" {
+		database = s.database
+	}
+	retentionPolicy := r.Header.Get(RetentionPolicyHeader)
+	if retentionPolicy == "" {
+		retentionPolicy = s.retentionPolicy
+	}
+
+	action := auth.Action{
+		Resource:  auth.DatabaseResource(database),
+		Privilege: auth.WritePrivilege,
+	}
+	if err := user.AuthorizeAction(action); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("%q user is not authorized to write to database %q", user.Name(), database), true, http.StatusUnauthorized)
+		return
+	}
+
+	points := s.points(req)
+	if len(points) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := s.PointsWriter.WritePoints(
+		database,
+		retentionPolicy,
+		models.ConsistencyLevelAll,
+		points,
+	); influxdb.IsClientError(err) {
+		s.statMap.Add(statPointsWrittenFail, int64(len(points)))
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		s.statMap.Add(statPointsWrittenFail, int64(len(points)))
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	s.statMap.Add(statPointsWrittenOK, int64(len(points)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pendingPoint collects the fields of the samples of a series at the same time.
+type pendingPoint struct {
+	measurement string
+	tags        models.Tags
+	fields      models.Fields
+	time        time.Time
+}
+
+// points converts the samples of the request into points.
+// Samples that map to the same measurement, tags and time become fields of a single point.
+// Samples that cannot be converted, e.g. NaN staleness markers, are skipped.
+func (s *Service) points(req *WriteRequest) []models.Point {
+	var order []string
+	pending := make(map[string]*pendingPoint)
+	for _, ts := range req.Timeseries {
+		s.statMap.Add(statSamplesReceived, int64(len(ts.Samples)))
+
+		labels := make(map[string]string, len(ts.Labels))
+		for _, l := range ts.Labels {
+			labels[l.Name] = l.Value
+		}
+		name := labels[metricNameLabel]
+		if name == "" {
+			s.statMap.Add(statSamplesInvalid, int64(len(ts.Samples)))
+			continue
+		}
+		delete(labels, metricNameLabel)
+
+		measurement, field := s.mapMetric(name, labels)
+		if field == "" {
+			s.statMap.Add(statSamplesInvalid, int64(len(ts.Samples)))
+			continue
+		}
+		tags := models.NewTags(labels)
+		key := string(models.MakeKey([]byte(measurement), tags))
+
+		for _, sample := range ts.Samples {
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				s.statMap.Add(statSamplesInvalid, 1)
+				continue
+			}
+			t := time.Unix(0, sample.Timestamp*int64(time.Millisecond)).UTC()
+			k := fmt.Sprintf("%s %d", key, sample.Timestamp)
+			p, ok := pending[k]
+			if !ok {
+				p = &pendingPoint{
+					measurement: measurement,
+					tags:        tags,
+					fields:      make(models.Fields),
+					time:        t,
+				}
+				pending[k] = p
+				order = append(order, k)
+			}
+			p.fields[field] = sample.Value
+		}
+	}
+
+	points := make([]models.Point, 0, len(order))
+	for _, k := range order {
+		p := pending[k]
+		point, err := models.NewPoint(p.measurement, p.tags, p.fields, p.time)
+		if err != nil {
+			s.statMap.Add(statSamplesInvalid, int64(len(p.fields)))
+			s.diag.Error("failed to create point", err, keyvalue.KV("measurement", p.measurement))
+			continue
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// mapMetric returns the measurement and field of the samples of a metric
+// and removes the labels that are not tags.
+func (s *Service) mapMetric(name string, labels map[string]string) (measurement, field string) {
+	for _, m := range s.mappings {
+		submatches := m.match.FindStringSubmatchIndex(name)
+		if submatches == nil {
+			continue
+		}
+		measurement = name
+		if m.Measurement != "" {
+			measurement = string(m.match.ExpandString(nil, m.Measurement, name, submatches))
+		}
+		field = s.field
+		if m.Field != "" {
+			field = string(m.match.ExpandString(nil, m.Field, name, submatches))
+		}
+		if m.FieldLabel != "" {
+			field = labels[m.FieldLabel]
+			delete(labels, m.FieldLabel)
+		}
+		for l := range m.dropLabels {
+			delete(labels, l)
+		}
+		return measurement, field
+	}
+	return name, s.field
+}
@@ -0,0 +1,189 @@
+package remote_write_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/services/diagnostic"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/httpd/httpdtest"
+	"github.com/influxdata/kapacitor/services/remote_write"
+)
+
+var diagService *diagnostic.Service
+
+func init() {
+	diagService = diagnostic.NewService(diagnostic.NewConfig(), ioutil.Discard, ioutil.Discard)
+	diagService.Open()
+}
+
+type pointsWriter struct {
+	database        string
+	retentionPolicy string
+	points          []string
+}
+
+func (w *pointsWriter) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	w.database = database
+	w.retentionPolicy = retentionPolicy
+	for _, p := range points {
+		w.points = append(w.points, p.String())
+	}
+	sort.Strings(w.points)
+	return nil
+}
+
+func series(name string, labels map[string]string, value float64, ms int64) *remote_write.TimeSeries {
+	ts := &remote_write.TimeSeries{
+		Labels:  []*remote_write.Label{{Name: "__name__", Value: name}},
+		Samples: []*remote_write.Sample{{Value: value, Timestamp: ms}},
+	}
+	for k, v := range labels {
+		ts.Labels = append(ts.Labels, &remote_write.Label{Name: k, Value: v})
+	}
+	return ts
+}
+
+func TestService_Write(t *testing.T) {
+	req := &remote_write.WriteRequest{
+		Timeseries: []*remote_write.TimeSeries{
+			series("up", map[string]string{"job": "node", "instance": "a:9100"}, 1, 1000),
+			series("node_cpu", map[string]string{"mode": "idle", "instance": "a:9100"}, 10, 1000),
+			series("node_cpu", map[string]string{"mode": "user", "instance": "a:9100"}, 5, 1000),
+			series("node_memory_free_bytes", map[string]string{"instance": "a:9100"}, 100, 2000),
+			series("node_memory_total_bytes", map[string]string{"instance": "a:9100"}, 200, 2000),
+			// Staleness marker
+			series("up", map[string]string{"job": "node", "instance": "b:9100"}, math.NaN(), 1000),
+		},
+	}
+	testCases := []struct {
+		name     string
+		mappings []remote_write.Mapping
+		header   http.Header
+		db, rp   string
+		points   []string
+	}{
+		{
+			name: "default",
+			db:   "prometheus",
+			rp:   "autogen",
+			points: []string{
+				"node_cpu,instance=a:9100,mode=idle value=10 1000000000",
+				"node_cpu,instance=a:9100,mode=user value=5 1000000000",
+				"node_memory_free_bytes,instance=a:9100 value=100 2000000000",
+				"node_memory_total_bytes,instance=a:9100 value=200 2000000000",
+				"up,instance=a:9100,job=node value=1 1000000000",
+			},
+		},
+		{
+			name: "mappings",
+			mappings: []remote_write.Mapping{
+				{
+					Match:       "node_cpu",
+					Measurement: "cpu",
+					FieldLabel:  "mode",
+				},
+				{
+					Match:       "node_memory_(.*)_bytes",
+					Measurement: "mem",
+					Field:       "${1}",
+					DropLabels:  []string{"instance"},
+				},
+			},
+			header: http.Header{
+				remote_write.DatabaseHeader:        []string{"mydb"},
+				remote_write.RetentionPolicyHeader: []string{"myrp"},
+			},
+			db: "mydb",
+			rp: "myrp",
+			points: []string{
+				"cpu,instance=a:9100 idle=10,user=5 1000000000",
+				"mem free=100,total=200 2000000000",
+				"up,instance=a:9100,job=node value=1 1000000000",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := remote_write.NewConfig()
+			c.Enabled = true
+			c.Mappings = tc.mappings
+			if err := c.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			s, err := remote_write.NewService(c, diagService.NewRemoteWriteHandler())
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := new(pointsWriter)
+			s.PointsWriter = w
+			server := httpdtest.NewServer(testing.Verbose())
+			defer server.Close()
+			s.HTTPDService = server
+			if err := s.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			b, err := proto.Marshal(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := http.NewRequest("POST", server.Server.URL+httpd.BasePath+"/prometheus/write", bytes.NewReader(snappy.Encode(nil, b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tc.header {
+				r.Header[k] = v
+			}
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got, exp := resp.StatusCode, http.StatusNoContent; got != exp {
+				t.Fatalf("unexpected status code got %d exp %d", got, exp)
+			}
+			if w.database != tc.db || w.retentionPolicy != tc.rp {
+				t.Errorf("unexpected database and retention policy got %s.%s exp %s.%s", w.database, w.retentionPolicy, tc.db, tc.rp)
+			}
+			if !reflect.DeepEqual(w.points, tc.points) {
+				t.Errorf("unexpected points\ngot\n%v\nexp\n%v", w.points, tc.points)
+			}
+		})
+	}
+}
+
+func TestService_Write_Invalid(t *testing.T) {
+	c := remote_write.NewConfig()
+	c.Enabled = true
+	s, err := remote_write.NewService(c, diagService.NewRemoteWriteHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.PointsWriter = new(pointsWriter)
+	server := httpdtest.NewServer(testing.Verbose())
+	defer server.Close()
+	s.HTTPDService = server
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	resp, err := http.Post(server.Server.URL+httpd.BasePath+"/prometheus/write", "application/x-protobuf", bytes.NewReader([]byte("not snappy")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, exp := resp.StatusCode, http.StatusBadRequest; got != exp {
+		t.Fatalf("unexpected status code got %d exp %d", got, exp)
+	}
+}
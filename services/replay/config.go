@@ -2,21 +2,64 @@ package replay
 
 import (
 	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// Default interval between retention checks.
+	DefaultRetentionCheckInterval = 10 * time.Minute
 )
 
 type Config struct {
 	Dir string `toml:"dir"`
+
+	// Recordings older than MaxAge are deleted. Zero means no age limit.
+	MaxAge toml.Duration `toml:"max-age"`
+	// The oldest recordings are deleted once the total size of all recordings exceeds MaxTotalSize bytes.
+	// Zero means no total size limit.
+	MaxTotalSize int64 `toml:"max-total-size"`
+	// The oldest recordings are deleted once there are more than MaxCount recordings.
+	// Zero means no count limit.
+	MaxCount int `toml:"max-count"`
+	// Recordings are truncated once they have recorded MaxRecordingSize bytes of uncompressed data.
+	// Zero means no per recording limit.
+	MaxRecordingSize int64 `toml:"max-recording-size"`
+	// How often to check for recordings that exceed the retention policy.
+	RetentionCheckInterval toml.Duration `toml:"retention-check-interval"`
 }
 
 func (c Config) Validate() error {
 	if c.Dir == "" {
 		return fmt.Errorf("must specify dir")
 	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("max-age cannot be negative")
+	}
+	if c.MaxTotalSize < 0 {
+		return fmt.Errorf("max-total-size cannot be negative")
+	}
+	if c.MaxCount < 0 {
+		return fmt.Errorf("max-count cannot be negative")
+	}
+	if c.MaxRecordingSize < 0 {
+		return fmt.Errorf("max-recording-size cannot be negative")
+	}
+	if c.RetentionCheckInterval <= 0 {
+		return fmt.Errorf("retention-check-interval must be positive")
+	}
 	return nil
 }
 
+// RetentionEnabled reports whether any retention policy is configured.
+func (c Config) RetentionEnabled() bool {
+	return c.MaxAge > 0 || c.MaxTotalSize > 0 || c.MaxCount > 0
+}
+
 func NewConfig() Config {
 	return Config{
-		Dir: "./replay",
+		Dir:                    "./replay",
+		RetentionCheckInterval: toml.Duration(DefaultRetentionCheckInterval),
 	}
 }
@@ -59,6 +59,8 @@ const (
 	Failed Status = iota
 	Running
 	Finished
+	// Truncated indicates a recording stopped early because it reached its size limit.
+	Truncated
 )
 
 type RecordingType int
@@ -212,6 +214,7 @@ type Clock int
 const (
 	Fast Clock = iota
 	Real
+	Scaled
 )
 
 type Replay struct {
@@ -220,6 +223,7 @@ type Replay struct {
 	TaskID        string
 	RecordingTime bool
 	Clock         Clock
+	Scale         float64
 	Date          time.Time
 	Error         string
 	Status        Status
@@ -0,0 +1,129 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/pkg/errors"
+)
+
+// statistics gathered by the replay service.
+const (
+	statRecordingsDeleted   = "recordings_deleted"
+	statBytesDeleted        = "bytes_deleted"
+	statRecordingsTruncated = "recordings_truncated"
+)
+
+// errRecordingTruncated is returned while recording once the recording has reached its size limit.
+var errRecordingTruncated = errors.New("recording size limit reached")
+
+// recordingQuota tracks the number of bytes written to a single recording.
+// Records are written whole or not at all so that a truncated recording can still be replayed.
+type recordingQuota struct {
+	limit   int64
+	written int64
+}
+
+func (s *Service) newRecordingQuota() *recordingQuota {
+	return &recordingQuota{limit: s.maxRecordingSize}
+}
+
+// writeRecord writes the record produced by write to w,
+// unless doing so would exceed the quota in which case errRecordingTruncated is returned.
+func (q *recordingQuota) writeRecord(w io.Writer, write func(io.Writer) error) error {
+	if q.limit <= 0 {
+		return write(w)
+	}
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	if q.written+int64(buf.Len()) > q.limit {
+		return errRecordingTruncated
+	}
+	q.written += int64(buf.Len())
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// runRetention periodically deletes recordings that violate the retention policy.
+func (s *Service) runRetention() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.retentionCheckInterval)
+	defer ticker.Stop()
+	for {
+		s.enforceRetention(time.Now())
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enforceRetention deletes recordings that are older than the max age,
+// followed by the oldest recordings until both the count and total size limits are met.
+// Running recordings are never deleted, but do count towards the limits.
+func (s *Service) enforceRetention(now time.Time) {
+	limit := 100
+	offset := 0
+	var all []Recording
+	for {
+		recordings, err := s.recordings.List("", offset, limit)
+		if err != nil {
+			s.diag.Error("failed to retrieve recordings", err)
+			return
+		}
+		all = append(all, recordings...)
+		if len(recordings) != limit {
+			break
+		}
+		offset += limit
+	}
+
+	count := len(all)
+	var total int64
+	for _, recording := range all {
+		total += recording.Size
+	}
+
+	// Oldest first
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Date.Before(all[j].Date)
+	})
+	for _, recording := range all {
+		if recording.Status == Running {
+			continue
+		}
+		expired := s.maxAge > 0 && now.Sub(recording.Date) > s.maxAge
+		overCount := s.maxCount > 0 && count > s.maxCount
+		overSize := s.maxTotalSize > 0 && total > s.maxTotalSize
+		if !expired && !overCount && !overSize {
+			continue
+		}
+		if err := s.deleteRecording(recording); err != nil {
+			s.diag.Error("failed to delete recording", err, keyvalue.KV("recording_id", recording.ID))
+			continue
+		}
+		count--
+		total -= recording.Size
+		s.statMap.Add(statRecordingsDeleted, 1)
+		s.statMap.Add(statBytesDeleted, recording.Size)
+		s.diag.Debug("deleted recording per retention policy", keyvalue.KV("recording_id", recording.ID))
+	}
+}
+
+// deleteRecording removes both the metadata and the data of a recording.
+func (s *Service) deleteRecording(recording Recording) error {
+	if err := s.recordings.Delete(recording.ID); err != nil {
+		return err
+	}
+	ds, err := parseDataSourceURL(recording.DataURL)
+	if err != nil {
+		return err
+	}
+	return ds.Remove()
+}
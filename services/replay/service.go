@@ -15,6 +15,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/influxdata/influxdb/influxql"
@@ -22,9 +23,12 @@ import (
 	kclient "github.com/influxdata/kapacitor/client/v1"
 	"github.com/influxdata/kapacitor/clock"
 	"github.com/influxdata/kapacitor/edge"
+	kexpvar "github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/influxdb"
 	"github.com/influxdata/kapacitor/keyvalue"
 	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/namespace"
+	"github.com/influxdata/kapacitor/server/vars"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/storage"
 	"github.com/influxdata/kapacitor/uuid"
@@ -42,6 +46,7 @@ const (
 	recordStreamPath       = recordingsPath + "/stream"
 	recordBatchPath        = recordingsPath + "/batch"
 	recordQueryPath        = recordingsPath + "/query"
+	recordFlightPath       = recordingsPath + "/flight-recorder"
 
 	replaysPath         = "/replays"
 	replaysPathAnchored = "/replays/"
@@ -60,9 +65,27 @@ type Diagnostic interface {
 type Service struct {
 	saveDir string
 
+	// Retention policy and quotas for recordings
+	maxAge                 time.Duration
+	maxTotalSize           int64
+	maxCount               int
+	maxRecordingSize       int64
+	retentionEnabled       bool
+	retentionCheckInterval time.Duration
+
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	statsKey string
+	statMap  *kexpvar.Map
+
 	recordings RecordingDAO
 	replays    ReplayDAO
 
+	// Clocks of running replays that can be controlled
+	clocksMu sync.Mutex
+	clocks   map[string]clock.Controller
+
 	routes []httpd.Route
 
 	StorageService interface {
@@ -71,6 +94,7 @@ type Service struct {
 	}
 	TaskStore interface {
 		Load(id string) (*kapacitor.Task, error)
+		CheckNamespaceDBRPs(ns string, dbrps []kapacitor.DBRP) error
 	}
 	HTTPDService interface {
 		AddRoutes([]httpd.Route) error
@@ -89,6 +113,7 @@ type Service struct {
 		DelFork(name string)
 		New(name string) *kapacitor.TaskMaster
 		Stream(name string) (kapacitor.StreamCollector, error)
+		FlightRecording(id string) ([]edge.PointMessage, error)
 	}
 
 	diag Diagnostic
@@ -97,8 +122,15 @@ type Service struct {
 // Create a new replay master.
 func NewService(conf Config, d Diagnostic) *Service {
 	return &Service{
-		saveDir: conf.Dir,
-		diag:    d,
+		saveDir:                conf.Dir,
+		maxAge:                 time.Duration(conf.MaxAge),
+		maxTotalSize:           conf.MaxTotalSize,
+		maxCount:               conf.MaxCount,
+		maxRecordingSize:       conf.MaxRecordingSize,
+		retentionEnabled:       conf.RetentionEnabled(),
+		retentionCheckInterval: time.Duration(conf.RetentionCheckInterval),
+		clocks:                 make(map[string]clock.Controller),
+		diag:                   d,
 	}
 }
 
@@ -140,6 +172,18 @@ func (s *Service) Open() error {
 	s.markFailedRecordings()
 	s.markFailedReplays()
 
+	s.statsKey, s.statMap = vars.NewStatistic("replay", nil)
+	s.statMap.Add(statRecordingsDeleted, 0)
+	s.statMap.Add(statBytesDeleted, 0)
+	s.statMap.Add(statRecordingsTruncated, 0)
+
+	// Start deleting recordings that violate the retention policy
+	s.closing = make(chan struct{})
+	if s.retentionEnabled {
+		s.wg.Add(1)
+		go s.runRetention()
+	}
+
 	// Setup routes
 	s.routes = []httpd.Route{
 		{
@@ -158,24 +202,34 @@ func (s *Service) Open() error {
 			HandlerFunc: httpd.ServeOptions,
 		},
 		{
-			Method:      "GET",
-			Pattern:     recordingsPath,
-			HandlerFunc: s.handleListRecordings,
+			Method:              "GET",
+			Pattern:             recordingsPath,
+			HandlerFunc:         s.handleListRecordings,
+			NamespaceCollection: recordingsPath,
 		},
 		{
-			Method:      "POST",
-			Pattern:     recordStreamPath,
-			HandlerFunc: s.handleRecordStream,
+			Method:              "POST",
+			Pattern:             recordStreamPath,
+			HandlerFunc:         s.handleRecordStream,
+			NamespaceCollection: recordingsPath,
 		},
 		{
-			Method:      "POST",
-			Pattern:     recordBatchPath,
-			HandlerFunc: s.handleRecordBatch,
+			Method:              "POST",
+			Pattern:             recordBatchPath,
+			HandlerFunc:         s.handleRecordBatch,
+			NamespaceCollection: recordingsPath,
 		},
 		{
-			Method:      "POST",
-			Pattern:     recordQueryPath,
-			HandlerFunc: s.handleRecordQuery,
+			Method:              "POST",
+			Pattern:             recordQueryPath,
+			HandlerFunc:         s.handleRecordQuery,
+			NamespaceCollection: recordingsPath,
+		},
+		{
+			Method:              "POST",
+			Pattern:             recordFlightPath,
+			HandlerFunc:         s.handleRecordFlightRecorder,
+			NamespaceCollection: recordingsPath,
 		},
 		{
 			Method:      "GET",
@@ -187,6 +241,11 @@ func (s *Service) Open() error {
 			Pattern:     replaysPathAnchored,
 			HandlerFunc: s.handleDeleteReplay,
 		},
+		{
+			Method:      "PATCH",
+			Pattern:     replaysPathAnchored,
+			HandlerFunc: s.handleUpdateReplay,
+		},
 		{
 			Method:      "OPTIONS",
 			Pattern:     replaysPathAnchored,
@@ -340,6 +399,11 @@ func (s *Service) markFailedReplays() {
 
 func (s *Service) Close() error {
 	s.HTTPDService.DelRoutes(s.routes)
+	if s.closing != nil {
+		close(s.closing)
+		s.wg.Wait()
+	}
+	vars.DeleteStatistic(s.statsKey)
 	return nil
 }
 
@@ -372,6 +436,8 @@ func convertRecording(recording Recording) kclient.Recording {
 		status = kclient.Running
 	case Finished:
 		status = kclient.Finished
+	case Truncated:
+		status = kclient.Truncated
 	}
 	return kclient.Recording{
 		Link:     recordingLink(recording.ID),
@@ -405,6 +471,8 @@ func convertReplay(replay Replay) kclient.Replay {
 		clk = kclient.Real
 	case Fast:
 		clk = kclient.Fast
+	case Scaled:
+		clk = kclient.Scaled
 	}
 	var status kclient.Status
 	switch replay.Status {
@@ -422,6 +490,7 @@ func convertReplay(replay Replay) kclient.Replay {
 		Task:          replay.TaskID,
 		RecordingTime: replay.RecordingTime,
 		Clock:         clk,
+		Scale:         replay.Scale,
 		Date:          replay.Date,
 		Error:         replay.Error,
 		Status:        status,
@@ -441,7 +510,12 @@ var allRecordingFields = []string{
 }
 
 func (s *Service) handleListRecordings(w http.ResponseWriter, r *http.Request) {
-	pattern := r.URL.Query().Get("pattern")
+	ns := r.URL.Query().Get("namespace")
+	if err := namespace.Validate(ns); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	pattern := namespace.Pattern(ns, r.URL.Query().Get("pattern"))
 	fields := r.URL.Query()["fields"]
 	if len(fields) == 0 {
 		fields = allRecordingFields
@@ -508,6 +582,8 @@ func (s *Service) handleListRecordings(w http.ResponseWriter, r *http.Request) {
 					value = kclient.Running
 				case Finished:
 					value = kclient.Finished
+				case Truncated:
+					value = kclient.Truncated
 				}
 			case "progress":
 				value = recording.Progress
@@ -560,24 +636,44 @@ func (s *Service) handleDeleteRecording(w http.ResponseWriter, r *http.Request)
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
 		return
 	}
-	err = s.recordings.Delete(rid)
-	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
-		return
-	}
-	ds, err := parseDataSourceURL(recording.DataURL)
-	if err != nil {
+	if err := s.deleteRecording(recording); err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
 		return
 	}
 
-	err = ds.Remove()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newRecordingID returns the ID of a new recording and the ID of the recorded task, if any.
+// Requests with a namespace query parameter are restricted to the namespace,
+// and the recordings of a task are created within the namespace of the task.
+func newRecordingID(r *http.Request, id, task string) (string, string, error) {
+	ns := r.URL.Query().Get("namespace")
+	if err := namespace.Validate(ns); err != nil {
+		return "", "", err
+	}
+	if task != "" {
+		t, err := namespace.Qualify(ns, task)
+		if err != nil {
+			return "", "", err
+		}
+		task = t
+		ns = namespace.Of(task)
+	}
+	if id == "" {
+		id = uuid.New().String()
+	}
+	id, err := namespace.Qualify(ns, id)
 	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
-		return
+		return "", "", err
 	}
-
-	w.WriteHeader(http.StatusNoContent)
+	if !namespace.ValidID(id, validID) {
+		return "", "", fmt.Errorf("recording ID must contain only letters, numbers, '-', '.' and '_'. %q", id)
+	}
+	if task != "" && namespace.Of(id) != ns {
+		return "", "", fmt.Errorf("recording %q must be in the namespace of task %q", id, task)
+	}
+	return id, task, nil
 }
 
 func (s *Service) dataURLFromID(id, ext string) url.URL {
@@ -595,11 +691,9 @@ func (s *Service) handleRecordStream(w http.ResponseWriter, r *http.Request) {
 		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
-	if opt.ID == "" {
-		opt.ID = uuid.New().String()
-	}
-	if !validID.MatchString(opt.ID) {
-		httpd.HttpError(w, fmt.Sprintf("recording ID must contain only letters, numbers, '-', '.' and '_'. %q", opt.ID), true, http.StatusBadRequest)
+	opt.ID, opt.Task, err = newRecordingID(r, opt.ID, opt.Task)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
 	t, err := s.TaskStore.Load(opt.Task)
@@ -641,11 +735,9 @@ func (s *Service) handleRecordBatch(w http.ResponseWriter, req *http.Request) {
 		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
-	if opt.ID == "" {
-		opt.ID = uuid.New().String()
-	}
-	if !validID.MatchString(opt.ID) {
-		httpd.HttpError(w, fmt.Sprintf("recording ID must contain only letters, numbers, '-', '.' and '_'. %q", opt.ID), true, http.StatusBadRequest)
+	opt.ID, opt.Task, err = newRecordingID(req, opt.ID, opt.Task)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
 
@@ -695,17 +787,19 @@ func (s *Service) handleRecordQuery(w http.ResponseWriter, req *http.Request) {
 		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
-	if opt.ID == "" {
-		opt.ID = uuid.New().String()
-	}
-	if !validID.MatchString(opt.ID) {
-		httpd.HttpError(w, fmt.Sprintf("recording ID must contain only letters, numbers, '-', '.' and '_'. %q", opt.ID), true, http.StatusBadRequest)
+	opt.ID, _, err = newRecordingID(req, opt.ID, "")
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
 	if opt.Query == "" {
 		httpd.HttpError(w, "must provide query", true, http.StatusBadRequest)
 		return
 	}
+	if err := s.checkQueryNamespace(namespace.Of(opt.ID), opt.Query); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
 	var dataUrl url.URL
 	var typ RecordingType
 	switch opt.Type {
@@ -740,9 +834,72 @@ func (s *Service) handleRecordQuery(w http.ResponseWriter, req *http.Request) {
 	w.Write(httpd.MarshalJSON(convertRecording(recording), true))
 }
 
+func (s *Service) handleRecordFlightRecorder(w http.ResponseWriter, r *http.Request) {
+	var opt kclient.RecordFlightRecorderOptions
+	dec := json.NewDecoder(r.Body)
+	err := dec.Decode(&opt)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	opt.ID, opt.Task, err = newRecordingID(r, opt.ID, opt.Task)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	points, err := s.TaskMaster.FlightRecording(opt.Task)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	recording, err := s.saveFlightRecording(opt.ID, points)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(httpd.MarshalJSON(convertRecording(recording), true))
+}
+
+// FreezeFlightRecorder saves the points of a task's flight recorder as a new recording.
+// The recording is saved asynchronously with an ID derived from the task ID.
+func (s *Service) FreezeFlightRecorder(taskID string, points []edge.PointMessage) {
+	id := fmt.Sprintf("%s-critical-%d", taskID, time.Now().UnixNano())
+	go func() {
+		if _, err := s.saveFlightRecording(id, points); err != nil {
+			s.diag.Error("failed to freeze flight recorder", err, keyvalue.KV("task", taskID), keyvalue.KV("recording_id", id))
+			return
+		}
+		s.diag.Debug("froze flight recorder", keyvalue.KV("task", taskID), keyvalue.KV("recording_id", id))
+	}()
+}
+
+// saveFlightRecording saves the points as a finished stream recording.
+func (s *Service) saveFlightRecording(id string, points []edge.PointMessage) (Recording, error) {
+	dataUrl := s.dataURLFromID(id, streamEXT)
+	recording := Recording{
+		ID:      id,
+		DataURL: dataUrl.String(),
+		Type:    StreamRecording,
+		Date:    time.Now(),
+		Status:  Running,
+	}
+	if err := s.recordings.Create(recording); err != nil {
+		return Recording{}, err
+	}
+	ds, _ := parseDataSourceURL(dataUrl.String())
+	s.updateRecordingResult(recording, ds, s.writeStreamRecording(ds, points))
+	return s.recordings.Get(id)
+}
+
 func (s *Service) updateRecordingResult(recording Recording, ds DataSource, err error) {
 	recording.Status = Finished
-	if err != nil {
+	if err == errRecordingTruncated {
+		recording.Status = Truncated
+		recording.Error = fmt.Sprintf("recording truncated after reaching size limit of %d bytes", s.maxRecordingSize)
+		s.statMap.Add(statRecordingsTruncated, 1)
+	} else if err != nil {
 		recording.Status = Failed
 		recording.Error = err.Error()
 	}
@@ -788,7 +945,113 @@ func (s *Service) handleReplay(w http.ResponseWriter, req *http.Request) {
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
-	w.Write(httpd.MarshalJSON(convertReplay(replay), true))
+	w.Write(httpd.MarshalJSON(s.convertRunningReplay(replay), true))
+}
+
+func (s *Service) handleUpdateReplay(w http.ResponseWriter, req *http.Request) {
+	id, err := s.replayIDFromPath(req.URL.Path)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	var opt kclient.UpdateReplayOptions
+	dec := json.NewDecoder(req.Body)
+	if err := dec.Decode(&opt); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	replay, err := s.replays.Get(id)
+	if err != nil {
+		httpd.HttpError(w, "could not find replay: "+err.Error(), true, http.StatusNotFound)
+		return
+	}
+	if replay.Status != Running {
+		httpd.HttpError(w, fmt.Sprintf("replay %s is not running", id), true, http.StatusBadRequest)
+		return
+	}
+	clk, ok := s.replayClock(id)
+	if !ok {
+		httpd.HttpError(w, fmt.Sprintf("replay %s does not use the %v clock and cannot be controlled", id, kclient.Scaled), true, http.StatusBadRequest)
+		return
+	}
+
+	switch opt.Action {
+	case kclient.PauseReplay:
+		clk.Pause()
+	case kclient.ResumeReplay:
+		clk.Resume()
+	case kclient.StepReplay:
+		if opt.Step <= 0 {
+			httpd.HttpError(w, "step must be a positive duration", true, http.StatusBadRequest)
+			return
+		}
+		clk.Step(time.Duration(opt.Step))
+	case kclient.SeekReplay:
+		if err := clk.Seek(clk.Zero().Add(time.Duration(opt.Position))); err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+	default:
+		httpd.HttpError(w, fmt.Sprintf("invalid replay action %v", opt.Action), true, http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertRunningReplay(replay), true))
+}
+
+// convertRunningReplay converts the replay including the state of its clock if it can be controlled.
+func (s *Service) convertRunningReplay(replay Replay) kclient.Replay {
+	r := convertReplay(replay)
+	if clk, ok := s.replayClock(replay.ID); ok {
+		r.Paused = clk.Paused()
+		r.Position = kclient.Duration(clk.Now().Sub(clk.Zero()))
+	}
+	return r
+}
+
+func (s *Service) replayClock(id string) (clock.Controller, bool) {
+	s.clocksMu.Lock()
+	defer s.clocksMu.Unlock()
+	clk, ok := s.clocks[id]
+	return clk, ok
+}
+
+// startReplay runs the replay in the background and saves its result.
+// The clock of the replay can be controlled while it runs if it is a clock.Controller.
+func (s *Service) startReplay(replay Replay, clk clock.Clock, run func() error) {
+	if c, ok := clk.(clock.Controller); ok {
+		s.clocksMu.Lock()
+		s.clocks[replay.ID] = c
+		s.clocksMu.Unlock()
+	}
+	go func() {
+		err := run()
+		s.clocksMu.Lock()
+		delete(s.clocks, replay.ID)
+		s.clocksMu.Unlock()
+		s.updateReplayResult(replay, err)
+	}()
+}
+
+// newReplayClock returns the clock for a replay.
+// A zero scale defaults to realtime for the Scaled clock.
+func newReplayClock(c kclient.Clock, scale float64) (clock.Clock, Clock, float64, error) {
+	switch c {
+	case kclient.Real:
+		return clock.Wall(), Real, 0, nil
+	case kclient.Fast:
+		return clock.Fast(), Fast, 0, nil
+	case kclient.Scaled:
+		if scale == 0 {
+			scale = 1
+		}
+		if scale < 0 {
+			return nil, 0, 0, fmt.Errorf("invalid scale %v, must be greater than 0", scale)
+		}
+		return clock.Scaled(scale), Scaled, scale, nil
+	default:
+		return nil, 0, 0, fmt.Errorf("invalid clock type %v", c)
+	}
 }
 
 func (s *Service) handleDeleteReplay(w http.ResponseWriter, req *http.Request) {
@@ -798,6 +1061,10 @@ func (s *Service) handleDeleteReplay(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	//TODO: Cancel running replays
+	// Resume a paused replay so it is not left waiting forever.
+	if clk, ok := s.replayClock(id); ok {
+		clk.Resume()
+	}
 	s.replays.Delete(id)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -809,6 +1076,7 @@ var allReplayFields = []string{
 	"task",
 	"recording-time",
 	"clock",
+	"scale",
 	"date",
 	"error",
 	"status",
@@ -874,7 +1142,11 @@ func (s *Service) handleListReplays(w http.ResponseWriter, r *http.Request) {
 					value = kclient.Fast
 				case Real:
 					value = kclient.Real
+				case Scaled:
+					value = kclient.Scaled
 				}
+			case "scale":
+				value = replay.Scale
 			case "date":
 				value = replay.Date
 			case "error":
@@ -931,18 +1203,14 @@ func (s *Service) handleCreateReplay(w http.ResponseWriter, req *http.Request) {
 		httpd.HttpError(w, "recording not found: "+err.Error(), true, http.StatusNotFound)
 		return
 	}
+	if namespace.Of(opt.Recording) != namespace.Of(opt.Task) {
+		httpd.HttpError(w, fmt.Sprintf("recording %s and task %s must be in the same namespace", opt.Recording, opt.Task), true, http.StatusBadRequest)
+		return
+	}
 
-	var clk clock.Clock
-	var clockType Clock
-	switch opt.Clock {
-	case kclient.Real:
-		clk = clock.Wall()
-		clockType = Real
-	case kclient.Fast:
-		clk = clock.Fast()
-		clockType = Fast
-	default:
-		httpd.HttpError(w, fmt.Sprintf("invalid clock type %v", opt.Clock), true, http.StatusBadRequest)
+	clk, clockType, scale, err := newReplayClock(opt.Clock, opt.Scale)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
 
@@ -953,15 +1221,15 @@ func (s *Service) handleCreateReplay(w http.ResponseWriter, req *http.Request) {
 		TaskID:        opt.Task,
 		RecordingTime: opt.RecordingTime,
 		Clock:         clockType,
+		Scale:         scale,
 		Date:          time.Now(),
 		Status:        Running,
 	}
 	s.replays.Create(replay)
 
-	go func(replay Replay) {
-		err := s.doReplayFromRecording(opt.ID, t, recording, clk, opt.RecordingTime)
-		s.updateReplayResult(replay, err)
-	}(replay)
+	s.startReplay(replay, clk, func() error {
+		return s.doReplayFromRecording(opt.ID, t, recording, clk, opt.RecordingTime)
+	})
 
 	w.WriteHeader(http.StatusCreated)
 	w.Write(httpd.MarshalJSON(convertReplay(replay), true))
@@ -991,17 +1259,9 @@ func (s *Service) handleReplayBatch(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	var clk clock.Clock
-	var clockType Clock
-	switch opt.Clock {
-	case kclient.Real:
-		clk = clock.Wall()
-		clockType = Real
-	case kclient.Fast:
-		clk = clock.Fast()
-		clockType = Fast
-	default:
-		httpd.HttpError(w, fmt.Sprintf("invalid clock type %v", opt.Clock), true, http.StatusBadRequest)
+	clk, clockType, scale, err := newReplayClock(opt.Clock, opt.Scale)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
 	if t.Type == kapacitor.StreamTask {
@@ -1015,6 +1275,7 @@ func (s *Service) handleReplayBatch(w http.ResponseWriter, req *http.Request) {
 		TaskID:        opt.Task,
 		RecordingTime: opt.RecordingTime,
 		Clock:         clockType,
+		Scale:         scale,
 		Date:          time.Now(),
 		Status:        Running,
 	}
@@ -1024,10 +1285,9 @@ func (s *Service) handleReplayBatch(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	go func(replay Replay) {
-		err := s.doLiveBatchReplay(opt.ID, t, clk, opt.RecordingTime, opt.Start, opt.Stop)
-		s.updateReplayResult(replay, err)
-	}(replay)
+	s.startReplay(replay, clk, func() error {
+		return s.doLiveBatchReplay(opt.ID, t, clk, opt.RecordingTime, opt.Start, opt.Stop)
+	})
 
 	w.WriteHeader(http.StatusCreated)
 	w.Write(httpd.MarshalJSON(convertReplay(replay), true))
@@ -1058,18 +1318,14 @@ func (r *Service) handleReplayQuery(w http.ResponseWriter, req *http.Request) {
 		httpd.HttpError(w, "task load: "+err.Error(), true, http.StatusNotFound)
 		return
 	}
+	if err := r.checkQueryNamespace(namespace.Of(opt.Task), opt.Query); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
 
-	var clk clock.Clock
-	var clockType Clock
-	switch opt.Clock {
-	case kclient.Real:
-		clk = clock.Wall()
-		clockType = Real
-	case kclient.Fast:
-		clk = clock.Fast()
-		clockType = Fast
-	default:
-		httpd.HttpError(w, fmt.Sprintf("invalid clock type %v", opt.Clock), true, http.StatusBadRequest)
+	clk, clockType, scale, err := newReplayClock(opt.Clock, opt.Scale)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
 
@@ -1078,6 +1334,7 @@ func (r *Service) handleReplayQuery(w http.ResponseWriter, req *http.Request) {
 		TaskID:        opt.Task,
 		RecordingTime: opt.RecordingTime,
 		Clock:         clockType,
+		Scale:         scale,
 		Date:          time.Now(),
 		Status:        Running,
 	}
@@ -1087,15 +1344,30 @@ func (r *Service) handleReplayQuery(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	go func(replay Replay) {
-		err := r.doLiveQueryReplay(replay.ID, t, clk, opt.RecordingTime, opt.Query, opt.Cluster)
-		r.updateReplayResult(replay, err)
-	}(replay)
+	r.startReplay(replay, clk, func() error {
+		return r.doLiveQueryReplay(replay.ID, t, clk, opt.RecordingTime, opt.Query, opt.Cluster)
+	})
 
 	w.WriteHeader(http.StatusCreated)
 	w.Write(httpd.MarshalJSON(convertReplay(replay), true))
 }
 
+// checkQueryNamespace returns an error if the namespace does not allow the sources of the query.
+func (s *Service) checkQueryNamespace(ns, query string) error {
+	if ns == "" {
+		return nil
+	}
+	q, err := kapacitor.NewQuery(query)
+	if err != nil {
+		return err
+	}
+	dbrps, err := q.DBRPs()
+	if err != nil {
+		return err
+	}
+	return s.TaskStore.CheckNamespaceDBRPs(ns, dbrps)
+}
+
 func (r *Service) doReplayFromRecording(id string, task *kapacitor.Task, recording Recording, clk clock.Clock, recTime bool) error {
 	dataSource, err := parseDataSourceURL(recording.DataURL)
 	if err != nil {
@@ -1265,6 +1537,8 @@ func (s *Service) doRecordStream(id string, dataSource DataSource, stop time.Tim
 	}
 	defer sw.Close()
 
+	quota := s.newRecordingQuota()
+	var recordErr error
 	done := make(chan struct{})
 	go func() {
 		closed := false
@@ -1284,13 +1558,21 @@ func (s *Service) doRecordStream(id string, dataSource DataSource, stop time.Tim
 				//continue to read any data already on the edge, but just drop it.
 				continue
 			}
-			kapacitor.WritePointForRecording(sw, p, precision)
+			err := quota.writeRecord(sw, func(w io.Writer) error {
+				return kapacitor.WritePointForRecording(w, p, precision)
+			})
+			if err == errRecordingTruncated {
+				recordErr = err
+				closed = true
+				close(done)
+				continue
+			}
 		}
 	}()
 	<-done
 	e.Abort()
 	s.TaskMaster.DelFork(id)
-	return nil
+	return recordErr
 }
 
 // wrap the underlying file and archive
@@ -1418,16 +1700,38 @@ func (r *Service) saveBatchRecording(dataSource DataSource, sources []<-chan edg
 		return err
 	}
 
+	quota := r.newRecordingQuota()
+	truncated := false
 	for batchIdx, batches := range sources {
+		if truncated {
+			// Drain the remaining sources so the queries can complete.
+			for range batches {
+			}
+			continue
+		}
 		w, err := archiver.Archive(batchIdx)
 		if err != nil {
 			return err
 		}
 		for b := range batches {
-			kapacitor.WriteBatchForRecording(w, b)
+			if truncated {
+				continue
+			}
+			err := quota.writeRecord(w, func(w io.Writer) error {
+				return kapacitor.WriteBatchForRecording(w, b)
+			})
+			if err == errRecordingTruncated {
+				truncated = true
+			}
 		}
 	}
-	return archiver.Close()
+	if err := archiver.Close(); err != nil {
+		return err
+	}
+	if truncated {
+		return errRecordingTruncated
+	}
+	return nil
 }
 
 func (r *Service) doRecordQuery(dataSource DataSource, q string, typ RecordingType, cluster string) error {
@@ -1560,8 +1864,20 @@ func (r *Service) saveBatchQuery(dataSource DataSource, batches <-chan edge.Buff
 		return err
 	}
 
+	quota := r.newRecordingQuota()
 	for batch := range batches {
-		err := kapacitor.WriteBatchForRecording(w, batch)
+		err := quota.writeRecord(w, func(w io.Writer) error {
+			return kapacitor.WriteBatchForRecording(w, batch)
+		})
+		if err == errRecordingTruncated {
+			// Drain the remaining batches so the query can complete.
+			for range batches {
+			}
+			if err := archiver.Close(); err != nil {
+				return err
+			}
+			return errRecordingTruncated
+		}
 		if err != nil {
 			return err
 		}
@@ -1575,8 +1891,20 @@ func (s *Service) saveStreamQuery(dataSource DataSource, points <-chan edge.Poin
 	if err != nil {
 		return err
 	}
+	quota := s.newRecordingQuota()
 	for point := range points {
-		err := kapacitor.WritePointForRecording(sw, point, precision)
+		err := quota.writeRecord(sw, func(w io.Writer) error {
+			return kapacitor.WritePointForRecording(w, point, precision)
+		})
+		if err == errRecordingTruncated {
+			// Drain the remaining points so the query can complete.
+			for range points {
+			}
+			if err := sw.Close(); err != nil {
+				return err
+			}
+			return errRecordingTruncated
+		}
 		if err != nil {
 			return err
 		}
@@ -1585,6 +1913,25 @@ func (s *Service) saveStreamQuery(dataSource DataSource, points <-chan edge.Poin
 	return sw.Close()
 }
 
+// writeStreamRecording writes the points to the data source as a stream recording.
+func (s *Service) writeStreamRecording(dataSource DataSource, points []edge.PointMessage) error {
+	sw, err := dataSource.StreamWriter()
+	if err != nil {
+		return err
+	}
+	quota := s.newRecordingQuota()
+	for _, point := range points {
+		err := quota.writeRecord(sw, func(w io.Writer) error {
+			return kapacitor.WritePointForRecording(w, point, precision)
+		})
+		if err != nil {
+			sw.Close()
+			return err
+		}
+	}
+	return sw.Close()
+}
+
 func (s *Service) execQuery(q, cluster string) (kapacitor.DBRP, *influxdb.Response, error) {
 	// Parse query to determine dbrp
 	dbrp := kapacitor.DBRP{}
@@ -1654,6 +2001,14 @@ func getFilePathFromUrl(url *url.URL) string {
 	return url.Host + filepath.FromSlash(url.Path)
 }
 
+// create creates the file, and the directory of the namespace of a namespaced recording.
+func (s fileSource) create() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(string(s)), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(string(s))
+}
+
 func (s fileSource) Size() (int64, error) {
 	info, err := os.Stat(string(s))
 	if err != nil {
@@ -1672,7 +2027,7 @@ func (s fileSource) Remove() error {
 }
 
 func (s fileSource) StreamWriter() (io.WriteCloser, error) {
-	f, err := os.Create(string(s))
+	f, err := s.create()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create recording file: %s", err)
 	}
@@ -1694,7 +2049,7 @@ func (s fileSource) StreamReader() (io.ReadCloser, error) {
 }
 
 func (s fileSource) BatchArchiver() (BatchArchiver, error) {
-	f, err := os.Create(string(s))
+	f, err := s.create()
 	if err != nil {
 		return nil, err
 	}
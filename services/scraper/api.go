@@ -0,0 +1,47 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+)
+
+const (
+	scrapersPath             = "/scrapers"
+	scrapersPathAnchored     = "/scrapers/"
+	scrapersBasePath         = httpd.BasePath + scrapersPath
+	scrapersBasePathAnchored = httpd.BasePath + scrapersPathAnchored
+	targetsPath              = "targets"
+
+	// Names of the samples reported by Prometheus for each scrape of a target.
+	scrapeDurationMetricName = "scrape_duration_seconds"
+	scrapeSamplesMetricName  = "scrape_samples_scraped"
+)
+
+// handleListTargets responds with the targets of the scraper named in the path /scrapers/<name>/targets.
+func (s *Service) handleListTargets(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, scrapersBasePathAnchored)
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) != 2 || parts[1] != targetsPath {
+		httpd.HttpError(w, fmt.Sprintf("unknown path %q", r.URL.Path), true, http.StatusNotFound)
+		return
+	}
+	name := parts[0]
+	targets, ok := s.Targets(name)
+	if !ok {
+		httpd.HttpError(w, fmt.Sprintf("unknown scraper %q", name), true, http.StatusNotFound)
+		return
+	}
+	response := struct {
+		Link    client.Link            `json:"link"`
+		Targets []client.ScraperTarget `json:"targets"`
+	}{
+		Link:    client.Link{Relation: client.Self, Href: path.Join(scrapersBasePath, name, targetsPath)},
+		Targets: targets,
+	}
+	w.Write(httpd.MarshalJSON(response, true))
+}
@@ -58,6 +58,10 @@ type Config struct {
 
 	// Blacklist is a list of hosts to ignore and not scrape
 	Blacklist []string `toml:"blacklist" override:"blacklist"`
+
+	// Relabel rules applied in order to the discovered targets before they are scraped.
+	// Relabel rules can only be configured in the configuration file.
+	Relabel []RelabelConfig `toml:"relabel" override:"-"`
 }
 
 // Init adds default values to Config scraper
@@ -83,6 +87,11 @@ func (c *Config) Validate() error {
 	if c.Type != "prometheus" {
 		return fmt.Errorf("Unknown scraper type")
 	}
+	for i, r := range c.Relabel {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("invalid relabel rule %d of scraper %q: %v", i, c.Name, err)
+		}
+	}
 
 	return nil
 }
@@ -114,6 +123,12 @@ func (c *Config) Prom() *config.ScrapeConfig {
 			},
 		},
 	}
+	for _, r := range c.Relabel {
+		// Invalid rules are rejected by Validate
+		if rc, err := r.Prom(); err == nil {
+			sc.RelabelConfigs = append(sc.RelabelConfigs, rc)
+		}
+	}
 	return sc
 }
 
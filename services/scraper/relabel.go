@@ -0,0 +1,90 @@
+package scraper
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+)
+
+const (
+	// RelabelKeep drops the targets whose source labels do not match the regex.
+	RelabelKeep = "keep"
+	// RelabelDrop drops the targets whose source labels match the regex.
+	RelabelDrop = "drop"
+	// RelabelReplace writes the replacement into the target label if the source labels match the regex.
+	RelabelReplace = "replace"
+	// RelabelLabelMap copies the labels whose names match the regex to the labels named by the replacement.
+	RelabelLabelMap = "labelmap"
+)
+
+// RelabelConfig is a rule rewriting the labels of the discovered targets before they are scraped.
+// The rules follow the relabel_config conventions of Prometheus
+// and apply to the targets of any discoverer.
+type RelabelConfig struct {
+	// Action is one of keep, drop, replace or labelmap.
+	// Default: replace
+	Action string `toml:"action"`
+	// SourceLabels are the labels whose values are concatenated with the separator and matched against the regex.
+	SourceLabels []string `toml:"source-labels"`
+	// Separator of the values of the source labels.
+	// Default: ;
+	Separator string `toml:"separator"`
+	// Regex matched against the concatenated values, or the label names for the labelmap action.
+	// Default: (.*)
+	Regex string `toml:"regex"`
+	// TargetLabel is the label written by the replace action.
+	TargetLabel string `toml:"target-label"`
+	// Replacement written by the replace and labelmap actions, it may refer to the groups of the regex.
+	// Default: $1
+	Replacement string `toml:"replacement"`
+}
+
+// Validate validates the relabel rule
+func (c RelabelConfig) Validate() error {
+	_, err := c.Prom()
+	return err
+}
+
+// Prom generates the prometheus configuration for the relabel rule
+func (c RelabelConfig) Prom() (*config.RelabelConfig, error) {
+	rc := config.DefaultRelabelConfig
+	switch c.Action {
+	case "":
+	case RelabelKeep, RelabelDrop, RelabelReplace, RelabelLabelMap:
+		rc.Action = config.RelabelAction(c.Action)
+	default:
+		return nil, fmt.Errorf("unknown relabel action %q, must be one of %s, %s, %s or %s", c.Action, RelabelKeep, RelabelDrop, RelabelReplace, RelabelLabelMap)
+	}
+	if c.Separator != "" {
+		rc.Separator = c.Separator
+	}
+	if c.Regex != "" {
+		regex, err := config.NewRegexp(c.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid relabel regex %q: %v", c.Regex, err)
+		}
+		rc.Regex = regex
+	}
+	if c.Replacement != "" {
+		rc.Replacement = c.Replacement
+	}
+	for _, l := range c.SourceLabels {
+		if !model.LabelName(l).IsValid() {
+			return nil, fmt.Errorf("invalid relabel source label %q", l)
+		}
+		rc.SourceLabels = append(rc.SourceLabels, model.LabelName(l))
+	}
+	switch rc.Action {
+	case config.RelabelReplace:
+		if c.TargetLabel == "" {
+			return nil, fmt.Errorf("relabel action %s requires a target-label", rc.Action)
+		}
+		rc.TargetLabel = c.TargetLabel
+	case config.RelabelKeep, config.RelabelDrop:
+		if len(rc.SourceLabels) == 0 {
+			return nil, fmt.Errorf("relabel action %s requires source-labels", rc.Action)
+		}
+	}
+	return &rc, nil
+}
@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/relabel"
+)
+
+func TestRelabelConfig_Prom(t *testing.T) {
+	rules := []RelabelConfig{
+		{
+			Action:       RelabelDrop,
+			SourceLabels: []string{"env"},
+			Regex:        "dev",
+		},
+		{
+			SourceLabels: []string{"__address__"},
+			Regex:        "([^:]+):.*",
+			TargetLabel:  "host",
+		},
+		{
+			Action:      RelabelLabelMap,
+			Regex:       "__meta_(.+)",
+			Replacement: "meta_$1",
+		},
+		{
+			Action:       RelabelKeep,
+			SourceLabels: []string{"host", "env"},
+			Separator:    "/",
+			Regex:        "server.*/prod",
+		},
+	}
+	c := Config{Relabel: rules}
+	sc := c.Prom()
+	if got, exp := len(sc.RelabelConfigs), len(rules); got != exp {
+		t.Fatalf("unexpected number of relabel configs: got %d exp %d", got, exp)
+	}
+
+	testCases := []struct {
+		labels model.LabelSet
+		exp    model.LabelSet
+	}{
+		{
+			labels: model.LabelSet{"__address__": "server01:9100", "env": "prod", "__meta_zone": "a"},
+			exp:    model.LabelSet{"__address__": "server01:9100", "env": "prod", "__meta_zone": "a", "host": "server01", "meta_zone": "a"},
+		},
+		{
+			labels: model.LabelSet{"__address__": "server02:9100", "env": "dev"},
+			exp:    nil,
+		},
+		{
+			labels: model.LabelSet{"__address__": "db01:9100", "env": "prod"},
+			exp:    nil,
+		},
+	}
+	for _, tc := range testCases {
+		got := relabel.Process(tc.labels, sc.RelabelConfigs...)
+		if !reflect.DeepEqual(got, tc.exp) {
+			t.Errorf("unexpected labels for %v:\ngot\n%v\nexp\n%v", tc.labels, got, tc.exp)
+		}
+	}
+}
+
+func TestRelabelConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		c     RelabelConfig
+		valid bool
+	}{
+		{c: RelabelConfig{SourceLabels: []string{"a"}, TargetLabel: "b"}, valid: true},
+		{c: RelabelConfig{Action: RelabelLabelMap, Regex: "__meta_(.+)"}, valid: true},
+		{c: RelabelConfig{SourceLabels: []string{"a"}}, valid: false},
+		{c: RelabelConfig{Action: RelabelKeep}, valid: false},
+		{c: RelabelConfig{Action: "hashmod", SourceLabels: []string{"a"}, TargetLabel: "b"}, valid: false},
+		{c: RelabelConfig{SourceLabels: []string{"a"}, TargetLabel: "b", Regex: "("}, valid: false},
+		{c: RelabelConfig{SourceLabels: []string{"a-b"}, TargetLabel: "b"}, valid: false},
+	}
+	for _, tc := range testCases {
+		err := tc.c.Validate()
+		if tc.valid && err != nil {
+			t.Errorf("unexpected error for %+v: %v", tc.c, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("expected error for %+v", tc.c)
+		}
+	}
+}
@@ -3,11 +3,15 @@ package scraper
 import (
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	client "github.com/influxdata/kapacitor/client/v1"
 	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/services/httpd"
 	plog "github.com/prometheus/common/log"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
@@ -20,6 +24,9 @@ var (
 	_ storage.SampleAppender = &Service{}
 )
 
+// scrapesPruneInterval is how often the scrape results of targets that disappeared are removed.
+const scrapesPruneInterval = time.Minute
+
 // Prometheus logger
 type Diagnostic plog.Logger
 
@@ -28,6 +35,10 @@ type Service struct {
 	PointsWriter interface {
 		WriteKapacitorPoint(edge.PointMessage) error
 	}
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
 	mu sync.Mutex
 	wg sync.WaitGroup
 
@@ -42,19 +53,38 @@ type Service struct {
 
 	discoverers []Discoverer
 
+	routes []httpd.Route
+
+	// scrapes holds the duration and sample count of the last scrape of each target.
+	scrapesMu sync.RWMutex
+	scrapes   map[targetKey]scrapeResult
+
 	// TargetManager represents a scraping/discovery manager
 	mgr interface {
 		ApplyConfig(cfg *config.Config) error
 		Stop()
 		Start()
 		Wait()
+		Targets() []*retrieval.Target
 	}
 }
 
+// targetKey identifies a target by its job and instance labels.
+type targetKey struct {
+	job      string
+	instance string
+}
+
+type scrapeResult struct {
+	duration time.Duration
+	samples  int
+}
+
 // NewService creates a new scraper service
 func NewService(c []Config, d Diagnostic) *Service {
 	s := &Service{
-		diag: d,
+		diag:    d,
+		scrapes: make(map[targetKey]scrapeResult),
 	}
 	s.storeConfigs(c)
 	s.mgr = retrieval.NewTargetManager(s, d)
@@ -69,6 +99,17 @@ func (s *Service) Open() error {
 		return nil
 	}
 
+	s.routes = []httpd.Route{
+		{
+			Method:      "GET",
+			Pattern:     scrapersPathAnchored,
+			HandlerFunc: s.handleListTargets,
+		},
+	}
+	if err := s.HTTPDService.AddRoutes(s.routes); err != nil {
+		return fmt.Errorf("failed to add API routes: %v", err)
+	}
+
 	s.open = true
 	s.updating = make(chan *config.Config)
 	s.closing = make(chan struct{})
@@ -92,6 +133,7 @@ func (s *Service) Close() error {
 	close(s.closing)
 
 	s.wg.Wait()
+	s.HTTPDService.DelRoutes(s.routes)
 
 	return nil
 }
@@ -126,6 +168,8 @@ func (s *Service) scrape() {
 		s.mgr.Wait()
 	}()
 
+	prune := time.NewTicker(scrapesPruneInterval)
+	defer prune.Stop()
 	for {
 		select {
 		case <-s.closing:
@@ -139,6 +183,9 @@ func (s *Service) scrape() {
 			return
 		case conf := <-s.updating:
 			s.mgr.ApplyConfig(conf)
+			s.pruneScrapes()
+		case <-prune.C:
+			s.pruneScrapes()
 		}
 	}
 }
@@ -152,6 +199,8 @@ func (s *Service) Append(sample *model.Sample) error {
 		return nil
 	}
 
+	s.recordScrape(sample)
+
 	var err error
 	db := ""
 	rp := ""
@@ -197,6 +246,100 @@ func (s *Service) Append(sample *model.Sample) error {
 	))
 }
 
+// recordScrape keeps the duration and sample count reported by the scrapes of the targets.
+func (s *Service) recordScrape(sample *model.Sample) {
+	name := sample.Metric[model.MetricNameLabel]
+	if name != scrapeDurationMetricName && name != scrapeSamplesMetricName {
+		return
+	}
+	key := targetKey{
+		job:      string(sample.Metric[model.JobLabel]),
+		instance: string(sample.Metric[model.InstanceLabel]),
+	}
+	s.scrapesMu.Lock()
+	defer s.scrapesMu.Unlock()
+	r := s.scrapes[key]
+	switch name {
+	case scrapeDurationMetricName:
+		r.duration = time.Duration(float64(sample.Value) * float64(time.Second))
+	case scrapeSamplesMetricName:
+		r.samples = int(sample.Value)
+	}
+	s.scrapes[key] = r
+}
+
+// pruneScrapes removes the scrape results of the targets that are no longer scraped,
+// since targets come and go with their discovery.
+func (s *Service) pruneScrapes() {
+	targets := s.mgr.Targets()
+	keys := make(map[targetKey]bool, len(targets))
+	for _, t := range targets {
+		labels := t.Labels()
+		keys[targetKey{
+			job:      string(labels[model.JobLabel]),
+			instance: string(labels[model.InstanceLabel]),
+		}] = true
+	}
+	s.scrapesMu.Lock()
+	defer s.scrapesMu.Unlock()
+	for key := range s.scrapes {
+		if !keys[key] {
+			delete(s.scrapes, key)
+		}
+	}
+}
+
+// Targets returns the targets discovered for the named scraper, sorted by URL.
+// It returns false if the scraper does not exist.
+func (s *Service) Targets(name string) ([]client.ScraperTarget, bool) {
+	job := ""
+	for _, c := range s.loadConfigs() {
+		if c.Name == name {
+			job = encodeJobName(c.Database, c.RetentionPolicy, c.Name)
+			break
+		}
+	}
+	if job == "" {
+		return nil, false
+	}
+
+	targets := []client.ScraperTarget{}
+	s.scrapesMu.RLock()
+	defer s.scrapesMu.RUnlock()
+	for _, t := range s.mgr.Targets() {
+		labels := t.Labels()
+		if string(labels[model.JobLabel]) != job {
+			continue
+		}
+		// The job label encodes the database and retention policy, it is not part of the points.
+		delete(labels, model.JobLabel)
+		r := s.scrapes[targetKey{job: job, instance: string(labels[model.InstanceLabel])}]
+		target := client.ScraperTarget{
+			URL:              t.URL().String(),
+			Labels:           labelsToMap(labels),
+			DiscoveredLabels: labelsToMap(t.DiscoveredLabels()),
+			Health:           string(t.Health()),
+			LastScrape:       t.LastScrape(),
+			Duration:         client.Duration(r.duration),
+			Samples:          r.samples,
+		}
+		if err := t.LastError(); err != nil {
+			target.LastError = err.Error()
+		}
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].URL < targets[j].URL })
+	return targets, true
+}
+
+func labelsToMap(labels model.LabelSet) map[string]string {
+	m := make(map[string]string, len(labels))
+	for k, v := range labels {
+		m[string(k)] = string(v)
+	}
+	return m
+}
+
 // NeedsThrottling conforms to SampleAppender and never returns true currently.
 func (s *Service) NeedsThrottling() bool {
 	return false
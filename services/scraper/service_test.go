@@ -0,0 +1,51 @@
+package scraper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/retrieval"
+)
+
+type targetManager struct {
+	targets []*retrieval.Target
+}
+
+func (m *targetManager) ApplyConfig(cfg *config.Config) error { return nil }
+func (m *targetManager) Stop()                                {}
+func (m *targetManager) Start()                               {}
+func (m *targetManager) Wait()                                {}
+func (m *targetManager) Targets() []*retrieval.Target         { return m.targets }
+
+func TestService_PruneScrapes(t *testing.T) {
+	s := NewService(nil, nil)
+	s.mgr = &targetManager{
+		targets: []*retrieval.Target{
+			retrieval.NewTarget(model.LabelSet{
+				model.JobLabel:      "job",
+				model.InstanceLabel: "pod-a:9100",
+			}, nil, nil),
+		},
+	}
+	for _, instance := range []string{"pod-a:9100", "pod-b:9100"} {
+		s.recordScrape(&model.Sample{
+			Metric: model.Metric{
+				model.MetricNameLabel: scrapeSamplesMetricName,
+				model.JobLabel:        "job",
+				model.InstanceLabel:   model.LabelValue(instance),
+			},
+			Value: 10,
+		})
+	}
+
+	s.pruneScrapes()
+
+	exp := map[targetKey]scrapeResult{
+		{job: "job", instance: "pod-a:9100"}: {samples: 10},
+	}
+	if !reflect.DeepEqual(s.scrapes, exp) {
+		t.Errorf("unexpected scrapes got %v exp %v", s.scrapes, exp)
+	}
+}
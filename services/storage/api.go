@@ -25,9 +25,10 @@ const (
 
 type APIServer struct {
 	Registrar StoreActionerRegistrar
-	DB        *bolt.DB
-	routes    []httpd.Route
-	diag      Diagnostic
+	// DB is nil unless the bolt backend is used.
+	DB     *bolt.DB
+	routes []httpd.Route
+	diag   Diagnostic
 
 	HTTPDService interface {
 		AddRoutes([]httpd.Route) error
@@ -69,6 +70,10 @@ func (s *APIServer) Close() error {
 }
 
 func (s *APIServer) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if s.DB == nil {
+		httpd.HttpError(w, "backup is only supported by the bolt storage backend", true, http.StatusNotImplemented)
+		return
+	}
 	err := s.DB.View(func(tx *bolt.Tx) error {
 		w.Header().Set("Content-Type", "application/octet-stream")
 		w.Header().Set("Content-Disposition", `attachment; filename="kapacitor.db"`)
@@ -1,21 +1,65 @@
 package storage
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// BoltBackend stores all data in a single boltdb file.
+	BoltBackend = "bolt"
+	// LevelDBBackend stores all data in a leveldb directory and supports online compaction.
+	LevelDBBackend = "leveldb"
+)
 
 type Config struct {
+	// Storage backend to use, one of "bolt" or "leveldb".
+	Backend string `toml:"backend"`
 	// Path to a boltdb database file.
 	BoltDBPath string `toml:"boltdb"`
+	// Path to a leveldb database directory.
+	LevelDBPath string `toml:"leveldb"`
+	// How often to compact the leveldb database, zero disables online compaction.
+	CompactionInterval toml.Duration `toml:"compaction-interval"`
+	// Path to a file to which all committed mutations are appended, empty disables the export.
+	WALExportPath string `toml:"wal-export"`
+	// Path to a wal export file of another Kapacitor, whose mutations are applied as they are written.
+	WALFollowPath string `toml:"wal-follow"`
+	// How often to check the followed wal export file for new mutations.
+	WALFollowInterval toml.Duration `toml:"wal-follow-interval"`
 }
 
 func NewConfig() Config {
 	return Config{
-		BoltDBPath: "./kapacitor.db",
+		Backend:           BoltBackend,
+		BoltDBPath:        "./kapacitor.db",
+		LevelDBPath:       "./kapacitor.leveldb",
+		WALFollowInterval: toml.Duration(DefaultWALFollowInterval),
 	}
 }
 
 func (c Config) Validate() error {
-	if c.BoltDBPath == "" {
-		return fmt.Errorf("must specify storage 'boltdb' path")
+	switch c.Backend {
+	case BoltBackend, "":
+		if c.BoltDBPath == "" {
+			return fmt.Errorf("must specify storage 'boltdb' path")
+		}
+	case LevelDBBackend:
+		if c.LevelDBPath == "" {
+			return fmt.Errorf("must specify storage 'leveldb' path")
+		}
+	default:
+		return fmt.Errorf("unknown storage backend %q, must be one of %q or %q", c.Backend, BoltBackend, LevelDBBackend)
+	}
+	if c.CompactionInterval < 0 {
+		return fmt.Errorf("storage 'compaction-interval' cannot be negative")
+	}
+	if c.WALFollowPath != "" && c.WALFollowInterval <= 0 {
+		return fmt.Errorf("storage 'wal-follow-interval' must be positive")
+	}
+	if c.WALFollowPath != "" && c.WALFollowPath == c.WALExportPath {
+		return fmt.Errorf("storage 'wal-follow' and 'wal-export' cannot be the same file")
 	}
 	return nil
 }
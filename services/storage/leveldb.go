@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// levelDBSeparator separates the namespace from the key.
+// LevelDB has a single key space so each key is prefixed with its namespace.
+const levelDBSeparator = "\x00"
+
+// LevelDB implementation of Store
+type LevelDB struct {
+	db     *leveldb.DB
+	prefix string
+}
+
+func NewLevelDB(db *leveldb.DB, namespace string) *LevelDB {
+	return &LevelDB{
+		db:     db,
+		prefix: namespace + levelDBSeparator,
+	}
+}
+
+func (l *LevelDB) View(f func(tx ReadOnlyTx) error) error {
+	return DoView(l, f)
+}
+
+func (l *LevelDB) Update(f func(tx Tx) error) error {
+	return DoUpdate(l, f)
+}
+
+func (l *LevelDB) BeginTx() (Tx, error) {
+	tx, err := l.db.OpenTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBTx{
+		levelDBReader: levelDBReader{l: l, r: tx},
+		tx:            tx,
+	}, nil
+}
+
+func (l *LevelDB) BeginReadOnlyTx() (ReadOnlyTx, error) {
+	snap, err := l.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBReadOnlyTx{
+		levelDBReader: levelDBReader{l: l, r: snap},
+		snap:          snap,
+	}, nil
+}
+
+func (l *LevelDB) key(key string) []byte {
+	return []byte(l.prefix + key)
+}
+
+// levelDBSource is the common read interface of leveldb snapshots and transactions.
+type levelDBSource interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	Has(key []byte, ro *opt.ReadOptions) (bool, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+// levelDBReader implements ReadOperator for a levelDBSource.
+type levelDBReader struct {
+	l *LevelDB
+	r levelDBSource
+}
+
+func (t levelDBReader) Get(key string) (*KeyValue, error) {
+	value, err := t.r.Get(t.l.key(key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNoKeyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &KeyValue{
+		Key:   key,
+		Value: value,
+	}, nil
+}
+
+func (t levelDBReader) Exists(key string) (bool, error) {
+	return t.r.Has(t.l.key(key), nil)
+}
+
+func (t levelDBReader) List(prefix string) (kvs []*KeyValue, err error) {
+	iter := t.r.NewIterator(util.BytesPrefix(t.l.key(prefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		v := iter.Value()
+		value := make([]byte, len(v))
+		copy(value, v)
+		kvs = append(kvs, &KeyValue{
+			Key:   string(iter.Key()[len(t.l.prefix):]),
+			Value: value,
+		})
+	}
+	return kvs, iter.Error()
+}
+
+// levelDBReadOnlyTx reads from a consistent snapshot of the database.
+type levelDBReadOnlyTx struct {
+	levelDBReader
+	snap *leveldb.Snapshot
+}
+
+func (t *levelDBReadOnlyTx) Rollback() error {
+	t.snap.Release()
+	return nil
+}
+
+// levelDBTx wraps an underlying leveldb.Transaction to implement the Tx interface.
+type levelDBTx struct {
+	levelDBReader
+	tx *leveldb.Transaction
+}
+
+func (t *levelDBTx) Put(key string, value []byte) error {
+	return t.tx.Put(t.l.key(key), value, nil)
+}
+
+func (t *levelDBTx) Delete(key string) error {
+	return t.tx.Delete(t.l.key(key), nil)
+}
+
+func (t *levelDBTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *levelDBTx) Rollback() error {
+	// Discard is a no-op once the transaction has been committed.
+	t.tx.Discard()
+	return nil
+}
@@ -4,10 +4,18 @@ import (
 	"os"
 	"path"
 	"sync"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// Default interval between checks for new mutations in a followed wal export file.
+	DefaultWALFollowInterval = time.Second
 )
 
 type Diagnostic interface {
@@ -15,17 +23,28 @@ type Diagnostic interface {
 }
 
 type Service struct {
-	dbpath string
-
-	boltdb *bolt.DB
-	stores map[string]Interface
-	mu     sync.Mutex
+	backend            string
+	dbpath             string
+	leveldbPath        string
+	compactionInterval time.Duration
+	walExportPath      string
+	walFollowPath      string
+	walFollowInterval  time.Duration
+
+	boltdb  *bolt.DB
+	leveldb *leveldb.DB
+	wal     *WALWriter
+	stores  map[string]Interface
+	mu      sync.Mutex
 
 	registrar StoreActionerRegistrar
 	apiServer *APIServer
 
 	versions Versions
 
+	closing chan struct{}
+	wg      sync.WaitGroup
+
 	HTTPDService interface {
 		AddRoutes([]httpd.Route) error
 		DelRoutes([]httpd.Route)
@@ -35,29 +54,85 @@ type Service struct {
 }
 
 func NewService(conf Config, d Diagnostic) *Service {
+	backend := conf.Backend
+	if backend == "" {
+		backend = BoltBackend
+	}
 	return &Service{
-		dbpath: conf.BoltDBPath,
-		diag:   d,
-		stores: make(map[string]Interface),
+		backend:            backend,
+		dbpath:             conf.BoltDBPath,
+		leveldbPath:        conf.LevelDBPath,
+		compactionInterval: time.Duration(conf.CompactionInterval),
+		walExportPath:      conf.WALExportPath,
+		walFollowPath:      conf.WALFollowPath,
+		walFollowInterval:  time.Duration(conf.WALFollowInterval),
+		diag:               d,
+		stores:             make(map[string]Interface),
 	}
 }
 
 const (
 	versionsNamespace = "versions"
+	// The namespace of the position within a followed wal export file.
+	walFollowNamespace = "wal_follow"
 )
 
 func (s *Service) Open() error {
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	if s.walFollowPath != "" {
+		// Apply everything exported so far before any other service reads its stores.
+		follower := NewWALFollower(s.walFollowPath, s.Store, s.backendStore(walFollowNamespace))
+		if err := follower.Apply(); err != nil {
+			return errors.Wrapf(err, "apply wal @ %q", s.walFollowPath)
+		}
+		s.wg.Add(1)
+		go s.runWALFollower(follower)
+	}
+	return nil
+}
+
+func (s *Service) open() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	err := os.MkdirAll(path.Dir(s.dbpath), 0755)
-	if err != nil {
-		return errors.Wrapf(err, "mkdir dirs %q", s.dbpath)
+	s.closing = make(chan struct{})
+
+	switch s.backend {
+	case LevelDBBackend:
+		err := os.MkdirAll(s.leveldbPath, 0755)
+		if err != nil {
+			return errors.Wrapf(err, "mkdir dirs %q", s.leveldbPath)
+		}
+		db, err := leveldb.OpenFile(s.leveldbPath, nil)
+		if err != nil {
+			return errors.Wrapf(err, "open leveldb @ %q", s.leveldbPath)
+		}
+		s.leveldb = db
+		if s.compactionInterval > 0 {
+			s.wg.Add(1)
+			go s.runCompaction()
+		}
+	default:
+		err := os.MkdirAll(path.Dir(s.dbpath), 0755)
+		if err != nil {
+			return errors.Wrapf(err, "mkdir dirs %q", s.dbpath)
+		}
+		db, err := bolt.Open(s.dbpath, 0600, nil)
+		if err != nil {
+			return errors.Wrapf(err, "open boltdb @ %q", s.dbpath)
+		}
+		s.boltdb = db
 	}
-	db, err := bolt.Open(s.dbpath, 0600, nil)
-	if err != nil {
-		return errors.Wrapf(err, "open boltdb @ %q", s.dbpath)
+
+	if s.walExportPath != "" {
+		wal, err := OpenWALWriter(s.walExportPath)
+		if err != nil {
+			return err
+		}
+		s.wal = wal
 	}
-	s.boltdb = db
 
 	s.registrar = NewStorageResitrar()
 	s.apiServer = &APIServer{
@@ -77,6 +152,14 @@ func (s *Service) Open() error {
 }
 
 func (s *Service) Close() error {
+	s.mu.Lock()
+	if s.closing != nil {
+		close(s.closing)
+	}
+	s.mu.Unlock()
+	// Wait without the lock as the background routines use the stores.
+	s.wg.Wait()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.apiServer != nil {
@@ -84,12 +167,54 @@ func (s *Service) Close() error {
 			return err
 		}
 	}
+	if s.wal != nil {
+		if err := s.wal.Close(); err != nil {
+			return err
+		}
+	}
+	if s.leveldb != nil {
+		return s.leveldb.Close()
+	}
 	if s.boltdb != nil {
 		return s.boltdb.Close()
 	}
 	return nil
 }
 
+// runCompaction periodically compacts the entire leveldb database while it remains online.
+func (s *Service) runCompaction() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.compactionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			if err := s.leveldb.CompactRange(util.Range{}); err != nil {
+				s.diag.Error("failed to compact leveldb", err)
+			}
+		}
+	}
+}
+
+// runWALFollower periodically applies new mutations from the followed wal export file.
+func (s *Service) runWALFollower(follower *WALFollower) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.walFollowInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			if err := follower.Apply(); err != nil {
+				s.diag.Error("failed to apply wal", err)
+			}
+		}
+	}
+}
+
 // Return a namespaced store.
 // Calling Store with the same namespace returns the same Store.
 func (s *Service) Store(name string) Interface {
@@ -102,12 +227,23 @@ func (s *Service) store(name string) Interface {
 	if store, ok := s.stores[name]; ok {
 		return store
 	} else {
-		store = NewBolt(s.boltdb, name)
+		store = s.backendStore(name)
+		if s.wal != nil {
+			store = NewWALStore(store, name, s.wal, s.diag)
+		}
 		s.stores[name] = store
 		return store
 	}
 }
 
+// backendStore returns a namespaced store of the configured backend.
+func (s *Service) backendStore(name string) Interface {
+	if s.leveldb != nil {
+		return NewLevelDB(s.leveldb, name)
+	}
+	return NewBolt(s.boltdb, name)
+}
+
 func (s *Service) Versions() Versions {
 	return s.versions
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/influxdata/kapacitor/services/storage"
 	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 // Error used to specifically trigger a rollback for tests.
@@ -21,8 +22,9 @@ type createStoreCloser func() (storeCloser, error)
 // stores is a map of all storage implementations,
 // each test will be run against the stores found in this map.
 var stores = map[string]createStoreCloser{
-	"bolt": newBolt,
-	"mem":  newMemStore,
+	"bolt":    newBolt,
+	"leveldb": newLevelDB,
+	"mem":     newMemStore,
 }
 
 type storeCloser interface {
@@ -56,6 +58,35 @@ func (b boltDB) Store(bucket string) storage.Interface {
 	return storage.NewBolt(b.db, bucket)
 }
 
+type levelDB struct {
+	db  *leveldb.DB
+	dir string
+}
+
+func (l levelDB) Close() {
+	l.db.Close()
+	os.RemoveAll(l.dir)
+}
+
+func newLevelDB() (storeCloser, error) {
+	tmpDir, err := ioutil.TempDir("", "storage-leveldb")
+	if err != nil {
+		return levelDB{}, err
+	}
+	db, err := leveldb.OpenFile(tmpDir, nil)
+	if err != nil {
+		return levelDB{}, err
+	}
+	return levelDB{
+		db:  db,
+		dir: tmpDir,
+	}, nil
+}
+
+func (l levelDB) Store(namespace string) storage.Interface {
+	return storage.NewLevelDB(l.db, namespace)
+}
+
 type memStore struct {
 	stores map[string]storage.Interface
 }
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// walRecord is a single committed transaction of a namespaced store.
+// Records are written to the write-ahead export as newline delimited JSON.
+type walRecord struct {
+	Namespace string  `json:"namespace"`
+	Ops       []walOp `json:"ops"`
+}
+
+// walOp is a single mutation within a transaction.
+type walOp struct {
+	Delete bool   `json:"delete,omitempty"`
+	Key    string `json:"key"`
+	Value  []byte `json:"value"`
+}
+
+// WALWriter appends all committed mutations to a write-ahead export file,
+// so that a standby can tail the file and apply the mutations to its own storage.
+type WALWriter struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenWALWriter opens the file for appending, creating it if needed.
+func OpenWALWriter(path string) (*WALWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open wal export file %q", path)
+	}
+	return &WALWriter{f: f}, nil
+}
+
+func (w *WALWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// write appends and syncs the record, the caller must hold the lock.
+func (w *WALWriter) write(r walRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := w.f.Write(data); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// walStore exports all mutations committed to the underlying store.
+type walStore struct {
+	store     Interface
+	namespace string
+	w         *WALWriter
+	diag      Diagnostic
+}
+
+// NewWALStore returns a store that writes all mutations committed to store to the WAL.
+func NewWALStore(store Interface, namespace string, w *WALWriter, d Diagnostic) Interface {
+	return &walStore{
+		store:     store,
+		namespace: namespace,
+		w:         w,
+		diag:      d,
+	}
+}
+
+func (s *walStore) View(f func(tx ReadOnlyTx) error) error {
+	return s.store.View(f)
+}
+
+func (s *walStore) Update(f func(tx Tx) error) error {
+	// Hold the lock while committing so that records are written in commit order.
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	var ops []walOp
+	err := s.store.Update(func(tx Tx) error {
+		ops = ops[:0]
+		return f(&walTx{Tx: tx, ops: &ops})
+	})
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	// The transaction is already committed, so only report the failed export.
+	if err := s.w.write(walRecord{Namespace: s.namespace, Ops: ops}); err != nil {
+		s.diag.Error("failed to write mutations to wal export", err)
+	}
+	return nil
+}
+
+// walTx records the mutations made within a transaction.
+type walTx struct {
+	Tx
+	ops *[]walOp
+}
+
+func (t *walTx) Put(key string, value []byte) error {
+	if err := t.Tx.Put(key, value); err != nil {
+		return err
+	}
+	*t.ops = append(*t.ops, walOp{Key: key, Value: value})
+	return nil
+}
+
+func (t *walTx) Delete(key string) error {
+	if err := t.Tx.Delete(key); err != nil {
+		return err
+	}
+	*t.ops = append(*t.ops, walOp{Delete: true, Key: key})
+	return nil
+}
+
+const walFollowPositionKey = "position"
+
+// WALFollower applies the mutations of a write-ahead export file written by another Kapacitor.
+// The position within the file is persisted in the position store so following resumes after a restart.
+type WALFollower struct {
+	path     string
+	stores   func(namespace string) Interface
+	position Interface
+}
+
+func NewWALFollower(path string, stores func(namespace string) Interface, position Interface) *WALFollower {
+	return &WALFollower{
+		path:     path,
+		stores:   stores,
+		position: position,
+	}
+}
+
+// Apply applies all complete records written to the file since the last call.
+// Incomplete records at the end of the file are applied once they are complete.
+func (f *WALFollower) Apply() error {
+	offset, err := f.offset()
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		// Nothing has been exported yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < offset {
+		// The file was truncated or replaced, start from the beginning.
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// Wait for the rest of a partially written record
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var record walRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			return errors.Wrapf(err, "invalid wal record at offset %d", offset)
+		}
+		if err := f.apply(record); err != nil {
+			return err
+		}
+		offset += int64(len(line))
+		if err := f.setOffset(offset); err != nil {
+			return err
+		}
+	}
+}
+
+// apply applies the mutations of the record in a single transaction.
+// Applying a record more than once has no further effect,
+// so a record applied again after a crash is harmless.
+func (f *WALFollower) apply(record walRecord) error {
+	return f.stores(record.Namespace).Update(func(tx Tx) error {
+		for _, op := range record.Ops {
+			var err error
+			if op.Delete {
+				err = tx.Delete(op.Key)
+			} else {
+				err = tx.Put(op.Key, op.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (f *WALFollower) offset() (offset int64, err error) {
+	err = f.position.View(func(tx ReadOnlyTx) error {
+		kv, err := tx.Get(walFollowPositionKey)
+		if err == ErrNoKeyExists {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset, err = strconv.ParseInt(string(kv.Value), 10, 64)
+		return err
+	})
+	return
+}
+
+func (f *WALFollower) setOffset(offset int64) error {
+	return f.position.Update(func(tx Tx) error {
+		return tx.Put(walFollowPositionKey, []byte(strconv.FormatInt(offset, 10)))
+	})
+}
@@ -0,0 +1,83 @@
+package storage_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/kapacitor/services/storage"
+)
+
+type testDiag struct{}
+
+func (testDiag) Error(msg string, err error) {}
+
+func TestWAL_ExportFollow(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "storage-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "kapacitor.wal")
+
+	w, err := storage.OpenWALWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	primary := storage.NewWALStore(storage.NewMemStore("primary"), "ns", w, testDiag{})
+
+	standby := make(map[string]storage.Interface)
+	follower := storage.NewWALFollower(path, func(namespace string) storage.Interface {
+		s, ok := standby[namespace]
+		if !ok {
+			s = storage.NewMemStore(namespace)
+			standby[namespace] = s
+		}
+		return s
+	}, storage.NewMemStore("position"))
+
+	put := func(key, value string) {
+		if err := primary.Update(func(tx storage.Tx) error {
+			return tx.Put(key, []byte(value))
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("a", "1")
+	put("b", "2")
+	if err := primary.Update(func(tx storage.Tx) error {
+		return tx.Delete("a")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Rolled back transactions are not exported
+	primary.Update(func(tx storage.Tx) error {
+		tx.Put("c", []byte("3"))
+		return rollbackErr
+	})
+
+	if err := follower.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	// Applying again is a no-op
+	if err := follower.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	put("b", "4")
+	if err := follower.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	var kvs []*storage.KeyValue
+	if err := standby["ns"].View(func(tx storage.ReadOnlyTx) error {
+		kvs, err = tx.List("")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 1 || kvs[0].Key != "b" || string(kvs[0].Value) != "4" {
+		t.Errorf("unexpected standby data got %v", kvs)
+	}
+}
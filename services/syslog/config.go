@@ -0,0 +1,62 @@
+package syslog
+
+import (
+	"fmt"
+
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default measurement of the points of the messages.
+	DefaultMeasurement = "syslog"
+
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+)
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// Network of the listener, udp or tcp.
+	Network     string `toml:"network"`
+	BindAddress string `toml:"bind-address"`
+
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+
+	// How the MSG part of the messages is decoded.
+	// If no format is given each message is a point of the measurement
+	// with the fields message, facility_code, severity_code, procid and msgid.
+	// Otherwise the points are decoded from the MSG, points without a time have the time of the message.
+	// The points are tagged with the hostname, appname, facility and severity of the message.
+	decoder.Config
+}
+
+// withDefaults returns the config with the default measurement if none is given.
+func (c Config) withDefaults() Config {
+	if c.Measurement == "" {
+		c.Measurement = DefaultMeasurement
+	}
+	return c
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Network {
+	case NetworkUDP, NetworkTCP:
+	default:
+		return fmt.Errorf("invalid network %q, must be %s or %s", c.Network, NetworkUDP, NetworkTCP)
+	}
+	if c.BindAddress == "" {
+		return errors.New("must specify bind-address")
+	}
+	if c.Database == "" {
+		return errors.New("must specify database")
+	}
+	if c.Format == "" {
+		return nil
+	}
+	return c.withDefaults().Config.Validate()
+}
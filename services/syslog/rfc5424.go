@@ -0,0 +1,148 @@
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// nilValue is the value of absent header fields and structured data.
+const nilValue = "-"
+
+// Names of the facilities and severities, indexed by their code.
+var (
+	facilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+	severities = []string{
+		"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+	}
+)
+
+// utf8BOM may start the MSG part of a message.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// message is a syslog message as specified by RFC 5424.
+// Absent header fields are empty, an absent timestamp is zero.
+type message struct {
+	Facility  int
+	Severity  int
+	Version   int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	Message   []byte
+}
+
+// parseMessage parses a message of the form
+// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG].
+// The structured data is skipped.
+func parseMessage(b []byte) (message, error) {
+	var m message
+	if len(b) == 0 || b[0] != '<' {
+		return m, errors.New("missing priority")
+	}
+	end := bytes.IndexByte(b, '>')
+	if end < 2 || end > 4 {
+		return m, errors.New("invalid priority")
+	}
+	// The priority is 1 to 3 digits, Atoi would also accept a sign.
+	for _, c := range b[1:end] {
+		if c < '0' || c > '9' {
+			return m, fmt.Errorf("invalid priority %q", b[1:end])
+		}
+	}
+	pri, err := strconv.Atoi(string(b[1:end]))
+	if err != nil || pri > 191 {
+		return m, fmt.Errorf("invalid priority %q", b[1:end])
+	}
+	m.Facility = pri / 8
+	m.Severity = pri % 8
+	b = b[end+1:]
+
+	version, b := nextField(b)
+	if m.Version, err = strconv.Atoi(version); err != nil || m.Version < 1 {
+		return m, fmt.Errorf("invalid version %q", version)
+	}
+	timestamp, b := nextField(b)
+	if timestamp != nilValue {
+		if m.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+			return m, fmt.Errorf("invalid timestamp %q", timestamp)
+		}
+		m.Timestamp = m.Timestamp.UTC()
+	}
+	var fields [4]string
+	for i := range fields {
+		if len(b) == 0 {
+			return m, errors.New("message ends in header")
+		}
+		fields[i], b = nextField(b)
+		if fields[i] == nilValue {
+			fields[i] = ""
+		}
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = fields[0], fields[1], fields[2], fields[3]
+
+	if b, err = skipStructuredData(b); err != nil {
+		return m, err
+	}
+	if len(b) > 0 {
+		if b[0] != ' ' {
+			return m, errors.New("missing space before message")
+		}
+		m.Message = bytes.TrimPrefix(b[1:], utf8BOM)
+	}
+	return m, nil
+}
+
+// nextField returns the field up to the next space and the remainder after the space.
+func nextField(b []byte) (string, []byte) {
+	i := bytes.IndexByte(b, ' ')
+	if i < 0 {
+		return string(b), nil
+	}
+	return string(b[:i]), b[i+1:]
+}
+
+// skipStructuredData returns the remainder after the structured data.
+// The structured data is either - or a sequence of elements [ID PARAM="VALUE" ...],
+// whose values may escape ", \ and ] with a backslash.
+func skipStructuredData(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, errors.New("missing structured data")
+	}
+	if b[0] == '-' {
+		return b[1:], nil
+	}
+	if b[0] != '[' {
+		return nil, errors.New("invalid structured data")
+	}
+	for len(b) > 0 && b[0] == '[' {
+		quoted := false
+		i := 1
+		for ; i < len(b); i++ {
+			c := b[i]
+			if quoted && c == '\\' {
+				i++
+				continue
+			}
+			if c == '"' {
+				quoted = !quoted
+			}
+			if !quoted && c == ']' {
+				break
+			}
+		}
+		if i >= len(b) {
+			return nil, errors.New("unterminated structured data")
+		}
+		b = b[i+1:]
+	}
+	return b, nil
+}
@@ -0,0 +1,28 @@
+package syslog
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestParseMessage_InvalidPriority(t *testing.T) {
+	for _, pri := range []string{"<>", "<-1>", "<+1>", "<192>", "<1a>", "<1000>"} {
+		b := []byte(pri + "1 2017-05-01T10:00:00Z web01 nginx 42 - - message")
+		if _, err := parseMessage(b); err == nil {
+			t.Errorf("%s: expected error", pri)
+		}
+	}
+}
+
+func TestReadFrame_MaxMessageSize(t *testing.T) {
+	for _, frame := range []string{
+		strings.Repeat("a", maxMessageSize+1) + "\n",
+		strings.Repeat("1", maxMessageSize+1) + " message",
+	} {
+		r := bufio.NewReaderSize(strings.NewReader(frame), maxMessageSize)
+		if _, err := readFrame(r); err == nil {
+			t.Errorf("expected error for frame of %d bytes", len(frame))
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/influxdata/kapacitor/server/vars"
+)
+
+const (
+	// Maximum size of a message, larger TCP frames are rejected.
+	maxMessageSize = 65536
+)
+
+// statistics gathered by the syslog package.
+const (
+	statMessagesReceived  = "messages_rx"
+	statBytesReceived     = "bytes_rx"
+	statMessageParseFail  = "messages_parse_fail"
+	statReadFail          = "read_fail"
+	statPointsReceived    = "points_rx"
+	statPointsParseFail   = "points_parse_fail"
+	statPointsTransmitted = "points_tx"
+	statTransmitFail      = "tx_fail"
+)
+
+type Diagnostic interface {
+	Error(msg string, err error, ctx ...keyvalue.T)
+	StartedListening(network, addr string)
+	ClosedService()
+}
+
+// Service receives RFC 5424 syslog messages over UDP or TCP and writes them as points.
+// Over UDP each datagram is a message, over TCP messages are framed by octet counting
+// or terminated by a newline as described in RFC 6587.
+type Service struct {
+	config  Config
+	decoder *decoder.Decoder
+
+	ln   net.Listener
+	conn net.PacketConn
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	PointsWriter interface {
+		WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	Diag    Diagnostic
+	statMap *expvar.Map
+	statKey string
+}
+
+func NewService(c Config, diag Diagnostic) (*Service, error) {
+	c = c.withDefaults()
+	s := &Service{
+		config: c,
+		Diag:   diag,
+	}
+	if c.Format != "" {
+		d, err := decoder.New(c.Config)
+		if err != nil {
+			return nil, err
+		}
+		s.decoder = d
+	}
+	return s, nil
+}
+
+func (s *Service) Open() error {
+	var addr net.Addr
+	switch s.config.Network {
+	case NetworkUDP:
+		conn, err := net.ListenPacket("udp", s.config.BindAddress)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		addr = conn.LocalAddr()
+	case NetworkTCP:
+		ln, err := net.Listen("tcp", s.config.BindAddress)
+		if err != nil {
+			return err
+		}
+		s.ln = ln
+		addr = ln.Addr()
+	default:
+		return fmt.Errorf("invalid network %q", s.config.Network)
+	}
+
+	tags := map[string]string{"bind": addr.String(), "network": s.config.Network}
+	s.statKey, s.statMap = vars.NewStatistic("syslog", tags)
+	s.conns = make(map[net.Conn]struct{})
+	s.closing = make(chan struct{})
+
+	s.Diag.StartedListening(s.config.Network, addr.String())
+
+	s.wg.Add(1)
+	if s.conn != nil {
+		go s.serveUDP()
+	} else {
+		go s.serveTCP()
+	}
+	return nil
+}
+
+func (s *Service) Close() error {
+	if s.closing == nil {
+		return errors.New("service already closed")
+	}
+	close(s.closing)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.ln != nil {
+		s.ln.Close()
+	}
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	vars.DeleteStatistic(s.statKey)
+
+	s.closing = nil
+	s.conn = nil
+	s.ln = nil
+	s.Diag.ClosedService()
+	return nil
+}
+
+// Addr returns the address the service listens on.
+func (s *Service) Addr() net.Addr {
+	if s.conn != nil {
+		return s.conn.LocalAddr()
+	}
+	if s.ln != nil {
+		return s.ln.Addr()
+	}
+	return nil
+}
+
+func (s *Service) isClosing() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Service) serveUDP() {
+	defer s.wg.Done()
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if s.isClosing() {
+				return
+			}
+			s.statMap.Add(statReadFail, 1)
+			s.Diag.Error("failed to read syslog message", err)
+			continue
+		}
+		s.statMap.Add(statBytesReceived, int64(n))
+		s.handle(bytes.TrimRight(buf[:n], "\r\n"))
+	}
+}
+
+func (s *Service) serveTCP() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if s.isClosing() {
+				return
+			}
+			s.Diag.Error("failed to accept syslog connection", err)
+			continue
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn handles the messages of a TCP connection until it is closed.
+func (s *Service) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		frame, err := readFrame(r)
+		if err != nil {
+			if err != io.EOF && !s.isClosing() {
+				s.statMap.Add(statReadFail, 1)
+				s.Diag.Error("failed to read syslog message", err, keyvalue.KV("remote", conn.RemoteAddr().String()))
+			}
+			return
+		}
+		s.statMap.Add(statBytesReceived, int64(len(frame)))
+		if len(frame) > 0 {
+			s.handle(frame)
+		}
+	}
+}
+
+// readFrame reads a message framed by octet counting, "LENGTH MESSAGE",
+// or otherwise terminated by a newline.
+// The reader must be sized to hold maxMessageSize bytes, longer lines are rejected.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		length, err := r.ReadSlice(' ')
+		if err == bufio.ErrBufferFull {
+			return nil, fmt.Errorf("message length exceeds maximum of %d", maxMessageSize)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(string(length[:len(length)-1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid message length %q", length)
+		}
+		if n > maxMessageSize {
+			return nil, fmt.Errorf("message length %d exceeds maximum of %d", n, maxMessageSize)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("message exceeds maximum size of %d", maxMessageSize)
+	}
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, err
+	}
+	// ReadSlice returns a view of the buffer, which is overwritten by the next read.
+	return append([]byte(nil), bytes.TrimRight(line, "\r\n")...), nil
+}
+
+// handle writes the points of a message.
+func (s *Service) handle(b []byte) {
+	s.statMap.Add(statMessagesReceived, 1)
+	m, err := parseMessage(b)
+	if err != nil {
+		s.statMap.Add(statMessageParseFail, 1)
+		s.Diag.Error("failed to parse syslog message", err)
+		return
+	}
+	points, err := s.points(m)
+	if err != nil {
+		s.statMap.Add(statPointsParseFail, 1)
+		s.Diag.Error("failed to decode points of syslog message", err)
+		return
+	}
+	s.statMap.Add(statPointsReceived, int64(len(points)))
+	if len(points) == 0 {
+		return
+	}
+	if err := s.PointsWriter.WritePoints(
+		s.config.Database,
+		s.config.RetentionPolicy,
+		models.ConsistencyLevelAll,
+		points,
+	); err != nil {
+		s.Diag.Error("failed to write points to database", err, keyvalue.KV("database", s.config.Database))
+		s.statMap.Add(statTransmitFail, 1)
+		return
+	}
+	s.statMap.Add(statPointsTransmitted, int64(len(points)))
+}
+
+// points creates the points of a message, tagged with its header.
+func (s *Service) points(m message) ([]models.Point, error) {
+	t := m.Timestamp
+	if t.IsZero() {
+		t = time.Now().UTC()
+	}
+	tags := map[string]string{
+		"facility": facilities[m.Facility],
+		"severity": severities[m.Severity],
+	}
+	if m.Hostname != "" {
+		tags["hostname"] = m.Hostname
+	}
+	if m.AppName != "" {
+		tags["appname"] = m.AppName
+	}
+
+	if s.decoder == nil {
+		fields := models.Fields{
+			"message":       string(m.Message),
+			"facility_code": int64(m.Facility),
+			"severity_code": int64(m.Severity),
+		}
+		if m.ProcID != "" {
+			fields["procid"] = m.ProcID
+		}
+		if m.MsgID != "" {
+			fields["msgid"] = m.MsgID
+		}
+		p, err := models.NewPoint(s.config.Measurement, models.NewTags(tags), fields, t)
+		if err != nil {
+			return nil, err
+		}
+		return []models.Point{p}, nil
+	}
+
+	points, err := s.decoder.Decode(m.Message, t)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range points {
+		for k, v := range tags {
+			p.AddTag(k, v)
+		}
+	}
+	return points, nil
+}
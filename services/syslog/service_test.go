@@ -0,0 +1,167 @@
+package syslog_test
+
+import (
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor/decoder"
+	"github.com/influxdata/kapacitor/services/diagnostic"
+	"github.com/influxdata/kapacitor/services/syslog"
+)
+
+var diagService *diagnostic.Service
+
+func init() {
+	diagService = diagnostic.NewService(diagnostic.NewConfig(), ioutil.Discard, ioutil.Discard)
+	diagService.Open()
+}
+
+type pointsWriter struct {
+	points chan string
+}
+
+func (w *pointsWriter) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	for _, p := range points {
+		w.points <- database + " " + retentionPolicy + " " + p.String()
+	}
+	return nil
+}
+
+func openService(t *testing.T, c syslog.Config) (*syslog.Service, *pointsWriter) {
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := syslog.NewService(c, diagService.NewSyslogHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &pointsWriter{points: make(chan string, 10)}
+	s.PointsWriter = w
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	return s, w
+}
+
+func receive(t *testing.T, w *pointsWriter, n int) []string {
+	var points []string
+	for i := 0; i < n; i++ {
+		select {
+		case p := <-w.points:
+			points = append(points, p)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for point %d", i)
+		}
+	}
+	return points
+}
+
+func TestService_UDP(t *testing.T) {
+	s, w := openService(t, syslog.Config{
+		Enabled:         true,
+		Network:         syslog.NetworkUDP,
+		BindAddress:     "127.0.0.1:0",
+		Database:        "logs",
+		RetentionPolicy: "autogen",
+	})
+	defer s.Close()
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	msgs := []string{
+		"not a syslog message",
+		`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventID="1011" msg="a \] b"] ` + "\xEF\xBB\xBF" + "An application event log entry...\n",
+		"<34>1 2003-10-11T22:14:15.003Z - - - - -",
+	}
+	for _, m := range msgs {
+		if _, err := conn.Write([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp := []string{
+		`logs autogen syslog,appname=evntslog,facility=local4,hostname=mymachine.example.com,severity=notice facility_code=20i,message="An application event log entry...",msgid="ID47",severity_code=5i 1065910455003000000`,
+		`logs autogen syslog,facility=auth,severity=crit facility_code=4i,message="",severity_code=2i 1065910455003000000`,
+	}
+	if got := receive(t, w, len(exp)); !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected points:\ngot\n%v\nexp\n%v", got, exp)
+	}
+}
+
+func TestService_TCP(t *testing.T) {
+	s, w := openService(t, syslog.Config{
+		Enabled:     true,
+		Network:     syslog.NetworkTCP,
+		BindAddress: "127.0.0.1:0",
+		Database:    "logs",
+		Config: decoder.Config{
+			Format:      decoder.FormatGrok,
+			Measurement: "requests",
+			GrokPattern: `%{WORD:method} %{URIPATH:path} %{INT:status:int} %{NUMBER:duration:float}ms`,
+			TagPaths:    []string{"method", "path"},
+		},
+	})
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	msg := "<14>1 2017-05-01T10:00:00Z web01 nginx 42 - - GET /index.html 200 3.5ms"
+	// The first message is framed by octet counting, the second by a newline.
+	frames := []string{
+		"71 " + msg,
+		"<14>1 2017-05-01T10:00:01Z web01 nginx 42 - - unexpected message\n",
+		"<14>1 2017-05-01T10:00:02Z web01 nginx 42 - - POST /login 500 20ms\n",
+	}
+	for _, f := range frames {
+		if _, err := conn.Write([]byte(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp := []string{
+		`logs  requests,appname=nginx,facility=user,hostname=web01,method=GET,path=/index.html,severity=info duration=3.5,status=200i 1493632800000000000`,
+		`logs  requests,appname=nginx,facility=user,hostname=web01,method=POST,path=/login,severity=info duration=20,status=500i 1493632802000000000`,
+	}
+	if got := receive(t, w, len(exp)); !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected points:\ngot\n%v\nexp\n%v", got, exp)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name string
+		c    syslog.Config
+	}{
+		{
+			name: "invalid network",
+			c:    syslog.Config{Enabled: true, Network: "unix", BindAddress: ":514", Database: "logs"},
+		},
+		{
+			name: "missing database",
+			c:    syslog.Config{Enabled: true, Network: syslog.NetworkUDP, BindAddress: ":514"},
+		},
+		{
+			name: "invalid format",
+			c: syslog.Config{
+				Enabled:     true,
+				Network:     syslog.NetworkUDP,
+				BindAddress: ":514",
+				Database:    "logs",
+				Config:      decoder.Config{Format: decoder.FormatRegex},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		if err := tc.c.Validate(); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
@@ -6,17 +6,21 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"strings"
 	"time"
 
+	"github.com/influxdata/kapacitor/namespace"
 	"github.com/influxdata/kapacitor/services/storage"
 )
 
 var (
-	ErrTaskExists       = errors.New("task already exists")
-	ErrNoTaskExists     = errors.New("no task exists")
-	ErrTemplateExists   = errors.New("template already exists")
-	ErrNoTemplateExists = errors.New("no template exists")
-	ErrNoSnapshotExists = errors.New("no snapshot exists")
+	ErrTaskExists        = errors.New("task already exists")
+	ErrNoTaskExists      = errors.New("no task exists")
+	ErrTemplateExists    = errors.New("template already exists")
+	ErrNoTemplateExists  = errors.New("no template exists")
+	ErrNoSnapshotExists  = errors.New("no snapshot exists")
+	ErrNamespaceExists   = errors.New("namespace already exists")
+	ErrNoNamespaceExists = errors.New("no namespace exists")
 )
 
 // Data access object for Task data.
@@ -90,6 +94,32 @@ type SnapshotDAO interface {
 	Exists(id string) (bool, error)
 }
 
+// Data access object for Namespace data.
+type NamespaceDAO interface {
+	// Retrieve a namespace
+	Get(name string) (Namespace, error)
+
+	// Create a namespace.
+	// ErrNamespaceExists is returned if a namespace already exists with the same name.
+	Create(n Namespace) error
+
+	// Replace an existing namespace.
+	// ErrNoNamespaceExists is returned if the namespace does not exist.
+	Replace(n Namespace) error
+
+	// Delete a namespace.
+	// It is not an error to delete an non-existent namespace.
+	Delete(name string) error
+
+	// List namespaces matching a pattern.
+	// The pattern is shell/glob matching see https://golang.org/pkg/path/#Match
+	// Offset and limit are pagination bounds. Offset is inclusive starting at index 0.
+	// More results may exist while the number of returned items is equal to limit.
+	List(pattern string, offset, limit int) ([]Namespace, error)
+
+	Rebuild() error
+}
+
 //--------------------------------------------------------------------
 // The following structures are stored in a database via gob encoding.
 // Changes to the structures could break existing data.
@@ -137,8 +167,63 @@ type Task struct {
 	Modified time.Time
 	// The time the task was last changed to status Enabled.
 	LastEnabled time.Time
+	// Flight recorder settings of the task
+	FlightRecorder FlightRecorder
+	// Resource limits of the task
+	Limits TaskLimits
+	// Policies of the edges of the task
+	EdgePolicies EdgePolicies
 }
 
+type FlightRecorder struct {
+	// How much of the most recent input data to keep, zero disables the flight recorder.
+	Window time.Duration
+	// Whether to freeze the flight recorder into a recording when an alert goes critical.
+	FreezeOnCritical bool
+}
+
+type TaskLimits struct {
+	// Maximum number of points buffered across window and join nodes.
+	BufferedPoints Limit
+	// Maximum number of groups of any node.
+	Groups Limit
+	// Maximum number of messages queued on any edge.
+	EdgeQueue Limit
+	// Maximum share of a single CPU in percent.
+	CPU Limit
+}
+
+type Limit struct {
+	// The maximum, zero disables the limit.
+	Max int64
+	// What happens once the maximum is reached.
+	Policy LimitPolicy
+}
+
+type LimitPolicy int
+
+const (
+	LimitDrop LimitPolicy = iota
+	LimitPause
+	LimitError
+)
+
+type EdgePolicies struct {
+	// Policy of all edges of the task.
+	Default EdgePolicy
+	// Policies of the edges into specific nodes, keyed by node name.
+	Nodes map[string]EdgePolicy
+}
+
+type EdgePolicy int
+
+const (
+	EdgeBlock EdgePolicy = iota
+	EdgeDropOldest
+	EdgeDropNewest
+	EdgeSpillToDisk
+)
+
 type rawTask Task
 
 func (t Task) ObjectID() string {
@@ -181,6 +266,33 @@ type Snapshot struct {
 	NodeSnapshots map[string][]byte
 }
 
+type Namespace struct {
+	// Unique name of the namespace
+	Name string
+	// The DBs and RPs tasks within the namespace are allowed to access.
+	// An empty retention policy allows all retention policies of the database,
+	// no DBRPs allow all DBs and RPs.
+	DBRPs []DBRP
+}
+
+type rawNamespace Namespace
+
+func (n Namespace) ObjectID() string {
+	return n.Name
+}
+
+func (n Namespace) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	err := enc.Encode(rawNamespace(n))
+	return buf.Bytes(), err
+}
+
+func (n *Namespace) UnmarshalBinary(data []byte) error {
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	return dec.Decode((*rawNamespace)(n))
+}
+
 // Key/Value store based implementation of the TaskDAO
 type taskKV struct {
 	store *storage.IndexedStore
@@ -252,6 +364,77 @@ func (kv *taskKV) Rebuild() error {
 	return kv.store.Rebuild()
 }
 
+// Key/Value store based implementation of the NamespaceDAO
+type namespaceKV struct {
+	store *storage.IndexedStore
+}
+
+func newNamespaceKV(store storage.Interface) (*namespaceKV, error) {
+	c := storage.DefaultIndexedStoreConfig("namespaces", func() storage.BinaryObject {
+		return new(Namespace)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &namespaceKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *namespaceKV) error(err error) error {
+	if err == storage.ErrObjectExists {
+		return ErrNamespaceExists
+	} else if err == storage.ErrNoObjectExists {
+		return ErrNoNamespaceExists
+	}
+	return err
+}
+
+func (kv *namespaceKV) Get(name string) (Namespace, error) {
+	o, err := kv.store.Get(name)
+	if err != nil {
+		return Namespace{}, kv.error(err)
+	}
+	n, ok := o.(*Namespace)
+	if !ok {
+		return Namespace{}, fmt.Errorf("impossible error, object not a Namespace, got %T", o)
+	}
+	return *n, nil
+}
+
+func (kv *namespaceKV) Create(n Namespace) error {
+	return kv.error(kv.store.Create(&n))
+}
+
+func (kv *namespaceKV) Replace(n Namespace) error {
+	return kv.error(kv.store.Replace(&n))
+}
+
+func (kv *namespaceKV) Delete(name string) error {
+	return kv.store.Delete(name)
+}
+
+func (kv *namespaceKV) List(pattern string, offset, limit int) ([]Namespace, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, pattern, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make([]Namespace, len(objects))
+	for i, o := range objects {
+		n, ok := o.(*Namespace)
+		if !ok {
+			return nil, fmt.Errorf("impossible error, object not a Namespace, got %T", o)
+		}
+		namespaces[i] = *n
+	}
+	return namespaces, nil
+}
+
+func (kv *namespaceKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
+
 const (
 	templateDataPrefix    = "/templates/data/"
 	templateIndexesPrefix = "/templates/indexes/"
@@ -305,7 +488,14 @@ func (d *templateKV) templateIndexKey(index, value string) string {
 
 // Create a key for the template task association
 func (d *templateKV) templateTaskAssociationKey(templateId, taskId string) string {
-	return templateTaskPrefix + templateId + "/" + taskId
+	return d.templateTaskAssociationPrefix(templateId) + taskId
+}
+
+// Create the key prefix of all tasks associated with the template.
+// The namespace separator of a namespaced template ID is escaped,
+// so that the associations of a template are never listed as those of a template named like its namespace.
+func (d *templateKV) templateTaskAssociationPrefix(templateId string) string {
+	return templateTaskPrefix + strings.Replace(templateId, namespace.Separator, ":", 1) + "/"
 }
 
 func (d *templateKV) Get(id string) (t Template, err error) {
@@ -391,7 +581,7 @@ func (d *templateKV) Delete(id string) error {
 		}
 
 		// Delete all associations
-		ids, err := tx.List(templateTaskPrefix + id + "/")
+		ids, err := tx.List(d.templateTaskAssociationPrefix(id))
 		if err != nil {
 			return nil
 		}
@@ -422,7 +612,7 @@ func (d *templateKV) DisassociateTask(templateId, taskId string) error {
 
 func (d *templateKV) ListAssociatedTasks(templateId string) (taskIds []string, err error) {
 	err = d.store.View(func(tx storage.ReadOnlyTx) error {
-		ids, err := tx.List(templateTaskPrefix + templateId + "/")
+		ids, err := tx.List(d.templateTaskAssociationPrefix(templateId))
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,278 @@
+package task_store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/namespace"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/services/httpd"
+)
+
+const (
+	namespacesPath         = "/namespaces"
+	namespacesPathAnchored = "/namespaces/"
+
+	namespacesBasePathAnchored = httpd.BasePath + namespacesPathAnchored
+
+	// Public name for the namespace storage layer
+	namespacesAPIName = "namespaces"
+)
+
+// CheckNamespaceDBRPs returns an error if the namespace does not exist
+// or does not allow access to any of the DBRPs.
+// The default namespace always exists and allows all DBRPs.
+func (ts *Service) CheckNamespaceDBRPs(ns string, dbrps []kapacitor.DBRP) error {
+	if ns == "" {
+		return nil
+	}
+	n, err := ts.namespaces.Get(ns)
+	if err == ErrNoNamespaceExists {
+		return fmt.Errorf("unknown namespace %q", ns)
+	}
+	if err != nil {
+		return err
+	}
+	if len(n.DBRPs) == 0 {
+		return nil
+	}
+	for _, dbrp := range dbrps {
+		if !namespaceAllows(n, dbrp) {
+			return fmt.Errorf("namespace %q does not allow access to database %q retention policy %q", ns, dbrp.Database, dbrp.RetentionPolicy)
+		}
+	}
+	return nil
+}
+
+func namespaceAllows(n Namespace, dbrp kapacitor.DBRP) bool {
+	for _, allowed := range n.DBRPs {
+		if allowed.Database == dbrp.Database && (allowed.RetentionPolicy == "" || allowed.RetentionPolicy == dbrp.RetentionPolicy) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTaskNamespace returns an error if the namespace of the task does not allow
+// the DBRPs the task reads from or writes to.
+// Besides the DBRPs of the task these are the sources of batch queries,
+// and the targets of InfluxDB and Kapacitor loopback outputs.
+func (ts *Service) checkTaskNamespace(task Task) error {
+	ns := namespace.Of(task.ID)
+	if ns == "" {
+		return nil
+	}
+	kt, err := ts.newKapacitorTask(task)
+	if err != nil {
+		return err
+	}
+	dbrps := kt.DBRPs
+	err = kt.Pipeline.Walk(func(n pipeline.Node) error {
+		switch node := n.(type) {
+		case *pipeline.QueryNode:
+			q, err := kapacitor.NewQuery(node.QueryStr)
+			if err != nil {
+				return err
+			}
+			sources, err := q.DBRPs()
+			if err != nil {
+				return err
+			}
+			dbrps = append(dbrps, sources...)
+		case *pipeline.InfluxDBOutNode:
+			dbrps = append(dbrps, kapacitor.DBRP{Database: node.Database, RetentionPolicy: node.RetentionPolicy})
+		case *pipeline.KapacitorLoopbackNode:
+			dbrps = append(dbrps, kapacitor.DBRP{Database: node.Database, RetentionPolicy: node.RetentionPolicy})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return ts.CheckNamespaceDBRPs(ns, dbrps)
+}
+
+func (ts *Service) namespaceNameFromPath(p string) (string, error) {
+	if len(p) <= len(namespacesBasePathAnchored) {
+		return "", errors.New("must specify namespace name on path")
+	}
+	return p[len(namespacesBasePathAnchored):], nil
+}
+
+func (ts *Service) namespaceLink(name string) client.Link {
+	return client.Link{Relation: client.Self, Href: path.Join(httpd.BasePath, namespacesPath, name)}
+}
+
+func (ts *Service) convertNamespace(n Namespace) client.Namespace {
+	dbrps := make([]client.DBRP, len(n.DBRPs))
+	for i, dbrp := range n.DBRPs {
+		dbrps[i] = client.DBRP{
+			Database:        dbrp.Database,
+			RetentionPolicy: dbrp.RetentionPolicy,
+		}
+	}
+	return client.Namespace{
+		Link:  ts.namespaceLink(n.Name),
+		Name:  n.Name,
+		DBRPs: dbrps,
+	}
+}
+
+func convertToServiceDBRPs(dbrps []client.DBRP) []DBRP {
+	converted := make([]DBRP, len(dbrps))
+	for i, dbrp := range dbrps {
+		converted[i] = DBRP{
+			Database:        dbrp.Database,
+			RetentionPolicy: dbrp.RetentionPolicy,
+		}
+	}
+	return converted
+}
+
+func (ts *Service) handleListNamespaces(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	var err error
+	offset := int64(0)
+	offsetStr := r.URL.Query().Get("offset")
+	if offsetStr != "" {
+		offset, err = strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid offset parameter %q must be an integer: %s", offsetStr, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := int64(100)
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr != "" {
+		limit, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid limit parameter %q must be an integer: %s", limitStr, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	namespaces, err := ts.namespaces.List(pattern, int(offset), int(limit))
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to list namespaces with pattern %q: %s", pattern, err), true, http.StatusBadRequest)
+		return
+	}
+	type response struct {
+		Namespaces []client.Namespace `json:"namespaces"`
+	}
+	resp := response{Namespaces: make([]client.Namespace, len(namespaces))}
+	for i, n := range namespaces {
+		resp.Namespaces[i] = ts.convertNamespace(n)
+	}
+	w.Write(httpd.MarshalJSON(resp, true))
+}
+
+func (ts *Service) handleNamespace(w http.ResponseWriter, r *http.Request) {
+	name, err := ts.namespaceNameFromPath(r.URL.Path)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	n, err := ts.namespaces.Get(name)
+	if err == ErrNoNamespaceExists {
+		httpd.HttpError(w, fmt.Sprintf("no namespace exists with name %s", name), true, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.Write(httpd.MarshalJSON(ts.convertNamespace(n), true))
+}
+
+func (ts *Service) handleCreateNamespace(w http.ResponseWriter, r *http.Request) {
+	opt := client.CreateNamespaceOptions{}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&opt); err != nil {
+		httpd.HttpError(w, "invalid JSON", true, http.StatusBadRequest)
+		return
+	}
+	if !namespace.Valid(opt.Name) {
+		httpd.HttpError(w, fmt.Sprintf("namespace name must contain only letters, numbers, '-', '.' and '_'. %q", opt.Name), true, http.StatusBadRequest)
+		return
+	}
+	n := Namespace{
+		Name:  opt.Name,
+		DBRPs: convertToServiceDBRPs(opt.DBRPs),
+	}
+	if err := ts.namespaces.Create(n); err == ErrNamespaceExists {
+		httpd.HttpError(w, fmt.Sprintf("namespace %s already exists", n.Name), true, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.Write(httpd.MarshalJSON(ts.convertNamespace(n), true))
+}
+
+func (ts *Service) handleUpdateNamespace(w http.ResponseWriter, r *http.Request) {
+	name, err := ts.namespaceNameFromPath(r.URL.Path)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	opt := client.UpdateNamespaceOptions{}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&opt); err != nil {
+		httpd.HttpError(w, "invalid JSON", true, http.StatusBadRequest)
+		return
+	}
+	n, err := ts.namespaces.Get(name)
+	if err == ErrNoNamespaceExists {
+		httpd.HttpError(w, fmt.Sprintf("no namespace exists with name %s", name), true, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	if opt.DBRPs != nil {
+		// Existing tasks are not checked against the new DBRPs,
+		// they are checked the next time they are updated.
+		n.DBRPs = convertToServiceDBRPs(*opt.DBRPs)
+	}
+	if err := ts.namespaces.Replace(n); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.Write(httpd.MarshalJSON(ts.convertNamespace(n), true))
+}
+
+func (ts *Service) handleDeleteNamespace(w http.ResponseWriter, r *http.Request) {
+	name, err := ts.namespaceNameFromPath(r.URL.Path)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	// Tasks and templates must not outlive the DBRPs of their namespace.
+	tasks, err := ts.tasks.List(namespace.Pattern(name, ""), 0, 1)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	templates, err := ts.templates.List(namespace.Pattern(name, ""), 0, 1)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	if len(tasks) > 0 || len(templates) > 0 {
+		httpd.HttpError(w, fmt.Sprintf("namespace %s is not empty, delete its tasks and templates first", name), true, http.StatusBadRequest)
+		return
+	}
+	if err := ts.namespaces.Delete(name); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
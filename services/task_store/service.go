@@ -9,13 +9,17 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/influxdata/kapacitor"
 	"github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/keyvalue"
+	"github.com/influxdata/kapacitor/namespace"
+	"github.com/influxdata/kapacitor/pipeline"
 	"github.com/influxdata/kapacitor/server/vars"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/storage"
@@ -52,6 +56,7 @@ type Service struct {
 	tasks            TaskDAO
 	templates        TemplateDAO
 	snapshots        SnapshotDAO
+	namespaces       NamespaceDAO
 	routes           []httpd.Route
 	snapshotInterval time.Duration
 	StorageService   interface {
@@ -104,6 +109,12 @@ func (ts *Service) Open() error {
 	ts.StorageService.Register(tasksAPIName, ts.tasks)
 	ts.templates = newTemplateKV(store)
 	ts.snapshots = newSnapshotKV(store)
+	namespacesDAO, err := newNamespaceKV(store)
+	if err != nil {
+		return err
+	}
+	ts.namespaces = namespacesDAO
+	ts.StorageService.Register(namespacesAPIName, ts.namespaces)
 
 	// Perform migration to new storage service.
 	if err := ts.migrate(); err != nil {
@@ -134,14 +145,21 @@ func (ts *Service) Open() error {
 			HandlerFunc: ts.handleUpdateTask,
 		},
 		{
-			Method:      "GET",
-			Pattern:     tasksPath,
-			HandlerFunc: ts.handleListTasks,
+			Method:              "GET",
+			Pattern:             tasksPath,
+			HandlerFunc:         ts.handleListTasks,
+			NamespaceCollection: tasksPath,
+		},
+		{
+			Method:              "POST",
+			Pattern:             tasksPath,
+			HandlerFunc:         ts.handleCreateTask,
+			NamespaceCollection: tasksPath,
 		},
 		{
 			Method:      "POST",
-			Pattern:     tasksPath,
-			HandlerFunc: ts.handleCreateTask,
+			Pattern:     tasksTestPath,
+			HandlerFunc: ts.handleTestTask,
 		},
 		{
 			Method:      "GET",
@@ -164,15 +182,48 @@ func (ts *Service) Open() error {
 			Pattern:     templatesPathAnchored,
 			HandlerFunc: ts.handleUpdateTemplate,
 		},
+		{
+			Method:              "GET",
+			Pattern:             templatesPath,
+			HandlerFunc:         ts.handleListTemplates,
+			NamespaceCollection: templatesPath,
+		},
+		{
+			Method:              "POST",
+			Pattern:             templatesPath,
+			HandlerFunc:         ts.handleCreateTemplate,
+			NamespaceCollection: templatesPath,
+		},
+		{
+			Method:      "GET",
+			Pattern:     namespacesPathAnchored,
+			HandlerFunc: ts.handleNamespace,
+		},
+		{
+			Method:      "DELETE",
+			Pattern:     namespacesPathAnchored,
+			HandlerFunc: ts.handleDeleteNamespace,
+		},
+		{
+			// Satisfy CORS checks.
+			Method:      "OPTIONS",
+			Pattern:     namespacesPathAnchored,
+			HandlerFunc: httpd.ServeOptions,
+		},
+		{
+			Method:      "PATCH",
+			Pattern:     namespacesPathAnchored,
+			HandlerFunc: ts.handleUpdateNamespace,
+		},
 		{
 			Method:      "GET",
-			Pattern:     templatesPath,
-			HandlerFunc: ts.handleListTemplates,
+			Pattern:     namespacesPath,
+			HandlerFunc: ts.handleListNamespaces,
 		},
 		{
 			Method:      "POST",
-			Pattern:     templatesPath,
-			HandlerFunc: ts.handleCreateTemplate,
+			Pattern:     namespacesPath,
+			HandlerFunc: ts.handleCreateNamespace,
 		},
 	}
 
@@ -189,7 +240,7 @@ func (ts *Service) Open() error {
 	limit := 100
 	vars.NumEnabledTasksVar.Set(0)
 	for {
-		tasks, err := ts.tasks.List("*", offset, limit)
+		tasks, err := ts.tasks.List("", offset, limit)
 		if err != nil {
 			return err
 		}
@@ -497,6 +548,7 @@ var allTaskFields = []string{
 	"modified",
 	"last-enabled",
 	"vars",
+	"flight-recorder",
 }
 
 const tasksBasePathAnchored = httpd.BasePath + tasksPathAnchored
@@ -515,7 +567,12 @@ func (ts *Service) taskLink(id string) client.Link {
 
 func (ts *Service) handleListTasks(w http.ResponseWriter, r *http.Request) {
 
-	pattern := r.URL.Query().Get("pattern")
+	ns := r.URL.Query().Get("namespace")
+	if err := namespace.Validate(ns); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	pattern := namespace.Pattern(ns, r.URL.Query().Get("pattern"))
 	fields := r.URL.Query()["fields"]
 	if len(fields) == 0 {
 		fields = allTaskFields
@@ -658,6 +715,12 @@ func (ts *Service) handleListTasks(w http.ResponseWriter, r *http.Request) {
 					break
 				}
 				value = vars
+			case "flight-recorder":
+				value = convertFlightRecorder(task.FlightRecorder)
+			case "limits":
+				value = convertTaskLimits(task.Limits)
+			case "edge-policies":
+				value = convertEdgePolicies(task.EdgePolicies)
 			default:
 				httpd.HttpError(w, fmt.Sprintf("unsupported field %q", field), true, http.StatusBadRequest)
 				return
@@ -686,7 +749,18 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	if task.ID == "" {
 		task.ID = uuid.New().String()
 	}
-	if !validTaskID.MatchString(task.ID) {
+	// Tasks created for a namespace are created within the namespace.
+	ns := r.URL.Query().Get("namespace")
+	if err := namespace.Validate(ns); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	task.ID, err = namespace.Qualify(ns, task.ID)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if !namespace.ValidID(task.ID, validTaskID) {
 		httpd.HttpError(w, fmt.Sprintf("task ID must contain only letters, numbers, '-', '.' and '_'. %q", task.ID), true, http.StatusBadRequest)
 		return
 	}
@@ -704,6 +778,12 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 
 	// Check for template ID
 	if task.TemplateID != "" {
+		// Tasks can only be created from templates within their namespace.
+		task.TemplateID, err = namespace.Qualify(namespace.Of(task.ID), task.TemplateID)
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
 		template, err := ts.templates.Get(task.TemplateID)
 		if err != nil {
 			httpd.HttpError(w, fmt.Sprintf("unknown template %s: err: %s", task.TemplateID, err), true, http.StatusBadRequest)
@@ -783,12 +863,34 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Set flight recorder
+	if err := setFlightRecorder(&newTask, task.FlightRecorder); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	// Set limits
+	if err := setTaskLimits(&newTask, task.Limits); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	// Set edge policies
+	if err := setEdgePolicies(&newTask, task.EdgePolicies); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
 	// Validate task
-	_, err = ts.newKapacitorTask(newTask)
+	kt, err := ts.newKapacitorTask(newTask)
 	if err != nil {
 		httpd.HttpError(w, "invalid TICKscript: "+err.Error(), true, http.StatusBadRequest)
 		return
 	}
+	if err := validateEdgePolicies(kt); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
 
 	now := time.Now()
 	newTask.Created = now
@@ -819,6 +921,11 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		newTask.DBRPs = dbrps
 	}
 
+	if err := ts.checkTaskNamespace(newTask); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
 	// Save task
 	err = ts.tasks.Create(newTask)
 	if err != nil {
@@ -872,8 +979,29 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	updated := original
 
 	// Set ID if changing
+	ns := namespace.Of(original.ID)
 	if task.ID != "" {
-		updated.ID = task.ID
+		// Tasks cannot be moved to a different namespace.
+		id, err := namespace.Qualify(ns, task.ID)
+		if err == nil && namespace.Of(id) != ns {
+			err = fmt.Errorf("task %s cannot be moved to namespace %q", original.ID, namespace.Of(id))
+		}
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+		if !namespace.ValidID(id, validTaskID) {
+			httpd.HttpError(w, fmt.Sprintf("task ID must contain only letters, numbers, '-', '.' and '_'. %q", id), true, http.StatusBadRequest)
+			return
+		}
+		updated.ID = id
+	}
+	if task.TemplateID != "" {
+		task.TemplateID, err = namespace.Qualify(ns, task.TemplateID)
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
 	}
 
 	if task.TemplateID != "" || updated.TemplateID != "" {
@@ -992,12 +1120,38 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Set flight recorder
+	if err := setFlightRecorder(&updated, task.FlightRecorder); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	// Set limits
+	if err := setTaskLimits(&updated, task.Limits); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	// Set edge policies
+	if err := setEdgePolicies(&updated, task.EdgePolicies); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
 	// Validate task
-	_, err = ts.newKapacitorTask(updated)
+	kt, err := ts.newKapacitorTask(updated)
 	if err != nil {
 		httpd.HttpError(w, "invalid TICKscript: "+err.Error(), true, http.StatusBadRequest)
 		return
 	}
+	if err := validateEdgePolicies(kt); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if err := ts.checkTaskNamespace(updated); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
 
 	now := time.Now()
 	updated.Modified = now
@@ -1141,10 +1295,183 @@ func (ts *Service) convertTask(t Task, scriptFormat, dotView string, tm *kapacit
 		Created:        t.Created,
 		Modified:       t.Modified,
 		LastEnabled:    t.LastEnabled,
+		FlightRecorder: convertFlightRecorder(t.FlightRecorder),
+		Limits:         convertTaskLimits(t.Limits),
+		EdgePolicies:   convertEdgePolicies(t.EdgePolicies),
 		Error:          errMsg,
 	}, nil
 }
 
+func convertFlightRecorder(fr FlightRecorder) client.FlightRecorder {
+	return client.FlightRecorder{
+		Window:           client.Duration(fr.Window),
+		FreezeOnCritical: fr.FreezeOnCritical,
+	}
+}
+
+// setFlightRecorder sets the flight recorder of the task from the options, if present.
+func setFlightRecorder(task *Task, fr *client.FlightRecorder) error {
+	if fr != nil {
+		if fr.Window < 0 {
+			return errors.New("flight recorder window cannot be negative")
+		}
+		task.FlightRecorder = FlightRecorder{
+			Window:           time.Duration(fr.Window),
+			FreezeOnCritical: fr.FreezeOnCritical,
+		}
+	}
+	if task.FlightRecorder.Window > 0 && task.Type != StreamTask {
+		return errors.New("flight recorder is only supported for stream tasks")
+	}
+	return nil
+}
+
+func convertTaskLimits(l TaskLimits) client.TaskLimits {
+	return client.TaskLimits{
+		BufferedPoints: convertLimit(l.BufferedPoints),
+		Groups:         convertLimit(l.Groups),
+		EdgeQueue:      convertLimit(l.EdgeQueue),
+		CPU:            convertLimit(l.CPU),
+	}
+}
+
+func convertLimit(l Limit) client.Limit {
+	cl := client.Limit{Max: l.Max}
+	switch l.Policy {
+	case LimitDrop:
+		cl.Policy = client.LimitDrop
+	case LimitPause:
+		cl.Policy = client.LimitPause
+	case LimitError:
+		cl.Policy = client.LimitError
+	}
+	return cl
+}
+
+// setTaskLimits sets the limits of the task from the options, if present.
+func setTaskLimits(task *Task, l *client.TaskLimits) error {
+	if l == nil {
+		return nil
+	}
+	limits := TaskLimits{}
+	for _, x := range []struct {
+		name   string
+		limit  client.Limit
+		target *Limit
+	}{
+		{name: "buffered-points", limit: l.BufferedPoints, target: &limits.BufferedPoints},
+		{name: "groups", limit: l.Groups, target: &limits.Groups},
+		{name: "edge-queue", limit: l.EdgeQueue, target: &limits.EdgeQueue},
+		{name: "cpu", limit: l.CPU, target: &limits.CPU},
+	} {
+		if x.limit.Max < 0 {
+			return fmt.Errorf("%s limit cannot be negative", x.name)
+		}
+		x.target.Max = x.limit.Max
+		switch x.limit.Policy {
+		case client.LimitDrop:
+			x.target.Policy = LimitDrop
+		case client.LimitPause:
+			x.target.Policy = LimitPause
+		case client.LimitError:
+			x.target.Policy = LimitError
+		default:
+			return fmt.Errorf("invalid %s limit policy %v", x.name, x.limit.Policy)
+		}
+	}
+	task.Limits = limits
+	return nil
+}
+
+func convertEdgePolicies(p EdgePolicies) client.EdgePolicies {
+	cp := client.EdgePolicies{
+		Default: convertEdgePolicy(p.Default),
+	}
+	if len(p.Nodes) > 0 {
+		cp.Nodes = make(map[string]client.EdgePolicy, len(p.Nodes))
+		for node, policy := range p.Nodes {
+			cp.Nodes[node] = convertEdgePolicy(policy)
+		}
+	}
+	return cp
+}
+
+func convertEdgePolicy(p EdgePolicy) client.EdgePolicy {
+	switch p {
+	case EdgeDropOldest:
+		return client.EdgeDropOldest
+	case EdgeDropNewest:
+		return client.EdgeDropNewest
+	case EdgeSpillToDisk:
+		return client.EdgeSpillToDisk
+	default:
+		return client.EdgeBlock
+	}
+}
+
+// setEdgePolicies sets the edge policies of the task from the options, if present.
+func setEdgePolicies(task *Task, p *client.EdgePolicies) error {
+	if p == nil {
+		return nil
+	}
+	policies := EdgePolicies{}
+	var err error
+	if policies.Default, err = newEdgePolicy(p.Default); err != nil {
+		return err
+	}
+	if len(p.Nodes) > 0 {
+		policies.Nodes = make(map[string]EdgePolicy, len(p.Nodes))
+		for node, policy := range p.Nodes {
+			if node == "" {
+				return errors.New("edge policy node name cannot be empty")
+			}
+			if policies.Nodes[node], err = newEdgePolicy(policy); err != nil {
+				return err
+			}
+		}
+	}
+	task.EdgePolicies = policies
+	return nil
+}
+
+// validateEdgePolicies checks that the edge policies of the task only name nodes of its pipeline.
+func validateEdgePolicies(t *kapacitor.Task) error {
+	if len(t.EdgePolicies.Nodes) == 0 {
+		return nil
+	}
+	names := make(map[string]bool)
+	_ = t.Pipeline.Walk(func(n pipeline.Node) error {
+		names[n.Name()] = true
+		return nil
+	})
+	nodes := make([]string, 0, len(t.EdgePolicies.Nodes))
+	for node := range t.EdgePolicies.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if !names[node] {
+			return fmt.Errorf("edge policy of unknown node %q, the task has no such node", node)
+		}
+	}
+	return nil
+}
+
+func newEdgePolicy(p client.EdgePolicy) (EdgePolicy, error) {
+	switch p {
+	case client.EdgeBlock:
+		return EdgeBlock, nil
+	case client.EdgeDropOldest:
+		return EdgeDropOldest, nil
+	case client.EdgeDropNewest:
+		return EdgeDropNewest, nil
+	case client.EdgeSpillToDisk:
+		return EdgeSpillToDisk, nil
+	default:
+		return 0, fmt.Errorf("invalid edge policy %v", p)
+	}
+}
+
 func (ts *Service) convertToServiceVar(cvar client.Var) (Var, error) {
 	v := cvar.Value
 	var typ VarType
@@ -1540,7 +1867,12 @@ func (ts *Service) templateLink(id string) client.Link {
 
 func (ts *Service) handleListTemplates(w http.ResponseWriter, r *http.Request) {
 
-	pattern := r.URL.Query().Get("pattern")
+	ns := r.URL.Query().Get("namespace")
+	if err := namespace.Validate(ns); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	pattern := namespace.Pattern(ns, r.URL.Query().Get("pattern"))
 	fields := r.URL.Query()["fields"]
 	if len(fields) == 0 {
 		fields = allTemplateFields
@@ -1661,10 +1993,25 @@ func (ts *Service) handleCreateTemplate(w http.ResponseWriter, r *http.Request)
 	if template.ID == "" {
 		template.ID = uuid.New().String()
 	}
-	if !validTemplateID.MatchString(template.ID) {
+	// Templates created for a namespace are created within the namespace.
+	ns := r.URL.Query().Get("namespace")
+	if err := namespace.Validate(ns); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	template.ID, err = namespace.Qualify(ns, template.ID)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if !namespace.ValidID(template.ID, validTemplateID) {
 		httpd.HttpError(w, fmt.Sprintf("template ID must contain only letters, numbers, '-', '.' and '_'. %q", template.ID), true, http.StatusBadRequest)
 		return
 	}
+	if err := ts.CheckNamespaceDBRPs(namespace.Of(template.ID), nil); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
 
 	newTemplate := Template{
 		ID: template.ID,
@@ -1753,7 +2100,21 @@ func (ts *Service) handleUpdateTemplate(w http.ResponseWriter, r *http.Request)
 
 	// Set ID
 	if template.ID != "" {
-		updated.ID = template.ID
+		// Templates cannot be moved to a different namespace.
+		ns := namespace.Of(original.ID)
+		id, err := namespace.Qualify(ns, template.ID)
+		if err == nil && namespace.Of(id) != ns {
+			err = fmt.Errorf("template %s cannot be moved to namespace %q", original.ID, namespace.Of(id))
+		}
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+		if !namespace.ValidID(id, validTemplateID) {
+			httpd.HttpError(w, fmt.Sprintf("template ID must contain only letters, numbers, '-', '.' and '_'. %q", id), true, http.StatusBadRequest)
+			return
+		}
+		updated.ID = id
 	}
 
 	// Set template type
@@ -1909,6 +2270,9 @@ func (ts *Service) updateAllAssociatedTasks(old, new Template, taskIds []string)
 			}
 		}
 
+		if err := ts.checkTaskNamespace(task); err != nil {
+			return fmt.Errorf("error updating associated task %s: %s", taskId, err)
+		}
 		if err := ts.tasks.Replace(task); err != nil {
 			return fmt.Errorf("error updating associated task %s: %s", taskId, err)
 		}
@@ -1938,6 +2302,32 @@ func (ts *Service) handleDeleteTemplate(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func newKapacitorLimit(l Limit) kapacitor.Limit {
+	kl := kapacitor.Limit{Max: l.Max}
+	switch l.Policy {
+	case LimitDrop:
+		kl.Policy = kapacitor.LimitDrop
+	case LimitPause:
+		kl.Policy = kapacitor.LimitPause
+	case LimitError:
+		kl.Policy = kapacitor.LimitError
+	}
+	return kl
+}
+
+func newKapacitorEdgePolicy(p EdgePolicy) edge.Policy {
+	switch p {
+	case EdgeDropOldest:
+		return edge.DropOldest
+	case EdgeDropNewest:
+		return edge.DropNewest
+	case EdgeSpillToDisk:
+		return edge.SpillToDisk
+	default:
+		return edge.Block
+	}
+}
+
 func (ts *Service) newKapacitorTask(task Task) (*kapacitor.Task, error) {
 	dbrps := make([]kapacitor.DBRP, len(task.DBRPs))
 	for i, dbrp := range task.DBRPs {
@@ -1957,13 +2347,36 @@ func (ts *Service) newKapacitorTask(task Task) (*kapacitor.Task, error) {
 	if err != nil {
 		return nil, err
 	}
-	return ts.TaskMasterLookup.Main().NewTask(task.ID,
+	t, err := ts.TaskMasterLookup.Main().NewTask(task.ID,
 		task.TICKscript,
 		tt,
 		dbrps,
 		ts.snapshotInterval,
 		vars,
 	)
+	if err != nil {
+		return nil, err
+	}
+	t.FlightRecorder = kapacitor.FlightRecorderOptions{
+		Window:           task.FlightRecorder.Window,
+		FreezeOnCritical: task.FlightRecorder.FreezeOnCritical,
+	}
+	t.Limits = kapacitor.TaskLimits{
+		BufferedPoints: newKapacitorLimit(task.Limits.BufferedPoints),
+		Groups:         newKapacitorLimit(task.Limits.Groups),
+		EdgeQueue:      newKapacitorLimit(task.Limits.EdgeQueue),
+		CPU:            newKapacitorLimit(task.Limits.CPU),
+	}
+	t.EdgePolicies = kapacitor.EdgePolicies{
+		Default: newKapacitorEdgePolicy(task.EdgePolicies.Default),
+	}
+	if len(task.EdgePolicies.Nodes) > 0 {
+		t.EdgePolicies.Nodes = make(map[string]edge.Policy, len(task.EdgePolicies.Nodes))
+		for node, policy := range task.EdgePolicies.Nodes {
+			t.EdgePolicies.Nodes[node] = newKapacitorEdgePolicy(policy)
+		}
+	}
+	return t, nil
 }
 
 func (ts *Service) templateTask(template Template) (*kapacitor.Template, error) {
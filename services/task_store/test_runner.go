@@ -0,0 +1,479 @@
+package task_store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/influxql"
+	imodels "github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/clock"
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/influxdb"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/tick"
+	"github.com/influxdata/kapacitor/uuid"
+	"github.com/pkg/errors"
+)
+
+const tasksTestPath = tasksPath + "/test"
+
+const (
+	testOutputAlerts  = "alerts"
+	testOutputWrites  = "writes"
+	testOutputHTTPOut = "httpOut"
+)
+
+func (ts *Service) handleTestTask(w http.ResponseWriter, r *http.Request) {
+	opt := client.TestTaskOptions{}
+	dec := json.NewDecoder(r.Body)
+	err := dec.Decode(&opt)
+	if err != nil {
+		httpd.HttpError(w, "invalid JSON", true, http.StatusBadRequest)
+		return
+	}
+	if opt.TICKscript == "" {
+		httpd.HttpError(w, "must provide TICKscript", true, http.StatusBadRequest)
+		return
+	}
+	pn, err := newProgramNodeFromTickscript(opt.TICKscript)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	switch tt := taskTypeFromProgram(pn); tt {
+	case client.StreamTask:
+	case client.BatchTask:
+		httpd.HttpError(w, "only stream tasks can be tested", true, http.StatusBadRequest)
+		return
+	default:
+		httpd.HttpError(w, fmt.Sprintf("invalid task type: %v", tt), true, http.StatusBadRequest)
+		return
+	}
+
+	dbrps := dbrpsFromProgram(pn)
+	if len(dbrps) > 0 && len(opt.DBRPs) > 0 {
+		httpd.HttpError(w, "cannot specify dbrp in both implicitly and explicitly", true, http.StatusBadRequest)
+		return
+	}
+	if len(dbrps) == 0 {
+		dbrps = opt.DBRPs
+	}
+	if len(dbrps) == 0 {
+		httpd.HttpError(w, "must specify dbrp", true, http.StatusBadRequest)
+		return
+	}
+	kdbrps := make([]kapacitor.DBRP, len(dbrps))
+	for i, dbrp := range dbrps {
+		kdbrps[i] = kapacitor.DBRP{
+			Database:        dbrp.Database,
+			RetentionPolicy: dbrp.RetentionPolicy,
+		}
+	}
+
+	svars, err := ts.convertToServiceVars(opt.Vars)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	vars, err := ts.convertToTickVarsFromService(svars)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	precision := opt.Precision
+	if precision == "" {
+		precision = "n"
+	}
+	points, err := testPointsFromLineProtocol(opt.Data, precision, kdbrps[0])
+	if err != nil {
+		httpd.HttpError(w, "invalid data: "+err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	result := ts.runTaskTest(opt.TICKscript, kdbrps, vars, points)
+	if result.Error == "" {
+		result.Failures = compareTestOutputs(opt.Expected, result)
+		result.Passed = len(result.Failures) == 0
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(result, true))
+}
+
+// testPointsFromLineProtocol parses the fixture data and orders the points by time.
+// Points without a timestamp are placed at the epoch.
+func testPointsFromLineProtocol(data, precision string, dbrp kapacitor.DBRP) ([]edge.PointMessage, error) {
+	mps, err := imodels.ParsePointsWithPrecision([]byte(data), time.Unix(0, 0), precision)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]edge.PointMessage, len(mps))
+	for i, mp := range mps {
+		points[i] = edge.NewPointMessage(
+			mp.Name(),
+			dbrp.Database,
+			dbrp.RetentionPolicy,
+			models.Dimensions{},
+			models.Fields(mp.Fields()),
+			models.Tags(mp.Tags().Map()),
+			mp.Time().UTC(),
+		)
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Time().Before(points[j].Time())
+	})
+	return points, nil
+}
+
+// runTaskTest runs the task in an isolated task master using the fast replay clock.
+// Alerts and writes are captured instead of being sent to any external system,
+// tasks with nodes that otherwise act on external systems are rejected.
+func (ts *Service) runTaskTest(script string, dbrps []kapacitor.DBRP, vars map[string]tick.Var, points []edge.PointMessage) client.TestTaskResult {
+	result := client.TestTaskResult{
+		Failures: []client.TestFailure{},
+		Alerts:   []client.TestAlert{},
+		Writes:   []client.TestPoint{},
+		HTTPOut:  make(map[string]*influxql.Result),
+	}
+
+	id := "test-" + uuid.New().String()
+	recorder := newTestRecorder()
+
+	tm := ts.TaskMasterLookup.Main().New(id)
+	tm.AlertService = testAlertService{}
+	tm.AlertEventRecorder = recorder
+	tm.InfluxDBService = recorder
+
+	err := func() error {
+		if err := tm.Open(); err != nil {
+			return errors.Wrap(err, "task master open")
+		}
+		defer tm.Close()
+
+		task, err := tm.NewTask(id, script, kapacitor.StreamTask, dbrps, 0, vars)
+		if err != nil {
+			return errors.Wrap(err, "invalid TICKscript")
+		}
+		if err := task.Pipeline.Walk(validateTestNode); err != nil {
+			return err
+		}
+		et, err := tm.StartTask(task)
+		if err != nil {
+			return errors.Wrap(err, "task start")
+		}
+		// This will force the task to stop or do nothing if it already stopped.
+		defer tm.StopTasks()
+
+		stream, err := tm.Stream(id)
+		if err != nil {
+			return errors.Wrap(err, "stream start")
+		}
+		source := make(chan edge.PointMessage)
+		go func() {
+			defer close(source)
+			for _, p := range points {
+				source <- p
+			}
+		}()
+		if err := <-kapacitor.ReplayStreamFromChan(clock.Fast(), source, stream, true); err != nil {
+			return errors.Wrap(err, "running replay")
+		}
+
+		// Drain tm so the task can finish
+		tm.Drain()
+		et.StopStats()
+		if err := et.Wait(); err != nil {
+			return errors.Wrap(err, "task run")
+		}
+
+		_ = task.Pipeline.Walk(func(n pipeline.Node) error {
+			hn, ok := n.(*pipeline.HTTPOutNode)
+			if !ok {
+				return nil
+			}
+			o, err := et.GetOutput(hn.Endpoint)
+			if err != nil {
+				return nil
+			}
+			if out, ok := o.(*kapacitor.HTTPOutNode); ok {
+				r, err := convertTestResult(out.Result())
+				if err != nil {
+					return err
+				}
+				result.HTTPOut[hn.Endpoint] = r
+			}
+			return nil
+		})
+
+		// Stopping the task flushes any buffered writes.
+		tm.StopTasks()
+		return nil
+	}()
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Alerts = append(result.Alerts, recorder.Alerts()...)
+	result.Writes = append(result.Writes, recorder.Writes()...)
+	return result
+}
+
+// validateTestNode returns an error for nodes that post, publish or scale,
+// since their effects cannot be captured by a test run.
+func validateTestNode(n pipeline.Node) error {
+	switch n.(type) {
+	case *pipeline.HTTPPostNode,
+		*pipeline.KafkaOutNode,
+		*pipeline.MQTTOutNode,
+		*pipeline.K8sAutoscaleNode,
+		*pipeline.SwarmAutoscaleNode,
+		*pipeline.HTTPAutoscaleNode:
+		return fmt.Errorf("cannot test task with node %s, it acts on an external system", n.Name())
+	}
+	return nil
+}
+
+func convertTestResult(r models.Result) (*influxql.Result, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	ir := &influxql.Result{}
+	if err := json.Unmarshal(b, ir); err != nil {
+		return nil, err
+	}
+	return ir, nil
+}
+
+// compareTestOutputs returns the differences between the expected and actual outputs.
+// Only the outputs present in the expectations are compared.
+func compareTestOutputs(exp client.TestTaskExpectations, result client.TestTaskResult) []client.TestFailure {
+	failures := []client.TestFailure{}
+	if exp.Alerts != nil {
+		if e, g := len(exp.Alerts), len(result.Alerts); e != g {
+			failures = append(failures, client.TestFailure{
+				Output:   testOutputAlerts,
+				Index:    -1,
+				Message:  fmt.Sprintf("unexpected number of alerts got %d exp %d", g, e),
+				Expected: e,
+				Actual:   g,
+			})
+		}
+		for i := 0; i < len(exp.Alerts) && i < len(result.Alerts); i++ {
+			if msg := compareTestAlert(exp.Alerts[i], result.Alerts[i]); msg != "" {
+				failures = append(failures, client.TestFailure{
+					Output:   testOutputAlerts,
+					Index:    i,
+					Message:  msg,
+					Expected: exp.Alerts[i],
+					Actual:   result.Alerts[i],
+				})
+			}
+		}
+	}
+	if exp.Writes != nil {
+		if e, g := len(exp.Writes), len(result.Writes); e != g {
+			failures = append(failures, client.TestFailure{
+				Output:   testOutputWrites,
+				Index:    -1,
+				Message:  fmt.Sprintf("unexpected number of writes got %d exp %d", g, e),
+				Expected: e,
+				Actual:   g,
+			})
+		}
+		for i := 0; i < len(exp.Writes) && i < len(result.Writes); i++ {
+			if !equalJSON(exp.Writes[i], result.Writes[i]) {
+				failures = append(failures, client.TestFailure{
+					Output:   testOutputWrites,
+					Index:    i,
+					Message:  "unexpected point",
+					Expected: exp.Writes[i],
+					Actual:   result.Writes[i],
+				})
+			}
+		}
+	}
+	endpoints := make([]string, 0, len(exp.HTTPOut))
+	for endpoint := range exp.HTTPOut {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		output := testOutputHTTPOut + "/" + endpoint
+		e := exp.HTTPOut[endpoint]
+		g, ok := result.HTTPOut[endpoint]
+		if !ok {
+			failures = append(failures, client.TestFailure{
+				Output:   output,
+				Index:    -1,
+				Message:  fmt.Sprintf("no httpOut node with endpoint %q", endpoint),
+				Expected: e,
+			})
+			continue
+		}
+		if !equalJSON(e, g) {
+			failures = append(failures, client.TestFailure{
+				Output:   output,
+				Index:    -1,
+				Message:  "unexpected result",
+				Expected: e,
+				Actual:   g,
+			})
+		}
+	}
+	return failures
+}
+
+func compareTestAlert(exp, got client.TestAlert) string {
+	var diffs []string
+	if exp.ID != got.ID {
+		diffs = append(diffs, fmt.Sprintf("id got %q exp %q", got.ID, exp.ID))
+	}
+	if !strings.EqualFold(exp.Level, got.Level) {
+		diffs = append(diffs, fmt.Sprintf("level got %s exp %s", got.Level, exp.Level))
+	}
+	if exp.Message != "" && exp.Message != got.Message {
+		diffs = append(diffs, fmt.Sprintf("message got %q exp %q", got.Message, exp.Message))
+	}
+	if !exp.Time.IsZero() && !exp.Time.Equal(got.Time) {
+		diffs = append(diffs, fmt.Sprintf("time got %v exp %v", got.Time, exp.Time))
+	}
+	if len(diffs) == 0 {
+		return ""
+	}
+	return "unexpected alert: " + strings.Join(diffs, ", ")
+}
+
+// equalJSON compares values by their JSON representation,
+// so that numbers and times compare equal regardless of their Go types.
+func equalJSON(a, b interface{}) bool {
+	var av, bv interface{}
+	if ab, err := json.Marshal(a); err != nil {
+		return false
+	} else if err := json.Unmarshal(ab, &av); err != nil {
+		return false
+	}
+	if bb, err := json.Marshal(b); err != nil {
+		return false
+	} else if err := json.Unmarshal(bb, &bv); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// testRecorder captures the alerts and writes of a tested task.
+type testRecorder struct {
+	mu     sync.Mutex
+	alerts []client.TestAlert
+	writes []client.TestPoint
+}
+
+func newTestRecorder() *testRecorder {
+	return &testRecorder{}
+}
+
+func (r *testRecorder) RecordAlertEvent(taskID string, event alert.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, client.TestAlert{
+		ID:      event.State.ID,
+		Message: event.State.Message,
+		Level:   event.State.Level.String(),
+		Time:    event.State.Time.UTC(),
+	})
+}
+
+func (r *testRecorder) NewNamedClient(name string) (influxdb.Client, error) {
+	return testInfluxDBClient{r: r}, nil
+}
+
+func (r *testRecorder) Alerts() []client.TestAlert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	alerts := make([]client.TestAlert, len(r.alerts))
+	copy(alerts, r.alerts)
+	return alerts
+}
+
+// Writes returns the captured points ordered by time, database,
+// retention policy, measurement and tags.
+// Buffered writes are flushed in no particular order so they must be sorted to be deterministic.
+func (r *testRecorder) Writes() []client.TestPoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	writes := make([]client.TestPoint, len(r.writes))
+	copy(writes, r.writes)
+	key := func(p client.TestPoint) string {
+		return string(imodels.MakeKey([]byte(p.Name), imodels.NewTags(p.Tags)))
+	}
+	sort.SliceStable(writes, func(i, j int) bool {
+		a, b := writes[i], writes[j]
+		switch {
+		case !a.Time.Equal(b.Time):
+			return a.Time.Before(b.Time)
+		case a.Database != b.Database:
+			return a.Database < b.Database
+		case a.RetentionPolicy != b.RetentionPolicy:
+			return a.RetentionPolicy < b.RetentionPolicy
+		default:
+			return key(a) < key(b)
+		}
+	})
+	return writes
+}
+
+type testInfluxDBClient struct {
+	r *testRecorder
+}
+
+func (c testInfluxDBClient) Ping(ctx context.Context) (time.Duration, string, error) {
+	return 0, "", nil
+}
+
+func (c testInfluxDBClient) Write(bp influxdb.BatchPoints) error {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	for _, p := range bp.Points() {
+		c.r.writes = append(c.r.writes, client.TestPoint{
+			Database:        bp.Database(),
+			RetentionPolicy: bp.RetentionPolicy(),
+			Name:            p.Name,
+			Tags:            p.Tags,
+			Fields:          p.Fields,
+			Time:            p.Time.UTC(),
+		})
+	}
+	return nil
+}
+
+func (c testInfluxDBClient) Query(q influxdb.Query) (*influxdb.Response, error) {
+	return &influxdb.Response{}, nil
+}
+
+// testAlertService keeps tested tasks from sending events to any handler or topic.
+type testAlertService struct{}
+
+func (testAlertService) RegisterAnonHandler(topic string, h alert.Handler)   {}
+func (testAlertService) DeregisterAnonHandler(topic string, h alert.Handler) {}
+func (testAlertService) Collect(event alert.Event) error                     { return nil }
+func (testAlertService) UpdateEvent(topic string, event alert.EventState) error {
+	return nil
+}
+func (testAlertService) EventState(topic, event string) (alert.EventState, bool, error) {
+	return alert.EventState{}, false, nil
+}
+func (testAlertService) CloseTopic(topic string) error   { return nil }
+func (testAlertService) DeleteTopic(topic string) error  { return nil }
+func (testAlertService) RestoreTopic(topic string) error { return nil }
@@ -0,0 +1,34 @@
+package userauth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Config struct {
+	// Enabled authenticates users against the users and roles stored by Kapacitor.
+	// Otherwise the noauth service is used, which treats every user as an admin.
+	Enabled bool `toml:"enabled"`
+	// Cost of the bcrypt password hashes.
+	BcryptCost int `toml:"bcrypt-cost"`
+	// Admin user to create when no users exist.
+	BootstrapUsername string `toml:"bootstrap-username"`
+	BootstrapPassword string `toml:"bootstrap-password"`
+}
+
+func NewConfig() Config {
+	return Config{
+		BcryptCost: bcrypt.DefaultCost,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt-cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.BcryptCost)
+	}
+	if (c.BootstrapUsername == "") != (c.BootstrapPassword == "") {
+		return fmt.Errorf("must set both bootstrap-username and bootstrap-password")
+	}
+	return nil
+}
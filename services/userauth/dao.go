@@ -0,0 +1,481 @@
+package userauth
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/influxdata/kapacitor/auth"
+	"github.com/influxdata/kapacitor/services/storage"
+)
+
+var (
+	ErrUserExists                = errors.New("user already exists")
+	ErrNoUserExists              = errors.New("no user exists")
+	ErrRoleExists                = errors.New("role already exists")
+	ErrNoRoleExists              = errors.New("no role exists")
+	ErrNoSubscriptionGrantExists = errors.New("no subscription grant exists")
+	ErrNoAPITokenExists          = errors.New("no API token exists")
+)
+
+// Data access object for User data.
+type UserDAO interface {
+	// Retrieve a user
+	Get(name string) (User, error)
+
+	// Create a user.
+	// ErrUserExists is returned if a user already exists with the same name.
+	Create(u User) error
+
+	// Replace an existing user.
+	// ErrNoUserExists is returned if the user does not exist.
+	Replace(u User) error
+
+	// Delete a user.
+	// It is not an error to delete an non-existent user.
+	Delete(name string) error
+
+	// List users matching a pattern.
+	// The pattern is shell/glob matching see https://golang.org/pkg/path/#Match
+	// Offset and limit are pagination bounds. Offset is inclusive starting at index 0.
+	// More results may exist while the number of returned items is equal to limit.
+	List(pattern string, offset, limit int) ([]User, error)
+
+	Rebuild() error
+}
+
+// Data access object for Role data.
+type RoleDAO interface {
+	// Retrieve a role
+	Get(name string) (Role, error)
+
+	// Create a role.
+	// ErrRoleExists is returned if a role already exists with the same name.
+	Create(r Role) error
+
+	// Replace an existing role.
+	// ErrNoRoleExists is returned if the role does not exist.
+	Replace(r Role) error
+
+	// Delete a role.
+	// It is not an error to delete an non-existent role.
+	Delete(name string) error
+
+	// List roles matching a pattern.
+	// The pattern is shell/glob matching see https://golang.org/pkg/path/#Match
+	// Offset and limit are pagination bounds. Offset is inclusive starting at index 0.
+	// More results may exist while the number of returned items is equal to limit.
+	List(pattern string, offset, limit int) ([]Role, error)
+
+	Rebuild() error
+}
+
+// Data access object for the write access granted to InfluxDB subscriptions.
+type SubscriptionGrantDAO interface {
+	// Retrieve the grant of a token
+	Get(token string) (SubscriptionGrant, error)
+
+	// Set the grant of a token.
+	// If it does not already exist it will be created,
+	// otherwise it will be replaced.
+	Set(g SubscriptionGrant) error
+
+	// Delete the grant of a token.
+	// It is not an error to delete an non-existent grant.
+	Delete(token string) error
+
+	// List all grants.
+	List() ([]SubscriptionGrant, error)
+
+	Rebuild() error
+}
+
+// Data access object for APIToken data.
+type APITokenDAO interface {
+	// Retrieve a token
+	Get(id string) (APIToken, error)
+
+	// Create a token.
+	Create(t APIToken) error
+
+	// Delete a token.
+	// It is not an error to delete an non-existent token.
+	Delete(id string) error
+
+	// List all tokens.
+	List() ([]APIToken, error)
+
+	Rebuild() error
+}
+
+//--------------------------------------------------------------------
+// The following structures are stored in a database via JSON encoding.
+// Changes to the structures could break existing data.
+//
+// Many of these structures are exact copies of structures found elsewhere,
+// this is intentional so that all structures stored in the database are
+// defined here and nowhere else. So as to not accidentally change
+// the JSON serialization format in incompatible ways.
+
+// version is the current version of the stored structures.
+const version = 1
+
+type User struct {
+	// Unique name of the user
+	Name string `json:"name"`
+	// Bcrypt hash of the user's password
+	Hash []byte `json:"hash"`
+	// Admin users have all privileges on all resources.
+	Admin bool `json:"admin"`
+	// Names of the roles granted to the user
+	Roles []string `json:"roles"`
+}
+
+func (u User) ObjectID() string {
+	return u.Name
+}
+
+func (u User) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, u)
+}
+
+func (u *User) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(u)
+	})
+}
+
+type Role struct {
+	// Unique name of the role
+	Name string `json:"name"`
+	// Map of resource path to a bitmask of the privileges granted on the resource.
+	Permissions map[string]auth.Privilege `json:"permissions"`
+}
+
+func (r Role) ObjectID() string {
+	return r.Name
+}
+
+func (r Role) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, r)
+}
+
+func (r *Role) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(r)
+	})
+}
+
+// SubscriptionGrant is the write access granted to the token of an InfluxDB subscription.
+type SubscriptionGrant struct {
+	Token           string `json:"token"`
+	Database        string `json:"database"`
+	RetentionPolicy string `json:"retention-policy"`
+}
+
+func (g SubscriptionGrant) ObjectID() string {
+	return g.Token
+}
+
+func (g SubscriptionGrant) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, g)
+}
+
+func (g *SubscriptionGrant) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(g)
+	})
+}
+
+// APIToken is a long-lived credential of a user, optionally restricted to a set of resource scopes.
+type APIToken struct {
+	// Unique public ID of the token
+	ID string `json:"id"`
+	// SHA-256 hash of the secret part of the token
+	Hash []byte `json:"hash"`
+	// Name of the user the token authenticates as
+	Username    string    `json:"username"`
+	Description string    `json:"description"`
+	Scopes      []string  `json:"scopes"`
+	Created     time.Time `json:"created"`
+	// Zero if the token does not expire
+	Expires time.Time `json:"expires"`
+}
+
+func (t APIToken) ObjectID() string {
+	return t.ID
+}
+
+func (t APIToken) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, t)
+}
+
+func (t *APIToken) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(t)
+	})
+}
+
+// Key/Value store based implementation of the UserDAO
+type userKV struct {
+	store *storage.IndexedStore
+}
+
+func newUserKV(store storage.Interface) (*userKV, error) {
+	c := storage.DefaultIndexedStoreConfig("users", func() storage.BinaryObject {
+		return new(User)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &userKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *userKV) error(err error) error {
+	if err == storage.ErrObjectExists {
+		return ErrUserExists
+	} else if err == storage.ErrNoObjectExists {
+		return ErrNoUserExists
+	}
+	return err
+}
+
+func (kv *userKV) Get(name string) (User, error) {
+	o, err := kv.store.Get(name)
+	if err != nil {
+		return User{}, kv.error(err)
+	}
+	u, ok := o.(*User)
+	if !ok {
+		return User{}, storage.ImpossibleTypeErr(u, o)
+	}
+	return *u, nil
+}
+
+func (kv *userKV) Create(u User) error {
+	return kv.error(kv.store.Create(&u))
+}
+
+func (kv *userKV) Replace(u User) error {
+	return kv.error(kv.store.Replace(&u))
+}
+
+func (kv *userKV) Delete(name string) error {
+	return kv.store.Delete(name)
+}
+
+func (kv *userKV) List(pattern string, offset, limit int) ([]User, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, pattern, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, len(objects))
+	for i, o := range objects {
+		u, ok := o.(*User)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(u, o)
+		}
+		users[i] = *u
+	}
+	return users, nil
+}
+
+func (kv *userKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
+
+// Key/Value store based implementation of the RoleDAO
+type roleKV struct {
+	store *storage.IndexedStore
+}
+
+func newRoleKV(store storage.Interface) (*roleKV, error) {
+	c := storage.DefaultIndexedStoreConfig("roles", func() storage.BinaryObject {
+		return new(Role)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &roleKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *roleKV) error(err error) error {
+	if err == storage.ErrObjectExists {
+		return ErrRoleExists
+	} else if err == storage.ErrNoObjectExists {
+		return ErrNoRoleExists
+	}
+	return err
+}
+
+func (kv *roleKV) Get(name string) (Role, error) {
+	o, err := kv.store.Get(name)
+	if err != nil {
+		return Role{}, kv.error(err)
+	}
+	r, ok := o.(*Role)
+	if !ok {
+		return Role{}, storage.ImpossibleTypeErr(r, o)
+	}
+	return *r, nil
+}
+
+func (kv *roleKV) Create(r Role) error {
+	return kv.error(kv.store.Create(&r))
+}
+
+func (kv *roleKV) Replace(r Role) error {
+	return kv.error(kv.store.Replace(&r))
+}
+
+func (kv *roleKV) Delete(name string) error {
+	return kv.store.Delete(name)
+}
+
+func (kv *roleKV) List(pattern string, offset, limit int) ([]Role, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, pattern, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]Role, len(objects))
+	for i, o := range objects {
+		r, ok := o.(*Role)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(r, o)
+		}
+		roles[i] = *r
+	}
+	return roles, nil
+}
+
+func (kv *roleKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
+
+// Key/Value store based implementation of the SubscriptionGrantDAO
+type subscriptionGrantKV struct {
+	store *storage.IndexedStore
+}
+
+func newSubscriptionGrantKV(store storage.Interface) (*subscriptionGrantKV, error) {
+	c := storage.DefaultIndexedStoreConfig("subscription_grants", func() storage.BinaryObject {
+		return new(SubscriptionGrant)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &subscriptionGrantKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *subscriptionGrantKV) Get(token string) (SubscriptionGrant, error) {
+	o, err := kv.store.Get(token)
+	if err == storage.ErrNoObjectExists {
+		return SubscriptionGrant{}, ErrNoSubscriptionGrantExists
+	}
+	if err != nil {
+		return SubscriptionGrant{}, err
+	}
+	g, ok := o.(*SubscriptionGrant)
+	if !ok {
+		return SubscriptionGrant{}, storage.ImpossibleTypeErr(g, o)
+	}
+	return *g, nil
+}
+
+func (kv *subscriptionGrantKV) Set(g SubscriptionGrant) error {
+	return kv.store.Put(&g)
+}
+
+func (kv *subscriptionGrantKV) Delete(token string) error {
+	return kv.store.Delete(token)
+}
+
+func (kv *subscriptionGrantKV) List() ([]SubscriptionGrant, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, "", 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	grants := make([]SubscriptionGrant, len(objects))
+	for i, o := range objects {
+		g, ok := o.(*SubscriptionGrant)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(g, o)
+		}
+		grants[i] = *g
+	}
+	return grants, nil
+}
+
+func (kv *subscriptionGrantKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
+
+// Key/Value store based implementation of the APITokenDAO
+type apiTokenKV struct {
+	store *storage.IndexedStore
+}
+
+func newAPITokenKV(store storage.Interface) (*apiTokenKV, error) {
+	c := storage.DefaultIndexedStoreConfig("api_tokens", func() storage.BinaryObject {
+		return new(APIToken)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &apiTokenKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *apiTokenKV) Get(id string) (APIToken, error) {
+	o, err := kv.store.Get(id)
+	if err == storage.ErrNoObjectExists {
+		return APIToken{}, ErrNoAPITokenExists
+	}
+	if err != nil {
+		return APIToken{}, err
+	}
+	t, ok := o.(*APIToken)
+	if !ok {
+		return APIToken{}, storage.ImpossibleTypeErr(t, o)
+	}
+	return *t, nil
+}
+
+func (kv *apiTokenKV) Create(t APIToken) error {
+	return kv.store.Create(&t)
+}
+
+func (kv *apiTokenKV) Delete(id string) error {
+	return kv.store.Delete(id)
+}
+
+func (kv *apiTokenKV) List() ([]APIToken, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, "", 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]APIToken, len(objects))
+	for i, o := range objects {
+		t, ok := o.(*APIToken)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(t, o)
+		}
+		tokens[i] = *t
+	}
+	return tokens, nil
+}
+
+func (kv *apiTokenKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
@@ -0,0 +1,758 @@
+package userauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/storage"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	usersPath             = "/users"
+	usersPathAnchored     = "/users/"
+	usersBasePathAnchored = httpd.BasePath + usersPathAnchored
+
+	rolesPath             = "/roles"
+	rolesPathAnchored     = "/roles/"
+	rolesBasePathAnchored = httpd.BasePath + rolesPathAnchored
+
+	// Public names of the stores
+	usersAPIName              = "users"
+	rolesAPIName              = "roles"
+	subscriptionGrantsAPIName = "subscription-grants"
+	// The storage namespace for all auth data.
+	authNamespace = "auth"
+)
+
+var validName = regexp.MustCompile(`^[-\._\p{L}0-9]+$`)
+
+var errAuthenticationFailed = errors.New("authentication failed")
+
+type Diagnostic interface {
+	Error(msg string, err error)
+	CreatedBootstrapUser(username string)
+}
+
+// Service authenticates users against the users and roles in storage.
+// The privileges of a user are the union of the privileges of all its roles.
+type Service struct {
+	bcryptCost        int
+	bootstrapUsername string
+	bootstrapPassword string
+
+	users  UserDAO
+	roles  RoleDAO
+	grants SubscriptionGrantDAO
+	tokens APITokenDAO
+
+	routes []httpd.Route
+
+	diag Diagnostic
+
+	StorageService interface {
+		Store(namespace string) storage.Interface
+		Register(name string, store storage.StoreActioner)
+	}
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
+}
+
+func NewService(c Config, d Diagnostic) *Service {
+	return &Service{
+		bcryptCost:        c.BcryptCost,
+		bootstrapUsername: c.BootstrapUsername,
+		bootstrapPassword: c.BootstrapPassword,
+		diag:              d,
+	}
+}
+
+func (s *Service) Open() error {
+	store := s.StorageService.Store(authNamespace)
+	users, err := newUserKV(store)
+	if err != nil {
+		return err
+	}
+	s.users = users
+	s.StorageService.Register(usersAPIName, s.users)
+
+	roles, err := newRoleKV(store)
+	if err != nil {
+		return err
+	}
+	s.roles = roles
+	s.StorageService.Register(rolesAPIName, s.roles)
+
+	grants, err := newSubscriptionGrantKV(store)
+	if err != nil {
+		return err
+	}
+	s.grants = grants
+	s.StorageService.Register(subscriptionGrantsAPIName, s.grants)
+
+	tokens, err := newAPITokenKV(store)
+	if err != nil {
+		return err
+	}
+	s.tokens = tokens
+	s.StorageService.Register(apiTokensAPIName, s.tokens)
+
+	if err := s.bootstrap(); err != nil {
+		return errors.Wrap(err, "failed to create bootstrap user")
+	}
+
+	// Define API routes
+	s.routes = []httpd.Route{
+		{
+			Method:      "GET",
+			Pattern:     usersPath,
+			HandlerFunc: s.handleListUsers,
+		},
+		{
+			Method:      "POST",
+			Pattern:     usersPath,
+			HandlerFunc: s.handleCreateUser,
+		},
+		{
+			Method:      "GET",
+			Pattern:     usersPathAnchored,
+			HandlerFunc: s.handleUser,
+		},
+		{
+			Method:      "PATCH",
+			Pattern:     usersPathAnchored,
+			HandlerFunc: s.handleUpdateUser,
+		},
+		{
+			Method:      "DELETE",
+			Pattern:     usersPathAnchored,
+			HandlerFunc: s.handleDeleteUser,
+		},
+		{
+			Method:      "GET",
+			Pattern:     rolesPath,
+			HandlerFunc: s.handleListRoles,
+		},
+		{
+			Method:      "POST",
+			Pattern:     rolesPath,
+			HandlerFunc: s.handleCreateRole,
+		},
+		{
+			Method:      "GET",
+			Pattern:     rolesPathAnchored,
+			HandlerFunc: s.handleRole,
+		},
+		{
+			Method:      "PATCH",
+			Pattern:     rolesPathAnchored,
+			HandlerFunc: s.handleUpdateRole,
+		},
+		{
+			Method:      "DELETE",
+			Pattern:     rolesPathAnchored,
+			HandlerFunc: s.handleDeleteRole,
+		},
+		{
+			Method:      "GET",
+			Pattern:     apiTokensPath,
+			HandlerFunc: s.handleListAPITokens,
+		},
+		{
+			Method:      "POST",
+			Pattern:     apiTokensPath,
+			HandlerFunc: s.handleCreateAPIToken,
+		},
+		{
+			Method:      "GET",
+			Pattern:     apiTokensPathAnchored,
+			HandlerFunc: s.handleAPIToken,
+		},
+		{
+			Method:      "DELETE",
+			Pattern:     apiTokensPathAnchored,
+			HandlerFunc: s.handleDeleteAPIToken,
+		},
+	}
+
+	err = s.HTTPDService.AddRoutes(s.routes)
+	return errors.Wrap(err, "failed to add API routes")
+}
+
+func (s *Service) Close() error {
+	s.HTTPDService.DelRoutes(s.routes)
+	return nil
+}
+
+// bootstrap creates the configured admin user if no users exist yet.
+func (s *Service) bootstrap() error {
+	if s.bootstrapUsername == "" {
+		return nil
+	}
+	users, err := s.users.List("", 0, 1)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.bootstrapPassword), s.bcryptCost)
+	if err != nil {
+		return err
+	}
+	if err := s.users.Create(User{
+		Name:  s.bootstrapUsername,
+		Hash:  hash,
+		Admin: true,
+	}); err != nil {
+		return err
+	}
+	s.diag.CreatedBootstrapUser(s.bootstrapUsername)
+	return nil
+}
+
+// Authenticate the user by comparing the password to the stored password hash.
+func (s *Service) Authenticate(username, password string) (auth.User, error) {
+	u, err := s.users.Get(username)
+	if err == ErrNoUserExists {
+		return auth.User{}, errAuthenticationFailed
+	}
+	if err != nil {
+		return auth.User{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword(u.Hash, []byte(password)); err != nil {
+		return auth.User{}, errAuthenticationFailed
+	}
+	return s.authUser(u)
+}
+
+// Return the user with the privileges of all its roles.
+func (s *Service) User(username string) (auth.User, error) {
+	u, err := s.users.Get(username)
+	if err == ErrNoUserExists {
+		return auth.User{}, fmt.Errorf("unknown user %q", username)
+	}
+	if err != nil {
+		return auth.User{}, err
+	}
+	return s.authUser(u)
+}
+
+// authUser converts a stored user into an auth.User.
+// Roles that no longer exist grant no privileges.
+func (s *Service) authUser(u User) (auth.User, error) {
+	privileges := make(map[string][]auth.Privilege)
+	for _, name := range u.Roles {
+		r, err := s.roles.Get(name)
+		if err == ErrNoRoleExists {
+			continue
+		}
+		if err != nil {
+			return auth.User{}, err
+		}
+		for resource, mask := range r.Permissions {
+			privileges[resource] = append(privileges[resource], privilegeList(mask)...)
+		}
+	}
+	return auth.NewUser(u.Name, u.Hash, u.Admin, privileges), nil
+}
+
+// Return a user that may only write to the database of the subscription the token was granted to.
+func (s *Service) SubscriptionUser(token string) (auth.User, error) {
+	g, err := s.grants.Get(token)
+	if err == ErrNoSubscriptionGrantExists {
+		return auth.User{}, errors.New("invalid subscription token")
+	}
+	if err != nil {
+		return auth.User{}, err
+	}
+	return auth.NewUser(httpd.SubscriptionUser, nil, false, map[string][]auth.Privilege{
+		auth.APIResource("/write"):        {auth.WritePrivilege},
+		auth.DatabaseResource(g.Database): {auth.WritePrivilege},
+	}), nil
+}
+
+func (s *Service) GrantSubscriptionAccess(token, db, rp string) error {
+	return s.grants.Set(SubscriptionGrant{
+		Token:           token,
+		Database:        db,
+		RetentionPolicy: rp,
+	})
+}
+
+func (s *Service) ListSubscriptionTokens() ([]string, error) {
+	grants, err := s.grants.List()
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]string, len(grants))
+	for i, g := range grants {
+		tokens[i] = g.Token
+	}
+	return tokens, nil
+}
+
+func (s *Service) RevokeSubscriptionAccess(token string) error {
+	return s.grants.Delete(token)
+}
+
+// privilegeList splits a bitmask into its individual privileges.
+func privilegeList(mask auth.Privilege) []auth.Privilege {
+	var privileges []auth.Privilege
+	for _, p := range auth.PrivilegeList {
+		if mask&p != 0 {
+			privileges = append(privileges, p)
+		}
+	}
+	return privileges
+}
+
+func convertPrivilegeFromClient(p client.Privilege) (auth.Privilege, error) {
+	switch p {
+	case client.NoPrivileges:
+		return auth.NoPrivileges, nil
+	case client.ReadPrivilege:
+		return auth.ReadPrivilege, nil
+	case client.WritePrivilege:
+		return auth.WritePrivilege, nil
+	case client.DeletePrivilege:
+		return auth.DeletePrivilege, nil
+	case client.AllPrivileges:
+		return auth.AllPrivileges, nil
+	default:
+		return 0, fmt.Errorf("unknown privilege %v", p)
+	}
+}
+
+func convertPrivilegeToClient(p auth.Privilege) client.Privilege {
+	switch p {
+	case auth.ReadPrivilege:
+		return client.ReadPrivilege
+	case auth.WritePrivilege:
+		return client.WritePrivilege
+	case auth.DeletePrivilege:
+		return client.DeletePrivilege
+	case auth.AllPrivileges:
+		return client.AllPrivileges
+	default:
+		return client.NoPrivileges
+	}
+}
+
+// convertPermissions validates the resource paths and converts the privileges of each resource to a bitmask.
+func convertPermissions(permissions map[string][]client.Privilege) (map[string]auth.Privilege, error) {
+	converted := make(map[string]auth.Privilege, len(permissions))
+	for resource, privileges := range permissions {
+		if !path.IsAbs(resource) {
+			return nil, fmt.Errorf("invalid resource %q, must be an absolute path", resource)
+		}
+		if len(privileges) == 0 {
+			return nil, fmt.Errorf("must provide privileges for resource %q", resource)
+		}
+		mask := converted[path.Clean(resource)]
+		for _, p := range privileges {
+			ap, err := convertPrivilegeFromClient(p)
+			if err != nil {
+				return nil, err
+			}
+			mask |= ap
+		}
+		converted[path.Clean(resource)] = mask
+	}
+	return converted, nil
+}
+
+func (s *Service) userLink(name string) client.Link {
+	return client.Link{Relation: client.Self, Href: path.Join(httpd.BasePath, usersPath, name)}
+}
+
+func (s *Service) roleLink(name string) client.Link {
+	return client.Link{Relation: client.Self, Href: path.Join(httpd.BasePath, rolesPath, name)}
+}
+
+func (s *Service) convertUser(u User) client.User {
+	roles := u.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return client.User{
+		Link:  s.userLink(u.Name),
+		Name:  u.Name,
+		Admin: u.Admin,
+		Roles: roles,
+	}
+}
+
+func (s *Service) convertRole(r Role) client.Role {
+	permissions := make(map[string][]client.Privilege, len(r.Permissions))
+	for resource, mask := range r.Permissions {
+		for _, p := range privilegeList(mask) {
+			permissions[resource] = append(permissions[resource], convertPrivilegeToClient(p))
+		}
+	}
+	return client.Role{
+		Link:        s.roleLink(r.Name),
+		Name:        r.Name,
+		Permissions: permissions,
+	}
+}
+
+// nameFromPath returns the user or role name from the request path.
+func nameFromPath(p, basePathAnchored, kind string) (string, error) {
+	if len(p) <= len(basePathAnchored) {
+		return "", fmt.Errorf("must specify %s name on path", kind)
+	}
+	return p[len(basePathAnchored):], nil
+}
+
+// requireAdmin writes a forbidden error and returns false if the user is not an admin.
+// Only admins may manage users and roles, since doing so can grant any privilege.
+func requireAdmin(w http.ResponseWriter, user auth.User) bool {
+	if !user.IsAdmin() {
+		httpd.HttpError(w, fmt.Sprintf("user %s must be an admin to manage users and roles", user.Name()), true, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// paginationFromQuery parses the pattern, offset and limit list parameters.
+func paginationFromQuery(r *http.Request) (pattern string, offset, limit int, err error) {
+	pattern = r.URL.Query().Get("pattern")
+	offset = 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		o, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("invalid offset parameter %q must be an integer: %s", offsetStr, err)
+		}
+		offset = int(o)
+	}
+	limit = 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("invalid limit parameter %q must be an integer: %s", limitStr, err)
+		}
+		limit = int(l)
+	}
+	return
+}
+
+// checkRoles returns an error if any of the roles does not exist.
+func (s *Service) checkRoles(roles []string) error {
+	for _, name := range roles {
+		if _, err := s.roles.Get(name); err == ErrNoRoleExists {
+			return fmt.Errorf("unknown role %q", name)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	pattern, offset, limit, err := paginationFromQuery(r)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	users, err := s.users.List(pattern, offset, limit)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	type response struct {
+		Users []client.User `json:"users"`
+	}
+	resp := response{Users: make([]client.User, len(users))}
+	for i, u := range users {
+		resp.Users[i] = s.convertUser(u)
+	}
+	w.Write(httpd.MarshalJSON(resp, true))
+}
+
+func (s *Service) handleUser(w http.ResponseWriter, r *http.Request) {
+	name, err := nameFromPath(r.URL.Path, usersBasePathAnchored, "user")
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	u, err := s.users.Get(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return
+	}
+	w.Write(httpd.MarshalJSON(s.convertUser(u), true))
+}
+
+func (s *Service) handleCreateUser(w http.ResponseWriter, r *http.Request, user auth.User) {
+	if !requireAdmin(w, user) {
+		return
+	}
+	opt := client.CreateUserOptions{}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&opt); err != nil {
+		httpd.HttpError(w, "invalid JSON", true, http.StatusBadRequest)
+		return
+	}
+	if !validName.MatchString(opt.Name) {
+		httpd.HttpError(w, fmt.Sprintf("user name must contain only letters, numbers, '-', '.' and '_'. %q", opt.Name), true, http.StatusBadRequest)
+		return
+	}
+	if opt.Password == "" {
+		httpd.HttpError(w, "must provide a password", true, http.StatusBadRequest)
+		return
+	}
+	if err := s.checkRoles(opt.Roles); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(opt.Password), s.bcryptCost)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	u := User{
+		Name:  opt.Name,
+		Hash:  hash,
+		Admin: opt.Admin,
+		Roles: opt.Roles,
+	}
+	if err := s.users.Create(u); err == ErrUserExists {
+		httpd.HttpError(w, fmt.Sprintf("user %s already exists", u.Name), true, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.Write(httpd.MarshalJSON(s.convertUser(u), true))
+}
+
+func (s *Service) handleUpdateUser(w http.ResponseWriter, r *http.Request, user auth.User) {
+	if !requireAdmin(w, user) {
+		return
+	}
+	name, err := nameFromPath(r.URL.Path, usersBasePathAnchored, "user")
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	opt := client.UpdateUserOptions{}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&opt); err != nil {
+		httpd.HttpError(w, "invalid JSON", true, http.StatusBadRequest)
+		return
+	}
+	u, err := s.users.Get(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return
+	}
+	if opt.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opt.Password), s.bcryptCost)
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+			return
+		}
+		u.Hash = hash
+	}
+	if opt.Admin != nil {
+		u.Admin = *opt.Admin
+	}
+	if opt.Roles != nil {
+		if err := s.checkRoles(*opt.Roles); err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+		u.Roles = *opt.Roles
+	}
+	if err := s.users.Replace(u); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.Write(httpd.MarshalJSON(s.convertUser(u), true))
+}
+
+func (s *Service) handleDeleteUser(w http.ResponseWriter, r *http.Request, user auth.User) {
+	if !requireAdmin(w, user) {
+		return
+	}
+	name, err := nameFromPath(r.URL.Path, usersBasePathAnchored, "user")
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if err := s.deleteUserAPITokens(name); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	if err := s.users.Delete(name); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) handleListRoles(w http.ResponseWriter, r *http.Request) {
+	pattern, offset, limit, err := paginationFromQuery(r)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	roles, err := s.roles.List(pattern, offset, limit)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	type response struct {
+		Roles []client.Role `json:"roles"`
+	}
+	resp := response{Roles: make([]client.Role, len(roles))}
+	for i, role := range roles {
+		resp.Roles[i] = s.convertRole(role)
+	}
+	w.Write(httpd.MarshalJSON(resp, true))
+}
+
+func (s *Service) handleRole(w http.ResponseWriter, r *http.Request) {
+	name, err := nameFromPath(r.URL.Path, rolesBasePathAnchored, "role")
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	role, err := s.roles.Get(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return
+	}
+	w.Write(httpd.MarshalJSON(s.convertRole(role), true))
+}
+
+func (s *Service) handleCreateRole(w http.ResponseWriter, r *http.Request, user auth.User) {
+	if !requireAdmin(w, user) {
+		return
+	}
+	opt := client.CreateRoleOptions{}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&opt); err != nil {
+		httpd.HttpError(w, "invalid JSON", true, http.StatusBadRequest)
+		return
+	}
+	if !validName.MatchString(opt.Name) {
+		httpd.HttpError(w, fmt.Sprintf("role name must contain only letters, numbers, '-', '.' and '_'. %q", opt.Name), true, http.StatusBadRequest)
+		return
+	}
+	permissions, err := convertPermissions(opt.Permissions)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	role := Role{
+		Name:        opt.Name,
+		Permissions: permissions,
+	}
+	if err := s.roles.Create(role); err == ErrRoleExists {
+		httpd.HttpError(w, fmt.Sprintf("role %s already exists", role.Name), true, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.Write(httpd.MarshalJSON(s.convertRole(role), true))
+}
+
+func (s *Service) handleUpdateRole(w http.ResponseWriter, r *http.Request, user auth.User) {
+	if !requireAdmin(w, user) {
+		return
+	}
+	name, err := nameFromPath(r.URL.Path, rolesBasePathAnchored, "role")
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	opt := client.UpdateRoleOptions{}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&opt); err != nil {
+		httpd.HttpError(w, "invalid JSON", true, http.StatusBadRequest)
+		return
+	}
+	role, err := s.roles.Get(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return
+	}
+	if opt.Permissions != nil {
+		permissions, err := convertPermissions(opt.Permissions)
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+		role.Permissions = permissions
+	}
+	if err := s.roles.Replace(role); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.Write(httpd.MarshalJSON(s.convertRole(role), true))
+}
+
+func (s *Service) handleDeleteRole(w http.ResponseWriter, r *http.Request, user auth.User) {
+	if !requireAdmin(w, user) {
+		return
+	}
+	name, err := nameFromPath(r.URL.Path, rolesBasePathAnchored, "role")
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	// Remove the role from its users first,
+	// so that a new role with the same name is not granted to them.
+	if err := s.removeRoleFromUsers(name); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	if err := s.roles.Delete(name); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) removeRoleFromUsers(role string) error {
+	offset := 0
+	limit := 100
+	for {
+		users, err := s.users.List("", offset, limit)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			var roles []string
+			for _, r := range u.Roles {
+				if r != role {
+					roles = append(roles, r)
+				}
+			}
+			if len(roles) == len(u.Roles) {
+				continue
+			}
+			u.Roles = roles
+			if err := s.users.Replace(u); err != nil {
+				return err
+			}
+		}
+		if len(users) != limit {
+			return nil
+		}
+		offset += limit
+	}
+}
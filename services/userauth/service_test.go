@@ -0,0 +1,287 @@
+package userauth_test
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/diagnostic"
+	"github.com/influxdata/kapacitor/services/httpd/httpdtest"
+	"github.com/influxdata/kapacitor/services/storage/storagetest"
+	"github.com/influxdata/kapacitor/services/userauth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var diagService *diagnostic.Service
+
+func init() {
+	diagService = diagnostic.NewService(diagnostic.NewConfig(), ioutil.Discard, ioutil.Discard)
+	diagService.Open()
+}
+
+func openService(t *testing.T) (*userauth.Service, *client.Client, func()) {
+	c := userauth.NewConfig()
+	c.BcryptCost = bcrypt.MinCost
+	c.BootstrapUsername = "admin"
+	c.BootstrapPassword = "admin password"
+	s := userauth.NewService(c, diagService.NewUserAuthHandler())
+	s.StorageService = storagetest.New()
+	server := httpdtest.NewServer(testing.Verbose())
+	s.HTTPDService = server
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	cli, err := client.New(client.Config{
+		URL: server.Server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, cli, func() {
+		s.Close()
+		server.Close()
+	}
+}
+
+func TestService_Authenticate(t *testing.T) {
+	s, cli, close := openService(t)
+	defer close()
+
+	if _, err := s.Authenticate("admin", "wrong password"); err == nil {
+		t.Error("expected error authenticating with the wrong password")
+	}
+	if _, err := s.Authenticate("unknown", "admin password"); err == nil {
+		t.Error("expected error authenticating unknown user")
+	}
+	admin, err := s.Authenticate("admin", "admin password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !admin.IsAdmin() {
+		t.Error("expected bootstrap user to be an admin")
+	}
+
+	if _, err := cli.CreateRole(client.CreateRoleOptions{
+		Name: "reader",
+		Permissions: map[string][]client.Privilege{
+			"/api": {client.ReadPrivilege},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.CreateRole(client.CreateRoleOptions{
+		Name: "author",
+		Permissions: map[string][]client.Privilege{
+			"/api/tasks": {client.ReadPrivilege, client.WritePrivilege},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.CreateUser(client.CreateUserOptions{
+		Name:     "bob",
+		Password: "bob password",
+		Roles:    []string{"reader", "author"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	bob, err := s.Authenticate("bob", "bob password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		action     auth.Action
+		authorized bool
+	}{
+		{
+			action:     auth.Action{Resource: "/api/templates", Privilege: auth.ReadPrivilege},
+			authorized: true,
+		},
+		{
+			action:     auth.Action{Resource: "/api/templates", Privilege: auth.WritePrivilege},
+			authorized: false,
+		},
+		{
+			action:     auth.Action{Resource: "/api/tasks/cpu", Privilege: auth.WritePrivilege},
+			authorized: true,
+		},
+		{
+			action:     auth.Action{Resource: "/api/tasks/cpu", Privilege: auth.DeletePrivilege},
+			authorized: false,
+		},
+	}
+	for _, tc := range testCases {
+		err := bob.AuthorizeAction(tc.action)
+		if got, exp := err == nil, tc.authorized; got != exp {
+			t.Errorf("unexpected authorization for %v got %t exp %t: %v", tc.action, got, exp, err)
+		}
+	}
+
+	// Deleting a role removes it from its users
+	if err := cli.DeleteRole(cli.RoleLink("author")); err != nil {
+		t.Fatal(err)
+	}
+	user, err := cli.User(cli.UserLink("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := len(user.Roles), 1; got != exp {
+		t.Fatalf("unexpected number of roles got %d exp %d", got, exp)
+	}
+	bob, err = s.User("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.AuthorizeAction(auth.Action{Resource: "/api/tasks/cpu", Privilege: auth.WritePrivilege}); err == nil {
+		t.Error("expected write to be unauthorized after deleting role")
+	}
+}
+
+func TestService_SubscriptionAccess(t *testing.T) {
+	s, _, close := openService(t)
+	defer close()
+
+	if _, err := s.SubscriptionUser("token"); err == nil {
+		t.Error("expected error for unknown subscription token")
+	}
+	if err := s.GrantSubscriptionAccess("token", "mydb", "myrp"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := s.SubscriptionUser("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.AuthorizeAction(auth.Action{Resource: auth.DatabaseResource("mydb"), Privilege: auth.WritePrivilege}); err != nil {
+		t.Error(err)
+	}
+	if err := u.AuthorizeAction(auth.Action{Resource: auth.DatabaseResource("otherdb"), Privilege: auth.WritePrivilege}); err == nil {
+		t.Error("expected write to other database to be unauthorized")
+	}
+	tokens, err := s.ListSubscriptionTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0] != "token" {
+		t.Errorf("unexpected tokens %v", tokens)
+	}
+	if err := s.RevokeSubscriptionAccess("token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SubscriptionUser("token"); err == nil {
+		t.Error("expected error for revoked subscription token")
+	}
+}
+
+func TestService_TokenUser(t *testing.T) {
+	s, cli, close := openService(t)
+	defer close()
+
+	if _, err := cli.CreateRole(client.CreateRoleOptions{
+		Name: "author",
+		Permissions: map[string][]client.Privilege{
+			"/api/tasks": {client.AllPrivileges},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.CreateUser(client.CreateUserOptions{
+		Name:     "ci",
+		Password: "ci password",
+		Roles:    []string{"author"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	token, err := cli.CreateAPIToken(client.CreateAPITokenOptions{
+		Username:    "ci",
+		Description: "team a pipeline",
+		Scopes:      []string{"/kapacitor/v1/tasks/team-a-*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := token.Scopes, []string{"/api/tasks/team-a-*"}; len(got) != 1 || got[0] != exp[0] {
+		t.Errorf("unexpected scopes got %v exp %v", got, exp)
+	}
+
+	if _, err := s.TokenUser(token.ID + ".wrong"); err == nil {
+		t.Error("expected error for wrong token secret")
+	}
+	u, err := s.TokenUser(token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := u.Name(), "ci"; got != exp {
+		t.Errorf("unexpected token user got %s exp %s", got, exp)
+	}
+	testCases := []struct {
+		action     auth.Action
+		authorized bool
+	}{
+		{
+			action:     auth.Action{Resource: "/api/tasks/team-a-cpu", Privilege: auth.WritePrivilege},
+			authorized: true,
+		},
+		{
+			action:     auth.Action{Resource: "/api/tasks/team-b-cpu", Privilege: auth.WritePrivilege},
+			authorized: false,
+		},
+		{
+			action:     auth.Action{Resource: "/api/tasks", Privilege: auth.ReadPrivilege},
+			authorized: false,
+		},
+		{
+			action:     auth.Action{Resource: "/api/templates/team-a-tmpl", Privilege: auth.ReadPrivilege},
+			authorized: false,
+		},
+	}
+	for _, tc := range testCases {
+		err := u.AuthorizeAction(tc.action)
+		if got, exp := err == nil, tc.authorized; got != exp {
+			t.Errorf("unexpected authorization for %v got %t exp %t: %v", tc.action, got, exp, err)
+		}
+	}
+
+	// Revoked tokens are invalid
+	if err := cli.DeleteAPIToken(token.Link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TokenUser(token.Token); err == nil {
+		t.Error("expected error for revoked token")
+	}
+
+	// Expired tokens are invalid
+	expired, err := cli.CreateAPIToken(client.CreateAPITokenOptions{
+		Username: "ci",
+		TTL:      client.Duration(time.Nanosecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := s.TokenUser(expired.Token); err == nil {
+		t.Error("expected error for expired token")
+	}
+
+	// Deleting a user revokes its tokens
+	unscoped, err := cli.CreateAPIToken(client.CreateAPITokenOptions{
+		Username: "ci",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TokenUser(unscoped.Token); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.DeleteUser(cli.UserLink("ci")); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := cli.ListAPITokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 0 {
+		t.Errorf("unexpected tokens after deleting user %v", tokens)
+	}
+}
@@ -0,0 +1,268 @@
+package userauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/pkg/errors"
+)
+
+const (
+	apiTokensPath             = "/tokens"
+	apiTokensPathAnchored     = "/tokens/"
+	apiTokensBasePathAnchored = httpd.BasePath + apiTokensPathAnchored
+
+	apiTokensAPIName = "api-tokens"
+
+	// Number of random bytes in the public ID and in the secret of a token.
+	apiTokenIDSize     = 8
+	apiTokenSecretSize = 32
+)
+
+var errInvalidAPIToken = errors.New("invalid API token")
+
+// TokenUser returns the owner of the API token, restricted to the scopes of the token.
+// Tokens have the form <id>.<secret>, only a hash of the secret is stored.
+func (s *Service) TokenUser(token string) (auth.User, error) {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return auth.User{}, errInvalidAPIToken
+	}
+	id, secret := token[:i], token[i+1:]
+	t, err := s.tokens.Get(id)
+	if err == ErrNoAPITokenExists {
+		return auth.User{}, errInvalidAPIToken
+	}
+	if err != nil {
+		return auth.User{}, err
+	}
+	hash := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(hash[:], t.Hash) != 1 {
+		return auth.User{}, errInvalidAPIToken
+	}
+	if !t.Expires.IsZero() && !time.Now().Before(t.Expires) {
+		return auth.User{}, errors.New("API token has expired")
+	}
+	u, err := s.users.Get(t.Username)
+	if err == ErrNoUserExists {
+		return auth.User{}, errInvalidAPIToken
+	}
+	if err != nil {
+		return auth.User{}, err
+	}
+	user, err := s.authUser(u)
+	if err != nil {
+		return auth.User{}, err
+	}
+	if len(t.Scopes) == 0 {
+		return user, nil
+	}
+	return user.WithScopes(t.Scopes), nil
+}
+
+// generateAPIToken returns a new random token ID and secret.
+func generateAPIToken() (id, secret string, err error) {
+	b := make([]byte, apiTokenIDSize+apiTokenSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	id = hex.EncodeToString(b[:apiTokenIDSize])
+	secret = base64.RawURLEncoding.EncodeToString(b[apiTokenIDSize:])
+	return id, secret, nil
+}
+
+// convertScope validates a scope and converts it to an auth resource pattern.
+// Scopes may be given either as API paths, i.e. /kapacitor/v1/tasks/team-a-*,
+// or as auth resources, i.e. /api/tasks/team-a-*.
+// API paths are converted the same way the HTTP handler converts request paths.
+func convertScope(scope string) (string, error) {
+	if !path.IsAbs(scope) {
+		return "", fmt.Errorf("invalid scope %q, must be an absolute path", scope)
+	}
+	resource := path.Clean(scope)
+	if strings.HasPrefix(resource, httpd.BasePath) {
+		resource = auth.APIResource(strings.TrimPrefix(resource, httpd.BasePath))
+	}
+	if _, err := path.Match(resource, ""); err != nil {
+		return "", fmt.Errorf("invalid scope %q: %v", scope, err)
+	}
+	return resource, nil
+}
+
+func (s *Service) apiTokenLink(id string) client.Link {
+	return client.Link{Relation: client.Self, Href: path.Join(httpd.BasePath, apiTokensPath, id)}
+}
+
+func (s *Service) convertAPIToken(t APIToken) client.APIToken {
+	scopes := t.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	ct := client.APIToken{
+		Link:        s.apiTokenLink(t.ID),
+		ID:          t.ID,
+		Username:    t.Username,
+		Description: t.Description,
+		Scopes:      scopes,
+		Created:     t.Created,
+	}
+	if !t.Expires.IsZero() {
+		expires := t.Expires
+		ct.Expires = &expires
+	}
+	return ct
+}
+
+// ownedAPIToken returns the token from the request path,
+// if it exists and the user is allowed to manage it.
+func (s *Service) ownedAPIToken(w http.ResponseWriter, r *http.Request, user auth.User) (APIToken, bool) {
+	id, err := nameFromPath(r.URL.Path, apiTokensBasePathAnchored, "token")
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return APIToken{}, false
+	}
+	t, err := s.tokens.Get(id)
+	// Do not reveal the existence of tokens of other users.
+	if err == ErrNoAPITokenExists || (err == nil && !user.IsAdmin() && t.Username != user.Name()) {
+		httpd.HttpError(w, fmt.Sprintf("no API token exists with ID %s", id), true, http.StatusNotFound)
+		return APIToken{}, false
+	}
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return APIToken{}, false
+	}
+	return t, true
+}
+
+func (s *Service) handleListAPITokens(w http.ResponseWriter, r *http.Request, user auth.User) {
+	tokens, err := s.tokens.List()
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	type response struct {
+		Tokens []client.APIToken `json:"tokens"`
+	}
+	resp := response{Tokens: make([]client.APIToken, 0, len(tokens))}
+	for _, t := range tokens {
+		if !user.IsAdmin() && t.Username != user.Name() {
+			continue
+		}
+		resp.Tokens = append(resp.Tokens, s.convertAPIToken(t))
+	}
+	w.Write(httpd.MarshalJSON(resp, true))
+}
+
+func (s *Service) handleAPIToken(w http.ResponseWriter, r *http.Request, user auth.User) {
+	t, ok := s.ownedAPIToken(w, r, user)
+	if !ok {
+		return
+	}
+	w.Write(httpd.MarshalJSON(s.convertAPIToken(t), true))
+}
+
+func (s *Service) handleCreateAPIToken(w http.ResponseWriter, r *http.Request, user auth.User) {
+	// A scoped token must not be able to mint a token with wider scopes.
+	if len(user.Scopes()) > 0 {
+		httpd.HttpError(w, "API tokens cannot be created using a scoped API token", true, http.StatusForbidden)
+		return
+	}
+	opt := client.CreateAPITokenOptions{}
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&opt); err != nil {
+		httpd.HttpError(w, "invalid JSON", true, http.StatusBadRequest)
+		return
+	}
+	if opt.Username == "" {
+		opt.Username = user.Name()
+	}
+	if opt.Username != user.Name() && !user.IsAdmin() {
+		httpd.HttpError(w, fmt.Sprintf("user %s must be an admin to create API tokens for other users", user.Name()), true, http.StatusForbidden)
+		return
+	}
+	if _, err := s.users.Get(opt.Username); err == ErrNoUserExists {
+		httpd.HttpError(w, fmt.Sprintf("unknown user %q", opt.Username), true, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	if opt.TTL < 0 {
+		httpd.HttpError(w, "ttl must not be negative", true, http.StatusBadRequest)
+		return
+	}
+	scopes := make([]string, len(opt.Scopes))
+	for i, scope := range opt.Scopes {
+		resource, err := convertScope(scope)
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+		scopes[i] = resource
+	}
+	id, secret, err := generateAPIToken()
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	hash := sha256.Sum256([]byte(secret))
+	t := APIToken{
+		ID:          id,
+		Hash:        hash[:],
+		Username:    opt.Username,
+		Description: opt.Description,
+		Scopes:      scopes,
+		Created:     time.Now().UTC(),
+	}
+	if opt.TTL > 0 {
+		t.Expires = t.Created.Add(time.Duration(opt.TTL))
+	}
+	if err := s.tokens.Create(t); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	ct := s.convertAPIToken(t)
+	ct.Token = id + "." + secret
+	w.Write(httpd.MarshalJSON(ct, true))
+}
+
+func (s *Service) handleDeleteAPIToken(w http.ResponseWriter, r *http.Request, user auth.User) {
+	t, ok := s.ownedAPIToken(w, r, user)
+	if !ok {
+		return
+	}
+	if err := s.tokens.Delete(t.ID); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteUserAPITokens revokes all tokens of the user.
+func (s *Service) deleteUserAPITokens(username string) error {
+	tokens, err := s.tokens.List()
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.Username != username {
+			continue
+		}
+		if err := s.tokens.Delete(t.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package kapacitor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/pkg/errors"
+
+	// Drivers of the databases queried by SQL query nodes.
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+const (
+	statsQueryErrors = "query_errors"
+)
+
+// Layouts of the times of rows returned as strings.
+var sqlTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+type SQLQueryNode struct {
+	node
+	s          *pipeline.SQLQueryNode
+	tagColumns map[string]bool
+	ticker     ticker
+	queryMu    sync.Mutex
+	queryErr   chan error
+	closing    chan struct{}
+	aborting   chan struct{}
+
+	batchesQueried *expvar.Int
+	pointsQueried  *expvar.Int
+	queryErrors    *expvar.Int
+}
+
+func newSQLQueryNode(et *ExecutingTask, n *pipeline.SQLQueryNode, d NodeDiagnostic) (*SQLQueryNode, error) {
+	sn := &SQLQueryNode{
+		node:       node{Node: n, et: et, diag: d},
+		s:          n,
+		tagColumns: make(map[string]bool, len(n.TagColumns)),
+		closing:    make(chan struct{}),
+		aborting:   make(chan struct{}),
+	}
+	sn.node.runF = sn.runBatch
+	sn.node.stopF = sn.stopBatch
+	for _, c := range n.TagColumns {
+		sn.tagColumns[c] = true
+	}
+
+	switch {
+	case n.Every != 0:
+		sn.ticker = newTimeTicker(n.Every, n.AlignFlag)
+	case n.Cron != "":
+		var err error
+		sn.ticker, err = newCronTicker(n.Cron)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("must define one of 'every' or 'cron'")
+	}
+	return sn, nil
+}
+
+// DBRPs returns no databases and retention policies, the node does not query InfluxDB.
+func (n *SQLQueryNode) DBRPs() ([]DBRP, error) {
+	return nil, nil
+}
+
+func (n *SQLQueryNode) Start() {
+	n.queryMu.Lock()
+	defer n.queryMu.Unlock()
+	n.queryErr = make(chan error, 1)
+	go func() {
+		n.queryErr <- n.doQuery(n.ins[0])
+	}()
+}
+
+func (n *SQLQueryNode) Abort() {
+	close(n.aborting)
+}
+
+// Query the database and collect batches on batch collector.
+func (n *SQLQueryNode) doQuery(in edge.Edge) error {
+	defer in.Close()
+	n.batchesQueried = &expvar.Int{}
+	n.pointsQueried = &expvar.Int{}
+	n.queryErrors = &expvar.Int{}
+
+	n.statMap.Set(statsBatchesQueried, n.batchesQueried)
+	n.statMap.Set(statsPointsQueried, n.pointsQueried)
+	n.statMap.Set(statsQueryErrors, n.queryErrors)
+
+	db, err := sql.Open(n.s.Driver, n.s.DataSourceName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s database", n.s.Driver)
+	}
+	defer db.Close()
+
+	tickC := n.ticker.Start()
+	for {
+		select {
+		case <-n.closing:
+			return nil
+		case <-n.aborting:
+			return errors.New("batch doQuery aborted")
+		case now := <-tickC:
+			n.timer.Start()
+			n.diag.StartingBatchQuery(n.s.QueryStr)
+
+			b, err := n.query(db, now.UTC())
+			if err != nil {
+				n.queryErrors.Add(1)
+				n.diag.Error("error executing query", err)
+				n.timer.Stop()
+				break
+			}
+			if b == nil {
+				n.timer.Stop()
+				break
+			}
+			n.batchesQueried.Add(1)
+			n.pointsQueried.Add(int64(len(b.Points())))
+
+			n.timer.Pause()
+			if err := in.Collect(b); err != nil {
+				return err
+			}
+			n.timer.Resume()
+			n.timer.Stop()
+		}
+	}
+}
+
+// query runs the query and returns a batch of its rows, or nil if it returned no rows.
+// Rows without a time are at the time of the query.
+func (n *SQLQueryNode) query(db *sql.DB, now time.Time) (edge.BufferedBatchMessage, error) {
+	ctx := context.Background()
+	if n.s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.s.Timeout)
+		defer cancel()
+	}
+	rows, err := db.QueryContext(ctx, n.s.QueryStr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var points []edge.BatchPointMessage
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		t := now
+		tags := make(models.Tags)
+		fields := make(models.Fields)
+		for i, c := range columns {
+			v := sqlValue(values[i], c.DatabaseTypeName())
+			if v == nil {
+				continue
+			}
+			name := c.Name()
+			switch {
+			case name == n.s.TimeColumn:
+				if t, err = sqlTime(v); err != nil {
+					return nil, errors.Wrapf(err, "invalid time of column %q", name)
+				}
+			case n.tagColumns[name]:
+				tags[name] = fmt.Sprint(v)
+			default:
+				if tv, ok := v.(time.Time); ok {
+					v = tv.UTC().Format(time.RFC3339Nano)
+				}
+				fields[name] = v
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		points = append(points, edge.NewBatchPointMessage(fields, tags, t.UTC()))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+	return edge.NewBufferedBatchMessage(
+		edge.NewBeginBatchMessage(
+			n.s.Measurement,
+			nil,
+			false,
+			now,
+			len(points),
+		),
+		points,
+		edge.NewEndBatchMessage(),
+	), nil
+}
+
+// sqlValue converts a value returned by a driver to a field value.
+// Drivers return numbers as text for some column types, which are parsed according to the type.
+func sqlValue(v interface{}, dbType string) interface{} {
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	s := string(b)
+	dbType = strings.ToUpper(dbType)
+	switch {
+	case strings.Contains(dbType, "INT"):
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+	case strings.Contains(dbType, "DECIMAL"),
+		strings.Contains(dbType, "NUMERIC"),
+		strings.Contains(dbType, "FLOAT"),
+		strings.Contains(dbType, "DOUBLE"),
+		strings.Contains(dbType, "REAL"):
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// sqlTime converts the value of a time column, a timestamp, a string or unix seconds.
+func sqlTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case float64:
+		return time.Unix(0, int64(v*float64(time.Second))), nil
+	case string:
+		for _, layout := range sqlTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unexpected time format %q", v)
+	default:
+		return time.Time{}, fmt.Errorf("unexpected time value %v", v)
+	}
+}
+
+func (n *SQLQueryNode) runBatch([]byte) error {
+	errC := make(chan error, 1)
+	go func() {
+		defer func() {
+			err := recover()
+			if err != nil {
+				errC <- fmt.Errorf("%v", err)
+			}
+		}()
+		for bt, ok := n.ins[0].Emit(); ok; bt, ok = n.ins[0].Emit() {
+			for _, child := range n.outs {
+				err := child.Collect(bt)
+				if err != nil {
+					errC <- err
+					return
+				}
+			}
+		}
+		errC <- nil
+	}()
+	var queryErr error
+	n.queryMu.Lock()
+	if n.queryErr != nil {
+		n.queryMu.Unlock()
+		select {
+		case queryErr = <-n.queryErr:
+		case <-n.aborting:
+			queryErr = errors.New("batch queryErr aborted")
+		}
+	} else {
+		n.queryMu.Unlock()
+	}
+
+	var err error
+	select {
+	case err = <-errC:
+	case <-n.aborting:
+		err = errors.New("batch run aborted")
+	}
+	if queryErr != nil {
+		return queryErr
+	}
+	return err
+}
+
+func (n *SQLQueryNode) stopBatch() {
+	if n.ticker != nil {
+		n.ticker.Stop()
+	}
+	close(n.closing)
+}
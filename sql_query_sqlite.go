@@ -0,0 +1,6 @@
+// +build sqlite
+
+package kapacitor
+
+// The sqlite3 driver requires cgo, so it is only included in builds with the sqlite tag.
+import _ "github.com/mattn/go-sqlite3"
@@ -79,6 +79,9 @@ type Task struct {
 	Type             TaskType
 	DBRPs            []DBRP
 	SnapshotInterval time.Duration
+	FlightRecorder   FlightRecorderOptions
+	Limits           TaskLimits
+	EdgePolicies     EdgePolicies
 }
 
 func (t *Task) Dot() []byte {
@@ -116,6 +119,12 @@ type ExecutingTask struct {
 	wg       sync.WaitGroup
 	diag     TaskDiagnostic
 
+	// Records the input of the task, nil if the task has no flight recorder
+	flightRecorder *flightRecorder
+
+	// Tracks the resource usage of the task and enforces its limits
+	limiter *taskLimiter
+
 	// Mutex for throughput var
 	tmu        sync.RWMutex
 	throughput float64
@@ -130,6 +139,7 @@ func NewExecutingTask(tm *TaskMaster, t *Task) (*ExecutingTask, error) {
 		outputs: make(map[string]Output),
 		lookup:  make(map[pipeline.ID]Node),
 		diag:    d,
+		limiter: newTaskLimiter(t.Limits, d),
 	}
 	err := et.link()
 	if err != nil {
@@ -229,6 +239,13 @@ func (et *ExecutingTask) start(ins []edge.StatsEdge, snapshot *TaskSnapshot) err
 	// Start calcThroughput
 	et.wg.Add(1)
 	go et.calcThroughput()
+	if et.Task.Limits.CPU.Max > 0 {
+		et.wg.Add(1)
+		go func() {
+			defer et.wg.Done()
+			et.limiter.runCPUShare(et.stopping)
+		}()
+	}
 	return nil
 }
 
@@ -317,9 +334,14 @@ func (et *ExecutingTask) StopStats() {
 
 // Wait till the task finishes and return any error
 func (et *ExecutingTask) Wait() error {
-	return et.rwalk(func(n Node) error {
+	err := et.rwalk(func(n Node) error {
 		return n.Wait()
 	})
+	if err != nil {
+		return err
+	}
+	// The task finishes without node errors once a limit aborts its input.
+	return et.limiter.Err()
 }
 
 // Get a named output.
@@ -349,8 +371,10 @@ func (et *ExecutingTask) ExecutionStats() (ExecutionStats, error) {
 
 	// Fill the task stats
 	executionStats.TaskStats["throughput"] = et.getThroughput()
+	et.limiter.stats(executionStats.TaskStats)
 
 	// Fill the nodes stats
+	var groups, queueDepth int64
 	err := et.walk(func(node Node) error {
 		nodeStats := node.stats()
 
@@ -360,8 +384,16 @@ func (et *ExecutingTask) ExecutionStats() (ExecutionStats, error) {
 
 		executionStats.NodeStats[node.Name()] = nodeStats
 
+		if c, ok := nodeStats[statCardinalityGauge].(int64); ok && c > groups {
+			groups = c
+		}
+		if d := node.queueDepth(); d > queueDepth {
+			queueDepth = d
+		}
 		return nil
 	})
+	executionStats.TaskStats[statGroups] = groups
+	executionStats.TaskStats[statEdgeQueueDepth] = queueDepth
 
 	if err != nil {
 		return executionStats, err
@@ -455,6 +487,8 @@ func (et *ExecutingTask) createNode(p pipeline.Node, d NodeDiagnostic) (n Node,
 		n, err = newBatchNode(et, t, d)
 	case *pipeline.QueryNode:
 		n, err = newQueryNode(et, t, d)
+	case *pipeline.SQLQueryNode:
+		n, err = newSQLQueryNode(et, t, d)
 	case *pipeline.WindowNode:
 		n, err = newWindowNode(et, t, d)
 	case *pipeline.HTTPOutNode:
@@ -463,6 +497,10 @@ func (et *ExecutingTask) createNode(p pipeline.Node, d NodeDiagnostic) (n Node,
 		n, err = newHTTPPostNode(et, t, d)
 	case *pipeline.InfluxDBOutNode:
 		n, err = newInfluxDBOutNode(et, t, d)
+	case *pipeline.KafkaOutNode:
+		n, err = newKafkaOutNode(et, t, d)
+	case *pipeline.MQTTOutNode:
+		n, err = newMQTTOutNode(et, t, d)
 	case *pipeline.KapacitorLoopbackNode:
 		n, err = newKapacitorLoopbackNode(et, t, d)
 	case *pipeline.AlertNode:
@@ -505,6 +543,8 @@ func (et *ExecutingTask) createNode(p pipeline.Node, d NodeDiagnostic) (n Node,
 		n, err = newK8sAutoscaleNode(et, t, d)
 	case *pipeline.SwarmAutoscaleNode:
 		n, err = newSwarmAutoscaleNode(et, t, d)
+	case *pipeline.HTTPAutoscaleNode:
+		n, err = newHTTPAutoscaleNode(et, t, d)
 	case *pipeline.StateDurationNode:
 		n, err = newStateDurationNode(et, t, d)
 	case *pipeline.StateCountNode:
@@ -0,0 +1,359 @@
+package kapacitor
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/timer"
+)
+
+const (
+	statBufferedPoints = "buffered_points"
+	statGroups         = "groups"
+	statEdgeQueueDepth = "edge_queue_depth"
+	statCPUShare       = "cpu_share"
+	statLimitDropped   = "limit_dropped"
+	statPaused         = "paused"
+	statPausedReason   = "paused_reason"
+
+	// How often the CPU share of a task is measured.
+	cpuShareInterval = time.Second
+)
+
+// LimitPolicy determines what happens when a task reaches one of its limits.
+type LimitPolicy int
+
+const (
+	// LimitDrop drops the data that would exceed the limit.
+	LimitDrop LimitPolicy = iota
+	// LimitPause pauses the task, all of its input is dropped until the task is restarted.
+	LimitPause
+	// LimitError stops the task with an error.
+	LimitError
+)
+
+func (p LimitPolicy) String() string {
+	switch p {
+	case LimitDrop:
+		return "drop"
+	case LimitPause:
+		return "pause"
+	case LimitError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+func (p LimitPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *LimitPolicy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "drop":
+		*p = LimitDrop
+	case "pause":
+		*p = LimitPause
+	case "error":
+		*p = LimitError
+	default:
+		return fmt.Errorf("unknown limit policy %s", string(text))
+	}
+	return nil
+}
+
+// Limit is a maximum and the policy applied once it is reached.
+// A zero maximum disables the limit.
+type Limit struct {
+	Max    int64
+	Policy LimitPolicy
+}
+
+// TaskLimits bounds the resources of a single task, so that it cannot starve the other tasks.
+type TaskLimits struct {
+	// BufferedPoints is the maximum number of points buffered across the window and join nodes of the task.
+	BufferedPoints Limit
+	// Groups is the maximum number of groups of any node of the task.
+	Groups Limit
+	// EdgeQueue is the maximum number of messages queued on any edge of the task.
+	EdgeQueue Limit
+	// CPU is the maximum share of a single CPU, in percent, the nodes of the task spend processing data.
+	// While the task exceeds its share its input is throttled according to the policy.
+	CPU Limit
+}
+
+// taskLimiter tracks the resource usage of an executing task and enforces its limits.
+type taskLimiter struct {
+	limits TaskLimits
+	diag   TaskDiagnostic
+
+	// Accessed atomically
+	bufferedPoints int64
+	dropped        int64
+	// Nanoseconds spent processing since the last CPU share measurement.
+	busy int64
+	// Set while the task exceeds its CPU share and its input is dropped.
+	throttled int32
+
+	mu       sync.Mutex
+	cpuShare float64
+	paused   bool
+	reason   string
+	err      error
+	inputs   []edge.StatsEdge
+}
+
+func newTaskLimiter(limits TaskLimits, d TaskDiagnostic) *taskLimiter {
+	return &taskLimiter{
+		limits: limits,
+		diag:   d,
+	}
+}
+
+// exceeded applies the policy of the limit once it has been reached.
+// The data that reached the limit is always dropped,
+// an error is returned if the task must fail.
+func (l *taskLimiter) exceeded(name string, limit Limit) error {
+	atomic.AddInt64(&l.dropped, 1)
+	reason := fmt.Sprintf("%s limit of %d reached", name, limit.Max)
+	switch limit.Policy {
+	case LimitPause:
+		l.pause(reason)
+	case LimitError:
+		err := fmt.Errorf("task %s", reason)
+		l.fail(err)
+		return err
+	}
+	return nil
+}
+
+// pause drops all further input of the task.
+func (l *taskLimiter) pause(reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.paused {
+		return
+	}
+	l.paused = true
+	l.reason = reason
+	l.diag.Error("pausing task", fmt.Errorf("task %s", reason))
+}
+
+// fail records the error of the task and aborts its input.
+// The task then finishes and reports the error.
+func (l *taskLimiter) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	l.err = err
+	for _, in := range l.inputs {
+		in.Abort()
+	}
+}
+
+// Err returns the error that stopped the task, if any.
+func (l *taskLimiter) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// dropInput reports whether input data must be dropped because the task is paused or throttled.
+func (l *taskLimiter) dropInput() bool {
+	if atomic.LoadInt32(&l.throttled) == 1 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.paused
+}
+
+// allowBuffering applies the buffered points limit before data is buffered.
+// It returns false if the data must be dropped instead.
+func (l *taskLimiter) allowBuffering() (bool, error) {
+	if max := l.limits.BufferedPoints.Max; max > 0 && atomic.LoadInt64(&l.bufferedPoints) >= max {
+		return false, l.exceeded("buffered points", l.limits.BufferedPoints)
+	}
+	return true, nil
+}
+
+// addBufferedPoints accounts for points added to or, if negative, removed from a buffer.
+func (l *taskLimiter) addBufferedPoints(delta int64) {
+	atomic.AddInt64(&l.bufferedPoints, delta)
+}
+
+// addBusy accounts for time spent processing data.
+func (l *taskLimiter) addBusy(d time.Duration) {
+	atomic.AddInt64(&l.busy, int64(d))
+}
+
+// runCPUShare periodically measures the CPU share of the task and throttles its input
+// while the share is above the limit.
+func (l *taskLimiter) runCPUShare(stopping <-chan struct{}) {
+	last := time.Now()
+	ticker := time.NewTicker(cpuShareInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			busy := atomic.SwapInt64(&l.busy, 0)
+			share := 100 * float64(busy) / float64(now.Sub(last))
+			last = now
+
+			l.mu.Lock()
+			l.cpuShare = share
+			l.mu.Unlock()
+
+			if share <= float64(l.limits.CPU.Max) {
+				atomic.StoreInt32(&l.throttled, 0)
+				continue
+			}
+			if l.limits.CPU.Policy == LimitDrop {
+				atomic.StoreInt32(&l.throttled, 1)
+				continue
+			}
+			l.exceeded("CPU share", l.limits.CPU)
+		case <-stopping:
+			return
+		}
+	}
+}
+
+// stats adds the usage of the task to the task stats.
+func (l *taskLimiter) stats(stats map[string]interface{}) {
+	stats[statBufferedPoints] = atomic.LoadInt64(&l.bufferedPoints)
+	stats[statLimitDropped] = atomic.LoadInt64(&l.dropped)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limits.CPU.Max > 0 {
+		stats[statCPUShare] = l.cpuShare
+	}
+	stats[statPaused] = l.paused
+	if l.paused {
+		stats[statPausedReason] = l.reason
+	}
+}
+
+// inputEdge wraps an input edge of the task,
+// so that its input is dropped while the task is paused or throttled.
+func (l *taskLimiter) inputEdge(e edge.StatsEdge) edge.StatsEdge {
+	l.mu.Lock()
+	l.inputs = append(l.inputs, e)
+	l.mu.Unlock()
+	return &limitedEdge{
+		StatsEdge: e,
+		limiter:   l,
+		input:     true,
+	}
+}
+
+// edge wraps an edge between two nodes of the task.
+func (l *taskLimiter) edge(e edge.StatsEdge) edge.StatsEdge {
+	return &limitedEdge{
+		StatsEdge: e,
+		limiter:   l,
+	}
+}
+
+// limitedEdge enforces the edge queue and groups limits of a task on one of its edges.
+type limitedEdge struct {
+	edge.StatsEdge
+	limiter *taskLimiter
+	// Whether the edge is an input of the task.
+	input bool
+}
+
+// isData reports whether the message carries data that may be dropped.
+// Messages that structure the data, i.e. barriers or the beginning and end of batches, are never dropped.
+func isData(m edge.Message) bool {
+	switch m.(type) {
+	case edge.PointMessage, edge.BatchPointMessage, edge.BufferedBatchMessage:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *limitedEdge) Collect(m edge.Message) error {
+	l := e.limiter
+	if max := l.limits.EdgeQueue.Max; max > 0 && isData(m) && e.Collected()-e.Emitted()-e.Dropped() >= max {
+		return l.exceeded("edge queue", l.limits.EdgeQueue)
+	}
+	return e.StatsEdge.Collect(m)
+}
+
+func (e *limitedEdge) Emit() (edge.Message, bool) {
+	if !e.input {
+		return e.StatsEdge.Emit()
+	}
+	for {
+		if e.limiter.Err() != nil {
+			return nil, false
+		}
+		m, ok := e.StatsEdge.Emit()
+		if !ok || !isData(m) || !e.limiter.dropInput() {
+			return m, ok
+		}
+		atomic.AddInt64(&e.limiter.dropped, 1)
+	}
+}
+
+// AllowGroup implements edge.GroupLimiter for the grouped consumers reading from the edge.
+func (e *limitedEdge) AllowGroup(groups int64) (bool, error) {
+	l := e.limiter
+	if max := l.limits.Groups.Max; max > 0 && groups >= max {
+		return false, l.exceeded("groups", l.limits.Groups)
+	}
+	return true, nil
+}
+
+// cpuTimer adds the time a node spends processing data to the CPU usage of its task.
+type cpuTimer struct {
+	timer.Timer
+	limiter *taskLimiter
+	start   time.Time
+	running bool
+}
+
+func (t *cpuTimer) Start() {
+	t.Timer.Start()
+	t.start = time.Now()
+	t.running = true
+}
+
+func (t *cpuTimer) Pause() {
+	t.Timer.Pause()
+	if t.running {
+		t.limiter.addBusy(time.Since(t.start))
+		t.running = false
+	}
+}
+
+func (t *cpuTimer) Resume() {
+	t.Timer.Resume()
+	t.start = time.Now()
+	t.running = true
+}
+
+func (t *cpuTimer) Stop() {
+	t.Timer.Stop()
+	if t.running {
+		t.limiter.addBusy(time.Since(t.start))
+		t.running = false
+	}
+}
+
+// pointCount returns the number of points held by a message.
+func pointCount(m edge.Message) int64 {
+	if b, ok := m.(edge.BufferedBatchMessage); ok {
+		return int64(len(b.Points()))
+	}
+	return 1
+}
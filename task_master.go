@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -23,6 +24,7 @@ import (
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/httppost"
 	k8s "github.com/influxdata/kapacitor/services/k8s/client"
+	"github.com/influxdata/kapacitor/services/kafka"
 	"github.com/influxdata/kapacitor/services/mqtt"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/pagerduty"
@@ -114,6 +116,10 @@ type TaskMaster struct {
 	}
 	MQTTService interface {
 		Handler(mqtt.HandlerConfig, ...keyvalue.T) alert.Handler
+		Publish(brokerName, topic string, qos mqtt.QoSLevel, retained bool, message []byte) error
+	}
+	KafkaService interface {
+		Write(cluster, topic string, messages ...kafka.Message) error
 	}
 
 	OpsGenieService interface {
@@ -175,8 +181,27 @@ type TaskMaster struct {
 
 	Commander command.Commander
 
+	// AlertEventRecorder, if set, is passed every event triggered by an alert node,
+	// regardless of the handlers or topic defined on the node.
+	AlertEventRecorder interface {
+		RecordAlertEvent(taskID string, event alert.Event)
+	}
+
+	// FlightRecorderService, if set, is asked to freeze the flight recorder of a task
+	// when one of its alerts goes critical.
+	FlightRecorderService interface {
+		FreezeFlightRecorder(taskID string, points []edge.PointMessage)
+	}
+
 	DefaultRetentionPolicy string
 
+	// EdgeSpillDir is the directory where edges with the spill to disk policy spill their data,
+	// into a directory per task master.
+	EdgeSpillDir string
+
+	// DiskQueueDir is the directory where output nodes queue writes while their destination is unavailable.
+	DiskQueueDir string
+
 	// Incoming streams
 	writePointsIn StreamCollector
 	writesClosed  bool
@@ -195,6 +220,9 @@ type TaskMaster struct {
 	// we have only the task id, and they are called after the task is deleted from TaskMaster.tasks
 	taskToForkKeys map[string][]forkKey
 
+	// Flight recorders of executing tasks, keyed by task id.
+	flightRecorders map[string]*flightRecorder
+
 	// Set of incoming batches
 	batches map[string][]BatchCollector
 
@@ -221,15 +249,16 @@ type forkKey struct {
 // Create a new Executor with a given clock.
 func NewTaskMaster(id string, info vars.Infoer, d Diagnostic) *TaskMaster {
 	return &TaskMaster{
-		id:             id,
-		forks:          make(map[forkKey]map[string]edge.Edge),
-		forkStats:      make(map[forkKey]*expvar.Int),
-		taskToForkKeys: make(map[string][]forkKey),
-		batches:        make(map[string][]BatchCollector),
-		tasks:          make(map[string]*ExecutingTask),
-		deleteHooks:    make(map[string][]deleteHook),
-		ServerInfo:     info,
-		diag:           d.WithTaskMasterContext(id),
+		id:              id,
+		forks:           make(map[forkKey]map[string]edge.Edge),
+		forkStats:       make(map[forkKey]*expvar.Int),
+		taskToForkKeys:  make(map[string][]forkKey),
+		flightRecorders: make(map[string]*flightRecorder),
+		batches:         make(map[string][]BatchCollector),
+		tasks:           make(map[string]*ExecutingTask),
+		deleteHooks:     make(map[string][]deleteHook),
+		ServerInfo:      info,
+		diag:            d.WithTaskMasterContext(id),
 
 		closed:        true,
 		TimingService: noOpTimingService{},
@@ -240,6 +269,8 @@ func NewTaskMaster(id string, info vars.Infoer, d Diagnostic) *TaskMaster {
 func (tm *TaskMaster) New(id string) *TaskMaster {
 	n := NewTaskMaster(id, tm.ServerInfo, tm.diag)
 	n.DefaultRetentionPolicy = tm.DefaultRetentionPolicy
+	n.EdgeSpillDir = tm.EdgeSpillDir
+	n.DiskQueueDir = tm.DiskQueueDir
 	n.HTTPDService = tm.HTTPDService
 	n.TaskStore = tm.TaskStore
 	n.DeadmanService = tm.DeadmanService
@@ -248,6 +279,7 @@ func (tm *TaskMaster) New(id string) *TaskMaster {
 	n.InfluxDBService = tm.InfluxDBService
 	n.SMTPService = tm.SMTPService
 	n.MQTTService = tm.MQTTService
+	n.KafkaService = tm.KafkaService
 	n.OpsGenieService = tm.OpsGenieService
 	n.VictorOpsService = tm.VictorOpsService
 	n.PagerDutyService = tm.PagerDutyService
@@ -270,6 +302,12 @@ func (tm *TaskMaster) ID() string {
 	return tm.id
 }
 
+// edgeSpillDir returns the directory where the edges of the tasks of the task master spill their data.
+// Task masters share the EdgeSpillDir, so that the spill files of a task are separated by task master.
+func (tm *TaskMaster) edgeSpillDir() string {
+	return filepath.Join(tm.EdgeSpillDir, tm.id)
+}
+
 func (tm *TaskMaster) Open() (err error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
@@ -461,9 +499,21 @@ func (tm *TaskMaster) StartTask(t *Task) (*ExecutingTask, error) {
 	}
 
 	var ins []edge.StatsEdge
+	started := false
 	switch et.Task.Type {
 	case StreamTask:
-		e, err := tm.newFork(et.Task.ID, et.Task.DBRPs, et.Task.Measurements())
+		if w := et.Task.FlightRecorder.Window; w > 0 {
+			et.flightRecorder = newFlightRecorder(w)
+			// The recorder is registered before the fork is created so that the fork is teed into it,
+			// it must not remain registered if the task fails to start.
+			tm.flightRecorders[et.Task.ID] = et.flightRecorder
+			defer func() {
+				if !started {
+					delete(tm.flightRecorders, et.Task.ID)
+				}
+			}()
+		}
+		e, err := tm.newFork(et.Task.ID, et.Task.DBRPs, et.Task.Measurements(), et.Task.EdgePolicies.policy("stream0"), et.limiter)
 		if err != nil {
 			return nil, err
 		}
@@ -476,7 +526,8 @@ func (tm *TaskMaster) StartTask(t *Task) (*ExecutingTask, error) {
 		ins = make([]edge.StatsEdge, count)
 		for i := 0; i < count; i++ {
 			d := tm.diag.WithEdgeContext(t.ID, "batch", fmt.Sprintf("batch%d", i))
-			in := newEdge(t.ID, "batch", fmt.Sprintf("batch%d", i), pipeline.BatchEdge, defaultEdgeBufferSize, d)
+			child := fmt.Sprintf("batch%d", i)
+			in := et.limiter.inputEdge(newEdge(t.ID, "batch", child, pipeline.BatchEdge, defaultEdgeBufferSize, t.EdgePolicies.policy(child), tm.edgeSpillDir(), d))
 			ins[i] = in
 			tm.batches[t.ID] = append(tm.batches[t.ID], &batchCollector{edge: in})
 		}
@@ -496,6 +547,7 @@ func (tm *TaskMaster) StartTask(t *Task) (*ExecutingTask, error) {
 	}
 
 	tm.tasks[et.Task.ID] = et
+	started = true
 	tm.diag.StartedTask(t.ID)
 	tm.diag.TaskMasterDot(string(t.Dot()))
 
@@ -532,6 +584,7 @@ func (tm *TaskMaster) stopTask(id string) (err error) {
 		switch et.Task.Type {
 		case StreamTask:
 			tm.delFork(id)
+			delete(tm.flightRecorders, id)
 		case BatchTask:
 			delete(tm.batches, id)
 		}
@@ -600,7 +653,7 @@ func (tm *TaskMaster) stream(name string) (StreamCollector, error) {
 		return nil, ErrTaskMasterClosed
 	}
 	d := tm.diag.WithEdgeContext(fmt.Sprintf("task_master:%s", tm.id), name, "stream")
-	in := newEdge(fmt.Sprintf("task_master:%s", tm.id), name, "stream", pipeline.StreamEdge, defaultEdgeBufferSize, d)
+	in := newEdge(fmt.Sprintf("task_master:%s", tm.id), name, "stream", pipeline.StreamEdge, defaultEdgeBufferSize, edge.Block, "", d)
 	se := &streamEdge{edge: in}
 	tm.wg.Add(1)
 	go func() {
@@ -752,7 +805,7 @@ func (tm *TaskMaster) WriteKapacitorPoint(p edge.PointMessage) error {
 func (tm *TaskMaster) NewFork(taskName string, dbrps []DBRP, measurements []string) (edge.StatsEdge, error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
-	return tm.newFork(taskName, dbrps, measurements)
+	return tm.newFork(taskName, dbrps, measurements, edge.Block, nil)
 }
 
 func forkKeys(dbrps []DBRP, measurements []string) []forkKey {
@@ -774,13 +827,24 @@ func forkKeys(dbrps []DBRP, measurements []string) []forkKey {
 }
 
 // internal newFork, must have acquired lock before calling.
-func (tm *TaskMaster) newFork(taskName string, dbrps []DBRP, measurements []string) (edge.StatsEdge, error) {
+// The policy applies to the fork once it is full, so that a slow task does not block the writes of all tasks.
+// The limits of the task are enforced on the fork, if a limiter is given.
+func (tm *TaskMaster) newFork(taskName string, dbrps []DBRP, measurements []string, policy edge.Policy, limiter *taskLimiter) (edge.StatsEdge, error) {
 	if tm.closed {
 		return nil, ErrTaskMasterClosed
 	}
 
 	d := tm.diag.WithEdgeContext(taskName, "stream", "stream0")
-	e := newEdge(taskName, "stream", "stream0", pipeline.StreamEdge, defaultEdgeBufferSize, d)
+	e := newEdge(taskName, "stream", "stream0", pipeline.StreamEdge, defaultEdgeBufferSize, policy, tm.edgeSpillDir(), d)
+	if limiter != nil {
+		e = limiter.inputEdge(e)
+	}
+
+	// Tee the fork into the task's flight recorder
+	var fork edge.Edge = e
+	if r, ok := tm.flightRecorders[taskName]; ok {
+		fork = flightRecorderEdge{StatsEdge: e, recorder: r}
+	}
 
 	for _, key := range forkKeys(dbrps, measurements) {
 		tm.taskToForkKeys[taskName] = append(tm.taskToForkKeys[taskName], key)
@@ -792,7 +856,7 @@ func (tm *TaskMaster) newFork(taskName string, dbrps []DBRP, measurements []stri
 		}
 
 		// Add the edge to task map
-		tasksMap[taskName] = e
+		tasksMap[taskName] = fork
 
 		// update the task map in the forks
 		tm.forks[key] = tasksMap
@@ -801,6 +865,17 @@ func (tm *TaskMaster) newFork(taskName string, dbrps []DBRP, measurements []stri
 	return e, nil
 }
 
+// FlightRecording returns the points currently held by the flight recorder of the task.
+func (tm *TaskMaster) FlightRecording(id string) ([]edge.PointMessage, error) {
+	tm.mu.RLock()
+	r, ok := tm.flightRecorders[id]
+	tm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("task %s is not executing with a flight recorder", id)
+	}
+	return r.snapshot(), nil
+}
+
 func (tm *TaskMaster) DelFork(id string) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
@@ -60,6 +60,7 @@ func (n *WindowNode) newWindow(group edge.GroupInfo, first edge.PointMeta) (edge
 			n.w.Every,
 			n.w.AlignFlag,
 			n.w.FillPeriodFlag,
+			n.et.limiter,
 			n.diag,
 		), nil
 	case n.w.PeriodCount != 0:
@@ -69,6 +70,7 @@ func (n *WindowNode) newWindow(group edge.GroupInfo, first edge.PointMeta) (edge
 			int(n.w.PeriodCount),
 			int(n.w.EveryCount),
 			n.w.FillPeriodFlag,
+			n.et.limiter,
 			n.diag,
 		), nil
 	default:
@@ -90,6 +92,8 @@ type windowByTime struct {
 	period time.Duration
 	every  time.Duration
 
+	limiter *taskLimiter
+
 	diag NodeDiagnostic
 }
 
@@ -101,6 +105,7 @@ func newWindowByTime(
 	every time.Duration,
 	align,
 	fillPeriod bool,
+	limiter *taskLimiter,
 	d NodeDiagnostic,
 
 ) *windowByTime {
@@ -127,11 +132,12 @@ func newWindowByTime(
 		name:       name,
 		group:      group,
 		nextEmit:   nextEmit,
-		buf:        &windowTimeBuffer{diag: d},
+		buf:        &windowTimeBuffer{limiter: limiter, diag: d},
 		align:      align,
 		fillPeriod: fillPeriod,
 		period:     period,
 		every:      every,
+		limiter:    limiter,
 		diag:       d,
 	}
 }
@@ -150,10 +156,15 @@ func (w *windowByTime) Barrier(b edge.BarrierMessage) (edge.Message, error) {
 	return b, nil
 }
 func (w *windowByTime) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, error) {
+	// The window is discarded along with its group.
+	w.limiter.addBufferedPoints(-int64(w.buf.size))
 	return d, nil
 }
 
 func (w *windowByTime) Point(p edge.PointMessage) (msg edge.Message, err error) {
+	if ok, err := w.limiter.allowBuffering(); !ok {
+		return nil, err
+	}
 	if w.every == 0 {
 		// Insert point before.
 		w.buf.insert(p)
@@ -211,11 +222,12 @@ func (w *windowByTime) batch(tmax time.Time) edge.BufferedBatchMessage {
 
 // implements a purpose built ring buffer for the window of points
 type windowTimeBuffer struct {
-	window []edge.PointMessage
-	start  int
-	stop   int
-	size   int
-	diag   NodeDiagnostic
+	window  []edge.PointMessage
+	start   int
+	stop    int
+	size    int
+	limiter *taskLimiter
+	diag    NodeDiagnostic
 }
 
 // Insert a single point into the buffer.
@@ -257,6 +269,7 @@ func (b *windowTimeBuffer) insert(p edge.PointMessage) {
 	}
 	b.size++
 	b.stop++
+	b.limiter.addBufferedPoints(1)
 }
 
 // Purge expired data from the window.
@@ -271,6 +284,10 @@ func (b *windowTimeBuffer) purge(oldest time.Time, inclusive bool) {
 	if l == 0 {
 		return
 	}
+	size := b.size
+	defer func() {
+		b.limiter.addBufferedPoints(int64(b.size - size))
+	}()
 	if b.start < b.stop {
 		for ; b.start < b.stop; b.start++ {
 			if include(b.window[b.start].Time()) {
@@ -338,6 +355,8 @@ type windowByCount struct {
 	size     int
 	count    int
 
+	limiter *taskLimiter
+
 	diag NodeDiagnostic
 }
 
@@ -347,6 +366,7 @@ func newWindowByCount(
 	period,
 	every int,
 	fillPeriod bool,
+	limiter *taskLimiter,
 	d NodeDiagnostic,
 ) *windowByCount {
 	// Determine the first nextEmit index
@@ -361,6 +381,7 @@ func newWindowByCount(
 		period:   period,
 		every:    every,
 		nextEmit: nextEmit,
+		limiter:  limiter,
 		diag:     d,
 	}
 }
@@ -378,16 +399,25 @@ func (w *windowByCount) Barrier(b edge.BarrierMessage) (edge.Message, error) {
 	return b, nil
 }
 func (w *windowByCount) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, error) {
+	// The window is discarded along with its group.
+	w.limiter.addBufferedPoints(-int64(w.size))
 	return d, nil
 }
 
 func (w *windowByCount) Point(p edge.PointMessage) (msg edge.Message, err error) {
+	if w.size < w.period {
+		// Only a window that is not yet full buffers more points.
+		if ok, err := w.limiter.allowBuffering(); !ok {
+			return nil, err
+		}
+	}
 	w.buf[w.stop] = edge.BatchPointFromPoint(p)
 	w.stop = (w.stop + 1) % w.period
 	if w.size == w.period {
 		w.start = (w.start + 1) % w.period
 	} else {
 		w.size++
+		w.limiter.addBufferedPoints(1)
 	}
 	w.count++
 	//Check if its time to emit
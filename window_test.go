@@ -28,7 +28,7 @@ func (d *windowNodeDiagnostic) UDFLog(s string)
 func TestWindowBufferByTime(t *testing.T) {
 	assert := assert.New(t)
 
-	buf := &windowTimeBuffer{}
+	buf := &windowTimeBuffer{limiter: newTaskLimiter(TaskLimits{}, nil)}
 
 	size := 100
 
@@ -143,6 +143,7 @@ func TestWindowBufferByCount(t *testing.T) {
 			tc.period,
 			tc.every,
 			tc.fillPeriod,
+			newTaskLimiter(TaskLimits{}, nil),
 			newWindowNodeDiagnostic(),
 		)
 